@@ -24,11 +24,13 @@ import (
 type Backoff interface {
 	Duration() time.Duration
 	ShouldRetry() bool
+	Reset()
 }
 
 //go:generate mockgen.sh docker $GOFILE ../docker
 
 type retryBackoff struct {
+	min            time.Duration
 	current        time.Duration
 	max            time.Duration
 	jitterMultiple float64
@@ -47,6 +49,7 @@ type retryBackoff struct {
 // max + max * jiterMultiple
 func NewBackoff(min, max time.Duration, jitterMultiple, multiple float64, maxRetries int) Backoff {
 	return &retryBackoff{
+		min:            min,
 		current:        min,
 		max:            max,
 		jitterMultiple: jitterMultiple,
@@ -72,6 +75,15 @@ func (rb *retryBackoff) ShouldRetry() bool {
 	return rb.count < rb.maxRetries
 }
 
+// Reset decays the backoff back to its minimum duration and resets the retry
+// count
+func (rb *retryBackoff) Reset() {
+	rb.countLock.Lock()
+	defer rb.countLock.Unlock()
+	rb.current = rb.min
+	rb.count = 0
+}
+
 // addJitter adds an amount of jitter between 0 and the given jitter to the
 // given duration
 func addJitter(duration time.Duration, jitter time.Duration) time.Duration {
@@ -46,3 +46,14 @@ func TestRetryBackoffDuration(t *testing.T) {
 	assert.Equal(t, retryBackoff.Duration(), 3*time.Second, "expect 3rd backoff to be max backoff")
 	assert.False(t, retryBackoff.ShouldRetry(), "expect to not retry when count >= max retries")
 }
+
+func TestRetryBackoffReset(t *testing.T) {
+	// Create a backoff with no jitter
+	retryBackoff := NewBackoff(time.Second, 3*time.Second, 0, 2, 2)
+	retryBackoff.Duration()
+	retryBackoff.Duration()
+	assert.False(t, retryBackoff.ShouldRetry(), "expect to not retry when count >= max retries")
+	retryBackoff.Reset()
+	assert.True(t, retryBackoff.ShouldRetry(), "expect to retry after reset")
+	assert.Equal(t, time.Second, retryBackoff.Duration(), "expect backoff to decay to min backoff duration after reset")
+}
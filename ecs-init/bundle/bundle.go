@@ -0,0 +1,206 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package bundle writes diagnostics bundles, which are tar.gz archives of
+// files gathered from the host. Failing to gather a file doesn't fail the
+// bundle, the failure is listed in the errors.txt file of the bundle instead.
+// Secrets are redacted from every file added to a bundle.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
+)
+
+const (
+	bundlePerm = 0600
+	entryPerm  = 0600
+	// ErrorsFile is the name of the file listing the failures to gather
+	// files of the bundle
+	ErrorsFile = "errors.txt"
+)
+
+// Writer writes a bundle to a temporary file that is renamed to the path of
+// the bundle once it is closed, so that partial bundles are never left
+// behind under the name of a bundle
+type Writer struct {
+	path     string
+	file     *os.File
+	gzip     *gzip.Writer
+	tar      *tar.Writer
+	failures []string
+}
+
+// Create creates a bundle at path
+func Create(path string) (*Writer, error) {
+	file, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, bundlePerm)
+	if err != nil {
+		return nil, err
+	}
+	gzipWriter := gzip.NewWriter(file)
+	return &Writer{
+		path: path,
+		file: file,
+		gzip: gzipWriter,
+		tar:  tar.NewWriter(gzipWriter),
+	}, nil
+}
+
+// AddBytes adds a file named name with data to the bundle
+func (w *Writer) AddBytes(name string, data []byte) error {
+	return w.add(name, data, time.Now())
+}
+
+func (w *Writer) add(name string, data []byte, modTime time.Time) error {
+	data = redact.Bytes(data)
+	err := w.tar.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    entryPerm,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.tar.Write(data)
+	return err
+}
+
+// AddJSON adds a file named name with v encoded as indented JSON
+func (w *Writer) AddJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return w.AddBytes(name, append(data, '\n'))
+}
+
+// AddFile adds the file at path to the bundle as name. Only the last
+// maxSize bytes of the file are added if it is larger, unless maxSize is 0.
+func (w *Writer) AddFile(name, path string, maxSize int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if maxSize > 0 && size > maxSize {
+		_, err = file.Seek(size-maxSize, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		size = maxSize
+	}
+	// the file may grow while it is read, only size bytes are added
+	data := make([]byte, size)
+	_, err = io.ReadFull(file, data)
+	if err != nil {
+		return err
+	}
+	return w.add(name, data, info.ModTime())
+}
+
+// Fail records that the file named name couldn't be gathered
+func (w *Writer) Fail(name string, err error) {
+	w.failures = append(w.failures, fmt.Sprintf("%s: %v", name, err))
+}
+
+// Add adds the file named name with the data returned by gather, or records
+// the failure to gather it
+func (w *Writer) Add(name string, gather func() ([]byte, error)) {
+	data, err := gather()
+	if err == nil {
+		err = w.AddBytes(name, data)
+	}
+	if err != nil {
+		w.Fail(name, err)
+	}
+}
+
+// Close adds the list of failures, if any, and completes the bundle
+func (w *Writer) Close() error {
+	var err error
+	if len(w.failures) > 0 {
+		err = w.AddBytes(ErrorsFile, []byte(strings.Join(w.failures, "\n")+"\n"))
+	}
+	for _, closer := range []io.Closer{w.tar, w.gzip, w.file} {
+		closeErr := closer.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	return os.Rename(w.file.Name(), w.path)
+}
+
+// Abort discards the bundle
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// Prune removes the oldest bundles in dir whose names start with prefix until
+// at most maxCount bundles remain and they take up at most maxTotalSize bytes
+// together. The newest bundle is always kept. A limit of 0 is no limit.
+func Prune(dir, prefix string, maxCount int, maxTotalSize int64) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var bundles []os.FileInfo
+	for _, info := range infos {
+		if info.Mode().IsRegular() && strings.HasPrefix(info.Name(), prefix) && strings.HasSuffix(info.Name(), ".tar.gz") {
+			bundles = append(bundles, info)
+		}
+	}
+	// newest first
+	sort.Slice(bundles, func(i, j int) bool {
+		return bundles[i].ModTime().After(bundles[j].ModTime())
+	})
+	var totalSize int64
+	for i, info := range bundles {
+		totalSize += info.Size()
+		withinCount := maxCount == 0 || i < maxCount
+		withinSize := maxTotalSize == 0 || totalSize <= maxTotalSize
+		if i == 0 || (withinCount && withinSize) {
+			continue
+		}
+		// this bundle and all the older ones are beyond the limits
+		for _, old := range bundles[i:] {
+			err := os.Remove(filepath.Join(dir, old.Name()))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
@@ -17,11 +17,13 @@ package cache
 
 import (
 	"bufio"
-	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aws/amazon-ecs-init/ecs-init/config"
 
@@ -36,6 +38,10 @@ const (
 	regionalBucketFormat = "%s-%s"
 )
 
+// desiredVersionPattern finds the version of the Agent in the name of the
+// desired image file
+var desiredVersionPattern = regexp.MustCompile(`v[0-9]+\.[0-9]+\.[0-9]+`)
+
 // CacheStatus represents the status of the on-disk cache for agent
 // tarballs in the cache directory. This status may be used to
 // determine what the appropriate actions are based on the
@@ -65,15 +71,35 @@ type Downloader struct {
 	fs           fileSystem
 	metadata     instanceMetadata
 	region       string
+	external     bool
+	// externalRegion is the region configured when running outside of EC2
+	externalRegion string
+	// version is the version of the Agent to cache, DefaultAgentVersion if
+	// it's empty
+	version string
+	// policy is what happens to unsigned Agent images, SignaturePolicyWarn
+	// if it's empty
+	policy string
+	// trustedKeys is the directory of the keys trusted to sign Agent images
+	trustedKeys string
+	// md5Fallback checks the downloaded Agent against its MD5 checksum when
+	// its version has no SHA-256 manifest
+	md5Fallback bool
 }
 
 // NewDownloader returns a Downloader with default dependencies
-func NewDownloader() (*Downloader, error) {
+func NewDownloader(cfg *config.Config) (*Downloader, error) {
 	downloader := &Downloader{
-		fs: &standardFS{},
+		fs:             &standardFS{},
+		external:       cfg.External,
+		externalRegion: cfg.DefaultRegion,
+		version:        cfg.AgentVersion,
+		policy:         cfg.AgentSignaturePolicy,
+		trustedKeys:    cfg.AgentTrustedKeys,
+		md5Fallback:    cfg.AgentMD5Fallback,
 	}
 
-	if config.RunningInExternal() {
+	if downloader.external {
 		downloader.metadata = &blackholeInstanceMetadata{}
 	} else {
 		sessionInstance, err := session.NewSession()
@@ -90,31 +116,18 @@ func NewDownloader() (*Downloader, error) {
 	}
 
 	s3Downloader := &s3Downloader{
-		bucketDownloaders: make([]*s3BucketDownloader, 0),
-		cacheDir:          config.CacheDirectory(),
-		fs:                downloader.fs,
-	}
-
-	partitionBucketRegion := downloader.getPartitionBucketRegion()
-	partitionBucket := config.AgentPartitionBucketName
-	partitionBucketDownloader, err := newS3BucketDownloader(partitionBucketRegion, partitionBucket)
-	if err != nil {
-		log.Warnf("Failed to initialize partition bucket downloader: %v", err)
-	} else {
-		s3Downloader.addBucketDownloader(partitionBucketDownloader)
+		cacheDir:       config.CacheDirectory(),
+		fs:             downloader.fs,
+		retries:        cfg.DownloadRetries,
+		bandwidthLimit: cfg.DownloadBandwidthLimit,
 	}
 
-	region := downloader.getRegion()
-	regionalBucket := fmt.Sprintf(regionalBucketFormat, partitionBucket, region)
-	regionalBucketDownloader, err := newS3BucketDownloader(region, regionalBucket)
-	if err != nil {
-		log.Warnf("Failed to initialize regional bucket downloader: %v", err)
-	} else {
-		s3Downloader.addBucketDownloader(regionalBucketDownloader)
+	for _, source := range cfg.AgentSources {
+		downloader.addSource(s3Downloader, source)
 	}
 
-	if len(s3Downloader.bucketDownloaders) == 0 {
-		log.Error("Failed to initialize s3 downloader for either partition bucket or regional bucket. Downloader initialization fails.")
+	if len(s3Downloader.sources) == 0 {
+		log.Error("Failed to initialize any of the sources of the Agent. Downloader initialization fails.")
 		return nil, errors.New("failed to initialize downloader")
 	}
 
@@ -122,27 +135,87 @@ func NewDownloader() (*Downloader, error) {
 	return downloader, nil
 }
 
+// addSource adds the downloader of a configured source, warning about the
+// sources that can't be initialized. An S3 source without a bucket stands
+// for the partition bucket and the regional bucket of the Agent.
+func (d *Downloader) addSource(s3Downloader *s3Downloader, source config.AgentSource) {
+	switch source.Type {
+	case config.AgentSourceS3:
+		if source.Location != "" {
+			d.addBucket(s3Downloader, d.getRegion(), source.Location, source.Timeout)
+			return
+		}
+		partitionBucket := config.AgentPartitionBucketName
+		d.addBucket(s3Downloader, d.getPartitionBucketRegion(), partitionBucket, source.Timeout)
+		region := d.getRegion()
+		regionalBucket := fmt.Sprintf(regionalBucketFormat, partitionBucket, region)
+		d.addBucket(s3Downloader, region, regionalBucket, source.Timeout)
+	case config.AgentSourceHTTP:
+		mirror, err := newHTTPMirror(source)
+		if err != nil {
+			log.Warnf("Failed to initialize mirror downloader: %v", err)
+			return
+		}
+		s3Downloader.addSource(mirror)
+	case config.AgentSourceFile:
+		s3Downloader.addSource(&localSource{dir: source.Location, inactivity: source.Timeout})
+	default:
+		log.Warnf("Unsupported Agent source %s", source)
+	}
+}
+
+func (d *Downloader) addBucket(s3Downloader *s3Downloader, region, bucket string, timeout time.Duration) {
+	bucketDownloader, err := newS3BucketDownloader(region, bucket, timeout)
+	if err != nil {
+		log.Warnf("Failed to initialize bucket %s downloader: %v", bucket, err)
+		return
+	}
+	s3Downloader.addSource(bucketDownloader)
+}
+
 // AgentCacheStatus inspects the on-disk cache and returns its
 // status. See `CacheStatus` for possible cache statuses and
-// scenarios.
+// scenarios. A cached Agent of another version than the one to cache
+// needs to be reloaded.
 func (d *Downloader) AgentCacheStatus() CacheStatus {
 	stateFile := config.CacheState()
 	// State file and tarball must be non-zero to report status on
-	uncached := !(d.fileNotEmpty(stateFile) && d.fileNotEmpty(config.AgentTarball()))
+	uncached := !(d.fileNotEmpty(stateFile) && d.fileNotEmpty(d.agentTarball()))
 	if uncached {
 		return StatusUncached
 	}
 
-	file, err := d.fs.Open(stateFile)
+	state, err := d.readState()
 	if err != nil {
 		return StatusUncached
 	}
-	var status CacheStatus
-	_, err = fmt.Fscanf(file, "%d", &status)
-	if err != nil {
-		return StatusUncached
+	if state.status == StatusCached && cachedVersion(state) != d.agentVersion() {
+		return StatusReloadNeeded
+	}
+	return state.status
+}
+
+// cachedVersion returns the version recorded in the cache state,
+// DefaultAgentVersion if the state was recorded without a version
+func cachedVersion(state *cacheState) string {
+	if state.version == "" {
+		return config.DefaultAgentVersion
+	}
+	return state.version
+}
+
+// agentVersion returns the version of the Agent to cache
+func (d *Downloader) agentVersion() string {
+	if d.version == "" {
+		return config.DefaultAgentVersion
 	}
-	return status
+	return d.version
+}
+
+// agentTarball returns the location on disk of the cached Agent of the
+// version to cache
+func (d *Downloader) agentTarball() string {
+	return config.AgentVersionTarball(d.agentVersion())
 }
 
 // IsAgentCached returns true if there is a cached copy of the Agent present
@@ -171,9 +244,8 @@ func (d *Downloader) getRegion() string {
 	}
 
 	defaultRegion := config.DefaultRegionName
-	if config.RunningInExternal() {
-		region := os.Getenv(config.DefaultRegionEnvVar)
-		if region == "" {
+	if d.external {
+		if d.externalRegion == "" {
 			log.Warnf("%s is not specified while running in external (non-EC2) environment. Using default region: %s",
 				config.DefaultRegionEnvVar, defaultRegion)
 		}
@@ -204,17 +276,34 @@ func (d *Downloader) getPartitionBucketRegion() string {
 	return destination
 }
 
-// DownloadAgent downloads a copy of the Agent and performs an
-// integrity check of the downloaded image
+// DownloadAgent downloads a copy of the Agent and checks its integrity
+// against the SHA-256 digest of the checksum manifest of its version, and
+// its authenticity against its detached signature. The digest is recorded in
+// the cache state to check the cached Agent again before it's loaded. Unless
+// the MD5 fallback is disabled, versions without a manifest are checked
+// against their MD5 checksum instead.
 func (d *Downloader) DownloadAgent() error {
 	err := d.fs.MkdirAll(config.CacheDirectory(), os.ModeDir|orwPerm)
 	if err != nil {
 		return err
 	}
 
-	publishedMd5Sum, err := d.getPublishedMd5Sum()
+	version := d.agentVersion()
+	agentTarballName, err := config.AgentRemoteTarballKey(version)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "failed to determine download tarball")
+	}
+	publishedDigest, err := d.getPublishedDigest(version, agentTarballName)
+	publishedMd5Sum := ""
+	if err != nil {
+		if !d.md5Fallback {
+			return err
+		}
+		log.Warnf("Could not get the SHA-256 digest of %s, falling back to its MD5 checksum: %v", agentTarballName, err)
+		publishedMd5Sum, err = d.getPublishedMd5Sum(version)
+		if err != nil {
+			return err
+		}
 	}
 
 	tempFileName, err := d.getPublishedTarball()
@@ -229,63 +318,32 @@ func (d *Downloader) DownloadAgent() error {
 		}
 	}()
 
-	publishedTarballReader, err := d.fs.Open(tempFileName)
-	if err != nil {
-		return err
-	}
-	defer publishedTarballReader.Close()
-
-	md5hash := md5.New()
-	_, err = d.fs.Copy(md5hash, publishedTarballReader)
-	if err != nil {
-		return err
-	}
-
-	calculatedMd5Sum := md5hash.Sum(nil)
-	calculatedMd5SumString := fmt.Sprintf("%x", calculatedMd5Sum)
-	log.Debugf("Expected MD5 %q", publishedMd5Sum)
-	log.Debugf("Calculated MD5 %q", calculatedMd5SumString)
-	if publishedMd5Sum != calculatedMd5SumString {
-		agentTarballName, err := config.AgentRemoteTarballKey()
-		if err != nil {
-			return errors.New("downloaded agent does not match expected checksum")
+	if publishedMd5Sum != "" {
+		err = d.checkMd5Sum(tempFileName, publishedMd5Sum)
+		if err == nil {
+			publishedDigest, err = d.fileDigest(tempFileName)
 		}
-		return errors.Errorf("downloaded agent %q does not match expected checksum", agentTarballName)
-	}
-
-	log.Debugf("Attempting to rename %s to %s", tempFileName, config.AgentTarball())
-	return d.fs.Rename(tempFileName, config.AgentTarball())
-}
-
-func (d *Downloader) getPublishedMd5Sum() (string, error) {
-	objectKey, err := config.AgentRemoteTarballMD5Key()
-	if err != nil {
-		return "", errors.Wrap(err, "failed to determine md5 file for download")
+	} else {
+		err = d.checkDigest(tempFileName, publishedDigest)
 	}
-	tempMd5FileName, err := d.s3Downloader.downloadFile(objectKey)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to download md5 file for published tarball")
+		return errors.Wrapf(err, "downloaded agent %q does not match expected checksum", agentTarballName)
 	}
-
-	tempMd5File, err := d.fs.Open(tempMd5FileName)
+	err = d.checkRemoteSignature(tempFileName, version)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to open temporary md5 file")
+		return err
 	}
-	defer func() { // clean up temp file
-		log.Debugf("Removing temp file %s", tempMd5FileName)
-		d.fs.Remove(tempMd5FileName)
-	}()
 
-	body, err := d.fs.ReadAll(tempMd5File)
+	log.Debugf("Attempting to rename %s to %s", tempFileName, d.agentTarball())
+	err = d.fs.Rename(tempFileName, d.agentTarball())
 	if err != nil {
-		return "", errors.Wrap(err, "failed to read from temporary md5 file")
+		return err
 	}
-
-	return strings.TrimSpace(string(body)), nil
+	return d.recordDigest(d.agentTarball(), publishedDigest)
 }
 
 func (d *Downloader) getPublishedTarball() (string, error) {
-	objectKey, err := config.AgentRemoteTarballKey()
+	objectKey, err := config.AgentRemoteTarballKey(d.agentVersion())
 	if err != nil {
 		return "", errors.Wrap(err, "failed to determine download tarball")
 	}
@@ -297,29 +355,100 @@ func (d *Downloader) getPublishedTarball() (string, error) {
 	return tempAgentFileName, nil
 }
 
-// LoadCachedAgent returns an io.ReadCloser of the Agent from the cache
+// LoadCachedAgent returns an io.ReadCloser of the Agent from the cache, once
+// it's checked against its recorded digest. A *DigestMismatchError is
+// returned if the cached Agent was corrupted or changed.
 func (d *Downloader) LoadCachedAgent() (io.ReadCloser, error) {
-	return d.fs.Open(config.AgentTarball())
+	tarball := d.agentTarball()
+	return d.openVerified(tarball, d.cachedTarballDigest(tarball))
 }
 
 // RecordCachedAgent writes the StatusCached state to disk to record a newly
 // cached or loaded agent image; this prevents StatusReloadNeeded from
-// being interpreted after the reload.
+// being interpreted after the reload. The version of the Agent is recorded
+// so that pinning another version reloads the Agent.
 func (d *Downloader) RecordCachedAgent() error {
-	data := []byte(fmt.Sprintf("%d", StatusCached))
-	return d.fs.WriteFile(config.CacheState(), data, orwPerm)
+	state, err := d.readState()
+	if err != nil {
+		state = newCacheState(StatusCached)
+	}
+	state.status = StatusCached
+	state.version = d.agentVersion()
+	state.rollback = ""
+	return d.writeState(state)
+}
+
+// Rollback describes an automatic rollback of an Agent upgrade that failed
+// to come up
+type Rollback struct {
+	Time time.Time `json:"time"`
+	// Reason is why the upgraded Agent was considered failed
+	Reason string `json:"reason"`
+	// ExitCode is the exit code of the upgraded Agent
+	ExitCode int `json:"exitCode"`
+	// Restored is the known-good Agent that was restored
+	Restored string `json:"restored"`
+}
+
+// RecordRollback records a rollback in the cache state file, after the cache
+// status. The record is cleared the next time an agent image is recorded as
+// cached.
+func (d *Downloader) RecordRollback(rollback *Rollback) error {
+	data, err := json.Marshal(rollback)
+	if err != nil {
+		return err
+	}
+	state, err := d.readState()
+	if err != nil {
+		state = newCacheState(StatusCached)
+	}
+	state.rollback = string(data)
+	return d.writeState(state)
+}
+
+// LastRollback returns the rollback recorded in the cache state file, or nil
+// if there is none
+func (d *Downloader) LastRollback() (*Rollback, error) {
+	state, err := d.readState()
+	if err != nil {
+		return nil, err
+	}
+	if state.rollback == "" {
+		return nil, nil
+	}
+	rollback := &Rollback{}
+	err = json.Unmarshal([]byte(state.rollback), rollback)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid rollback record in cache state")
+	}
+	return rollback, nil
 }
 
 // LoadDesiredAgent returns an io.ReadCloser of the Agent indicated by the desiredImageLocatorFile
 // (/var/cache/ecs/desired-image). The desiredImageLocatorFile must contain as the beginning of the file the name of
 // the file containing the desired image (interpreted as a basename) and ending in a newline.  Only the first line is
-// read, with the rest of the file reserved for future use.
+// read, with the rest of the file reserved for future use. The desired image is checked against its recorded digest,
+// see desiredTarballDigest for the signature check and the digest recorded the first time it's loaded.
 func (d *Downloader) LoadDesiredAgent() (io.ReadCloser, error) {
 	desiredImageFile, err := d.getDesiredImageFile()
 	if err != nil {
 		return nil, err
 	}
-	return d.fs.Open(desiredImageFile)
+	return d.openVerified(desiredImageFile, d.desiredTarballDigest(desiredImageFile))
+}
+
+// DesiredAgentVersion returns the version of the Agent indicated by the
+// desiredImageLocatorFile, if its file name has one
+func (d *Downloader) DesiredAgentVersion() (string, error) {
+	desiredImageFile, err := d.getDesiredImageFile()
+	if err != nil {
+		return "", err
+	}
+	version := desiredVersionPattern.FindString(d.fs.Base(desiredImageFile))
+	if version == "" {
+		return "", errors.Errorf("no version in the name of the desired agent image %s", desiredImageFile)
+	}
+	return version, nil
 }
 
 func (d *Downloader) getDesiredImageFile() (string, error) {
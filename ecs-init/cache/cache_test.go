@@ -16,13 +16,17 @@ package cache
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-init/ecs-init/config"
 	"github.com/golang/mock/gomock"
@@ -30,18 +34,19 @@ import (
 )
 
 var (
-	remoteTarballKey    string
-	remoteTarballMD5Key string
+	remoteTarballKey   string
+	remoteSignatureKey string
+	remoteManifestKey  = config.AgentRemoteManifestKey(config.DefaultAgentVersion)
 )
 
 func init() {
 	// Load up the architecture's S3 tarball key for use in this
 	// package's tests; unconfigured architectures will result in
 	// failing tests.
-	agentS3Key, err := config.AgentRemoteTarballKey()
+	agentS3Key, err := config.AgentRemoteTarballKey(config.DefaultAgentVersion)
 	if err == nil {
 		remoteTarballKey = agentS3Key
-		remoteTarballMD5Key, _ = config.AgentRemoteTarballMD5Key()
+		remoteSignatureKey = agentS3Key + signatureSuffix
 	} else {
 		log.Println("Warning: this architecture does not support downloading of agent")
 	}
@@ -193,7 +198,14 @@ func TestDownloadAgentMkdirFailure(t *testing.T) {
 	d.DownloadAgent()
 }
 
-func TestDownloadAgentDownloadMD5Failure(t *testing.T) {
+// testManifest returns a checksum manifest with the SHA-256 digest of
+// contents for the tarball of this architecture
+func testManifest(contents string) []byte {
+	return []byte(fmt.Sprintf("%x  %s\n%x  ecs-agent-other-arch.tar\n",
+		sha256.Sum256([]byte(contents)), remoteTarballKey, sha256.Sum256([]byte("other"))))
+}
+
+func TestDownloadAgentDownloadManifestFailure(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -203,7 +215,7 @@ func TestDownloadAgentDownloadMD5Failure(t *testing.T) {
 
 	gomock.InOrder(
 		mockFS.EXPECT().MkdirAll(config.CacheDirectory(), os.ModeDir|0700),
-		mockS3Downloader.EXPECT().downloadFile(remoteTarballMD5Key).Return("", errors.New("test error")),
+		mockS3Downloader.EXPECT().downloadFile(remoteManifestKey).Return("", errors.New("test error")),
 	)
 
 	d := &Downloader{
@@ -216,7 +228,7 @@ func TestDownloadAgentDownloadMD5Failure(t *testing.T) {
 	d.DownloadAgent()
 }
 
-func TestDownloadAgentReadPublishedMd5Failure(t *testing.T) {
+func TestDownloadAgentReadPublishedManifestFailure(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -224,16 +236,16 @@ func TestDownloadAgentReadPublishedMd5Failure(t *testing.T) {
 	mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
 	mockMetadata := NewMockinstanceMetadata(mockCtrl)
 
-	tempMD5File, err := ioutil.TempFile("", "md5-test")
+	tempManifestFile, err := ioutil.TempFile("", "manifest-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
-	defer tempMD5File.Close()
+	defer tempManifestFile.Close()
 
 	gomock.InOrder(
 		mockFS.EXPECT().MkdirAll(config.CacheDirectory(), os.ModeDir|0700),
-		mockS3Downloader.EXPECT().downloadFile(remoteTarballMD5Key).Return(tempMD5File.Name(), nil),
-		mockFS.EXPECT().Open(tempMD5File.Name()).Return(tempMD5File, nil),
-		mockFS.EXPECT().ReadAll(tempMD5File).Return(nil, errors.New("test error")),
-		mockFS.EXPECT().Remove(tempMD5File.Name()),
+		mockS3Downloader.EXPECT().downloadFile(remoteManifestKey).Return(tempManifestFile.Name(), nil),
+		mockFS.EXPECT().Open(tempManifestFile.Name()).Return(tempManifestFile, nil),
+		mockFS.EXPECT().ReadAll(tempManifestFile).Return(nil, errors.New("test error")),
+		mockFS.EXPECT().Remove(tempManifestFile.Name()),
 	)
 
 	d := &Downloader{
@@ -250,15 +262,15 @@ func TestDownloadAgentDownloadTarballFailure(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
-	md5sum := "md5sum"
+	manifest := testManifest("published tarball")
 
 	mockFS := NewMockfileSystem(mockCtrl)
 	mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
 	mockMetadata := NewMockinstanceMetadata(mockCtrl)
 
-	tempMD5File, err := ioutil.TempFile("", "md5-test")
+	tempManifestFile, err := ioutil.TempFile("", "manifest-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
-	defer tempMD5File.Close()
+	defer tempManifestFile.Close()
 
 	tempAgentFile, err := ioutil.TempFile("", "agent-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
@@ -266,10 +278,10 @@ func TestDownloadAgentDownloadTarballFailure(t *testing.T) {
 
 	gomock.InOrder(
 		mockFS.EXPECT().MkdirAll(config.CacheDirectory(), os.ModeDir|0700),
-		mockS3Downloader.EXPECT().downloadFile(remoteTarballMD5Key).Return(tempMD5File.Name(), nil),
-		mockFS.EXPECT().Open(tempMD5File.Name()).Return(tempMD5File, nil),
-		mockFS.EXPECT().ReadAll(tempMD5File).Return([]byte(md5sum), nil),
-		mockFS.EXPECT().Remove(tempMD5File.Name()),
+		mockS3Downloader.EXPECT().downloadFile(remoteManifestKey).Return(tempManifestFile.Name(), nil),
+		mockFS.EXPECT().Open(tempManifestFile.Name()).Return(tempManifestFile, nil),
+		mockFS.EXPECT().ReadAll(tempManifestFile).Return(manifest, nil),
+		mockFS.EXPECT().Remove(tempManifestFile.Name()),
 		mockS3Downloader.EXPECT().downloadFile(remoteTarballKey).Return("", errors.New("test error")),
 	)
 
@@ -287,15 +299,15 @@ func TestDownloadAgentCopyFailure(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
-	md5sum := "md5sum"
+	manifest := testManifest("published tarball")
 
 	mockFS := NewMockfileSystem(mockCtrl)
 	mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
 	mockMetadata := NewMockinstanceMetadata(mockCtrl)
 
-	tempMD5File, err := ioutil.TempFile("", "md5-test")
+	tempManifestFile, err := ioutil.TempFile("", "manifest-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
-	defer tempMD5File.Close()
+	defer tempManifestFile.Close()
 
 	tempAgentFile, err := ioutil.TempFile("", "agent-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
@@ -305,10 +317,10 @@ func TestDownloadAgentCopyFailure(t *testing.T) {
 
 	gomock.InOrder(
 		mockFS.EXPECT().MkdirAll(config.CacheDirectory(), os.ModeDir|0700),
-		mockS3Downloader.EXPECT().downloadFile(remoteTarballMD5Key).Return(tempMD5File.Name(), nil),
-		mockFS.EXPECT().Open(tempMD5File.Name()).Return(tempMD5File, nil),
-		mockFS.EXPECT().ReadAll(tempMD5File).Return([]byte(md5sum), nil),
-		mockFS.EXPECT().Remove(tempMD5File.Name()),
+		mockS3Downloader.EXPECT().downloadFile(remoteManifestKey).Return(tempManifestFile.Name(), nil),
+		mockFS.EXPECT().Open(tempManifestFile.Name()).Return(tempManifestFile, nil),
+		mockFS.EXPECT().ReadAll(tempManifestFile).Return(manifest, nil),
+		mockFS.EXPECT().Remove(tempManifestFile.Name()),
 		mockS3Downloader.EXPECT().downloadFile(remoteTarballKey).Return(tempAgentFile.Name(), nil),
 		mockFS.EXPECT().Open(tempAgentFile.Name()).Return(tempReader, nil),
 		mockFS.EXPECT().Copy(gomock.Any(), tempReader).Return(int64(0), errors.New("test error")),
@@ -326,19 +338,19 @@ func TestDownloadAgentCopyFailure(t *testing.T) {
 	d.DownloadAgent()
 }
 
-func TestDownloadAgentMD5Mismatch(t *testing.T) {
+func TestDownloadAgentDigestMismatch(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
-	md5sum := "md5sum"
+	manifest := testManifest("published tarball")
 
 	mockFS := NewMockfileSystem(mockCtrl)
 	mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
 	mockMetadata := NewMockinstanceMetadata(mockCtrl)
 
-	tempMD5File, err := ioutil.TempFile("", "md5-test")
+	tempManifestFile, err := ioutil.TempFile("", "manifest-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
-	defer tempMD5File.Close()
+	defer tempManifestFile.Close()
 
 	tempAgentFile, err := ioutil.TempFile("", "agent-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
@@ -348,10 +360,10 @@ func TestDownloadAgentMD5Mismatch(t *testing.T) {
 
 	gomock.InOrder(
 		mockFS.EXPECT().MkdirAll(config.CacheDirectory(), os.ModeDir|0700),
-		mockS3Downloader.EXPECT().downloadFile(remoteTarballMD5Key).Return(tempMD5File.Name(), nil),
-		mockFS.EXPECT().Open(tempMD5File.Name()).Return(tempMD5File, nil),
-		mockFS.EXPECT().ReadAll(tempMD5File).Return([]byte(md5sum), nil),
-		mockFS.EXPECT().Remove(tempMD5File.Name()),
+		mockS3Downloader.EXPECT().downloadFile(remoteManifestKey).Return(tempManifestFile.Name(), nil),
+		mockFS.EXPECT().Open(tempManifestFile.Name()).Return(tempManifestFile, nil),
+		mockFS.EXPECT().ReadAll(tempManifestFile).Return(manifest, nil),
+		mockFS.EXPECT().Remove(tempManifestFile.Name()),
 		mockS3Downloader.EXPECT().downloadFile(remoteTarballKey).Return(tempAgentFile.Name(), nil),
 		mockFS.EXPECT().Open(tempAgentFile.Name()).Return(tempReader, nil),
 		mockFS.EXPECT().Copy(gomock.Any(), tempReader).Return(int64(0), nil),
@@ -375,11 +387,11 @@ func TestDownloadAgentSuccess(t *testing.T) {
 
 	tarballContents := "tarball contents"
 	tarballReader := ioutil.NopCloser(bytes.NewBufferString(tarballContents))
-	expectedMd5Sum := fmt.Sprintf("%x\n", md5.Sum([]byte(tarballContents)))
+	expectedDigest := fmt.Sprintf("%x", sha256.Sum256([]byte(tarballContents)))
 
-	tempMD5File, err := ioutil.TempFile("", "md5-test")
+	tempManifestFile, err := ioutil.TempFile("", "manifest-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
-	defer tempMD5File.Close()
+	defer tempManifestFile.Close()
 
 	tempAgentFile, err := ioutil.TempFile("", "agent-test")
 	assert.NoError(t, err, "Expect to successfully create a temporary file")
@@ -391,17 +403,22 @@ func TestDownloadAgentSuccess(t *testing.T) {
 
 	gomock.InOrder(
 		mockFS.EXPECT().MkdirAll(config.CacheDirectory(), os.ModeDir|0700),
-		mockS3Downloader.EXPECT().downloadFile(remoteTarballMD5Key).Return(tempMD5File.Name(), nil),
-		mockFS.EXPECT().Open(tempMD5File.Name()).Return(tempMD5File, nil),
-		mockFS.EXPECT().ReadAll(tempMD5File).Return([]byte(expectedMd5Sum), nil),
-		mockFS.EXPECT().Remove(tempMD5File.Name()),
+		mockS3Downloader.EXPECT().downloadFile(remoteManifestKey).Return(tempManifestFile.Name(), nil),
+		mockFS.EXPECT().Open(tempManifestFile.Name()).Return(tempManifestFile, nil),
+		mockFS.EXPECT().ReadAll(tempManifestFile).Return(testManifest(tarballContents), nil),
+		mockFS.EXPECT().Remove(tempManifestFile.Name()),
 		mockS3Downloader.EXPECT().downloadFile(remoteTarballKey).Return(tempAgentFile.Name(), nil),
 		mockFS.EXPECT().Open(tempAgentFile.Name()).Return(tarballReader, nil),
 		mockFS.EXPECT().Copy(gomock.Any(), tarballReader).Do(func(writer io.Writer, reader io.Reader) {
 			_, err = io.Copy(writer, reader)
 			assert.NoError(t, err, "Expect to successfully write to file")
 		}),
+		mockS3Downloader.EXPECT().downloadFile(remoteSignatureKey).Return("signature-test", nil),
+		mockFS.EXPECT().Remove("signature-test"),
 		mockFS.EXPECT().Rename(tempAgentFile.Name(), config.AgentTarball()),
+		mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString("2\n")), nil),
+		mockFS.EXPECT().WriteFile(config.CacheState(),
+			[]byte("2\nsha256 "+expectedDigest+" ecs-agent.tar\n"), os.FileMode(orwPerm)),
 		mockFS.EXPECT().Stat(tempAgentFile.Name()).Return(nil, errors.New("temp file has been renamed")),
 	)
 
@@ -410,9 +427,76 @@ func TestDownloadAgentSuccess(t *testing.T) {
 		fs:           mockFS,
 		metadata:     mockMetadata,
 		region:       config.DefaultRegionName,
+		trustedKeys:  config.TrustedKeysDirectory(),
 	}
 
-	d.DownloadAgent()
+	verifySignatureBkp := verifySignature
+	defer func() {
+		verifySignature = verifySignatureBkp
+	}()
+	verifySignature = func(keysDir, signature, path string) (string, error) {
+		assert.Equal(t, config.TrustedKeysDirectory(), keysDir)
+		assert.Equal(t, "signature-test", signature)
+		assert.Equal(t, tempAgentFile.Name(), path)
+		return "0123456789ABCDEF", nil
+	}
+
+	assert.NoError(t, d.DownloadAgent())
+}
+
+func TestDownloadAgentMD5Fallback(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tarballContents := "tarball contents"
+	expectedDigest := fmt.Sprintf("%x", sha256.Sum256([]byte(tarballContents)))
+	md5Sum := fmt.Sprintf("%x", md5.Sum([]byte(tarballContents)))
+
+	tempMd5File, err := ioutil.TempFile("", "md5-test")
+	assert.NoError(t, err, "Expect to successfully create a temporary file")
+	defer tempMd5File.Close()
+
+	tempAgentFile, err := ioutil.TempFile("", "agent-test")
+	assert.NoError(t, err, "Expect to successfully create a temporary file")
+	defer tempAgentFile.Close()
+
+	mockFS := NewMockfileSystem(mockCtrl)
+	mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
+	openTarball := func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewBufferString(tarballContents)), nil
+	}
+	copyTarball := func(writer io.Writer, reader io.Reader) {
+		_, err := io.Copy(writer, reader)
+		assert.NoError(t, err, "Expect to successfully write to file")
+	}
+
+	gomock.InOrder(
+		mockFS.EXPECT().MkdirAll(config.CacheDirectory(), os.ModeDir|0700),
+		mockS3Downloader.EXPECT().downloadFile(remoteManifestKey).Return("", errors.New("not found")),
+		mockS3Downloader.EXPECT().downloadFile(remoteTarballKey+".md5").Return(tempMd5File.Name(), nil),
+		mockFS.EXPECT().Open(tempMd5File.Name()).Return(tempMd5File, nil),
+		mockFS.EXPECT().ReadAll(tempMd5File).Return([]byte(md5Sum+"\n"), nil),
+		mockFS.EXPECT().Remove(tempMd5File.Name()),
+		mockS3Downloader.EXPECT().downloadFile(remoteTarballKey).Return(tempAgentFile.Name(), nil),
+		mockFS.EXPECT().Open(tempAgentFile.Name()).DoAndReturn(openTarball),
+		mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).Do(copyTarball),
+		mockFS.EXPECT().Open(tempAgentFile.Name()).DoAndReturn(openTarball),
+		mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).Do(copyTarball),
+		mockS3Downloader.EXPECT().downloadFile(remoteSignatureKey).Return("", errors.New("not found")),
+		mockFS.EXPECT().Rename(tempAgentFile.Name(), config.AgentTarball()),
+		mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString("2\n")), nil),
+		mockFS.EXPECT().WriteFile(config.CacheState(),
+			[]byte("2\nsha256 "+expectedDigest+" ecs-agent.tar\n"), os.FileMode(orwPerm)),
+		mockFS.EXPECT().Stat(tempAgentFile.Name()).Return(nil, errors.New("temp file has been renamed")),
+	)
+
+	d := &Downloader{
+		s3Downloader: mockS3Downloader,
+		fs:           mockFS,
+		region:       config.DefaultRegionName,
+		md5Fallback:  true,
+	}
+	assert.NoError(t, d.DownloadAgent())
 }
 
 func TestLoadDesiredAgentFailOpenDesired(t *testing.T) {
@@ -453,14 +537,210 @@ func TestRecordCachedAgent(t *testing.T) {
 
 	mockFS := NewMockfileSystem(mockCtrl)
 
-	mockFS.EXPECT().WriteFile(config.CacheState(), []byte("1"), os.FileMode(orwPerm))
+	mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString(
+		"2\nsha256 "+strings.Repeat("0", 64)+" ecs-agent.tar\nrollback {}\n")), nil)
+	mockFS.EXPECT().WriteFile(config.CacheState(),
+		[]byte("1\nversion v1.60.0\nsha256 "+strings.Repeat("0", 64)+" ecs-agent.tar\n"), os.FileMode(orwPerm))
 
 	d := &Downloader{
-		fs: mockFS,
+		fs:      mockFS,
+		version: "v1.60.0",
 	}
 	d.RecordCachedAgent()
 }
 
+func TestAgentCacheStatusPinnedVersion(t *testing.T) {
+	var cases = []struct {
+		data     string
+		expected CacheStatus
+	}{
+		{"1\nversion v1.60.0\n", StatusCached},
+		{"1\nversion v1.63.1\nrollback {}\n", StatusReloadNeeded},
+		{"1", StatusReloadNeeded},
+		{"2\nversion v1.60.0\n", StatusReloadNeeded},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.data, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			file := ioutil.NopCloser(bytes.NewBufferString(testcase.data))
+			mockFS := NewMockfileSystem(mockCtrl)
+			mockFSInfo := NewMockfileSizeInfo(mockCtrl)
+
+			mockFS.EXPECT().Stat(config.CacheState()).Return(mockFSInfo, nil)
+			mockFS.EXPECT().Stat(config.AgentVersionTarball("v1.60.0")).Return(mockFSInfo, nil)
+			mockFSInfo.EXPECT().Size().Return(int64(1)).Times(2)
+			mockFS.EXPECT().Open(config.CacheState()).Return(file, nil)
+
+			d := &Downloader{fs: mockFS, version: "v1.60.0"}
+			assert.Equal(t, testcase.expected, d.AgentCacheStatus())
+		})
+	}
+}
+
+func TestLoadCachedAgentPinnedVersion(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tarball := config.CacheDirectory() + "/ecs-agent-v1.60.0.tar"
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte("cached tarball")))
+
+	mockFS := NewMockfileSystem(mockCtrl)
+	gomock.InOrder(
+		mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString(
+			"1\nversion v1.60.0\nsha256 "+digest+" ecs-agent-v1.60.0.tar\n")), nil),
+		mockFS.EXPECT().Open(tarball).Return(ioutil.NopCloser(bytes.NewBufferString("cached tarball")), nil),
+		mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).DoAndReturn(io.Copy),
+		mockFS.EXPECT().Open(tarball).Return(ioutil.NopCloser(&bytes.Buffer{}), nil),
+	)
+
+	d := &Downloader{fs: mockFS, version: "v1.60.0"}
+	_, err := d.LoadCachedAgent()
+	assert.NoError(t, err)
+}
+
+func TestLoadCachedAgentDigestMismatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte("cached tarball")))
+
+	mockFS := NewMockfileSystem(mockCtrl)
+	gomock.InOrder(
+		mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString(
+			"1\nsha256 "+digest+" ecs-agent.tar\n")), nil),
+		mockFS.EXPECT().Open(config.AgentTarball()).Return(ioutil.NopCloser(bytes.NewBufferString("changed tarball")), nil),
+		mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).DoAndReturn(io.Copy),
+	)
+
+	d := &Downloader{fs: mockFS}
+	_, err := d.LoadCachedAgent()
+	mismatch, ok := err.(*DigestMismatchError)
+	if assert.True(t, ok, "expected a DigestMismatchError, got %v", err) {
+		assert.Equal(t, digest, mismatch.Expected)
+	}
+}
+
+func TestLoadCachedAgentRecordsDigest(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte("installed tarball")))
+	installedTarball := func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewBufferString("installed tarball")), nil
+	}
+
+	mockFS := NewMockfileSystem(mockCtrl)
+	gomock.InOrder(
+		mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString("2\n")), nil),
+		mockFS.EXPECT().Open(config.AgentTarball()).DoAndReturn(installedTarball),
+		mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).DoAndReturn(io.Copy),
+		mockFS.EXPECT().Open(config.AgentTarball()).DoAndReturn(installedTarball),
+		mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).DoAndReturn(io.Copy),
+		mockFS.EXPECT().WriteFile(config.CacheState(),
+			[]byte("2\nsha256 "+digest+" ecs-agent.tar\n"), os.FileMode(orwPerm)),
+		mockFS.EXPECT().Open(config.AgentTarball()).DoAndReturn(installedTarball),
+	)
+
+	d := &Downloader{fs: mockFS}
+	_, err := d.LoadCachedAgent()
+	assert.NoError(t, err)
+}
+
+func TestLoadCachedAgentPinnedVersionWithoutDigest(t *testing.T) {
+	tarball := config.CacheDirectory() + "/ecs-agent-v1.60.0.tar"
+	artifact, err := config.AgentRemoteTarballKey("v1.60.0")
+	if err != nil {
+		t.Skip("this architecture does not support downloading of agent")
+	}
+	for _, published := range []string{"cached tarball", "published tarball"} {
+		t.Run(published, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			digest := fmt.Sprintf("%x", sha256.Sum256([]byte(published)))
+			cachedTarball := func(string) (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewBufferString("cached tarball")), nil
+			}
+
+			mockFS := NewMockfileSystem(mockCtrl)
+			mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
+			calls := []*gomock.Call{
+				mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString("2\n")), nil),
+				mockS3Downloader.EXPECT().downloadFile(config.AgentRemoteManifestKey("v1.60.0")).Return("manifest-test", nil),
+				mockFS.EXPECT().Open("manifest-test").Return(ioutil.NopCloser(&bytes.Buffer{}), nil),
+				mockFS.EXPECT().ReadAll(gomock.Any()).Return([]byte(digest+"  "+artifact+"\n"), nil),
+				mockFS.EXPECT().Remove("manifest-test"),
+				mockFS.EXPECT().Open(tarball).DoAndReturn(cachedTarball),
+				mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).DoAndReturn(io.Copy),
+			}
+			if published == "cached tarball" {
+				calls = append(calls,
+					mockFS.EXPECT().WriteFile(config.CacheState(),
+						[]byte("2\nsha256 "+digest+" ecs-agent-v1.60.0.tar\n"), os.FileMode(orwPerm)),
+					mockFS.EXPECT().Open(tarball).DoAndReturn(cachedTarball),
+				)
+			}
+			gomock.InOrder(calls...)
+
+			d := &Downloader{fs: mockFS, s3Downloader: mockS3Downloader, version: "v1.60.0"}
+			_, err := d.LoadCachedAgent()
+			if published == "cached tarball" {
+				assert.NoError(t, err)
+			} else {
+				_, ok := err.(*DigestMismatchError)
+				assert.True(t, ok, "expected a DigestMismatchError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManifestDigest(t *testing.T) {
+	digest := strings.Repeat("0123456789abcdef", 4)
+	manifest := []byte(digest + "  ecs-agent-v1.63.1.tar\n" +
+		strings.ToUpper(digest) + " *ecs-agent-arm64-v1.63.1.tar\n" +
+		"not-a-digest  ecs-agent-invalid.tar\n")
+
+	actual, err := manifestDigest(manifest, "ecs-agent-v1.63.1.tar")
+	assert.NoError(t, err)
+	assert.Equal(t, digest, actual)
+	actual, err = manifestDigest(manifest, "ecs-agent-arm64-v1.63.1.tar")
+	assert.NoError(t, err)
+	assert.Equal(t, digest, actual)
+	_, err = manifestDigest(manifest, "ecs-agent-invalid.tar")
+	assert.Error(t, err)
+	_, err = manifestDigest(manifest, "ecs-agent-v1.60.0.tar")
+	assert.Error(t, err)
+}
+
+func TestDesiredAgentVersion(t *testing.T) {
+	var cases = []struct {
+		name     string
+		expected string
+	}{
+		{"ecs-agent-v1.64.0.tar", "v1.64.0"},
+		{"ecs-update-123456", ""},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockFS := NewMockfileSystem(mockCtrl)
+			mockFS.EXPECT().Open(config.DesiredImageLocatorFile()).Return(ioutil.NopCloser(bytes.NewBufferString(testcase.name+"\n")), nil)
+			mockFS.EXPECT().Base(gomock.Any()).DoAndReturn(filepath.Base).AnyTimes()
+
+			d := &Downloader{fs: mockFS}
+			version, err := d.DesiredAgentVersion()
+			assert.Equal(t, testcase.expected, version)
+			assert.Equal(t, testcase.expected == "", err != nil)
+		})
+	}
+}
+
 func TestLoadDesiredAgent(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -469,13 +749,122 @@ func TestLoadDesiredAgent(t *testing.T) {
 
 	mockFS := NewMockfileSystem(mockCtrl)
 
-	mockFS.EXPECT().Open(config.DesiredImageLocatorFile()).Return(ioutil.NopCloser(bytes.NewBufferString(desiredImage+"\n")), nil)
-	mockFS.EXPECT().Base(gomock.Any()).Return(desiredImage + "\n")
-	mockFS.EXPECT().Open(config.CacheDirectory() + "/" + desiredImage)
+	desiredImagePath := config.CacheDirectory() + "/" + desiredImage
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte("desired image")))
+
+	gomock.InOrder(
+		mockFS.EXPECT().Open(config.DesiredImageLocatorFile()).Return(ioutil.NopCloser(bytes.NewBufferString(desiredImage+"\n")), nil),
+		mockFS.EXPECT().Base(gomock.Any()).Return(desiredImage+"\n"),
+		mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString(
+			"1\nsha256 "+digest+" "+desiredImage+"\n")), nil),
+		mockFS.EXPECT().Open(desiredImagePath).Return(ioutil.NopCloser(bytes.NewBufferString("desired image")), nil),
+		mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).DoAndReturn(io.Copy),
+		mockFS.EXPECT().Open(desiredImagePath).Return(ioutil.NopCloser(&bytes.Buffer{}), nil),
+	)
+
+	d := &Downloader{
+		fs: mockFS,
+	}
+
+	_, err := d.LoadDesiredAgent()
+	assert.NoError(t, err)
+}
+
+func TestLoadDesiredAgentMD5Fallback(t *testing.T) {
+	desiredImage := "ecs-agent-v1.64.0.tar"
+	desiredImagePath := config.CacheDirectory() + "/" + desiredImage
+	md5Key, err := config.AgentRemoteTarballMD5Key("v1.64.0")
+	if err != nil {
+		t.Skip("this architecture does not support downloading of agent")
+	}
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte("desired image")))
+	md5Sum := fmt.Sprintf("%x", md5.Sum([]byte("desired image")))
+
+	for _, md5Fallback := range []bool{true, false} {
+		t.Run(fmt.Sprintf("md5Fallback=%t", md5Fallback), func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockFS := NewMockfileSystem(mockCtrl)
+			mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
+			desiredTarball := func(string) (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewBufferString("desired image")), nil
+			}
+			mockFS.EXPECT().Open(config.DesiredImageLocatorFile()).DoAndReturn(func(string) (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewBufferString(desiredImage + "\n")), nil
+			}).Times(2)
+			mockFS.EXPECT().Base(gomock.Any()).DoAndReturn(filepath.Base).AnyTimes()
+			mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString("1\n")), nil)
+			mockFS.EXPECT().Stat(desiredImagePath+signatureSuffix).Return(nil, os.ErrNotExist)
+			mockS3Downloader.EXPECT().downloadFile(config.AgentRemoteManifestKey("v1.64.0")).Return("", errors.New("not found"))
+			if md5Fallback {
+				gomock.InOrder(
+					mockS3Downloader.EXPECT().downloadFile(md5Key).Return("md5-test", nil),
+					mockFS.EXPECT().Open("md5-test").Return(ioutil.NopCloser(&bytes.Buffer{}), nil),
+					mockFS.EXPECT().ReadAll(gomock.Any()).Return([]byte(md5Sum+"\n"), nil),
+					mockFS.EXPECT().Remove("md5-test"),
+				)
+				mockFS.EXPECT().Open(desiredImagePath).DoAndReturn(desiredTarball).Times(4)
+				mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).DoAndReturn(io.Copy).Times(3)
+				mockFS.EXPECT().WriteFile(config.CacheState(),
+					[]byte("1\nsha256 "+digest+" "+desiredImage+"\n"), os.FileMode(orwPerm))
+			}
+
+			d := &Downloader{fs: mockFS, s3Downloader: mockS3Downloader, md5Fallback: md5Fallback}
+			_, err := d.LoadDesiredAgent()
+			assert.Equal(t, !md5Fallback, err != nil, "unexpected error %v", err)
+		})
+	}
+}
+
+func TestRecordRollback(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockFS := NewMockfileSystem(mockCtrl)
+	rollback := &Rollback{
+		Time:     time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Reason:   "agent exited with code 1 during upgrade probation",
+		ExitCode: 1,
+		Restored: "amazon/amazon-ecs-agent:known-good",
+	}
+	expected := "2\nversion v1.60.0\nrollback " +
+		`{"time":"2020-01-01T00:00:00Z","reason":"agent exited with code 1 during upgrade probation",` +
+		`"exitCode":1,"restored":"amazon/amazon-ecs-agent:known-good"}` + "\n"
+
+	mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString("2\nversion v1.60.0\n")), nil)
+	mockFS.EXPECT().WriteFile(config.CacheState(), []byte(expected), os.FileMode(orwPerm))
 
 	d := &Downloader{
 		fs: mockFS,
 	}
+	assert.NoError(t, d.RecordRollback(rollback))
+}
 
-	d.LoadDesiredAgent()
+func TestLastRollback(t *testing.T) {
+	var cases = []struct {
+		name     string
+		data     string
+		expected *Rollback
+	}{
+		{"no rollback", "1", nil},
+		{"rollback", "1\nrollback {\"reason\":\"failed\",\"exitCode\":2}\n", &Rollback{Reason: "failed", ExitCode: 2}},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockFS := NewMockfileSystem(mockCtrl)
+			mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString(testcase.data)), nil)
+
+			d := &Downloader{
+				fs: mockFS,
+			}
+			rollback, err := d.LastRollback()
+			assert.NoError(t, err)
+			assert.Equal(t, testcase.expected, rollback)
+		})
+	}
 }
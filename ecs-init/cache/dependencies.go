@@ -21,10 +21,13 @@ package cache
 //go:generate mockgen.sh $GOPACKAGE $GOFILE
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -37,17 +40,29 @@ import (
 
 // s3API captures the only method used from the s3 package
 type s3API interface {
-	Download(w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (n int64, err error)
+	DownloadWithContext(ctx aws.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (n int64, err error)
+}
+
+// artifactSource is a place the Agent artifacts are downloaded from
+type artifactSource interface {
+	// fetch writes fileName to w, from offset on, at the offsets of w
+	// relative to offset, returning the number of bytes written
+	fetch(ctx context.Context, fileName string, w io.WriterAt, offset int64) (int64, error)
+	// timeout is how long a download may go without receiving anything
+	// from the source before it's abandoned
+	timeout() time.Duration
+	String() string
 }
 
 // s3BucketDownloader wraps a bucket together with a downloader that can download from it
 type s3BucketDownloader struct {
-	bucket string
-	region string
-	client s3API
+	bucket     string
+	region     string
+	client     s3API
+	inactivity time.Duration
 }
 
-func newS3BucketDownloader(region, bucketName string) (*s3BucketDownloader, error) {
+func newS3BucketDownloader(region, bucketName string, inactivity time.Duration) (*s3BucketDownloader, error) {
 	session, err := session.NewSession(&aws.Config{
 		Credentials: credentials.AnonymousCredentials,
 		Region:      aws.String(region),
@@ -57,71 +72,69 @@ func newS3BucketDownloader(region, bucketName string) (*s3BucketDownloader, erro
 	}
 
 	s3BucketDownloader := &s3BucketDownloader{
-		client: s3manager.NewDownloader(session),
-		bucket: bucketName,
-		region: region,
+		client:     s3manager.NewDownloader(session),
+		bucket:     bucketName,
+		region:     region,
+		inactivity: inactivity,
 	}
 
 	return s3BucketDownloader, nil
 }
 
-func (bd *s3BucketDownloader) download(fileName, cacheDir string, fs fileSystem) (string, error) {
-	file, err := fs.TempFile(cacheDir, fileName)
-	if err != nil {
-		return "", errors.Wrap(err, "could not create local file during download")
-	}
-
-	defer func() { // make sure we also handle possible error from f.Close()
-		cerr := file.Close()
-		if err == nil { // if no error return from download, captures error from f.Close()
-			err = cerr
-		}
-	}()
-
-	_, err = bd.client.Download(file, &s3.GetObjectInput{
+func (bd *s3BucketDownloader) fetch(ctx context.Context, fileName string, w io.WriterAt, offset int64) (int64, error) {
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(bd.bucket),
 		Key:    aws.String(fileName),
-	})
+	}
+	if offset > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	return bd.client.DownloadWithContext(ctx, w, input)
+}
 
-	return file.Name(), err
+func (bd *s3BucketDownloader) timeout() time.Duration {
+	return bd.inactivity
+}
+
+func (bd *s3BucketDownloader) String() string {
+	return fmt.Sprintf("bucket %s in region %s", bd.bucket, bd.region)
 }
 
 type s3DownloaderAPI interface {
-	addBucketDownloader(bucketDownloader *s3BucketDownloader)
+	addSource(source artifactSource)
 	downloadFile(fileName string) (string, error)
 }
 
+// s3Downloader downloads from the first of its sources that has a file,
+// which are the S3 buckets of the Agent unless other sources are configured
 type s3Downloader struct {
-	bucketDownloaders []*s3BucketDownloader
-	fs                fileSystem
-	cacheDir          string
+	sources  []artifactSource
+	fs       fileSystem
+	cacheDir string
+	// retries is how many more rounds of attempts are made once a download
+	// from every source failed
+	retries int
+	// bandwidthLimit is in bytes per second, unlimited if it's 0
+	bandwidthLimit int64
 }
 
-func (d *s3Downloader) addBucketDownloader(bucketDownloader *s3BucketDownloader) {
-	d.bucketDownloaders = append(d.bucketDownloaders, bucketDownloader)
+func (d *s3Downloader) addSource(source artifactSource) {
+	d.sources = append(d.sources, source)
 }
 
 func (d *s3Downloader) downloadFile(fileName string) (string, error) {
-	for _, bucketDownloader := range d.bucketDownloaders {
-		fileName, err := bucketDownloader.download(fileName, d.cacheDir, d.fs)
-		if err == nil {
-			log.Debugf("Download file %s from bucket %s in region %s succeeded.",
-				fileName, bucketDownloader.bucket, bucketDownloader.region)
-			return fileName, nil
-		} else {
-			log.Errorf("Download file %s from bucket %s in region %s failed with error: %v",
-				fileName, bucketDownloader.bucket, bucketDownloader.region, err)
-		}
+	downloadedFileName, err := d.downloadWithRetries(fileName)
+	if err != nil {
+		log.Debugf("Failed to download file %s from any source", fileName)
+		return "", err
 	}
-
-	log.Debugf("Failed to download file %s from s3", fileName)
-	return "", errors.New("failed to download file from s3")
+	return downloadedFileName, nil
 }
 
 // fileSystem captures related functions from os, io, and io/ioutil packages
 type fileSystem interface {
 	MkdirAll(path string, perm os.FileMode) error
-	TempFile(dir, prefix string) (f *os.File, err error)
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
 	Remove(path string)
 	TeeReader(r io.Reader, w io.Writer) io.Reader
 	Copy(dst io.Writer, src io.Reader) (written int64, err error)
@@ -155,8 +168,8 @@ func (s *standardFS) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
 
-func (s *standardFS) TempFile(dir, prefix string) (*os.File, error) {
-	return ioutil.TempFile(dir, prefix)
+func (s *standardFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
 }
 
 func (s *standardFS) Remove(path string) {
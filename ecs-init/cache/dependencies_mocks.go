@@ -1,4 +1,4 @@
-// Copyright 2015-2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// Copyright 2015-2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License"). You may
 // not use this file except in compliance with the License. A copy of the
@@ -18,10 +18,13 @@
 package cache
 
 import (
+	context "context"
 	io "io"
 	os "os"
 	reflect "reflect"
+	time "time"
 
+	aws "github.com/aws/aws-sdk-go/aws"
 	s3 "github.com/aws/aws-sdk-go/service/s3"
 	s3manager "github.com/aws/aws-sdk-go/service/s3/s3manager"
 	gomock "github.com/golang/mock/gomock"
@@ -50,24 +53,90 @@ func (m *Mocks3API) EXPECT() *Mocks3APIMockRecorder {
 	return m.recorder
 }
 
-// Download mocks base method
-func (m *Mocks3API) Download(w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (int64, error) {
+// DownloadWithContext mocks base method
+func (m *Mocks3API) DownloadWithContext(ctx aws.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (int64, error) {
 	m.ctrl.T.Helper()
-	varargs := []interface{}{w, input}
+	varargs := []interface{}{ctx, w, input}
 	for _, a := range options {
 		varargs = append(varargs, a)
 	}
-	ret := m.ctrl.Call(m, "Download", varargs...)
+	ret := m.ctrl.Call(m, "DownloadWithContext", varargs...)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Download indicates an expected call of Download
-func (mr *Mocks3APIMockRecorder) Download(w, input interface{}, options ...interface{}) *gomock.Call {
+// DownloadWithContext indicates an expected call of DownloadWithContext
+func (mr *Mocks3APIMockRecorder) DownloadWithContext(ctx, w, input interface{}, options ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	varargs := append([]interface{}{w, input}, options...)
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*Mocks3API)(nil).Download), varargs...)
+	varargs := append([]interface{}{ctx, w, input}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadWithContext", reflect.TypeOf((*Mocks3API)(nil).DownloadWithContext), varargs...)
+}
+
+// MockartifactSource is a mock of artifactSource interface
+type MockartifactSource struct {
+	ctrl     *gomock.Controller
+	recorder *MockartifactSourceMockRecorder
+}
+
+// MockartifactSourceMockRecorder is the mock recorder for MockartifactSource
+type MockartifactSourceMockRecorder struct {
+	mock *MockartifactSource
+}
+
+// NewMockartifactSource creates a new mock instance
+func NewMockartifactSource(ctrl *gomock.Controller) *MockartifactSource {
+	mock := &MockartifactSource{ctrl: ctrl}
+	mock.recorder = &MockartifactSourceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockartifactSource) EXPECT() *MockartifactSourceMockRecorder {
+	return m.recorder
+}
+
+// fetch mocks base method
+func (m *MockartifactSource) fetch(ctx context.Context, fileName string, w io.WriterAt, offset int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "fetch", ctx, fileName, w, offset)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// fetch indicates an expected call of fetch
+func (mr *MockartifactSourceMockRecorder) fetch(ctx, fileName, w, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "fetch", reflect.TypeOf((*MockartifactSource)(nil).fetch), ctx, fileName, w, offset)
+}
+
+// timeout mocks base method
+func (m *MockartifactSource) timeout() time.Duration {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "timeout")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// timeout indicates an expected call of timeout
+func (mr *MockartifactSourceMockRecorder) timeout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "timeout", reflect.TypeOf((*MockartifactSource)(nil).timeout))
+}
+
+// String mocks base method
+func (m *MockartifactSource) String() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "String")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// String indicates an expected call of String
+func (mr *MockartifactSourceMockRecorder) String() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "String", reflect.TypeOf((*MockartifactSource)(nil).String))
 }
 
 // Mocks3DownloaderAPI is a mock of s3DownloaderAPI interface
@@ -93,16 +162,16 @@ func (m *Mocks3DownloaderAPI) EXPECT() *Mocks3DownloaderAPIMockRecorder {
 	return m.recorder
 }
 
-// addBucketDownloader mocks base method
-func (m *Mocks3DownloaderAPI) addBucketDownloader(bucketDownloader *s3BucketDownloader) {
+// addSource mocks base method
+func (m *Mocks3DownloaderAPI) addSource(source artifactSource) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "addBucketDownloader", bucketDownloader)
+	m.ctrl.Call(m, "addSource", source)
 }
 
-// addBucketDownloader indicates an expected call of addBucketDownloader
-func (mr *Mocks3DownloaderAPIMockRecorder) addBucketDownloader(bucketDownloader interface{}) *gomock.Call {
+// addSource indicates an expected call of addSource
+func (mr *Mocks3DownloaderAPIMockRecorder) addSource(source interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "addBucketDownloader", reflect.TypeOf((*Mocks3DownloaderAPI)(nil).addBucketDownloader), bucketDownloader)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "addSource", reflect.TypeOf((*Mocks3DownloaderAPI)(nil).addSource), source)
 }
 
 // downloadFile mocks base method
@@ -157,19 +226,19 @@ func (mr *MockfileSystemMockRecorder) MkdirAll(path, perm interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MkdirAll", reflect.TypeOf((*MockfileSystem)(nil).MkdirAll), path, perm)
 }
 
-// TempFile mocks base method
-func (m *MockfileSystem) TempFile(dir, prefix string) (*os.File, error) {
+// OpenFile mocks base method
+func (m *MockfileSystem) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TempFile", dir, prefix)
+	ret := m.ctrl.Call(m, "OpenFile", name, flag, perm)
 	ret0, _ := ret[0].(*os.File)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// TempFile indicates an expected call of TempFile
-func (mr *MockfileSystemMockRecorder) TempFile(dir, prefix interface{}) *gomock.Call {
+// OpenFile indicates an expected call of OpenFile
+func (mr *MockfileSystemMockRecorder) OpenFile(name, flag, perm interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TempFile", reflect.TypeOf((*MockfileSystem)(nil).TempFile), dir, prefix)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenFile", reflect.TypeOf((*MockfileSystem)(nil).OpenFile), name, flag, perm)
 }
 
 // Remove mocks base method
@@ -0,0 +1,273 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/backoff"
+	"github.com/aws/amazon-ecs-init/ecs-init/metrics"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// partialSuffix names the partial file of a download in progress
+	partialSuffix = ".part"
+
+	// downloadProgressInterval is how often the progress of a download is
+	// logged
+	downloadProgressInterval = 30 * time.Second
+
+	// the backoff between the rounds of attempts to download from the
+	// sources
+	downloadMinBackoff      = 2 * time.Second
+	downloadMaxBackoff      = time.Minute
+	downloadBackoffJitter   = 0.2
+	downloadBackoffMultiple = 2
+	// downloadRetryTimeout is how long after the first attempt, or the last
+	// attempt that received part of the file, a download may be retried.
+	// Downloads that make no progress give up well within the 90 seconds
+	// systemd waits for pre-start by default.
+	downloadRetryTimeout = 45 * time.Second
+)
+
+// Injection points for testing purposes
+var (
+	sleep = time.Sleep
+	now   = time.Now
+)
+
+// downloadWithRetries downloads fileName from the first source that has it,
+// trying each source once per round. Sources that failed with an error that
+// may go away, such as an interrupted download, are tried again in the next
+// round after a backoff, resuming the download. Retries stop once the next
+// one would start more than downloadRetryTimeout after the first attempt or
+// the last one that made progress, so that pre-start doesn't keep retrying a
+// download that doesn't make progress until systemd gives up on it.
+func (d *s3Downloader) downloadWithRetries(fileName string) (string, error) {
+	retryBackoff := backoff.NewBackoff(downloadMinBackoff, downloadMaxBackoff, downloadBackoffJitter,
+		downloadBackoffMultiple, d.retries)
+	deadline := now().Add(downloadRetryTimeout)
+	sources := d.sources
+	for {
+		var retryable []artifactSource
+		for _, source := range sources {
+			name, progressed, err := d.download(source, fileName)
+			if progressed {
+				deadline = now().Add(downloadRetryTimeout)
+			}
+			if err == nil {
+				log.Debugf("Download file %s from %s succeeded.", fileName, source)
+				return name, nil
+			}
+			log.Errorf("Download file %s from %s failed with error: %v", fileName, source, err)
+			if isRetryableDownloadError(err) {
+				retryable = append(retryable, source)
+			}
+		}
+		if len(retryable) == 0 || !retryBackoff.ShouldRetry() {
+			return "", errors.New("failed to download file from any source")
+		}
+		backoffDuration := retryBackoff.Duration()
+		if now().Add(backoffDuration).After(deadline) {
+			log.Warnf("Not retrying the download of %s, nothing was received for more than %s", fileName, downloadRetryTimeout)
+			return "", errors.New("failed to download file from any source")
+		}
+		log.Warnf("Download of %s interrupted, resuming in %s", fileName, backoffDuration)
+		sleep(backoffDuration)
+		sources = retryable
+	}
+}
+
+// download downloads fileName from the source to a partial file in the
+// cache directory, resuming from the bytes already downloaded by a previous
+// attempt. The partial file is kept when the download fails, to be resumed
+// later. progressed is true if any part of the file was received.
+func (d *s3Downloader) download(source artifactSource, fileName string) (name string, progressed bool, err error) {
+	file, err := d.fs.OpenFile(filepath.Join(d.cacheDir, fileName+partialSuffix), os.O_CREATE|os.O_WRONLY, orwPerm)
+	if err != nil {
+		return "", false, errors.Wrap(err, "could not create local file during download")
+	}
+
+	defer func() { // make sure we also handle possible error from f.Close()
+		cerr := file.Close()
+		if err == nil { // if no error return from download, captures error from f.Close()
+			err = cerr
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", false, errors.Wrap(err, "could not read the size of the partial download")
+	}
+	offset := info.Size()
+	if offset > 0 {
+		log.Infof("Resuming the download of %s from %s after %d bytes", fileName, source, offset)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	writer := newDownloadWriter(file, offset, fileName, d.bandwidthLimit)
+	stopWatching := writer.watchInactivity(source.timeout(), cancel)
+	n, err := source.fetch(ctx, fileName, writer, offset)
+	if stopWatching() {
+		err = errors.Errorf("nothing received from %s for %s", source, source.timeout())
+	}
+	if offset > 0 && isRangeNotSatisfiable(err) {
+		log.Infof("The partial download of %s was already complete", fileName)
+		err = nil
+	}
+	metrics.Downloaded(source.String(), n, time.Since(start), err)
+	progressed = writer.logProgress(err == nil)
+
+	return file.Name(), progressed, err
+}
+
+// statusError is an error of a source with an HTTP status code, such as the
+// awserr.RequestFailure of S3
+type statusError interface {
+	error
+	StatusCode() int
+}
+
+// isRetryableDownloadError returns false for missing local files and for
+// client errors, other than timeouts and throttling
+func isRetryableDownloadError(err error) bool {
+	if os.IsNotExist(err) {
+		return false
+	}
+	failure, ok := err.(statusError)
+	if !ok {
+		return true
+	}
+	status := failure.StatusCode()
+	if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+		return true
+	}
+	return status < 400 || status >= 500
+}
+
+// isRangeNotSatisfiable returns true if err is a source refusing to resume
+// a download, which happens when the partial file is already complete
+func isRangeNotSatisfiable(err error) bool {
+	failure, ok := err.(statusError)
+	return ok && failure.StatusCode() == http.StatusRequestedRangeNotSatisfiable
+}
+
+// downloadWriter writes a download after the bytes already in the partial
+// file, limiting its bandwidth and logging its progress. Parts of the
+// download may be written concurrently.
+type downloadWriter struct {
+	file     io.WriterAt
+	offset   int64
+	fileName string
+	// limit is in bytes per second, unlimited if it's 0
+	limit   int64
+	lock    sync.Mutex
+	written int64
+	start   time.Time
+	logged  time.Time
+	// inactivity is reset by every write, to cancel the download once
+	// nothing is written for timeout
+	inactivity *time.Timer
+	timeout    time.Duration
+}
+
+func newDownloadWriter(file io.WriterAt, offset int64, fileName string, limit int64) *downloadWriter {
+	start := now()
+	return &downloadWriter{
+		file:     file,
+		offset:   offset,
+		fileName: fileName,
+		limit:    limit,
+		start:    start,
+		logged:   start,
+	}
+}
+
+// WriteAt writes p at off in the download, then waits for as long as it
+// takes to stay within the bandwidth limit
+func (w *downloadWriter) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.file.WriteAt(p, w.offset+off)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.written += int64(n)
+	if w.limit > 0 {
+		expected := time.Duration(float64(w.written) / float64(w.limit) * float64(time.Second))
+		elapsed := now().Sub(w.start)
+		if expected > elapsed {
+			sleep(expected - elapsed)
+		}
+	}
+	if now().Sub(w.logged) >= downloadProgressInterval {
+		w.logProgressLocked(false)
+	}
+	if w.inactivity != nil {
+		w.inactivity.Reset(w.timeout)
+	}
+	return n, err
+}
+
+// watchInactivity calls cancel once nothing is written for timeout. The
+// returned function stops watching, returning true if cancel was called.
+func (w *downloadWriter) watchInactivity(timeout time.Duration, cancel func()) func() bool {
+	var inactive int32
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.timeout = timeout
+	w.inactivity = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&inactive, 1)
+		cancel()
+	})
+	return func() bool {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		w.inactivity.Stop()
+		return atomic.LoadInt32(&inactive) == 1
+	}
+}
+
+// logProgress logs how much of the file was downloaded so far, or in total
+// once the download is done. It returns true if anything was written.
+func (w *downloadWriter) logProgress(done bool) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.logProgressLocked(done)
+	return w.written > 0
+}
+
+func (w *downloadWriter) logProgressLocked(done bool) {
+	w.logged = now()
+	elapsed := w.logged.Sub(w.start)
+	rate := int64(0)
+	if elapsed > 0 {
+		rate = int64(float64(w.written) / elapsed.Seconds() / 1024)
+	}
+	if done {
+		log.Infof("Downloaded %s: %d bytes in %s (%d KB/s)", w.fileName, w.offset+w.written,
+			elapsed.Round(time.Second), rate)
+		return
+	}
+	log.Infof("Downloading %s: %d bytes so far (%d KB/s)", w.fileName, w.offset+w.written, rate)
+}
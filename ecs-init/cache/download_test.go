@@ -0,0 +1,307 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSleep records the durations slept instead of sleeping, advancing the
+// clock returned by now, until the returned function is called
+func mockSleep(slept *[]time.Duration) func() {
+	sleepBkp, nowBkp := sleep, now
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	sleep = func(d time.Duration) {
+		*slept = append(*slept, d)
+		clock = clock.Add(d)
+	}
+	return func() {
+		sleep, now = sleepBkp, nowBkp
+	}
+}
+
+// writeDownload returns a DownloadWithContext of the s3API writing contents
+func writeDownload(contents string, err error) func(aws.Context, io.WriterAt, *s3.GetObjectInput, ...func(*s3manager.Downloader)) (int64, error) {
+	return func(ctx aws.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (int64, error) {
+		n, _ := w.WriteAt([]byte(contents), 0)
+		return int64(n), err
+	}
+}
+
+func TestDownloadResumesPartialFile(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+	partial := filepath.Join(cacheDir, "ecs-agent.tar"+partialSuffix)
+	require.NoError(t, ioutil.WriteFile(partial, []byte("hello "), 0600))
+
+	mockS3 := NewMocks3API(mockCtrl)
+	mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx aws.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (int64, error) {
+			assert.Equal(t, "bytes=6-", aws.StringValue(input.Range))
+			return writeDownload("world", nil)(ctx, w, input)
+		})
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir}
+	name, _, err := d.download(&s3BucketDownloader{bucket: "bucket", client: mockS3, inactivity: time.Minute}, "ecs-agent.tar")
+	require.NoError(t, err)
+	assert.Equal(t, partial, name)
+	contents, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(contents))
+}
+
+func TestDownloadCompletePartialFile(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+	partial := filepath.Join(cacheDir, "ecs-agent.tar"+partialSuffix)
+	require.NoError(t, ioutil.WriteFile(partial, []byte("hello world"), 0600))
+
+	mockS3 := NewMocks3API(mockCtrl)
+	mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), awserr.NewRequestFailure(
+		awserr.New("InvalidRange", "The requested range is not satisfiable", nil),
+		http.StatusRequestedRangeNotSatisfiable, "request-id"))
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir}
+	name, _, err := d.download(&s3BucketDownloader{bucket: "bucket", client: mockS3, inactivity: time.Minute}, "ecs-agent.tar")
+	assert.NoError(t, err)
+	assert.Equal(t, partial, name)
+}
+
+func TestDownloadAbandonsInactiveSource(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	source := NewMockartifactSource(mockCtrl)
+	source.EXPECT().timeout().Return(10 * time.Millisecond).AnyTimes()
+	source.EXPECT().String().Return("mirror").AnyTimes()
+	source.EXPECT().fetch(gomock.Any(), "ecs-agent.tar", gomock.Any(), int64(0)).DoAndReturn(
+		func(ctx context.Context, fileName string, w io.WriterAt, offset int64) (int64, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir}
+	_, _, err = d.download(source, "ecs-agent.tar")
+	assert.Error(t, err)
+}
+
+func TestDownloadFileResumesWithBackoff(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	var slept []time.Duration
+	defer mockSleep(&slept)()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	mockS3 := NewMocks3API(mockCtrl)
+	gomock.InOrder(
+		mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(writeDownload("hello ", errors.New("connection reset"))),
+		mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(writeDownload("", errors.New("connection reset"))),
+		mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(writeDownload("world", nil)),
+	)
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir, retries: 5}
+	d.addSource(&s3BucketDownloader{bucket: "bucket", client: mockS3, inactivity: time.Minute})
+	name, err := d.downloadFile("ecs-agent.tar")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(contents))
+	require.Len(t, slept, 2)
+	assert.True(t, slept[1] >= 2*downloadMinBackoff, "expected the backoff to increase, slept %v", slept)
+}
+
+func TestDownloadFileTriesNextSourceBeforeBackingOff(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	var slept []time.Duration
+	defer mockSleep(&slept)()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	partitionS3 := NewMocks3API(mockCtrl)
+	partitionS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), errors.New("connection refused"))
+	regionalS3 := NewMocks3API(mockCtrl)
+	regionalS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(writeDownload("hello world", nil))
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir, retries: 5}
+	d.addSource(&s3BucketDownloader{bucket: "partition", client: partitionS3, inactivity: time.Minute})
+	d.addSource(&s3BucketDownloader{bucket: "regional", client: regionalS3, inactivity: time.Minute})
+	name, err := d.downloadFile("ecs-agent.tar")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(contents))
+	assert.Empty(t, slept, "expected the next source to be tried without a backoff")
+}
+
+func TestDownloadFileStopsRetryingAfterTimeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	var slept []time.Duration
+	defer mockSleep(&slept)()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	mockS3 := NewMocks3API(mockCtrl)
+	mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), errors.New("connection refused")).AnyTimes()
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir, retries: 20}
+	d.addSource(&s3BucketDownloader{bucket: "bucket", client: mockS3, inactivity: time.Minute})
+	_, err = d.downloadFile("ecs-agent.tar")
+	assert.Error(t, err)
+	var total time.Duration
+	for _, d := range slept {
+		total += d
+	}
+	assert.True(t, total <= downloadRetryTimeout, "expected the retries to stop within %s, slept %v", downloadRetryTimeout, slept)
+	assert.Len(t, slept, 4)
+}
+
+func TestDownloadFileKeepsRetryingWhileProgressing(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	var slept []time.Duration
+	defer mockSleep(&slept)()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	mockS3 := NewMocks3API(mockCtrl)
+	var calls []*gomock.Call
+	for _, part := range []string{"h", "e", "l", "l", "o"} {
+		calls = append(calls, mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			writeDownload(part, errors.New("connection reset"))))
+	}
+	calls = append(calls, mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		writeDownload(" world", nil)))
+	gomock.InOrder(calls...)
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir, retries: 20}
+	d.addSource(&s3BucketDownloader{bucket: "bucket", client: mockS3, inactivity: time.Minute})
+	name, err := d.downloadFile("ecs-agent.tar")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(contents))
+	var total time.Duration
+	for _, d := range slept {
+		total += d
+	}
+	assert.True(t, total > downloadRetryTimeout, "expected the retries to go on past %s while progressing, slept %v", downloadRetryTimeout, slept)
+}
+
+func TestDownloadFileTriesSourcesInOrder(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	var slept []time.Duration
+	defer mockSleep(&slept)()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+	localDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(localDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(localDir, "ecs-agent.tar"), []byte("hello world"), 0600))
+
+	notFound := awserr.NewRequestFailure(awserr.New("NoSuchKey", "The specified key does not exist.", nil),
+		http.StatusNotFound, "request-id")
+	mockS3 := NewMocks3API(mockCtrl)
+	mockS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), notFound)
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir, retries: 5}
+	d.addSource(&localSource{dir: filepath.Join(localDir, "missing"), inactivity: time.Minute})
+	d.addSource(&s3BucketDownloader{bucket: "bucket", client: mockS3, inactivity: time.Minute})
+	d.addSource(&localSource{dir: localDir, inactivity: time.Minute})
+	name, err := d.downloadFile("ecs-agent.tar")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(contents))
+	assert.Empty(t, slept, "expected missing files not to be retried")
+}
+
+func TestDownloadFileDoesNotRetryMissingFile(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	var slept []time.Duration
+	defer mockSleep(&slept)()
+	cacheDir, err := ioutil.TempDir("", "download-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	notFound := awserr.NewRequestFailure(awserr.New("NoSuchKey", "The specified key does not exist.", nil),
+		http.StatusNotFound, "request-id")
+	partitionS3 := NewMocks3API(mockCtrl)
+	partitionS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), notFound)
+	regionalS3 := NewMocks3API(mockCtrl)
+	regionalS3.EXPECT().DownloadWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), notFound)
+
+	d := &s3Downloader{fs: &standardFS{}, cacheDir: cacheDir, retries: 5}
+	d.addSource(&s3BucketDownloader{bucket: "partition", client: partitionS3, inactivity: time.Minute})
+	d.addSource(&s3BucketDownloader{bucket: "regional", client: regionalS3, inactivity: time.Minute})
+	_, err = d.downloadFile("ecs-agent.tar.asc")
+	assert.Error(t, err)
+	assert.Empty(t, slept)
+}
+
+func TestDownloadWriterBandwidthLimit(t *testing.T) {
+	var slept []time.Duration
+	defer mockSleep(&slept)()
+	file, err := ioutil.TempFile("", "download-test")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	w := newDownloadWriter(file, 0, "ecs-agent.tar", 1024)
+	for i := 0; i < 4; i++ {
+		_, err := w.WriteAt(make([]byte, 512), int64(i*512))
+		require.NoError(t, err)
+	}
+	// 2 KB at 1 KB/s take 2 seconds
+	var total time.Duration
+	for _, d := range slept {
+		total += d
+	}
+	assert.Equal(t, 2*time.Second, total)
+}
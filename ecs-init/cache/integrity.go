@@ -0,0 +1,285 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// sha256Pattern matches the hex encoded SHA-256 digests of the manifest
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// DigestMismatchError is returned when a tarball doesn't have the SHA-256
+// digest it's expected to have, because it was corrupted or changed
+type DigestMismatchError struct {
+	File       string
+	Expected   string
+	Calculated string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("%s has SHA-256 digest %s instead of %s", e.File, e.Calculated, e.Expected)
+}
+
+// getPublishedDigest downloads the manifest of version and returns the
+// SHA-256 digest it has for artifact
+func (d *Downloader) getPublishedDigest(version, artifact string) (string, error) {
+	objectKey := config.AgentRemoteManifestKey(version)
+	tempManifestFileName, err := d.s3Downloader.downloadFile(objectKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to download checksum manifest")
+	}
+	defer func() { // clean up temp file
+		log.Debugf("Removing temp file %s", tempManifestFileName)
+		d.fs.Remove(tempManifestFileName)
+	}()
+
+	tempManifestFile, err := d.fs.Open(tempManifestFileName)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open temporary checksum manifest")
+	}
+	defer tempManifestFile.Close()
+
+	body, err := d.fs.ReadAll(tempManifestFile)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read from temporary checksum manifest")
+	}
+	return manifestDigest(body, artifact)
+}
+
+// manifestDigest returns the digest of artifact in a manifest in the format
+// of sha256sum, with the digest and the name of an artifact on each line
+func manifestDigest(manifest []byte, artifact string) (string, error) {
+	for _, line := range strings.Split(string(manifest), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != artifact {
+			continue
+		}
+		if !sha256Pattern.MatchString(fields[0]) {
+			return "", errors.Errorf("invalid SHA-256 digest %q of %s in checksum manifest", fields[0], artifact)
+		}
+		return strings.ToLower(fields[0]), nil
+	}
+	return "", errors.Errorf("no checksum of %s in checksum manifest", artifact)
+}
+
+// checkDigest returns a *DigestMismatchError unless the file at path has the
+// SHA-256 digest expected
+func (d *Downloader) checkDigest(path, expected string) error {
+	calculated, err := d.fileDigest(path)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Expected SHA-256 of %s %q", path, expected)
+	log.Debugf("Calculated SHA-256 of %s %q", path, calculated)
+	journal.Record(journal.Checksum, "", journal.Fields{
+		"algorithm":  "sha256",
+		"file":       filepath.Base(path),
+		"expected":   expected,
+		"calculated": calculated,
+		"match":      fmt.Sprint(expected == calculated),
+	}, nil)
+	if calculated != expected {
+		return &DigestMismatchError{File: path, Expected: expected, Calculated: calculated}
+	}
+	return nil
+}
+
+// getPublishedMd5Sum downloads the MD5 checksum of the tarball of version,
+// which versions without a checksum manifest are checked against
+func (d *Downloader) getPublishedMd5Sum(version string) (string, error) {
+	objectKey, err := config.AgentRemoteTarballMD5Key(version)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to determine md5 file for download")
+	}
+	tempMd5FileName, err := d.s3Downloader.downloadFile(objectKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to download md5 file for published tarball")
+	}
+	defer func() { // clean up temp file
+		log.Debugf("Removing temp file %s", tempMd5FileName)
+		d.fs.Remove(tempMd5FileName)
+	}()
+
+	tempMd5File, err := d.fs.Open(tempMd5FileName)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open temporary md5 file")
+	}
+	defer tempMd5File.Close()
+
+	body, err := d.fs.ReadAll(tempMd5File)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read from temporary md5 file")
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// checkMd5Sum returns an error unless the file at path has the MD5 checksum
+// expected
+func (d *Downloader) checkMd5Sum(path, expected string) error {
+	file, err := d.fs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := md5.New()
+	_, err = d.fs.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+	calculated := fmt.Sprintf("%x", hash.Sum(nil))
+	log.Debugf("Expected MD5 of %s %q", path, expected)
+	log.Debugf("Calculated MD5 of %s %q", path, calculated)
+	journal.Record(journal.Checksum, "", journal.Fields{
+		"algorithm":  "md5",
+		"file":       filepath.Base(path),
+		"expected":   expected,
+		"calculated": calculated,
+		"match":      fmt.Sprint(expected == calculated),
+	}, nil)
+	if calculated != expected {
+		return errors.Errorf("%s has MD5 checksum %s instead of %s", path, calculated, expected)
+	}
+	return nil
+}
+
+// fileDigest returns the hex encoded SHA-256 digest of the file at path
+func (d *Downloader) fileDigest(path string) (string, error) {
+	file, err := d.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = d.fs.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// openVerified opens the tarball at path once it's checked against the
+// digest recorded for it in the cache state. Tarballs without a recorded
+// digest are checked against the one returned by expected, which is then
+// recorded.
+func (d *Downloader) openVerified(path string, expected func() (string, error)) (io.ReadCloser, error) {
+	state, err := d.readState()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the digests of the cache state")
+	}
+	name := filepath.Base(path)
+	digest, recorded := state.digests[name]
+	if !recorded {
+		digest, err = expected()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = d.checkDigest(path, digest)
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		state.digests[name] = digest
+		err = d.writeState(state)
+		if err != nil {
+			log.Warnf("Could not record the digest of %s in the cache state: %v", path, err)
+		}
+	}
+	return d.fs.Open(path)
+}
+
+// recordDigest records the digest of a downloaded tarball in the cache state
+func (d *Downloader) recordDigest(path, digest string) error {
+	state, err := d.readState()
+	if err != nil {
+		state = newCacheState(StatusUncached)
+	}
+	state.digests[filepath.Base(path)] = digest
+	return d.writeState(state)
+}
+
+// cachedTarballDigest is the digest expected of a cached tarball without a
+// recorded digest. The tarball installed with ecs-init is trusted as is
+// until it's first loaded. The packaging resets the cache state on upgrades,
+// so the tarballs of pinned versions are checked against the published
+// digest of their version again rather than trusted.
+func (d *Downloader) cachedTarballDigest(path string) func() (string, error) {
+	return func() (string, error) {
+		if path == config.AgentTarball() {
+			log.Infof("No digest recorded for %s, recording its current digest", path)
+			return d.fileDigest(path)
+		}
+		version := d.agentVersion()
+		log.Infof("No digest recorded for %s, checking it against the published digest of %s", path, version)
+		return d.publishedTarballDigest(path, version)
+	}
+}
+
+// publishedTarballDigest returns the digest of the checksum manifest of
+// version for the tarball at path. Unless the MD5 fallback is disabled,
+// versions without a manifest have the current digest of the tarball once
+// it's checked against their MD5 checksum.
+func (d *Downloader) publishedTarballDigest(path, version string) (string, error) {
+	artifact, err := config.AgentRemoteTarballKey(version)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to determine the agent artifact")
+	}
+	digest, err := d.getPublishedDigest(version, artifact)
+	if err == nil || !d.md5Fallback {
+		return digest, err
+	}
+	log.Warnf("Could not get the SHA-256 digest of %s, falling back to its MD5 checksum: %v", artifact, err)
+	md5Sum, err := d.getPublishedMd5Sum(version)
+	if err != nil {
+		return "", err
+	}
+	err = d.checkMd5Sum(path, md5Sum)
+	if err != nil {
+		return "", err
+	}
+	return d.fileDigest(path)
+}
+
+// desiredTarballDigest is the digest expected of the desired Agent without a
+// recorded digest, once it's checked against the detached signature next to
+// it: the published digest of its version if the name of its file has one,
+// or else its current digest, as the Agent checked the integrity of the
+// update it downloaded.
+func (d *Downloader) desiredTarballDigest(path string) func() (string, error) {
+	return func() (string, error) {
+		err := d.checkLocalSignature(path)
+		if err != nil {
+			return "", err
+		}
+		version, err := d.DesiredAgentVersion()
+		if err != nil {
+			log.Warnf("Could not look up the published digest of the desired agent, recording its current digest: %v", err)
+			return d.fileDigest(path)
+		}
+		return d.publishedTarballDigest(path, version)
+	}
+}
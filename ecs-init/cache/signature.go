@@ -0,0 +1,269 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// signatureSuffix names the detached signature of an Agent image file next
+// to it
+const signatureSuffix = ".asc"
+
+// gpgBinary is the gpg executable checking the signatures
+var gpgBinary = "gpg"
+
+// verifySignature checks the file at path against its detached signature
+// with the keys of a directory, returning the fingerprint of the key it's
+// signed with. It's a variable to be mocked in tests.
+var verifySignature = gpgVerify
+
+// SignatureError is returned when an Agent image has a bad signature, or
+// has no signature that could be verified while they're required
+type SignatureError struct {
+	File   string
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature of %s not verified: %s", e.File, e.Reason)
+}
+
+// badSignatureError is returned by verifySignature when gpg checked the
+// signature and found it bad, as opposed to failing to check it
+type badSignatureError struct {
+	signature string
+	reason    string
+}
+
+func (e *badSignatureError) Error() string {
+	return fmt.Sprintf("bad signature %s: %s", e.signature, e.reason)
+}
+
+// signaturePolicy returns what happens to unsigned Agent images, one of the
+// config.SignaturePolicy* values
+func (d *Downloader) signaturePolicy() string {
+	if d.policy == "" {
+		return config.SignaturePolicyWarn
+	}
+	return d.policy
+}
+
+// checkRemoteSignature downloads the detached signature of version and
+// checks the downloaded Agent image at path against it
+func (d *Downloader) checkRemoteSignature(path, version string) error {
+	objectKey, err := config.AgentRemoteSignatureKey(version)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine download signature")
+	}
+	tempSignatureFileName, err := d.s3Downloader.downloadFile(objectKey)
+	if err != nil {
+		return d.unsigned(path, err)
+	}
+	defer func() { // clean up temp file
+		log.Debugf("Removing temp file %s", tempSignatureFileName)
+		d.fs.Remove(tempSignatureFileName)
+	}()
+	return d.checkSignature(path, tempSignatureFileName)
+}
+
+// checkLocalSignature checks the Agent image at path against the detached
+// signature next to it
+func (d *Downloader) checkLocalSignature(path string) error {
+	signature := path + signatureSuffix
+	_, err := d.fs.Stat(signature)
+	if err != nil {
+		return d.unsigned(path, err)
+	}
+	return d.checkSignature(path, signature)
+}
+
+// checkSignature returns a *SignatureError if the Agent image at path has a
+// bad signature, whatever the policy. A signature that can't be verified,
+// because gpg or the trusted keys are missing or the key of the signature
+// isn't trusted, is handled by the policy like a missing signature.
+func (d *Downloader) checkSignature(path, signature string) error {
+	fingerprint, err := verifySignature(d.trustedKeys, signature, path)
+	if err != nil {
+		if _, bad := err.(*badSignatureError); !bad {
+			return d.unsigned(path, err)
+		}
+		err = &SignatureError{File: path, Reason: err.Error()}
+	}
+	journal.Record(journal.Signature, "", journal.Fields{
+		"file":   filepath.Base(path),
+		"signed": "true",
+		"key":    fingerprint,
+		"policy": d.signaturePolicy(),
+	}, err)
+	if err != nil {
+		return err
+	}
+	log.Infof("Verified the signature of %s by key %s", path, fingerprint)
+	return nil
+}
+
+// unsigned applies the signature policy to the Agent image at path, whose
+// signature can't be found or verified
+func (d *Downloader) unsigned(path string, cause error) error {
+	var err error
+	switch d.signaturePolicy() {
+	case config.SignaturePolicyRequire:
+		err = &SignatureError{File: path, Reason: fmt.Sprintf("no verified signature: %v", cause)}
+	case config.SignaturePolicyWarn:
+		log.Warnf("No verified signature of %s, loading it unverified: %v", path, cause)
+	default:
+		log.Debugf("No verified signature of %s: %v", path, cause)
+	}
+	journal.Record(journal.Signature, "", journal.Fields{
+		"file":   filepath.Base(path),
+		"signed": "false",
+		"policy": d.signaturePolicy(),
+	}, err)
+	return err
+}
+
+// gpgOptions are the options of every gpg command, using the keyring of home
+// without starting a gpg-agent that would outlive it
+func gpgOptions(home string) []string {
+	return []string{"--batch", "--no-tty", "--no-autostart", "--homedir", home}
+}
+
+// gpgVerify checks the file at path against its detached signature with
+// gpg. The keys of keysDir are imported into a temporary keyring, so that
+// the keyring of root isn't trusted. Only a signature gpg found bad, or that
+// isn't a detached signature, is a *badSignatureError.
+func gpgVerify(keysDir, signature, path string) (string, error) {
+	home, err := ioutil.TempDir("", "ecs-init-gpg")
+	if err != nil {
+		return "", errors.Wrap(err, "could not create temporary keyring")
+	}
+	defer os.RemoveAll(home)
+	err = importTrustedKeys(keysDir, home)
+	if err != nil {
+		return "", err
+	}
+
+	verifyArgs := append(gpgOptions(home), "--status-fd", "1", "--verify", signature, path)
+	cmd := exec.Command(gpgBinary, verifyArgs...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	status, err := cmd.Output()
+	output := strings.TrimSpace(stderr.String())
+	if badSignature(string(status)) || strings.Contains(output, "not a detached signature") {
+		return "", &badSignatureError{signature: signature, reason: output}
+	}
+	fingerprint := validSignature(string(status))
+	if err != nil || fingerprint == "" {
+		return "", errors.Errorf("could not verify signature %s: %v: %s", signature, err, output)
+	}
+	return fingerprint, nil
+}
+
+// importTrustedKeys imports the keys of keysDir into the keyring of home
+func importTrustedKeys(keysDir, home string) error {
+	keys, err := trustedKeyFiles(keysDir)
+	if err != nil {
+		return err
+	}
+	importArgs := append(append(gpgOptions(home), "--import"), keys...)
+	output, err := exec.Command(gpgBinary, importArgs...).CombinedOutput()
+	if err != nil {
+		return errors.Errorf("could not import the trusted keys of %s: %v: %s",
+			keysDir, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// CheckTrustedKeys returns an error unless gpg can import the trusted keys
+// of keysDir, which is what signatures are verified with
+func CheckTrustedKeys(keysDir string) error {
+	home, err := ioutil.TempDir("", "ecs-init-gpg")
+	if err != nil {
+		return errors.Wrap(err, "could not create temporary keyring")
+	}
+	defer os.RemoveAll(home)
+	return importTrustedKeys(keysDir, home)
+}
+
+// trustedKeyFiles returns the key files of keysDir, failing if there is none
+func trustedKeyFiles(keysDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(keysDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the trusted keys")
+	}
+	var keys []string
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			keys = append(keys, filepath.Join(keysDir, entry.Name()))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("no trusted keys in %s", keysDir)
+	}
+	return keys, nil
+}
+
+// validSignature returns the fingerprint of the key of a good signature
+// reported on the status output of gpg, or an empty string if there is
+// none. Signatures by expired or revoked keys aren't good signatures.
+func validSignature(status string) string {
+	good := false
+	fingerprint := ""
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "[GNUPG:]" {
+			continue
+		}
+		switch fields[1] {
+		case "GOODSIG":
+			good = true
+		case "VALIDSIG":
+			fingerprint = fields[2]
+		}
+	}
+	if !good {
+		return ""
+	}
+	return fingerprint
+}
+
+// badSignature returns true if the status output of gpg reports a signature
+// that was checked and isn't good, or data that isn't a detached signature:
+// either no OpenPGP data or a signature with the signed data embedded
+func badSignature(status string) bool {
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "[GNUPG:]" {
+			continue
+		}
+		switch fields[1] {
+		case "BADSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG", "NODATA", "PLAINTEXT":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockVerifySignature replaces verifySignature with verify until the
+// returned function is called
+func mockVerifySignature(verify func(keysDir, signature, path string) (string, error)) func() {
+	verifySignatureBkp := verifySignature
+	verifySignature = verify
+	return func() {
+		verifySignature = verifySignatureBkp
+	}
+}
+
+func TestCheckRemoteSignatureUnsigned(t *testing.T) {
+	var cases = []struct {
+		policy string
+		err    bool
+	}{
+		{"", false},
+		{config.SignaturePolicyRequire, true},
+		{config.SignaturePolicyWarn, false},
+		{config.SignaturePolicyAccept, false},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.policy, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
+			mockS3Downloader.EXPECT().downloadFile(remoteSignatureKey).Return("", errors.New("not found"))
+
+			d := &Downloader{s3Downloader: mockS3Downloader, policy: testcase.policy}
+			err := d.checkRemoteSignature("agent-test", config.DefaultAgentVersion)
+			if testcase.err {
+				_, ok := err.(*SignatureError)
+				assert.True(t, ok, "expected a SignatureError, got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckRemoteSignatureBadSignature(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	defer mockVerifySignature(func(keysDir, signature, path string) (string, error) {
+		return "", &badSignatureError{signature: signature, reason: "BAD signature"}
+	})()
+
+	mockFS := NewMockfileSystem(mockCtrl)
+	mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
+	gomock.InOrder(
+		mockS3Downloader.EXPECT().downloadFile(remoteSignatureKey).Return("signature-test", nil),
+		mockFS.EXPECT().Remove("signature-test"),
+	)
+
+	// bad signatures are refused even when unsigned images are accepted
+	d := &Downloader{s3Downloader: mockS3Downloader, fs: mockFS, policy: config.SignaturePolicyAccept}
+	err := d.checkRemoteSignature("agent-test", config.DefaultAgentVersion)
+	_, ok := err.(*SignatureError)
+	assert.True(t, ok, "expected a SignatureError, got %v", err)
+}
+
+func TestCheckRemoteSignatureUnverifiable(t *testing.T) {
+	var cases = []struct {
+		policy string
+		err    bool
+	}{
+		{config.SignaturePolicyRequire, true},
+		{config.SignaturePolicyWarn, false},
+		{config.SignaturePolicyAccept, false},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.policy, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			defer mockVerifySignature(func(keysDir, signature, path string) (string, error) {
+				return "", errors.New("no trusted keys")
+			})()
+
+			mockFS := NewMockfileSystem(mockCtrl)
+			mockS3Downloader := NewMocks3DownloaderAPI(mockCtrl)
+			gomock.InOrder(
+				mockS3Downloader.EXPECT().downloadFile(remoteSignatureKey).Return("signature-test", nil),
+				mockFS.EXPECT().Remove("signature-test"),
+			)
+
+			// signatures that can't be verified are handled like missing
+			// signatures
+			d := &Downloader{s3Downloader: mockS3Downloader, fs: mockFS, policy: testcase.policy}
+			err := d.checkRemoteSignature("agent-test", config.DefaultAgentVersion)
+			if testcase.err {
+				_, ok := err.(*SignatureError)
+				assert.True(t, ok, "expected a SignatureError, got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadDesiredAgentSignature(t *testing.T) {
+	desiredImage := "ecs-update-123456"
+	desiredImagePath := config.CacheDirectory() + "/" + desiredImage
+
+	var cases = []struct {
+		name   string
+		policy string
+		signed bool
+		err    bool
+	}{
+		{"signed", config.SignaturePolicyRequire, true, false},
+		{"unsigned required", config.SignaturePolicyRequire, false, true},
+		{"unsigned accepted", config.SignaturePolicyAccept, false, false},
+	}
+
+	for _, testcase := range cases {
+		t.Run(testcase.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			verified := false
+			defer mockVerifySignature(func(keysDir, signature, path string) (string, error) {
+				assert.Equal(t, desiredImagePath+signatureSuffix, signature)
+				assert.Equal(t, desiredImagePath, path)
+				verified = true
+				return "0123456789ABCDEF", nil
+			})()
+
+			mockFS := NewMockfileSystem(mockCtrl)
+			mockFS.EXPECT().Open(config.DesiredImageLocatorFile()).DoAndReturn(func(string) (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewBufferString(desiredImage + "\n")), nil
+			}).AnyTimes()
+			mockFS.EXPECT().Base(gomock.Any()).DoAndReturn(filepath.Base).AnyTimes()
+			mockFS.EXPECT().Open(config.CacheState()).Return(ioutil.NopCloser(bytes.NewBufferString("1\n")), nil)
+			if testcase.signed {
+				mockFS.EXPECT().Stat(desiredImagePath+signatureSuffix).Return(nil, nil)
+			} else {
+				mockFS.EXPECT().Stat(desiredImagePath+signatureSuffix).Return(nil, os.ErrNotExist)
+			}
+			if !testcase.err {
+				mockFS.EXPECT().Open(desiredImagePath).Return(ioutil.NopCloser(bytes.NewBufferString("desired image")), nil).Times(3)
+				mockFS.EXPECT().Copy(gomock.Any(), gomock.Any()).Return(int64(0), nil).Times(2)
+				mockFS.EXPECT().WriteFile(config.CacheState(), gomock.Any(), os.FileMode(orwPerm))
+			}
+
+			d := &Downloader{fs: mockFS, policy: testcase.policy}
+			_, err := d.LoadDesiredAgent()
+			assert.Equal(t, testcase.err, err != nil, "unexpected error %v", err)
+			assert.Equal(t, testcase.signed, verified)
+		})
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	fingerprint := "E22D52AD88021AA91D98FEEDC3408158BD898759"
+	good := "[GNUPG:] NEWSIG\n" +
+		"[GNUPG:] GOODSIG C3408158BD898759 test <test@example.com>\n" +
+		"[GNUPG:] VALIDSIG " + fingerprint + " 2026-10-17 1792202667 0 4 0 22 8 00 " + fingerprint + "\n"
+	assert.Equal(t, fingerprint, validSignature(good))
+
+	expired := "[GNUPG:] EXPKEYSIG C3408158BD898759 test <test@example.com>\n" +
+		"[GNUPG:] VALIDSIG " + fingerprint + " 2026-10-17 1792202667 0 4 0 22 8 00 " + fingerprint + "\n"
+	assert.Empty(t, validSignature(expired))
+	assert.Empty(t, validSignature("[GNUPG:] ERRSIG C3408158BD898759 22 8 00 1792202667 9 -\n"))
+}
+
+func TestBadSignature(t *testing.T) {
+	fingerprint := "E22D52AD88021AA91D98FEEDC3408158BD898759"
+	good := "[GNUPG:] NEWSIG\n" +
+		"[GNUPG:] GOODSIG C3408158BD898759 test <test@example.com>\n" +
+		"[GNUPG:] VALIDSIG " + fingerprint + " 2026-10-17 1792202667 0 4 0 22 8 00 " + fingerprint + "\n"
+	assert.False(t, badSignature(good))
+	assert.False(t, badSignature("[GNUPG:] ERRSIG C3408158BD898759 22 8 00 1792202667 9 -\n[GNUPG:] NO_PUBKEY C3408158BD898759\n"),
+		"expected a signature by an unknown key not to be reported as bad")
+
+	assert.True(t, badSignature("[GNUPG:] BADSIG C3408158BD898759 test <test@example.com>\n"))
+	assert.True(t, badSignature("[GNUPG:] NODATA 1\n[GNUPG:] NODATA 2\n"))
+	assert.True(t, badSignature("[GNUPG:] PLAINTEXT 62 1792202667 agent\n"+good),
+		"expected a signature with embedded data to be reported as bad")
+}
+
+// gpgSign signs the file at path with a key generated in a temporary
+// keyring, exporting the key to keysDir. The file is also signed with the
+// signature and the file together, in path.signed.asc.
+func gpgSign(t *testing.T, keysDir, path string) {
+	home, err := ioutil.TempDir("", "gpg-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	defer exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+	for _, args := range [][]string{
+		{"--passphrase", "", "--quick-gen-key", "test <test@example.com>", "ed25519", "sign", "never"},
+		{"--armor", "--output", filepath.Join(keysDir, "test.gpg"), "--export"},
+		{"--armor", "--output", path + signatureSuffix, "--detach-sign", path},
+		{"--armor", "--output", path + ".signed" + signatureSuffix, "--sign", path},
+	} {
+		args = append([]string{"--batch", "--no-tty", "--homedir", home}, args...)
+		output, err := exec.Command(gpgBinary, args...).CombinedOutput()
+		require.NoError(t, err, "gpg %v: %s", args, output)
+	}
+}
+
+func TestGpgVerify(t *testing.T) {
+	if _, err := exec.LookPath(gpgBinary); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	dir, err := ioutil.TempDir("", "signature-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keysDir := filepath.Join(dir, "trusted-keys.d")
+	otherKeysDir := filepath.Join(dir, "other-keys.d")
+	require.NoError(t, os.Mkdir(keysDir, 0700))
+	require.NoError(t, os.Mkdir(otherKeysDir, 0700))
+	tarball := filepath.Join(dir, "ecs-agent.tar")
+	require.NoError(t, ioutil.WriteFile(tarball, []byte("agent"), 0600))
+	other := filepath.Join(dir, "other")
+	require.NoError(t, ioutil.WriteFile(other, []byte("other"), 0600))
+	gpgSign(t, keysDir, tarball)
+	gpgSign(t, otherKeysDir, other)
+
+	fingerprint, err := gpgVerify(keysDir, tarball+signatureSuffix, tarball)
+	assert.NoError(t, err)
+	assert.Len(t, fingerprint, 40)
+
+	_, err = gpgVerify(otherKeysDir, tarball+signatureSuffix, tarball)
+	assert.Error(t, err, "expected the signature of an untrusted key not to be verified")
+	assert.False(t, isBadSignature(err), "expected an untrusted key not to make a bad signature: %v", err)
+
+	_, err = gpgVerify(filepath.Join(dir, "empty"), tarball+signatureSuffix, tarball)
+	assert.Error(t, err, "expected missing trusted keys not to verify signatures")
+	assert.False(t, isBadSignature(err), "expected missing trusted keys not to make a bad signature: %v", err)
+	assert.NoError(t, CheckTrustedKeys(keysDir))
+	assert.Error(t, CheckTrustedKeys(filepath.Join(dir, "empty")))
+
+	_, err = gpgVerify(keysDir, tarball+".signed"+signatureSuffix, tarball)
+	assert.True(t, isBadSignature(err), "expected a signature that isn't detached to be bad: %v", err)
+
+	junk := filepath.Join(dir, "junk"+signatureSuffix)
+	require.NoError(t, ioutil.WriteFile(junk, []byte("not a signature"), 0600))
+	_, err = gpgVerify(keysDir, junk, tarball)
+	assert.True(t, isBadSignature(err), "expected a file without OpenPGP data to be a bad signature: %v", err)
+
+	require.NoError(t, ioutil.WriteFile(tarball, []byte("changed agent"), 0600))
+	_, err = gpgVerify(keysDir, tarball+signatureSuffix, tarball)
+	assert.True(t, isBadSignature(err), "expected the signature of a changed file to be bad: %v", err)
+}
+
+func isBadSignature(err error) bool {
+	_, ok := err.(*badSignatureError)
+	return ok
+}
@@ -0,0 +1,194 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/pkg/errors"
+)
+
+// httpStatusError is returned when an HTTP(S) mirror doesn't serve a file
+type httpStatusError struct {
+	url    string
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %d %s", e.url, e.status, http.StatusText(e.status))
+}
+
+func (e *httpStatusError) StatusCode() int {
+	return e.status
+}
+
+// httpMirror downloads from a plain HTTP(S) server mirroring the files of
+// the Agent buckets under a base URL
+type httpMirror struct {
+	base       *url.URL
+	name       string
+	client     *http.Client
+	inactivity time.Duration
+}
+
+func newHTTPMirror(source config.AgentSource) (*httpMirror, error) {
+	base, err := url.Parse(source.Location)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid mirror %s", source)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   source.Timeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = source.Timeout
+	transport.ResponseHeaderTimeout = source.Timeout
+	if source.CABundle != "" {
+		bundle, err := ioutil.ReadFile(source.CABundle)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read the CA bundle of mirror %s", source)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(bundle) {
+			return nil, errors.Errorf("no certificates in the CA bundle %s of mirror %s", source.CABundle, source)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	return &httpMirror{
+		base:       base,
+		name:       source.String(),
+		client:     &http.Client{Transport: transport},
+		inactivity: source.Timeout,
+	}, nil
+}
+
+func (m *httpMirror) fetch(ctx context.Context, fileName string, w io.WriterAt, offset int64) (int64, error) {
+	location := *m.base
+	location.Path = path.Join("/", location.Path, fileName)
+	request, err := http.NewRequest(http.MethodGet, location.String(), nil)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid request for %s", fileName)
+	}
+	request = request.WithContext(ctx)
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	response, err := m.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusPartialContent:
+	case response.StatusCode == http.StatusOK:
+		// the mirror ignored the range, so the bytes already downloaded
+		// are skipped
+		if _, err := io.CopyN(ioutil.Discard, response.Body, offset); err != nil {
+			return 0, err
+		}
+	default:
+		location.User = nil
+		return 0, &httpStatusError{url: location.String(), status: response.StatusCode}
+	}
+	return io.Copy(&sequentialWriter{w: w}, response.Body)
+}
+
+func (m *httpMirror) timeout() time.Duration {
+	return m.inactivity
+}
+
+func (m *httpMirror) String() string {
+	return "mirror " + m.name
+}
+
+// localSource copies from a local directory, which may be an NFS share
+type localSource struct {
+	dir        string
+	inactivity time.Duration
+}
+
+// fetch copies in the background, so that a hung NFS share is abandoned
+// once the context is cancelled
+func (s *localSource) fetch(ctx context.Context, fileName string, w io.WriterAt, offset int64) (int64, error) {
+	type result struct {
+		n   int64
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := s.copy(fileName, w, offset)
+		done <- result{n, err}
+	}()
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (s *localSource) copy(fileName string, w io.WriterAt, offset int64) (int64, error) {
+	file, err := os.Open(filepath.Join(s.dir, fileName))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if offset >= info.Size() {
+		return 0, nil
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(&sequentialWriter{w: w}, file)
+}
+
+func (s *localSource) timeout() time.Duration {
+	return s.inactivity
+}
+
+func (s *localSource) String() string {
+	return "directory " + s.dir
+}
+
+// sequentialWriter writes a stream to a io.WriterAt from its start
+type sequentialWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (s *sequentialWriter) Write(p []byte) (int, error) {
+	n, err := s.w.WriteAt(p, s.off)
+	s.off += int64(n)
+	return n, err
+}
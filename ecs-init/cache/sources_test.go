@@ -0,0 +1,146 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryWriterAt collects what's written to it
+type memoryWriterAt struct {
+	contents []byte
+}
+
+func (m *memoryWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(m.contents) {
+		m.contents = append(m.contents, make([]byte, end-len(m.contents))...)
+	}
+	copy(m.contents[off:], p)
+	return len(p), nil
+}
+
+// agentMirror serves ecs-agent.tar under /ecs, honoring ranges unless
+// ignoreRanges is set
+func agentMirror(ignoreRanges bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ecs/ecs-agent.tar" {
+			http.NotFound(w, r)
+			return
+		}
+		if ignoreRanges {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "ecs-agent.tar", time.Time{}, strings.NewReader("hello world"))
+	})
+}
+
+func TestHTTPMirrorFetch(t *testing.T) {
+	for _, ignoreRanges := range []bool{false, true} {
+		server := httptest.NewServer(agentMirror(ignoreRanges))
+		mirror, err := newHTTPMirror(config.AgentSource{
+			Type:     config.AgentSourceHTTP,
+			Location: server.URL + "/ecs/",
+			Timeout:  time.Minute,
+		})
+		require.NoError(t, err)
+
+		w := &memoryWriterAt{}
+		n, err := mirror.fetch(context.Background(), "ecs-agent.tar", w, 6)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), n)
+		assert.Equal(t, "world", string(w.contents), "ignoreRanges: %v", ignoreRanges)
+		server.Close()
+	}
+}
+
+func TestHTTPMirrorFetchMissingFile(t *testing.T) {
+	server := httptest.NewServer(agentMirror(false))
+	defer server.Close()
+	mirror, err := newHTTPMirror(config.AgentSource{
+		Type:     config.AgentSourceHTTP,
+		Location: server.URL + "/ecs",
+		Timeout:  time.Minute,
+	})
+	require.NoError(t, err)
+
+	_, err = mirror.fetch(context.Background(), "ecs-agent.tar.asc", &memoryWriterAt{}, 0)
+	assert.Error(t, err)
+	assert.False(t, isRetryableDownloadError(err), "expected %v not to be retried", err)
+}
+
+func TestHTTPMirrorCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(agentMirror(false))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "sources-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	bundle := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0600))
+	source := config.AgentSource{
+		Type:     config.AgentSourceHTTP,
+		Location: server.URL + "/ecs",
+		Timeout:  time.Minute,
+	}
+
+	untrusted, err := newHTTPMirror(source)
+	require.NoError(t, err)
+	_, err = untrusted.fetch(context.Background(), "ecs-agent.tar", &memoryWriterAt{}, 0)
+	assert.Error(t, err, "expected the certificate of the mirror not to be trusted without its CA bundle")
+
+	source.CABundle = bundle
+	trusted, err := newHTTPMirror(source)
+	require.NoError(t, err)
+	w := &memoryWriterAt{}
+	_, err = trusted.fetch(context.Background(), "ecs-agent.tar", w, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(w.contents))
+
+	require.NoError(t, ioutil.WriteFile(bundle, []byte("not a certificate"), 0600))
+	_, err = newHTTPMirror(source)
+	assert.Error(t, err)
+}
+
+func TestLocalSourceFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sources-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ecs-agent.tar"), []byte("hello world"), 0600))
+	source := &localSource{dir: dir, inactivity: time.Minute}
+
+	w := &memoryWriterAt{}
+	n, err := source.fetch(context.Background(), "ecs-agent.tar", w, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, "world", string(w.contents))
+
+	_, err = source.fetch(context.Background(), "ecs-agent.tar.asc", w, 0)
+	assert.False(t, isRetryableDownloadError(err), "expected %v not to be retried", err)
+}
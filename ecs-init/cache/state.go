@@ -0,0 +1,120 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// rollbackStatePrefix starts the line of the cache state file that
+	// records the last rollback of a failed Agent upgrade
+	rollbackStatePrefix = "rollback "
+	// versionStatePrefix starts the line of the cache state file that
+	// records the version of the Agent last recorded as cached
+	versionStatePrefix = "version "
+	// digestStatePrefix starts the lines of the cache state file that
+	// record the SHA-256 digest of a cached tarball, followed by its name
+	digestStatePrefix = "sha256 "
+)
+
+// cacheState is the content of the cache state file: the cache status on
+// the first line, which is all the packaging writes, followed by records
+// starting with their prefix
+type cacheState struct {
+	status CacheStatus
+	// version is the version of the Agent last recorded as cached, empty if
+	// none was recorded
+	version string
+	// digests are the SHA-256 digests of the cached tarballs, by file name
+	digests map[string]string
+	// rollback is the JSON record of the last rollback, if any
+	rollback string
+}
+
+func newCacheState(status CacheStatus) *cacheState {
+	return &cacheState{
+		status:  status,
+		digests: make(map[string]string),
+	}
+}
+
+// readState reads the cache state file
+func (d *Downloader) readState() (*cacheState, error) {
+	file, err := d.fs.Open(config.CacheState())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseState(file)
+}
+
+// writeState replaces the cache state file with state
+func (d *Downloader) writeState(state *cacheState) error {
+	return d.fs.WriteFile(config.CacheState(), state.bytes(), orwPerm)
+}
+
+func parseState(r io.Reader) (*cacheState, error) {
+	reader := bufio.NewReader(r)
+	state := newCacheState(StatusUncached)
+	_, err := fmt.Fscanf(reader, "%d", &state.status)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cache status")
+	}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, versionStatePrefix):
+			state.version = strings.TrimPrefix(line, versionStatePrefix)
+		case strings.HasPrefix(line, digestStatePrefix):
+			fields := strings.Fields(strings.TrimPrefix(line, digestStatePrefix))
+			if len(fields) == 2 {
+				state.digests[fields[1]] = fields[0]
+			}
+		case strings.HasPrefix(line, rollbackStatePrefix):
+			state.rollback = strings.TrimPrefix(line, rollbackStatePrefix)
+		}
+	}
+	return state, scanner.Err()
+}
+
+func (s *cacheState) bytes() []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%d\n", s.status)
+	if s.version != "" {
+		fmt.Fprintf(&buffer, "%s%s\n", versionStatePrefix, s.version)
+	}
+	names := make([]string, 0, len(s.digests))
+	for name := range s.digests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&buffer, "%s%s %s\n", digestStatePrefix, s.digests[name], name)
+	}
+	if s.rollback != "" {
+		fmt.Fprintf(&buffer, "%s%s\n", rollbackStatePrefix, s.rollback)
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseState(t *testing.T) {
+	data := "1\n" +
+		"version v1.60.0\n" +
+		"sha256 bbbb ecs-agent.tar\n" +
+		"sha256 aaaa ecs-agent-v1.60.0.tar\n" +
+		"rollback {\"reason\":\"failed\"}\n"
+
+	state, err := parseState(bytes.NewBufferString(data))
+	require.NoError(t, err)
+	assert.Equal(t, StatusCached, state.status)
+	assert.Equal(t, "v1.60.0", state.version)
+	assert.Equal(t, map[string]string{"ecs-agent.tar": "bbbb", "ecs-agent-v1.60.0.tar": "aaaa"}, state.digests)
+	assert.Equal(t, `{"reason":"failed"}`, state.rollback)
+
+	// digests are written sorted by file name
+	assert.Equal(t, "1\n"+
+		"version v1.60.0\n"+
+		"sha256 aaaa ecs-agent-v1.60.0.tar\n"+
+		"sha256 bbbb ecs-agent.tar\n"+
+		"rollback {\"reason\":\"failed\"}\n", string(state.bytes()))
+}
+
+func TestParseStatePackaged(t *testing.T) {
+	// the packaging only writes the status
+	state, err := parseState(bytes.NewBufferString("2"))
+	require.NoError(t, err)
+	assert.Equal(t, StatusReloadNeeded, state.status)
+	assert.Empty(t, state.version)
+	assert.Empty(t, state.digests)
+	assert.Equal(t, "2\n", string(state.bytes()))
+
+	_, err = parseState(bytes.NewBufferString("spurious"))
+	assert.Error(t, err)
+}
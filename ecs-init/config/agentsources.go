@@ -0,0 +1,153 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Agent source types
+const (
+	// AgentSourceS3 downloads from an S3 bucket
+	AgentSourceS3 = "s3"
+	// AgentSourceHTTP downloads from an HTTP(S) mirror
+	AgentSourceHTTP = "http"
+	// AgentSourceFile copies from a local directory, such as an NFS share
+	AgentSourceFile = "file"
+)
+
+const (
+	// defaultAgentSourceTimeout is how long a download may go without
+	// receiving anything from a source unless its timeout is set
+	defaultAgentSourceTimeout = time.Minute
+
+	// the query parameters of the sources
+	sourceTimeoutParameter  = "timeout"
+	sourceCABundleParameter = "ca"
+)
+
+// AgentSource is a place the Agent artifacts are downloaded from
+type AgentSource struct {
+	// Type is one of AgentSourceS3, AgentSourceHTTP and AgentSourceFile
+	Type string
+	// Location is the bucket of an S3 source, empty for the partition and
+	// regional buckets of the Agent, the base URL of an HTTP(S) mirror, or
+	// the directory of a local source
+	Location string
+	// Timeout is how long a download may go without receiving anything from
+	// the source before it's abandoned
+	Timeout time.Duration
+	// CABundle is the PEM file of the CAs trusted for an HTTPS mirror, the
+	// CAs of the system if it's empty
+	CABundle string
+}
+
+func (s AgentSource) String() string {
+	if s.Type == AgentSourceS3 {
+		if s.Location == "" {
+			return "s3"
+		}
+		return "s3://" + s.Location
+	}
+	if s.Type == AgentSourceFile {
+		return "file://" + s.Location
+	}
+	location, err := url.Parse(s.Location)
+	if err != nil {
+		return s.Location
+	}
+	// the credentials of the mirror aren't logged
+	return location.Redacted()
+}
+
+// parseAgentSources parses a comma separated list of sources, tried in
+// order. Each source is "s3" for the buckets of the Agent, an s3://bucket,
+// an http(s):// base URL or a file:// directory, optionally followed by
+// "?timeout=<duration>", and "&ca=<file>" for an HTTPS mirror.
+func parseAgentSources(value string) ([]AgentSource, error) {
+	var sources []AgentSource
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		source, err := parseAgentSource(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source %q: %v", item, err)
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("expected at least one source")
+	}
+	return sources, nil
+}
+
+func parseAgentSource(item string) (AgentSource, error) {
+	source := AgentSource{Timeout: defaultAgentSourceTimeout}
+	location, err := url.Parse(item)
+	if err != nil {
+		return source, err
+	}
+	query := location.Query()
+	if timeout := query.Get(sourceTimeoutParameter); timeout != "" {
+		source.Timeout, err = time.ParseDuration(timeout)
+		if err != nil || source.Timeout <= 0 {
+			return source, fmt.Errorf("expected a positive timeout such as \"2m\"")
+		}
+	}
+	query.Del(sourceTimeoutParameter)
+	source.CABundle = query.Get(sourceCABundleParameter)
+	query.Del(sourceCABundleParameter)
+
+	switch location.Scheme {
+	case "":
+		if location.Path != AgentSourceS3 {
+			return source, fmt.Errorf("expected s3, s3://<bucket>, http(s)://<url> or file://<directory>")
+		}
+		source.Type = AgentSourceS3
+	case "s3":
+		source.Type = AgentSourceS3
+		source.Location = location.Host
+		if source.Location == "" || strings.Trim(location.Path, "/") != "" {
+			return source, fmt.Errorf("expected s3://<bucket>")
+		}
+	case "http", "https":
+		source.Type = AgentSourceHTTP
+		if location.Host == "" {
+			return source, fmt.Errorf("expected a host")
+		}
+		location.RawQuery = query.Encode()
+		source.Location = location.String()
+	case "file":
+		source.Type = AgentSourceFile
+		source.Location = location.Path
+		if location.Host != "" || !filepath.IsAbs(source.Location) {
+			return source, fmt.Errorf("expected file:// followed by an absolute path")
+		}
+	default:
+		return source, fmt.Errorf("unsupported scheme %s", location.Scheme)
+	}
+	if source.CABundle != "" && location.Scheme != "https" {
+		return source, fmt.Errorf("a CA bundle is only supported for https mirrors")
+	}
+	if len(query) > 0 && source.Type != AgentSourceHTTP {
+		return source, fmt.Errorf("unsupported parameters %s", query.Encode())
+	}
+	return source, nil
+}
@@ -14,17 +14,12 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"runtime"
-	"strings"
-
-	"github.com/cihub/seelog"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/endpoints"
-	godocker "github.com/fsouza/go-dockerclient"
 	"github.com/pkg/errors"
 )
 
@@ -32,6 +27,14 @@ const (
 	// AgentImageName is the name of the Docker image containing the Agent
 	AgentImageName = "amazon/amazon-ecs-agent:latest"
 
+	// AgentKnownGoodImageName is the tag given to the last Agent image that
+	// was running before an upgrade, so that the upgrade can be rolled back
+	AgentKnownGoodImageName = "amazon/amazon-ecs-agent:known-good"
+
+	// AgentIntrospectionMetadataURL is the introspection endpoint of the
+	// Agent that reports its metadata once it is up
+	AgentIntrospectionMetadataURL = "http://localhost:51678/v1/metadata"
+
 	// AgentContainerName is the name of the Agent container started by this program
 	AgentContainerName = "ecs-agent"
 
@@ -44,8 +47,9 @@ const (
 	ProcFS = "/proc"
 
 	// DefaultAgentVersion is the version of the agent that will be
-	// fetched if required. This should look like v1.2.3 or an
-	// 8-character sha, as is downloadable from S3.
+	// fetched if required, unless ECS_INIT_AGENT_VERSION is set. This
+	// should look like v1.2.3 or an 8-character sha, as is downloadable
+	// from S3.
 	DefaultAgentVersion = "v1.63.1"
 
 	// AgentPartitionBucketName is the name of the paritional s3 bucket that stores the agent
@@ -93,6 +97,152 @@ const (
 
 	// DefaultRegionEnvVar is the environment variable for specifying the default AWS region to use.
 	DefaultRegionEnvVar = "AWS_DEFAULT_REGION"
+
+	// runPrivilegedEnvVar is the environment variable that runs the Agent
+	// container with --privileged
+	runPrivilegedEnvVar = "ECS_AGENT_RUN_PRIVILEGED"
+	// skipLocalhostTrafficFilterEnvVar is the environment variable that
+	// leaves out the iptables rule dropping non-local traffic to localhost
+	skipLocalhostTrafficFilterEnvVar = "ECS_SKIP_LOCALHOST_TRAFFIC_FILTER"
+	// offhostIntrospectionAccessEnvVar is the environment variable that
+	// leaves out the iptables rule blocking off-host access to the Agent
+	// introspection endpoint
+	offhostIntrospectionAccessEnvVar = "ECS_ALLOW_OFFHOST_INTROSPECTION_ACCESS"
+	// offhostIntrospectionInterfaceEnvVar is the environment variable that
+	// sets the network interface on which off-host access to the Agent
+	// introspection endpoint is blocked
+	offhostIntrospectionInterfaceEnvVar = "ECS_OFFHOST_INTROSPECTION_INTERFACE_NAME"
+
+	// preflightChecksEnvVar is the environment variable that enables the
+	// doctor checks at the beginning of pre-start.
+	preflightChecksEnvVar = "ECS_INIT_PREFLIGHT_CHECKS"
+
+	// restartHealthyUptimeEnvVar is the environment variable that may be
+	// used to override how long the Agent has to stay up for its restart
+	// backoff to be reset
+	restartHealthyUptimeEnvVar  = "ECS_INIT_RESTART_HEALTHY_UPTIME"
+	defaultRestartHealthyUptime = 10 * time.Minute
+	// restartMaxFailuresEnvVar is the environment variable that may be used
+	// to override the number of Agent failures within the failure window
+	// after which ecs-init stops restarting the Agent. 0 disables the limit.
+	restartMaxFailuresEnvVar  = "ECS_INIT_RESTART_MAX_FAILURES"
+	defaultRestartMaxFailures = 20
+	// restartFailureWindowEnvVar is the environment variable that may be
+	// used to override the window in which Agent failures are counted
+	restartFailureWindowEnvVar  = "ECS_INIT_RESTART_FAILURE_WINDOW"
+	defaultRestartFailureWindow = 30 * time.Minute
+
+	// upgradeProbationPeriodEnvVar is the environment variable that may be
+	// used to override how long an upgraded Agent is watched before it is
+	// considered good
+	upgradeProbationPeriodEnvVar  = "ECS_INIT_UPGRADE_PROBATION_PERIOD"
+	defaultUpgradeProbationPeriod = 5 * time.Minute
+	// upgradeProbationIntrospectionEnvVar is the environment variable that
+	// enables probing the introspection endpoint of an upgraded Agent
+	upgradeProbationIntrospectionEnvVar = "ECS_INIT_UPGRADE_PROBATION_INTROSPECTION"
+
+	// agentStopTimeoutEnvVar is the environment variable that may be used
+	// to override how long the Agent is given to stop gracefully before it
+	// is killed
+	agentStopTimeoutEnvVar  = "ECS_INIT_AGENT_STOP_TIMEOUT"
+	defaultAgentStopTimeout = 10 * time.Second
+
+	// notifyReadyOnIntrospectionEnvVar is the environment variable that
+	// delays the systemd readiness notification until the Agent answers on
+	// its introspection endpoint
+	notifyReadyOnIntrospectionEnvVar = "ECS_INIT_NOTIFY_READY_ON_INTROSPECTION"
+
+	// agentLivenessProbeEnvVar is the environment variable that enables
+	// probing the introspection endpoint of the running Agent
+	agentLivenessProbeEnvVar = "ECS_INIT_AGENT_LIVENESS_PROBE"
+	// agentLivenessIntervalEnvVar is the environment variable that may be
+	// used to override the interval between two liveness probes
+	agentLivenessIntervalEnvVar  = "ECS_INIT_AGENT_LIVENESS_INTERVAL"
+	defaultAgentLivenessInterval = 30 * time.Second
+	// agentLivenessGracePeriodEnvVar is the environment variable that may be
+	// used to override how long the Agent is given to start answering before
+	// it is probed
+	agentLivenessGracePeriodEnvVar  = "ECS_INIT_AGENT_LIVENESS_GRACE_PERIOD"
+	defaultAgentLivenessGracePeriod = 2 * time.Minute
+	// agentLivenessFailuresEnvVar is the environment variable that may be
+	// used to override the number of liveness probes in a row the Agent may
+	// fail before it is restarted
+	agentLivenessFailuresEnvVar  = "ECS_INIT_AGENT_LIVENESS_FAILURES"
+	defaultAgentLivenessFailures = 3
+
+	// crashBundleMaxCountEnvVar is the environment variable that may be
+	// used to override the number of crash bundles kept in the log
+	// directory. 0 disables the limit.
+	crashBundleMaxCountEnvVar  = "ECS_INIT_CRASH_BUNDLE_MAX_COUNT"
+	defaultCrashBundleMaxCount = 5
+	// crashBundleMaxTotalSizeEnvVar is the environment variable that may be
+	// used to override the size in MB that the crash bundles kept in the log
+	// directory may take up together. 0 disables the limit.
+	crashBundleMaxTotalSizeEnvVar  = "ECS_INIT_CRASH_BUNDLE_MAX_TOTAL_MB"
+	defaultCrashBundleMaxTotalSize = 100
+
+	// metricsListenAddressEnvVar is the environment variable that sets the
+	// loopback address on which the supervising process serves metrics
+	metricsListenAddressEnvVar = "ECS_INIT_METRICS_LISTEN_ADDRESS"
+	// metricsTextfileEnvVar is the environment variable that sets the path
+	// of a node_exporter textfile that metrics are written to
+	metricsTextfileEnvVar = "ECS_INIT_METRICS_TEXTFILE"
+
+	// configReloadEnvVar is the environment variable that sets what the
+	// supervising process does when the configuration files change
+	configReloadEnvVar = "ECS_INIT_CONFIG_RELOAD"
+	// ConfigReloadLog logs that the Agent has to be restarted to apply
+	// configuration changes
+	ConfigReloadLog = "log"
+	// ConfigReloadRestart recreates the Agent container to apply
+	// configuration changes
+	ConfigReloadRestart = "restart"
+	// ConfigReloadNone doesn't watch the configuration files
+	ConfigReloadNone = "none"
+
+	// agentImageReferenceEnvVar is the environment variable that sets the
+	// registry reference the Agent image is pulled from instead of being
+	// downloaded as a tarball
+	agentImageReferenceEnvVar = "ECS_INIT_AGENT_IMAGE_REFERENCE"
+	// agentImageAuthFileEnvVar is the environment variable that sets the
+	// Docker config.json holding the credentials of the registry
+	agentImageAuthFileEnvVar = "ECS_INIT_AGENT_IMAGE_AUTH_FILE"
+
+	// agentVersionEnvVar is the environment variable that pins the version
+	// of the Agent downloaded from S3 instead of DefaultAgentVersion
+	agentVersionEnvVar = "ECS_INIT_AGENT_VERSION"
+	// agentMinVersionEnvVar is the environment variable that sets the
+	// oldest version of the Agent ecs-init may start
+	agentMinVersionEnvVar = "ECS_INIT_AGENT_MIN_VERSION"
+
+	// agentSignaturePolicyEnvVar is the environment variable that sets what
+	// happens to Agent images without a detached signature
+	agentSignaturePolicyEnvVar = "ECS_INIT_AGENT_SIGNATURE_POLICY"
+	// SignaturePolicyRequire refuses to load unsigned Agent images
+	SignaturePolicyRequire = "require"
+	// SignaturePolicyWarn loads unsigned Agent images with a warning
+	SignaturePolicyWarn = "warn"
+	// SignaturePolicyAccept loads unsigned Agent images
+	SignaturePolicyAccept = "accept"
+	// agentTrustedKeysEnvVar is the environment variable that sets the
+	// directory of the OpenPGP public keys trusted to sign Agent images
+	agentTrustedKeysEnvVar = "ECS_INIT_AGENT_TRUSTED_KEYS"
+	// agentMD5FallbackEnvVar is the environment variable that sets whether
+	// the Agent downloaded from S3 is checked against its MD5 checksum when
+	// its version has no SHA-256 manifest
+	agentMD5FallbackEnvVar = "ECS_INIT_AGENT_MD5_FALLBACK"
+
+	// downloadRetriesEnvVar is the environment variable that sets how many
+	// more rounds of attempts are made once a download from every source
+	// failed
+	downloadRetriesEnvVar  = "ECS_INIT_DOWNLOAD_RETRIES"
+	defaultDownloadRetries = 5
+	// downloadBandwidthLimitEnvVar is the environment variable that limits
+	// the bandwidth of the downloads, in kilobytes per second
+	downloadBandwidthLimitEnvVar = "ECS_INIT_DOWNLOAD_BANDWIDTH_LIMIT_KB"
+	// agentSourcesEnvVar is the environment variable that sets the ordered
+	// list of the places the Agent is downloaded from
+	agentSourcesEnvVar = "ECS_INIT_AGENT_SOURCES"
 )
 
 // partitionBucketRegion provides the "partitional" bucket region
@@ -145,11 +295,23 @@ func AgentConfigFile() string {
 	return AgentConfigDirectory() + "/ecs.config"
 }
 
+// AgentConfigDropInDirectory returns the location of the drop-in files of
+// AgentConfigFile
+func AgentConfigDropInDirectory() string {
+	return AgentConfigFile() + ".d"
+}
+
 // AgentJSONConfigFile returns the location of a file containing configuration expressed in JSON
 func AgentJSONConfigFile() string {
 	return AgentConfigDirectory() + "/ecs.config.json"
 }
 
+// AgentExitPolicyFile returns the location of a file containing the policy
+// that maps exit codes of the Agent to the actions ecs-init takes
+func AgentExitPolicyFile() string {
+	return AgentConfigDirectory() + "/exit-code-policy.json"
+}
+
 // LogDirectory returns the location on disk where logs should be placed
 func LogDirectory() string {
 	return directoryPrefix + "/var/log/ecs"
@@ -159,6 +321,12 @@ func initLogFile() string {
 	return LogDirectory() + "/ecs-init.log"
 }
 
+// EventJournalFile returns the location on disk of the journal of lifecycle
+// events
+func EventJournalFile() string {
+	return LogDirectory() + "/ecs-init-events.log"
+}
+
 // AgentDataDirectory returns the location on disk where state should be saved
 func AgentDataDirectory() string {
 	return directoryPrefix + "/var/lib/ecs/data"
@@ -179,9 +347,19 @@ func AgentTarball() string {
 	return CacheDirectory() + "/ecs-agent.tar"
 }
 
-// AgentRemoteTarballKey is the remote filename of the Agent image, used for populating the cache
-func AgentRemoteTarballKey() (string, error) {
-	name, err := agentArtifactName(DefaultAgentVersion, goarch)
+// AgentVersionTarball returns the location on disk of the cached Agent image
+// of version. Versions are cached side by side, except for
+// DefaultAgentVersion, which is the one installed at AgentTarball.
+func AgentVersionTarball(version string) string {
+	if version == DefaultAgentVersion {
+		return AgentTarball()
+	}
+	return CacheDirectory() + "/ecs-agent-" + version + ".tar"
+}
+
+// AgentRemoteTarballKey is the remote filename of the Agent image of version, used for populating the cache
+func AgentRemoteTarballKey(version string) (string, error) {
+	name, err := agentArtifactName(version, goarch)
 	if err != nil {
 		return "", errors.Wrap(err, "no artifact available")
 	}
@@ -189,27 +367,56 @@ func AgentRemoteTarballKey() (string, error) {
 }
 
 // AgentRemoteTarballMD5Key is the remote file of a md5sum used to verify the integrity of the AgentRemoteTarball
-func AgentRemoteTarballMD5Key() (string, error) {
-	tarballKey, err := AgentRemoteTarballKey()
+// when its version has no manifest
+func AgentRemoteTarballMD5Key(version string) (string, error) {
+	tarballKey, err := AgentRemoteTarballKey(version)
 	if err != nil {
 		return "", err
 	}
 	return tarballKey + ".md5", nil
 }
 
+// AgentRemoteManifestKey is the remote file of the SHA-256 digests of the Agent images of version, one per
+// architecture, used to verify the integrity of the AgentRemoteTarball
+func AgentRemoteManifestKey(version string) string {
+	return fmt.Sprintf("ecs-agent-%s.sha256", version)
+}
+
+// AgentRemoteSignatureKey is the remote filename of the detached OpenPGP signature of the Agent image of version
+func AgentRemoteSignatureKey(version string) (string, error) {
+	name, err := AgentRemoteTarballKey(version)
+	if err != nil {
+		return "", err
+	}
+	return name + ".asc", nil
+}
+
+// TrustedKeysDirectory returns the location on disk of the OpenPGP public keys trusted to sign Agent images
+func TrustedKeysDirectory() string {
+	return AgentConfigDirectory() + "/trusted-keys.d"
+}
+
 // DesiredImageLocatorFile returns the location on disk of a well-known file describing an Agent image to load
 func DesiredImageLocatorFile() string {
 	return CacheDirectory() + "/desired-image"
 }
 
-// DockerUnixSocket returns the docker socket endpoint and whether it's read from DockerHostEnvVar
-func DockerUnixSocket() (string, bool) {
-	if dockerHost := os.Getenv(DockerHostEnvVar); strings.HasPrefix(dockerHost, UnixSocketPrefix) {
-		return strings.TrimPrefix(dockerHost, UnixSocketPrefix), true
-	}
-	// return /var/run instead of /var/run/docker.sock, in case the /var/run/docker.sock is deleted and recreated
-	// outside the container, eg: Docker daemon restart
-	return "/var/run", false
+// ControlSocket returns the path of the control socket of the ecs-init
+// process supervising the Agent
+func ControlSocket() string {
+	return directoryPrefix + "/var/run/ecs-init/control.sock"
+}
+
+// MetricsStateFile returns the location on disk where metrics are persisted
+// across invocations of ecs-init
+func MetricsStateFile() string {
+	return CacheDirectory() + "/metrics.json"
+}
+
+// RestartHistoryFile returns the location on disk where the exit history of
+// the Agent is stored
+func RestartHistoryFile() string {
+	return CacheDirectory() + "/restart-history.json"
 }
 
 // CgroupMountpoint returns the cgroup mountpoint for the system
@@ -233,49 +440,10 @@ func HostPKIDirPath() string {
 	return hostPKIDirPath
 }
 
-// AgentDockerLogDriverConfiguration returns a LogConfig object
-// suitable for used with the managed container.
-func AgentDockerLogDriverConfiguration() godocker.LogConfig {
-	driver := defaultLogDriver
-	options := parseLogOptions()
-	if envDriver := os.Getenv(agentLogDriverEnvVar); envDriver != "" {
-		if _, ok := validDrivers[envDriver]; ok {
-			driver = envDriver
-		} else {
-			seelog.Warnf("Input value for \"ECS_LOG_DRIVER\" is not a supported log driver, overriding to %s and using default log options", defaultLogDriver)
-			options = nil
-		}
-	}
-	if driver == defaultLogDriver && options == nil {
-		maxSize := dockerJSONLogMaxSize
-		if fromEnv := os.Getenv(dockerJSONLogMaxSizeEnvVar); fromEnv != "" {
-			maxSize = fromEnv
-		}
-		maxFiles := dockerJSONLogMaxFiles
-		if fromEnv := os.Getenv(dockerJSONLogMaxFilesEnvVar); fromEnv != "" {
-			maxFiles = fromEnv
-		}
-		options = map[string]string{
-			"max-size": maxSize,
-			"max-file": maxFiles,
-		}
-	}
-	return godocker.LogConfig{
-		Type:   driver,
-		Config: options,
-	}
-}
-
-func parseLogOptions() map[string]string {
-	opts := os.Getenv(agentLogOptionsEnvVar)
-	logOptsDecoder := json.NewDecoder(strings.NewReader(opts))
-	var logOptions map[string]string
-	err := logOptsDecoder.Decode(&logOptions)
-	// blank string is not a warning
-	if err != io.EOF && err != nil {
-		seelog.Warnf("Invalid format for \"ECS_LOG_OPTS\", expected a json object with string key value. error: %v", err)
-	}
-	return logOptions
+// PlatformHostPKIDirPath returns the CA store path that is expected on the
+// host for the platform ecs-init was built for, whether or not it exists
+func PlatformHostPKIDirPath() string {
+	return hostPKIDirPath
 }
 
 // InstanceConfigDirectory returns the location on disk for custom instance configuration
@@ -288,17 +456,16 @@ func InstanceConfigFile() string {
 	return InstanceConfigDirectory() + "/ecs.config"
 }
 
-// RunPrivileged returns if agent should be invoked with '--privileged'. This is not
-// recommended and may be removed in future versions of amazon-ecs-init.
-func RunPrivileged() bool {
-	envVar := os.Getenv("ECS_AGENT_RUN_PRIVILEGED")
-	return envVar == "true"
+// InstanceConfigDropInDirectory returns the location of the drop-in files of
+// InstanceConfigFile
+func InstanceConfigDropInDirectory() string {
+	return InstanceConfigFile() + ".d"
 }
 
-// RunningInExternal returns whether we are running in external (non-EC2) environment.
-func RunningInExternal() bool {
-	envVar := os.Getenv(ExternalEnvVar)
-	return envVar == "true"
+// CrashBundleDirectory returns the location on disk where crash bundles of
+// the Agent are written
+func CrashBundleDirectory() string {
+	return LogDirectory()
 }
 
 func agentArtifactName(version string, arch string) (string, error) {
@@ -15,41 +15,9 @@ package config
 
 import (
 	"fmt"
-	"os"
-	"reflect"
 	"testing"
-
-	"github.com/stretchr/testify/assert"
 )
 
-func TestDockerUnixSocketWithoutDockerHost(t *testing.T) {
-
-	// Make sure that the env variable is not set
-	os.Unsetenv("DOCKER_HOST")
-
-	dockerUnixSocketSourcePath, fromEnv := DockerUnixSocket()
-
-	if dockerUnixSocketSourcePath != "/var/run" {
-		t.Error("DockerUnixSocket() should be \"/var/run\"")
-	}
-	if fromEnv {
-		t.Error("DockerUnixSocket() should return the default instead of reading from DOCKER_HOST when DOCKER_HOST isn't set")
-	}
-}
-
-func TestDockerUnixSocketWithDockerHost(t *testing.T) {
-	os.Setenv("DOCKER_HOST", "unix:///foo/bar")
-	defer os.Unsetenv("DOCKER_HOST")
-
-	dockerUnixSocketSourcePath, fromEnv := DockerUnixSocket()
-	if dockerUnixSocketSourcePath != "/foo/bar" {
-		t.Error("DockerUnixSocket() should be \"/foo/bar\"")
-	}
-	if !fromEnv {
-		t.Error("DockerUnixSocket() should read from envrionment variable DOCKER_HOST, when DOCKER_HOST is set")
-	}
-}
-
 func TestGetAgentPartitionBucketRegion(t *testing.T) {
 	testCases := []struct {
 		region      string
@@ -85,101 +53,6 @@ func TestGetAgentPartitionBucketRegion(t *testing.T) {
 	}
 }
 
-func TestAgentDockerLogDriverConfiguration(t *testing.T) {
-	resetEnv := func() {
-		os.Unsetenv(agentLogDriverEnvVar)
-		os.Unsetenv(agentLogOptionsEnvVar)
-		os.Unsetenv(dockerJSONLogMaxFilesEnvVar)
-		os.Unsetenv(dockerJSONLogMaxSizeEnvVar)
-	}
-	resetEnv()
-
-	testcases := []struct {
-		name           string
-		envDriver      string
-		envOpts        string
-		envFiles       string
-		envSize        string
-		expectedDriver string
-		expectedOpts   map[string]string
-	}{
-
-		{
-			name:           "all defaults",
-			envDriver:      "",
-			envOpts:        "",
-			envFiles:       "",
-			envSize:        "",
-			expectedDriver: "json-file",
-			expectedOpts:   map[string]string{"max-size": dockerJSONLogMaxSize, "max-file": dockerJSONLogMaxFiles},
-		},
-		{
-			name:           "opts default",
-			envDriver:      "awslogs",
-			envOpts:        "",
-			envFiles:       "1",
-			envSize:        "1m",
-			expectedDriver: "awslogs",
-			expectedOpts:   nil,
-		},
-		{
-			name:           "override empty json opts outside of config",
-			envDriver:      "json-file",
-			envOpts:        "",
-			envFiles:       "1",
-			envSize:        "1m",
-			expectedDriver: "json-file",
-			expectedOpts:   map[string]string{"max-size": "1m", "max-file": "1"},
-		},
-		{
-			name:           "json log options take precedence",
-			envDriver:      "json-file",
-			envOpts:        "{\"max-size\":\"17m\",\"max-file\":\"5\"}",
-			envFiles:       "1",
-			envSize:        "1m",
-			expectedDriver: "json-file",
-			expectedOpts:   map[string]string{"max-size": "17m", "max-file": "5"},
-		},
-		{
-			name:           "malformed opts",
-			envDriver:      "splunk",
-			envOpts:        "{\"loggingOptions\"}",
-			envFiles:       "",
-			envSize:        "",
-			expectedDriver: "splunk",
-			expectedOpts:   nil,
-		},
-		{
-			name:           "invalid driver",
-			envDriver:      "invalidDriver",
-			envOpts:        "{\"max-size\":\"17m\",\"max-file\":\"5\"}",
-			envFiles:       "",
-			envSize:        "",
-			expectedDriver: "json-file",
-			expectedOpts:   map[string]string{"max-size": dockerJSONLogMaxSize, "max-file": dockerJSONLogMaxFiles},
-		},
-	}
-
-	for _, test := range testcases {
-		t.Run(test.name, func(t *testing.T) {
-			resetEnv()
-
-			os.Setenv(agentLogDriverEnvVar, test.envDriver)
-			os.Setenv(agentLogOptionsEnvVar, test.envOpts)
-			os.Setenv(dockerJSONLogMaxFilesEnvVar, test.envFiles)
-			os.Setenv(dockerJSONLogMaxSizeEnvVar, test.envSize)
-
-			result := AgentDockerLogDriverConfiguration()
-			if actual := result.Type; actual != test.expectedDriver {
-				t.Errorf("Configured log driver %q is not the expected %q", actual, test.expectedDriver)
-			}
-			if actual := result.Config; !reflect.DeepEqual(actual, test.expectedOpts) {
-				t.Errorf("Configured log options %v is not the expected %v", actual, test.expectedOpts)
-			}
-		})
-	}
-}
-
 func TestAgentRemoteTarballKey(t *testing.T) {
 	testcases := []struct {
 		arch        string
@@ -207,7 +80,7 @@ func TestAgentRemoteTarballKey(t *testing.T) {
 		t.Run(test.arch, func(t *testing.T) {
 			goarch = test.arch
 
-			actual, err := AgentRemoteTarballKey()
+			actual, err := AgentRemoteTarballKey(DefaultAgentVersion)
 			if err == nil && test.shouldError {
 				t.Fatal("expected error when trying to get tarball key")
 			}
@@ -221,35 +94,12 @@ func TestAgentRemoteTarballKey(t *testing.T) {
 	}
 }
 
-func TestAgentPrivileged(t *testing.T) {
-	os.Setenv("ECS_AGENT_RUN_PRIVILEGED", "true")
-	defer os.Unsetenv("ECS_AGENT_RUN_PRIVILEGED")
-
-	if !RunPrivileged() {
-		t.Fatalf("Agent was expected to be running with privileged mode")
-	}
-}
-
-func TestAgentPrivilegedNotConfigured(t *testing.T) {
-	defer os.Unsetenv("ECS_AGENT_RUN_PRIVILEGED")
-	cases := []string{
-		"false",
-		"unrelated_word",
-		"1",
-		"",
+func TestAgentVersionTarball(t *testing.T) {
+	if actual := AgentVersionTarball(DefaultAgentVersion); actual != AgentTarball() {
+		t.Errorf("expected the default version to be cached at %q, got %q", AgentTarball(), actual)
 	}
-
-	for _, test := range cases {
-		os.Setenv("ECS_AGENT_RUN_PRIVILEGED", test)
-
-		if RunPrivileged() {
-			t.Errorf("Agent was expected to be running without privileged mode. Testcase (%s)", test)
-		}
+	expected := CacheDirectory() + "/ecs-agent-v1.60.0.tar"
+	if actual := AgentVersionTarball("v1.60.0"); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
 	}
 }
-
-func TestAgentRunningInExternal(t *testing.T) {
-	os.Setenv(ExternalEnvVar, "true")
-	defer os.Unsetenv(ExternalEnvVar)
-	assert.True(t, RunningInExternal())
-}
@@ -0,0 +1,595 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+	godocker "github.com/fsouza/go-dockerclient"
+)
+
+// Config holds the settings of ecs-init itself. It is loaded once by Load
+// and passed to the packages that need it, rather than each of them reading
+// the environment.
+type Config struct {
+	// DockerHost is the endpoint of the Docker daemon
+	DockerHost string
+	// LogDriver is the log driver of the Agent container
+	LogDriver string
+	// LogOptions are the options of LogDriver
+	LogOptions map[string]string
+	// DockerLogFileSize and DockerLogFileNum bound the json-file logs of
+	// the Agent container when LogOptions aren't set
+	DockerLogFileSize string
+	DockerLogFileNum  string
+	// RunPrivileged runs the Agent container with --privileged
+	RunPrivileged bool
+	// External is set when running outside of EC2
+	External bool
+	// DefaultRegion is the AWS region when running outside of EC2
+	DefaultRegion string
+
+	// SkipLocalhostTrafficFilter leaves out the iptables rule dropping
+	// non-local traffic to localhost
+	SkipLocalhostTrafficFilter bool
+	// AllowOffhostIntrospectionAccess leaves out the iptables rule
+	// blocking off-host access to the Agent introspection endpoint
+	AllowOffhostIntrospectionAccess bool
+	// OffhostIntrospectionInterface is the network interface on which
+	// off-host access to the introspection endpoint is blocked. The
+	// interface of the default route is used if empty.
+	OffhostIntrospectionInterface string
+
+	PreflightChecks               bool
+	RestartHealthyUptime          time.Duration
+	RestartMaxFailures            int
+	RestartFailureWindow          time.Duration
+	UpgradeProbationPeriod        time.Duration
+	UpgradeProbationIntrospection bool
+	AgentStopTimeout              time.Duration
+	NotifyReadyOnIntrospection    bool
+	AgentLivenessProbe            bool
+	AgentLivenessInterval         time.Duration
+	AgentLivenessGracePeriod      time.Duration
+	AgentLivenessFailures         int
+	CrashBundleMaxCount           int
+	// CrashBundleMaxTotalSize is in bytes
+	CrashBundleMaxTotalSize int64
+	MetricsListenAddress    string
+	MetricsTextfile         string
+	// ConfigReload is one of ConfigReloadLog, ConfigReloadRestart and
+	// ConfigReloadNone
+	ConfigReload string
+	// AgentImageReference is the registry reference the Agent image is
+	// pulled from, optionally pinned to a digest. The image is downloaded
+	// from S3 if it's empty.
+	AgentImageReference string
+	// AgentImageAuthFile is the Docker config.json holding the credentials
+	// of the registry. The default Docker config.json is used if it's empty.
+	AgentImageAuthFile string
+	// AgentVersion is the version of the Agent downloaded from S3
+	AgentVersion string
+	// AgentMinVersion is the oldest version of the Agent that may be
+	// started, none if it's empty
+	AgentMinVersion string
+	// AgentSignaturePolicy is one of SignaturePolicyRequire,
+	// SignaturePolicyWarn and SignaturePolicyAccept
+	AgentSignaturePolicy string
+	// AgentTrustedKeys is the directory of the OpenPGP public keys trusted
+	// to sign Agent images
+	AgentTrustedKeys string
+	// AgentMD5Fallback checks the Agent downloaded from S3 against its MD5
+	// checksum when its version has no SHA-256 manifest
+	AgentMD5Fallback bool
+	// DownloadRetries is how many more rounds of attempts are made once a
+	// download from every source failed
+	DownloadRetries int
+	// DownloadBandwidthLimit is in bytes per second, unlimited if it's 0
+	DownloadBandwidthLimit int64
+	// AgentSources are the places the Agent is downloaded from, tried in
+	// order
+	AgentSources []AgentSource
+}
+
+// Defaults returns the configuration of ecs-init when nothing is set
+func Defaults() *Config {
+	return &Config{
+		LogDriver:                defaultLogDriver,
+		DockerLogFileSize:        dockerJSONLogMaxSize,
+		DockerLogFileNum:         dockerJSONLogMaxFiles,
+		RestartHealthyUptime:     defaultRestartHealthyUptime,
+		RestartMaxFailures:       defaultRestartMaxFailures,
+		RestartFailureWindow:     defaultRestartFailureWindow,
+		UpgradeProbationPeriod:   defaultUpgradeProbationPeriod,
+		AgentStopTimeout:         defaultAgentStopTimeout,
+		AgentLivenessInterval:    defaultAgentLivenessInterval,
+		AgentLivenessGracePeriod: defaultAgentLivenessGracePeriod,
+		AgentLivenessFailures:    defaultAgentLivenessFailures,
+		CrashBundleMaxCount:      defaultCrashBundleMaxCount,
+		CrashBundleMaxTotalSize:  defaultCrashBundleMaxTotalSize * 1024 * 1024,
+		ConfigReload:             ConfigReloadLog,
+		AgentVersion:             DefaultAgentVersion,
+		AgentSignaturePolicy:     SignaturePolicyWarn,
+		AgentTrustedKeys:         TrustedKeysDirectory(),
+		AgentMD5Fallback:         true,
+		DownloadRetries:          defaultDownloadRetries,
+		AgentSources:             []AgentSource{{Type: AgentSourceS3, Timeout: defaultAgentSourceTimeout}},
+	}
+}
+
+// setting parses the value of the variable name into the configuration
+type setting struct {
+	name string
+	// set returns why the value is invalid, if it is
+	set func(c *Config, value string) error
+}
+
+var settings = []setting{
+	stringSetting(DockerHostEnvVar, func(c *Config) *string { return &c.DockerHost }),
+	{agentLogDriverEnvVar, func(c *Config, value string) error {
+		if _, ok := validDrivers[value]; !ok {
+			return fmt.Errorf("expected a supported log driver such as %s", defaultLogDriver)
+		}
+		c.LogDriver = value
+		return nil
+	}},
+	{agentLogOptionsEnvVar, func(c *Config, value string) error {
+		var options map[string]string
+		err := json.Unmarshal([]byte(value), &options)
+		if err != nil {
+			return fmt.Errorf("expected a JSON object with string values: %v", err)
+		}
+		c.LogOptions = options
+		return nil
+	}},
+	stringSetting(dockerJSONLogMaxSizeEnvVar, func(c *Config) *string { return &c.DockerLogFileSize }),
+	stringSetting(dockerJSONLogMaxFilesEnvVar, func(c *Config) *string { return &c.DockerLogFileNum }),
+	exactBoolSetting(runPrivilegedEnvVar, func(c *Config) *bool { return &c.RunPrivileged }),
+	exactBoolSetting(ExternalEnvVar, func(c *Config) *bool { return &c.External }),
+	stringSetting(DefaultRegionEnvVar, func(c *Config) *string { return &c.DefaultRegion }),
+	boolSetting(skipLocalhostTrafficFilterEnvVar, func(c *Config) *bool { return &c.SkipLocalhostTrafficFilter }),
+	boolSetting(offhostIntrospectionAccessEnvVar, func(c *Config) *bool { return &c.AllowOffhostIntrospectionAccess }),
+	stringSetting(offhostIntrospectionInterfaceEnvVar, func(c *Config) *string { return &c.OffhostIntrospectionInterface }),
+	boolSetting(preflightChecksEnvVar, func(c *Config) *bool { return &c.PreflightChecks }),
+	durationSetting(restartHealthyUptimeEnvVar, func(c *Config) *time.Duration { return &c.RestartHealthyUptime }),
+	intSetting(restartMaxFailuresEnvVar, 0, func(c *Config) *int { return &c.RestartMaxFailures }),
+	durationSetting(restartFailureWindowEnvVar, func(c *Config) *time.Duration { return &c.RestartFailureWindow }),
+	durationSetting(upgradeProbationPeriodEnvVar, func(c *Config) *time.Duration { return &c.UpgradeProbationPeriod }),
+	boolSetting(upgradeProbationIntrospectionEnvVar, func(c *Config) *bool { return &c.UpgradeProbationIntrospection }),
+	durationSetting(agentStopTimeoutEnvVar, func(c *Config) *time.Duration { return &c.AgentStopTimeout }),
+	boolSetting(notifyReadyOnIntrospectionEnvVar, func(c *Config) *bool { return &c.NotifyReadyOnIntrospection }),
+	boolSetting(agentLivenessProbeEnvVar, func(c *Config) *bool { return &c.AgentLivenessProbe }),
+	durationSetting(agentLivenessIntervalEnvVar, func(c *Config) *time.Duration { return &c.AgentLivenessInterval }),
+	durationSetting(agentLivenessGracePeriodEnvVar, func(c *Config) *time.Duration { return &c.AgentLivenessGracePeriod }),
+	intSetting(agentLivenessFailuresEnvVar, 1, func(c *Config) *int { return &c.AgentLivenessFailures }),
+	intSetting(crashBundleMaxCountEnvVar, 0, func(c *Config) *int { return &c.CrashBundleMaxCount }),
+	{crashBundleMaxTotalSizeEnvVar, func(c *Config, value string) error {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return fmt.Errorf("expected a non-negative number of megabytes")
+		}
+		c.CrashBundleMaxTotalSize = int64(size) * 1024 * 1024
+		return nil
+	}},
+	stringSetting(metricsListenAddressEnvVar, func(c *Config) *string { return &c.MetricsListenAddress }),
+	stringSetting(metricsTextfileEnvVar, func(c *Config) *string { return &c.MetricsTextfile }),
+	{configReloadEnvVar, func(c *Config, value string) error {
+		switch value {
+		case ConfigReloadLog, ConfigReloadRestart, ConfigReloadNone:
+			c.ConfigReload = value
+			return nil
+		}
+		return fmt.Errorf("expected one of %s, %s, %s", ConfigReloadLog, ConfigReloadRestart, ConfigReloadNone)
+	}},
+	{agentImageReferenceEnvVar, func(c *Config, value string) error {
+		if !imageReferencePattern.MatchString(value) {
+			return fmt.Errorf("expected an image reference such as \"registry/repository:tag\" or \"registry/repository@sha256:<digest>\"")
+		}
+		c.AgentImageReference = value
+		return nil
+	}},
+	stringSetting(agentImageAuthFileEnvVar, func(c *Config) *string { return &c.AgentImageAuthFile }),
+	{agentVersionEnvVar, func(c *Config, value string) error {
+		if !agentVersionPattern.MatchString(value) && !agentCommitPattern.MatchString(value) {
+			return fmt.Errorf("expected a version such as \"%s\" or an 8-character commit", DefaultAgentVersion)
+		}
+		c.AgentVersion = value
+		return nil
+	}},
+	{agentMinVersionEnvVar, func(c *Config, value string) error {
+		if !agentVersionPattern.MatchString(value) {
+			return fmt.Errorf("expected a version such as \"%s\"", DefaultAgentVersion)
+		}
+		c.AgentMinVersion = value
+		return nil
+	}},
+	{agentSignaturePolicyEnvVar, func(c *Config, value string) error {
+		switch value {
+		case SignaturePolicyRequire, SignaturePolicyWarn, SignaturePolicyAccept:
+			c.AgentSignaturePolicy = value
+			return nil
+		}
+		return fmt.Errorf("expected one of %s, %s, %s", SignaturePolicyRequire, SignaturePolicyWarn, SignaturePolicyAccept)
+	}},
+	stringSetting(agentTrustedKeysEnvVar, func(c *Config) *string { return &c.AgentTrustedKeys }),
+	boolSetting(agentMD5FallbackEnvVar, func(c *Config) *bool { return &c.AgentMD5Fallback }),
+	intSetting(downloadRetriesEnvVar, 0, func(c *Config) *int { return &c.DownloadRetries }),
+	{downloadBandwidthLimitEnvVar, func(c *Config, value string) error {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("expected a non-negative number of kilobytes per second")
+		}
+		c.DownloadBandwidthLimit = int64(limit) * 1024
+		return nil
+	}},
+	{agentSourcesEnvVar, func(c *Config, value string) error {
+		sources, err := parseAgentSources(value)
+		if err != nil {
+			return err
+		}
+		c.AgentSources = sources
+		return nil
+	}},
+}
+
+// imageReferencePattern matches the image references that can be pulled: a
+// lowercase repository, optionally prefixed with a registry host and port,
+// followed by a tag and/or a sha256 digest. Like Docker, the first component
+// is only a registry host if it's localhost or has a dot or a port.
+var imageReferencePattern = regexp.MustCompile(`^` +
+	`((localhost|[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)+)(:[0-9]+)?/|[a-zA-Z0-9-]+:[0-9]+/)?` +
+	`[a-z0-9]+([._/-]+[a-z0-9]+)*` +
+	`(:[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+
+func stringSetting(name string, field func(c *Config) *string) setting {
+	return setting{name, func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+func boolSetting(name string, field func(c *Config) *bool) setting {
+	return setting{name, func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+// exactBoolSetting only enables a setting that is exactly "true", the way
+// ecs-init always read it, so that values such as "1" don't enable it
+func exactBoolSetting(name string, field func(c *Config) *bool) setting {
+	return setting{name, func(c *Config, value string) error {
+		switch value {
+		case "true":
+			*field(c) = true
+		case "false":
+			*field(c) = false
+		default:
+			return fmt.Errorf("expected true or false, only true enables it")
+		}
+		return nil
+	}}
+}
+
+// lenientSettings are the settings ecs-init read before its configuration
+// was validated. Their invalid values only warn and use the default, as they
+// always did, so that a configuration that used to work still starts the
+// Agent.
+var lenientSettings = map[string]bool{
+	DockerHostEnvVar:                    true,
+	agentLogDriverEnvVar:                true,
+	agentLogOptionsEnvVar:               true,
+	dockerJSONLogMaxSizeEnvVar:          true,
+	dockerJSONLogMaxFilesEnvVar:         true,
+	runPrivilegedEnvVar:                 true,
+	ExternalEnvVar:                      true,
+	DefaultRegionEnvVar:                 true,
+	skipLocalhostTrafficFilterEnvVar:    true,
+	offhostIntrospectionAccessEnvVar:    true,
+	offhostIntrospectionInterfaceEnvVar: true,
+}
+
+func durationSetting(name string, field func(c *Config) *time.Duration) setting {
+	return setting{name, func(c *Config, value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("expected a positive duration such as \"10m\"")
+		}
+		*field(c) = duration
+		return nil
+	}}
+}
+
+func intSetting(name string, min int, field func(c *Config) *int) setting {
+	return setting{name, func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil || i < min {
+			return fmt.Errorf("expected an integer of at least %d", min)
+		}
+		*field(c) = i
+		return nil
+	}}
+}
+
+// Source is a set of NAME=value settings
+type Source struct {
+	// Name is where the settings come from, such as the path of a file
+	Name   string
+	Values map[string]string
+	// Lines are the line numbers of the values of a file, starting at 1
+	Lines map[string]int
+}
+
+// EnvironmentSource is the name of the source of the settings of the
+// ecs-init process environment
+const EnvironmentSource = "environment"
+
+// Injection point for testing purposes
+var readConfigFile = ioutil.ReadFile
+
+// Sources returns the sources of the configuration of ecs-init, in
+// increasing order of precedence: the configuration Files that exist, and
+// the environment of the ecs-init process. The Agent configuration file is
+// read even if the init system already passes it as the environment, so that
+// all init systems behave the same. A drop-in directory that can't be read
+// is skipped with a warning, like the Agent does.
+func Sources() ([]*Source, error) {
+	paths, err := Files()
+	if err != nil {
+		log.Warnf("Could not list the drop-in configuration files: %v", err)
+	}
+	var sources []*Source
+	for _, path := range paths {
+		data, err := readConfigFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, FileSource(path, data))
+	}
+	return append(sources, environmentSource(sources)), nil
+}
+
+// FileSource returns the settings of the configuration file at path, the
+// last assignment of a variable taking precedence
+func FileSource(path string, data []byte) *Source {
+	source := &Source{
+		Name:   path,
+		Values: make(map[string]string),
+		Lines:  make(map[string]int),
+	}
+	assignments, _ := ParseEnvironmentFile(data)
+	for _, assignment := range assignments {
+		source.Values[assignment.Name] = assignment.Value
+		source.Lines[assignment.Name] = assignment.Line
+	}
+	return source
+}
+
+// environmentSource returns the environment of the ecs-init process. The
+// EnvironmentFile= of the systemd unit passes the main configuration files
+// as the environment, which would then take precedence over the drop-in
+// files. Variables set to the value a configuration file sets them to are
+// thus left to the files.
+func environmentSource(files []*Source) *Source {
+	environment := map[string]string{}
+	for _, variable := range os.Environ() {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 || setByFile(files, parts[0], parts[1]) {
+			continue
+		}
+		environment[parts[0]] = parts[1]
+	}
+	return &Source{Name: EnvironmentSource, Values: environment}
+}
+
+func setByFile(files []*Source, name, value string) bool {
+	for _, file := range files {
+		if fileValue, ok := file.Values[name]; ok && fileValue == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SettingError is an invalid value of a setting
+type SettingError struct {
+	Name   string
+	Value  string
+	Source string
+	Reason string
+	// Lenient is set for the settings whose invalid values only warn
+	Lenient bool
+}
+
+func (e *SettingError) Error() string {
+	return fmt.Sprintf("invalid value %q for %s in %s: %s", e.Value, e.Name, e.Source, e.Reason)
+}
+
+// SettingErrors are the invalid settings of a configuration
+type SettingErrors []*SettingError
+
+func (e SettingErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Fatal returns true if some of the invalid settings aren't lenient
+func (e SettingErrors) Fatal() bool {
+	for _, err := range e {
+		if !err.Lenient {
+			return true
+		}
+	}
+	return false
+}
+
+// Load loads the configuration of ecs-init from its Sources. The
+// configuration is returned even if some settings are invalid, with these
+// settings left to their defaults, along with a SettingErrors listing them.
+func Load() (*Config, error) {
+	sources, err := Sources()
+	if err != nil {
+		return Defaults(), err
+	}
+	return FromSources(sources)
+}
+
+// FromSources builds the configuration from sources, the latter sources
+// taking precedence. Empty values are ignored, as if they weren't set.
+func FromSources(sources []*Source) (*Config, error) {
+	c := Defaults()
+	var errs SettingErrors
+	for _, s := range settings {
+		value, source := lookup(sources, s.name)
+		if value == "" {
+			continue
+		}
+		err := s.set(c, value)
+		if err != nil {
+			errs = append(errs, &SettingError{
+				Name:    s.name,
+				Value:   value,
+				Source:  source,
+				Reason:  err.Error(),
+				Lenient: lenientSettings[s.name],
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return c, errs
+	}
+	return c, nil
+}
+
+// lookup returns the value of the setting named name from the source of
+// highest precedence that sets it, along with the name of that source
+func lookup(sources []*Source, name string) (string, string) {
+	for i := len(sources) - 1; i >= 0; i-- {
+		if value := sources[i].Values[name]; value != "" {
+			return value, sources[i].Name
+		}
+	}
+	return "", ""
+}
+
+// Origin is the effective value of a variable and where it comes from
+type Origin struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Source is the name of the source setting Value
+	Source string `json:"source,omitempty"`
+	// Line is the line of the file setting Value, starting at 1, or 0 for
+	// the environment
+	Line int `json:"line,omitempty"`
+}
+
+func (o *Origin) String() string {
+	if o.Line == 0 {
+		return o.Source
+	}
+	return fmt.Sprintf("%s:%d", o.Source, o.Line)
+}
+
+// Origins returns the effective variables of sources sorted by name, along
+// with the source of highest precedence setting them. Variables of the
+// environment are only included if they are settings of ecs-init, as the
+// rest of the environment isn't passed to the Agent, and if they aren't
+// empty, as empty values are ignored.
+func Origins(sources []*Source) []*Origin {
+	origins := make(map[string]*Origin)
+	for _, source := range sources {
+		for name, value := range source.Values {
+			if source.Name == EnvironmentSource && (value == "" || !IsSetting(name)) {
+				continue
+			}
+			origins[name] = &Origin{
+				Name:   name,
+				Value:  value,
+				Source: source.Name,
+				Line:   source.Lines[name],
+			}
+		}
+	}
+	sorted := make([]*Origin, 0, len(origins))
+	for _, origin := range origins {
+		sorted = append(sorted, origin)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// IsSetting returns true if name is a setting of ecs-init itself, rather
+// than of the Agent
+func IsSetting(name string) bool {
+	for _, s := range settings {
+		if s.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DockerUnixSocket returns the docker socket endpoint and whether it's read
+// from DockerHost
+func (c *Config) DockerUnixSocket() (string, bool) {
+	if strings.HasPrefix(c.DockerHost, UnixSocketPrefix) {
+		return strings.TrimPrefix(c.DockerHost, UnixSocketPrefix), true
+	}
+	// return /var/run instead of /var/run/docker.sock, in case the /var/run/docker.sock is deleted and recreated
+	// outside the container, eg: Docker daemon restart
+	return "/var/run", false
+}
+
+// AgentDockerLogDriverConfiguration returns a LogConfig object
+// suitable for used with the managed container.
+func (c *Config) AgentDockerLogDriverConfiguration() godocker.LogConfig {
+	options := c.LogOptions
+	if c.LogDriver == defaultLogDriver && options == nil {
+		options = map[string]string{
+			"max-size": c.DockerLogFileSize,
+			"max-file": c.DockerLogFileNum,
+		}
+	}
+	return godocker.LogConfig{
+		Type:   c.LogDriver,
+		Config: options,
+	}
+}
+
+// MetricsEnabled returns whether metrics are recorded, which is the case if
+// they are either served or written to a textfile
+func (c *Config) MetricsEnabled() bool {
+	return c.MetricsListenAddress != "" || c.MetricsTextfile != ""
+}
@@ -0,0 +1,303 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// exportPrefix is the shell prefix of assignments, which systemd doesn't
+// support
+const exportPrefix = "export "
+
+// validEnvName matches the variable names that systemd accepts
+var validEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Assignment is a NAME=value assignment of an environment file
+type Assignment struct {
+	// Line is the line number on which the assignment starts, starting at 1
+	Line  int
+	Name  string
+	Value string
+}
+
+// Diagnostic is a line of an environment file that is ignored or likely
+// doesn't mean what it was meant to
+type Diagnostic struct {
+	// Line is the line number of the diagnostic, starting at 1
+	Line    int
+	Message string
+}
+
+// ParseEnvironmentFile parses an environment file the way systemd parses the
+// EnvironmentFile= of a unit, so that the Agent gets the same environment
+// whichever init system starts it:
+//
+//   - Lines starting with # or ; are comments. A backslash at the end of a
+//     comment continues it on the next line.
+//   - Whitespace around the name and unquoted values is trimmed.
+//   - Values may be quoted with single quotes, which are taken literally, or
+//     with double quotes, in which \", \\, \` and \$ are escaped. Quoted
+//     values may span lines.
+//   - Outside of quotes, a backslash escapes the next character, and a
+//     backslash at the end of a line continues the value on the next line.
+//   - Both LF and CRLF line endings are supported.
+//
+// Assignments to invalid variable names, including the export prefix of
+// shells, and lines without a = are ignored, like systemd does, and reported
+// along with unterminated quotes as diagnostics.
+func ParseEnvironmentFile(data []byte) ([]*Assignment, []*Diagnostic) {
+	p := &envFileParser{line: 1}
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	for _, c := range data {
+		p.parse(c)
+		if c == '\n' || c == '\r' {
+			p.line++
+		}
+	}
+	p.end()
+	return p.assignments, p.diagnostics
+}
+
+type envFileState int
+
+const (
+	preKeyState envFileState = iota
+	keyState
+	preValueState
+	valueState
+	valueEscapeState
+	singleQuoteState
+	doubleQuoteState
+	doubleQuoteEscapeState
+	commentState
+	commentEscapeState
+)
+
+// envFileParser is a port of the state machine of systemd's
+// parse_env_file_internal
+type envFileParser struct {
+	state envFileState
+	// line is the number of the line being parsed
+	line int
+	// assignmentLine is the line on which the current assignment starts
+	assignmentLine int
+	// quoteLine is the line on which the current quote starts
+	quoteLine int
+	// continuedComment is the line of the comment ending with a backslash
+	// that the current line continues, if any
+	continuedComment int
+	key              []byte
+	// keyEnd is the length of the key without its trailing whitespace
+	keyEnd int
+	value  []byte
+	// valueEnd is the length of the value without its trailing unquoted
+	// whitespace
+	valueEnd    int
+	assignments []*Assignment
+	diagnostics []*Diagnostic
+}
+
+func isNewline(c byte) bool {
+	return c == '\n' || c == '\r'
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || isNewline(c)
+}
+
+func (p *envFileParser) parse(c byte) {
+	switch p.state {
+	case preKeyState:
+		p.preKey(c)
+	case keyState:
+		p.keyChar(c)
+	case preValueState:
+		p.preValue(c)
+	case valueState:
+		p.valueChar(c)
+	case valueEscapeState:
+		p.state = valueState
+		if !isNewline(c) {
+			p.appendValue(c)
+		}
+	case singleQuoteState:
+		p.singleQuote(c)
+	case doubleQuoteState:
+		p.doubleQuote(c)
+	case doubleQuoteEscapeState:
+		p.doubleQuoteEscape(c)
+	case commentState:
+		p.comment(c)
+	case commentEscapeState:
+		p.state = commentState
+		if isNewline(c) {
+			p.continuedComment = p.line
+		}
+	}
+}
+
+func (p *envFileParser) preKey(c byte) {
+	switch {
+	case c == '#' || c == ';':
+		p.state = commentState
+	case !isWhitespace(c):
+		p.state = keyState
+		p.assignmentLine = p.line
+		p.key = append(p.key[:0], c)
+		p.keyEnd = 1
+	}
+}
+
+func (p *envFileParser) keyChar(c byte) {
+	switch {
+	case isNewline(c):
+		p.diagnose(p.assignmentLine, "ignored, expected NAME=value")
+		p.state = preKeyState
+	case c == '=':
+		p.state = preValueState
+		p.key = p.key[:p.keyEnd]
+		p.value = p.value[:0]
+		p.valueEnd = 0
+	default:
+		p.key = append(p.key, c)
+		if !isWhitespace(c) {
+			p.keyEnd = len(p.key)
+		}
+	}
+}
+
+func (p *envFileParser) preValue(c byte) {
+	switch {
+	case isNewline(c):
+		p.push()
+	case isWhitespace(c):
+	case c == '\'':
+		p.state = singleQuoteState
+		p.quoteLine = p.line
+	case c == '"':
+		p.state = doubleQuoteState
+		p.quoteLine = p.line
+	case c == '\\':
+		p.state = valueEscapeState
+	default:
+		p.state = valueState
+		p.appendValue(c)
+	}
+}
+
+func (p *envFileParser) valueChar(c byte) {
+	switch {
+	case isNewline(c):
+		p.push()
+	case c == '\\':
+		p.state = valueEscapeState
+	default:
+		p.value = append(p.value, c)
+		if !isWhitespace(c) {
+			p.valueEnd = len(p.value)
+		}
+	}
+}
+
+func (p *envFileParser) singleQuote(c byte) {
+	if c == '\'' {
+		p.state = preValueState
+		return
+	}
+	p.appendValue(c)
+}
+
+func (p *envFileParser) doubleQuote(c byte) {
+	switch c {
+	case '"':
+		p.state = preValueState
+	case '\\':
+		p.state = doubleQuoteEscapeState
+	default:
+		p.appendValue(c)
+	}
+}
+
+func (p *envFileParser) doubleQuoteEscape(c byte) {
+	p.state = doubleQuoteState
+	switch {
+	case strings.IndexByte("\"\\`$", c) >= 0:
+		p.appendValue(c)
+	case isNewline(c):
+	default:
+		p.appendValue('\\')
+		p.appendValue(c)
+	}
+}
+
+func (p *envFileParser) comment(c byte) {
+	switch {
+	case c == '\\':
+		p.state = commentEscapeState
+	case isNewline(c):
+		p.state = preKeyState
+		p.continuedComment = 0
+	case p.continuedComment != 0 && !isWhitespace(c):
+		p.diagnose(p.line, "ignored, continues the comment ending with a backslash on line %d", p.continuedComment)
+		p.continuedComment = 0
+	}
+}
+
+// appendValue appends a character that isn't trimmed to the value
+func (p *envFileParser) appendValue(c byte) {
+	p.value = append(p.value, c)
+	p.valueEnd = len(p.value)
+}
+
+// end pushes the assignment left unfinished at the end of the file
+func (p *envFileParser) end() {
+	switch p.state {
+	case keyState:
+		p.diagnose(p.assignmentLine, "ignored, expected NAME=value")
+	case singleQuoteState, doubleQuoteState, doubleQuoteEscapeState:
+		p.diagnose(p.quoteLine, "unterminated quote, the value extends to the end of the file")
+		p.push()
+	case preValueState, valueState, valueEscapeState:
+		p.push()
+	}
+}
+
+// push records the current assignment unless systemd would ignore it
+func (p *envFileParser) push() {
+	p.state = preKeyState
+	name := string(p.key)
+	switch {
+	case strings.HasPrefix(name, exportPrefix):
+		p.diagnose(p.assignmentLine, "ignored, systemd doesn't support the export prefix of %s", name)
+	case !validEnvName.MatchString(name):
+		p.diagnose(p.assignmentLine, "ignored, invalid variable name %q", name)
+	case !utf8.Valid(p.value):
+		p.diagnose(p.assignmentLine, "ignored, the value of %s isn't valid UTF-8", name)
+	default:
+		p.assignments = append(p.assignments, &Assignment{
+			Line:  p.assignmentLine,
+			Name:  name,
+			Value: string(p.value[:p.valueEnd]),
+		})
+	}
+}
+
+func (p *envFileParser) diagnose(line int, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, &Diagnostic{Line: line, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,135 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnvironmentFile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        string
+		assignments []*Assignment
+		diagnostics []*Diagnostic
+	}{
+		{
+			name:        "plain",
+			data:        "A=1\nB=two words\n",
+			assignments: []*Assignment{{1, "A", "1"}, {2, "B", "two words"}},
+		},
+		{
+			name:        "whitespace is trimmed",
+			data:        "  A  =  1  \n\tB=\t2\t\n",
+			assignments: []*Assignment{{1, "A", "1"}, {2, "B", "2"}},
+		},
+		{
+			name:        "CRLF",
+			data:        "A=1\r\nB=2\r\n",
+			assignments: []*Assignment{{1, "A", "1"}, {2, "B", "2"}},
+		},
+		{
+			name:        "no trailing newline",
+			data:        "A=1\nB=2",
+			assignments: []*Assignment{{1, "A", "1"}, {2, "B", "2"}},
+		},
+		{
+			name:        "empty value",
+			data:        "A=\nB=  \n",
+			assignments: []*Assignment{{1, "A", ""}, {2, "B", ""}},
+		},
+		{
+			name:        "comments",
+			data:        "# A=1\n; B=2\n  # C=3\nD=4 # not a comment\n",
+			assignments: []*Assignment{{4, "D", "4 # not a comment"}},
+		},
+		{
+			name:        "single quotes",
+			data:        `A='  one \" two  '` + "\n",
+			assignments: []*Assignment{{1, "A", `  one \" two  `}},
+		},
+		{
+			name:        "double quotes",
+			data:        `A="say \"hi\" \\ \$HOME \n"` + "\n",
+			assignments: []*Assignment{{1, "A", `say "hi" \ $HOME \n`}},
+		},
+		{
+			name:        "quotes are concatenated",
+			data:        `A="val2"=val2` + "\nB='a'\"b\"c\n",
+			assignments: []*Assignment{{1, "A", "val2=val2"}, {2, "B", "abc"}},
+		},
+		{
+			name:        "unquoted escapes",
+			data:        `A=a\ \"b\"\\` + "\n",
+			assignments: []*Assignment{{1, "A", `a "b"\`}},
+		},
+		{
+			name:        "line continuation",
+			data:        "A=one \\\ntwo\nB=3\n",
+			assignments: []*Assignment{{1, "A", "one two"}, {3, "B", "3"}},
+		},
+		{
+			name:        "multi-line quotes",
+			data:        "A=\"one\ntwo\"\nB='three\nfour'\nC=\"five \\\nsix\"\n",
+			assignments: []*Assignment{{1, "A", "one\ntwo"}, {3, "B", "three\nfour"}, {5, "C", "five six"}},
+		},
+		{
+			name:        "comment continuation",
+			data:        "# comment \\\nA=1\nB=2\n",
+			assignments: []*Assignment{{3, "B", "2"}},
+			diagnostics: []*Diagnostic{{2, "ignored, continues the comment ending with a backslash on line 1"}},
+		},
+		{
+			name:        "missing equal sign",
+			data:        "not a setting\nA=1\n",
+			assignments: []*Assignment{{2, "A", "1"}},
+			diagnostics: []*Diagnostic{{1, "ignored, expected NAME=value"}},
+		},
+		{
+			name:        "export prefix",
+			data:        "export A=1\n",
+			diagnostics: []*Diagnostic{{1, "ignored, systemd doesn't support the export prefix of export A"}},
+		},
+		{
+			name: "invalid names",
+			data: "=1\n1A=2\nA-B=3\nA B=4\n",
+			diagnostics: []*Diagnostic{
+				{1, "ignored, expected NAME=value"},
+				{2, `ignored, invalid variable name "1A"`},
+				{3, `ignored, invalid variable name "A-B"`},
+				{4, `ignored, invalid variable name "A B"`},
+			},
+		},
+		{
+			name:        "invalid UTF-8",
+			data:        "A=\xff\n",
+			diagnostics: []*Diagnostic{{1, "ignored, the value of A isn't valid UTF-8"}},
+		},
+		{
+			name:        "unterminated quote",
+			data:        "A=1\nB=\"two\nC=3\n",
+			assignments: []*Assignment{{1, "A", "1"}, {2, "B", "two\nC=3\n"}},
+			diagnostics: []*Diagnostic{{2, "unterminated quote, the value extends to the end of the file"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assignments, diagnostics := ParseEnvironmentFile([]byte(tc.data))
+			assert.Equal(t, tc.assignments, assignments)
+			assert.Equal(t, tc.diagnostics, diagnostics)
+		})
+	}
+}
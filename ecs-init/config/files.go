@@ -0,0 +1,93 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dropInExtension is the extension of the files read from a drop-in
+// directory
+const dropInExtension = ".conf"
+
+// Injection point for testing purposes
+var readDropInDirectory = func(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	return names, nil
+}
+
+// InstanceConfigFiles returns InstanceConfigFile followed by the files of
+// InstanceConfigDropInDirectory, in increasing order of precedence
+func InstanceConfigFiles() ([]string, error) {
+	return withDropIns(InstanceConfigFile(), InstanceConfigDropInDirectory())
+}
+
+// AgentConfigFiles returns AgentConfigFile followed by the files of
+// AgentConfigDropInDirectory, in increasing order of precedence
+func AgentConfigFiles() ([]string, error) {
+	return withDropIns(AgentConfigFile(), AgentConfigDropInDirectory())
+}
+
+// Files returns the paths of the configuration files in increasing order of
+// precedence: the instance configuration file and its drop-ins, then the
+// Agent configuration file and its drop-ins. The configuration files are
+// returned whether or not they exist. If a drop-in directory can't be read,
+// the files that could be listed are returned along with the error.
+func Files() ([]string, error) {
+	instanceFiles, instanceErr := InstanceConfigFiles()
+	agentFiles, agentErr := AgentConfigFiles()
+	files := append(instanceFiles, agentFiles...)
+	if instanceErr != nil {
+		return files, instanceErr
+	}
+	return files, agentErr
+}
+
+// withDropIns returns path followed by the *.conf files of the drop-in
+// directory dir in lexical order, so that drop-ins can be numbered like
+// 10-base.conf and 90-user-data.conf. Hidden files are skipped, such as the
+// temporary files of editors.
+func withDropIns(path, dir string) ([]string, error) {
+	files := []string{path}
+	names, err := readDropInDirectory(dir)
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return files, errors.Wrapf(err, "could not read drop-in directory %s", dir)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") || filepath.Ext(name) != dropInExtension {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	return files, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	engineAuthTypeEnvVar = "ECS_ENGINE_AUTH_TYPE"
+	engineAuthDataEnvVar = "ECS_ENGINE_AUTH_DATA"
+	agentLabelsEnvVar    = "ECS_AGENT_LABELS"
+)
+
+// validator returns why value is invalid, if it is
+type validator func(value string) error
+
+// agentKeys are the configuration keys of the Agent, along with how their
+// values are validated. Keys with a nil validator accept any value.
+var agentKeys = map[string]validator{
+	"ECS_CLUSTER":                                nil,
+	"ECS_RESERVED_PORTS":                         validPorts,
+	"ECS_RESERVED_PORTS_UDP":                     validPorts,
+	"ECS_RESERVED_MEMORY":                        validNonNegativeInt,
+	engineAuthTypeEnvVar:                         oneOf("docker", "dockercfg"),
+	engineAuthDataEnvVar:                         nil,
+	"ECS_LOGLEVEL":                               oneOf("debug", "info", "warn", "error", "crit"),
+	"ECS_LOGLEVEL_ON_INSTANCE":                   oneOf("debug", "info", "warn", "error", "crit", "none"),
+	"ECS_LOGFILE":                                nil,
+	"ECS_LOG_ROLLOVER_TYPE":                      oneOf("size", "hourly"),
+	"ECS_LOG_OUTPUT_FORMAT":                      oneOf("logfmt", "json"),
+	"ECS_LOG_MAX_FILE_SIZE_MB":                   validNonNegativeInt,
+	"ECS_LOG_MAX_ROLL_COUNT":                     validNonNegativeInt,
+	"ECS_CHECKPOINT":                             validBool,
+	"ECS_DATADIR":                                nil,
+	"ECS_HOST_DATA_DIR":                          nil,
+	"ECS_UPDATES_ENABLED":                        validBool,
+	"ECS_UPDATE_DOWNLOAD_DIR":                    nil,
+	"ECS_AGENT_CONFIG_FILE_PATH":                 nil,
+	"ECS_DISABLE_METRICS":                        validBool,
+	"ECS_POLL_METRICS":                           validBool,
+	"ECS_POLLING_METRICS_WAIT_DURATION":          validDuration,
+	"ECS_AVAILABLE_LOGGING_DRIVERS":              validLoggingDrivers,
+	"ECS_DISABLE_PRIVILEGED":                     validBool,
+	"ECS_SELINUX_CAPABLE":                        validBool,
+	"ECS_APPARMOR_CAPABLE":                       validBool,
+	"ECS_ENGINE_TASK_CLEANUP_WAIT_DURATION":      validDuration,
+	"ECS_CONTAINER_STOP_TIMEOUT":                 validDuration,
+	"ECS_CONTAINER_START_TIMEOUT":                validDuration,
+	"ECS_ENABLE_TASK_IAM_ROLE":                   validBool,
+	"ECS_ENABLE_TASK_IAM_ROLE_NETWORK_HOST":      validBool,
+	"ECS_DISABLE_IMAGE_CLEANUP":                  validBool,
+	"ECS_IMAGE_CLEANUP_INTERVAL":                 validDuration,
+	"ECS_IMAGE_MINIMUM_CLEANUP_AGE":              validDuration,
+	"NON_ECS_IMAGE_MINIMUM_CLEANUP_AGE":          validDuration,
+	"ECS_NUM_IMAGES_DELETE_PER_CYCLE":            validNonNegativeInt,
+	"ECS_IMAGE_PULL_BEHAVIOR":                    oneOf("default", "always", "once", "prefer-cached"),
+	"ECS_IMAGE_PULL_INACTIVITY_TIMEOUT":          validDuration,
+	"ECS_INSTANCE_ATTRIBUTES":                    validStringMap,
+	"ECS_CONTAINER_INSTANCE_TAGS":                validStringMap,
+	"ECS_CONTAINER_INSTANCE_PROPAGATE_TAGS_FROM": oneOf("ec2_instance", "none"),
+	"ECS_ENABLE_TASK_ENI":                        validBool,
+	"ECS_ENABLE_HIGH_DENSITY_ENI":                validBool,
+	"ECS_CNI_PLUGINS_PATH":                       nil,
+	"ECS_AWSVPC_BLOCK_IMDS":                      validBool,
+	"ECS_AWSVPC_ADDITIONAL_LOCAL_ROUTES":         validStringList,
+	"ECS_ENABLE_CONTAINER_METADATA":              validBool,
+	"ECS_ENABLE_TASK_CPU_MEM_LIMIT":              validBool,
+	"ECS_CGROUP_PATH":                            nil,
+	"ECS_TASK_METADATA_RPS_LIMIT":                nil,
+	"ECS_SHARED_VOLUME_MATCH_FULL_CONFIG":        validBool,
+	"ECS_ENABLE_UNTRACKED_IMAGE_CLEANUP":         validBool,
+	"ECS_EXCLUDE_UNTRACKED_IMAGE":                nil,
+	"ECS_DISABLE_DOCKER_HEALTH_CHECK":            validBool,
+	"ECS_NVIDIA_RUNTIME":                         nil,
+	"ECS_ENABLE_SPOT_INSTANCE_DRAINING":          validBool,
+	"ECS_ENABLE_AWSLOGS_EXECUTIONROLE_OVERRIDE":  validBool,
+	"ECS_VOLUME_PLUGIN_CAPABILITIES":             validStringList,
+	"ECS_PULL_DEPENDENT_CONTAINERS_UPFRONT":      validBool,
+	"ECS_WARM_POOLS_CHECK":                       validBool,
+	"ECS_BACKEND_HOST":                           nil,
+	"AWS_ACCESS_KEY_ID":                          nil,
+	"AWS_SECRET_ACCESS_KEY":                      nil,
+	"AWS_SESSION_TOKEN":                          nil,
+	"HTTP_PROXY":                                 nil,
+	"HTTPS_PROXY":                                nil,
+	"NO_PROXY":                                   nil,
+	GPUSupportEnvVar:                             validBool,
+	agentLabelsEnvVar:                            validStringMap,
+	agentLogOptionsEnvVar:                        validStringMap,
+}
+
+// knownKeys returns the validators of the configuration keys of both the
+// Agent and ecs-init. The settings of ecs-init are validated the way they
+// are loaded.
+func knownKeys() map[string]validator {
+	keys := make(map[string]validator, len(agentKeys)+len(settings))
+	for key, validate := range agentKeys {
+		keys[key] = validate
+	}
+	for _, s := range settings {
+		set := s.set
+		keys[s.name] = func(value string) error {
+			return set(Defaults(), value)
+		}
+	}
+	return keys
+}
+
+func validBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("expected true or false")
+	}
+	return nil
+}
+
+func validDuration(value string) error {
+	if _, err := time.ParseDuration(value); err != nil {
+		return fmt.Errorf("expected a duration such as \"10m\"")
+	}
+	return nil
+}
+
+func validNonNegativeInt(value string) error {
+	if i, err := strconv.Atoi(value); err != nil || i < 0 {
+		return fmt.Errorf("expected a non-negative integer")
+	}
+	return nil
+}
+
+func oneOf(values ...string) validator {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of %s", strings.Join(values, ", "))
+	}
+}
+
+func validStringMap(value string) error {
+	var m map[string]string
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		return fmt.Errorf("expected a JSON object with string values: %v", err)
+	}
+	return nil
+}
+
+func validStringList(value string) error {
+	var l []string
+	if err := json.Unmarshal([]byte(value), &l); err != nil {
+		return fmt.Errorf("expected a JSON array of strings: %v", err)
+	}
+	return nil
+}
+
+func validPorts(value string) error {
+	var ports []uint16
+	if err := json.Unmarshal([]byte(value), &ports); err != nil {
+		return fmt.Errorf("expected a JSON array of port numbers: %v", err)
+	}
+	return nil
+}
+
+func validLoggingDrivers(value string) error {
+	var drivers []string
+	if err := json.Unmarshal([]byte(value), &drivers); err != nil {
+		return fmt.Errorf("expected a JSON array of log drivers: %v", err)
+	}
+	for _, driver := range drivers {
+		if _, ok := validDrivers[driver]; !ok && driver != "none" {
+			return fmt.Errorf("unsupported log driver %q", driver)
+		}
+	}
+	return nil
+}
+
+// validAuthData validates ECS_ENGINE_AUTH_DATA, whose shape depends on
+// ECS_ENGINE_AUTH_TYPE: the "docker" type maps registries to a username and
+// password, while the "dockercfg" type maps them to a base64 encoded auth
+// string. An unknown type only requires registries to map to objects.
+func validAuthData(authType string, value string) error {
+	var registries map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &registries); err != nil {
+		return fmt.Errorf("expected a JSON object of registries: %v", err)
+	}
+	var required []string
+	switch authType {
+	case "docker":
+		required = []string{"username", "password"}
+	case "dockercfg":
+		required = []string{"auth"}
+	}
+	names := make([]string, 0, len(registries))
+	for registry := range registries {
+		names = append(names, registry)
+	}
+	sort.Strings(names)
+	for _, registry := range names {
+		for _, field := range required {
+			if _, ok := registries[registry][field].(string); !ok {
+				return fmt.Errorf("expected a %q string for registry %s with %s %q",
+					field, registry, engineAuthTypeEnvVar, authType)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
+)
+
+// agentKeyPrefix is the prefix of the keys that are reported as unknown even
+// if they aren't close to a known key. Other keys may be meant for the
+// environment of the Agent container.
+const agentKeyPrefix = "ECS_"
+
+// File is a configuration file to validate
+type File struct {
+	Path string
+	Data []byte
+}
+
+// Problem is an issue found in a configuration file
+type Problem struct {
+	File string `json:"file"`
+	// Line is the line number of the problem, starting at 1
+	Line    int    `json:"line"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+// Validate lints configuration files, given in increasing order of
+// precedence. It reports unknown keys along with the known key they are
+// closest to, invalid values, keys set more than once in a file and the
+// diagnostics of ParseEnvironmentFile.
+func Validate(files []*File) []*Problem {
+	keys := knownKeys()
+	var problems []*Problem
+	authType := ""
+	for _, file := range files {
+		var fileProblems []*Problem
+		assignments, diagnostics := ParseEnvironmentFile(file.Data)
+		for _, diagnostic := range diagnostics {
+			fileProblems = append(fileProblems, &Problem{
+				File:    file.Path,
+				Line:    diagnostic.Line,
+				Message: diagnostic.Message,
+			})
+		}
+		seen := make(map[string]int)
+		for _, assignment := range assignments {
+			problem := validateAssignment(keys, seen, assignment)
+			if problem != nil {
+				problem.File = file.Path
+				fileProblems = append(fileProblems, problem)
+			}
+			if _, ok := seen[assignment.Name]; !ok {
+				seen[assignment.Name] = assignment.Line
+			}
+			if assignment.Name == engineAuthTypeEnvVar {
+				authType = assignment.Value
+			}
+		}
+		fileProblems = append(fileProblems, validateAuthAssignments(file.Path, assignments, authType)...)
+		sort.SliceStable(fileProblems, func(i, j int) bool {
+			return fileProblems[i].Line < fileProblems[j].Line
+		})
+		problems = append(problems, fileProblems...)
+	}
+	return problems
+}
+
+func validateAssignment(keys map[string]validator, seen map[string]int, assignment *Assignment) *Problem {
+	name, value := assignment.Name, assignment.Value
+	problem := &Problem{Line: assignment.Line, Key: name}
+	if first, ok := seen[name]; ok {
+		problem.Message = fmt.Sprintf("duplicate key %s, first set on line %d", name, first)
+		return problem
+	}
+	validate, ok := keys[name]
+	if !ok {
+		suggestion := closestKey(keys, name)
+		if suggestion != "" {
+			problem.Message = fmt.Sprintf("unknown key %s, did you mean %s?", name, suggestion)
+			return problem
+		}
+		if strings.HasPrefix(name, agentKeyPrefix) {
+			problem.Message = fmt.Sprintf("unknown key %s", name)
+			return problem
+		}
+		return nil
+	}
+	if validate == nil || value == "" {
+		return nil
+	}
+	if err := validate(value); err != nil {
+		problem.Message = fmt.Sprintf("invalid value %q for %s: %v", redact.Value(name, value), name, err)
+		return problem
+	}
+	return nil
+}
+
+// validateAuthAssignments validates the Docker auth data assignments against
+// the auth type in effect
+func validateAuthAssignments(path string, assignments []*Assignment, authType string) []*Problem {
+	var problems []*Problem
+	for _, assignment := range assignments {
+		if assignment.Name != engineAuthDataEnvVar || assignment.Value == "" {
+			continue
+		}
+		if err := validAuthData(authType, assignment.Value); err != nil {
+			problems = append(problems, &Problem{
+				File:    path,
+				Line:    assignment.Line,
+				Key:     assignment.Name,
+				Message: fmt.Sprintf("invalid value for %s: %v", assignment.Name, err),
+			})
+		}
+	}
+	return problems
+}
+
+// closestKey returns the known key that name is most likely a typo of, if
+// any
+func closestKey(keys map[string]validator, name string) string {
+	maxDistance := len(name) / 5
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	closest := ""
+	closestDistance := maxDistance + 1
+	for key := range keys {
+		distance := editDistance(strings.ToUpper(name), key)
+		if distance < closestDistance || (distance == closestDistance && key < closest) {
+			closest, closestDistance = key, distance
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
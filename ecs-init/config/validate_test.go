@@ -0,0 +1,108 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validateLines(lines string) []string {
+	var messages []string
+	for _, problem := range Validate([]*File{{Path: "ecs.config", Data: []byte(lines)}}) {
+		messages = append(messages, problem.String())
+	}
+	return messages
+}
+
+func TestValidateValidFile(t *testing.T) {
+	problems := validateLines(`
+# cluster of the instance
+ECS_CLUSTER=prod
+ECS_RESERVED_PORTS=[22, 2375]
+ECS_AGENT_LABELS={"team":"infra"}
+ECS_LOG_DRIVER=awslogs
+ECS_INIT_RESTART_MAX_FAILURES=5
+ECS_ENGINE_AUTH_TYPE=dockercfg
+ECS_ENGINE_AUTH_DATA={"registry.example.com":{"auth":"dXNlcjpwYXNz","email":"user@example.com"}}
+CUSTOM_AGENT_VARIABLE=anything
+ECS_UPDATES_ENABLED=
+`)
+	assert.Empty(t, problems)
+}
+
+func TestValidateUnknownKeys(t *testing.T) {
+	problems := validateLines("ECS_CLUSTR=prod\necs_loglevel=debug\nECS_SOMETHING_ELSE=1\nHTTP_PROXI=proxy:3128\n")
+	assert.Equal(t, []string{
+		"ecs.config:1: unknown key ECS_CLUSTR, did you mean ECS_CLUSTER?",
+		"ecs.config:2: unknown key ecs_loglevel, did you mean ECS_LOGLEVEL?",
+		"ecs.config:3: unknown key ECS_SOMETHING_ELSE",
+		"ecs.config:4: unknown key HTTP_PROXI, did you mean HTTP_PROXY?",
+	}, problems)
+}
+
+func TestValidateValues(t *testing.T) {
+	problems := validateLines(`ECS_UPDATES_ENABLED=maybe
+ECS_AGENT_LABELS={"team":1}
+ECS_LOG_DRIVER=nope
+ECS_AVAILABLE_LOGGING_DRIVERS=["awslogs","nope"]
+ECS_INIT_AGENT_LIVENESS_INTERVAL=-1m
+ECS_IMAGE_PULL_BEHAVIOR=sometimes
+ECS_SECRET_TOKEN_UNKNOWN=secret
+`)
+	require.Len(t, problems, 7)
+	assert.Equal(t, `ecs.config:1: invalid value "maybe" for ECS_UPDATES_ENABLED: expected true or false`, problems[0])
+	assert.Contains(t, problems[1], "expected a JSON object with string values")
+	assert.Contains(t, problems[2], "expected a supported log driver")
+	assert.Contains(t, problems[3], `unsupported log driver "nope"`)
+	assert.Contains(t, problems[4], "expected a positive duration")
+	assert.Contains(t, problems[5], "expected one of default, always, once, prefer-cached")
+	assert.NotContains(t, problems[6], "secret")
+}
+
+func TestValidateAuthData(t *testing.T) {
+	problems := validateLines(`ECS_ENGINE_AUTH_TYPE=docker
+ECS_ENGINE_AUTH_DATA={"registry.example.com":{"username":"user"}}
+`)
+	assert.Equal(t, []string{
+		`ecs.config:2: invalid value for ECS_ENGINE_AUTH_DATA: expected a "password" string for registry registry.example.com with ECS_ENGINE_AUTH_TYPE "docker"`,
+	}, problems)
+
+	problems = validateLines(`ECS_ENGINE_AUTH_DATA=not json`)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "expected a JSON object of registries")
+	assert.NotContains(t, problems[0], "not json")
+}
+
+func TestValidateAuthTypeFromEarlierFile(t *testing.T) {
+	problems := Validate([]*File{
+		{Path: "/var/lib/ecs/ecs.config", Data: []byte("ECS_ENGINE_AUTH_TYPE=dockercfg\n")},
+		{Path: "/etc/ecs/ecs.config", Data: []byte(`ECS_ENGINE_AUTH_DATA={"registry.example.com":{"username":"user","password":"pass"}}`)},
+	})
+	require.Len(t, problems, 1)
+	assert.Equal(t, "/etc/ecs/ecs.config", problems[0].File)
+	assert.Contains(t, problems[0].Message, `expected a "auth" string`)
+}
+
+func TestValidateDuplicatesAndSkippedLines(t *testing.T) {
+	problems := validateLines("ECS_CLUSTER=a\nnot a setting\n=value\nECS_CLUSTER=b\nECS_CLUSTER=c\n")
+	assert.Equal(t, []string{
+		"ecs.config:2: ignored, expected NAME=value",
+		"ecs.config:3: ignored, expected NAME=value",
+		"ecs.config:4: duplicate key ECS_CLUSTER, first set on line 1",
+		"ecs.config:5: duplicate key ECS_CLUSTER, first set on line 1",
+	}, problems)
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// agentVersionPattern matches the released versions of the Agent
+	agentVersionPattern = regexp.MustCompile(`^v([0-9]+)\.([0-9]+)\.([0-9]+)$`)
+	// agentCommitPattern matches the development builds of the Agent,
+	// named after their commit
+	agentCommitPattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+)
+
+// CheckMinimumAgentVersion returns an error if version is older than
+// minimum, or if it's a development build that can't be compared with
+// minimum. Any version may be started if minimum is empty.
+func CheckMinimumAgentVersion(version, minimum string) error {
+	if minimum == "" {
+		return nil
+	}
+	parsed := parseAgentVersion(version)
+	if parsed == nil {
+		return errors.Errorf("agent version %q can't be compared with the minimum version %s", version, minimum)
+	}
+	parsedMinimum := parseAgentVersion(minimum)
+	if parsedMinimum == nil {
+		return errors.Errorf("invalid minimum agent version %q", minimum)
+	}
+	for i := range parsed {
+		if parsed[i] != parsedMinimum[i] {
+			if parsed[i] < parsedMinimum[i] {
+				return errors.Errorf("agent version %s is older than the minimum version %s", version, minimum)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// parseAgentVersion returns the major, minor and patch numbers of version,
+// or nil if it isn't a released version
+func parseAgentVersion(version string) []int {
+	match := agentVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return nil
+	}
+	numbers := make([]int, 3)
+	for i := range numbers {
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return nil
+		}
+		numbers[i] = n
+	}
+	return numbers
+}
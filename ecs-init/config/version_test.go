@@ -0,0 +1,42 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMinimumAgentVersion(t *testing.T) {
+	cases := []struct {
+		version  string
+		minimum  string
+		expected bool
+	}{
+		{"v1.63.1", "", true},
+		{"0123abcd", "", true},
+		{"v1.63.1", "v1.63.1", true},
+		{"v1.63.1", "v1.9.0", true},
+		{"v2.0.0", "v1.63.1", true},
+		{"v1.9.0", "v1.10.0", false},
+		{"v1.63.0", "v1.63.1", false},
+		{"v0.99.99", "v1.0.0", false},
+		{"0123abcd", "v1.0.0", false},
+	}
+	for _, c := range cases {
+		err := CheckMinimumAgentVersion(c.version, c.minimum)
+		assert.Equal(t, c.expected, err == nil, "version %s with minimum %q: %v", c.version, c.minimum, err)
+	}
+}
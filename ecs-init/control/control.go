@@ -0,0 +1,237 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package control implements the local control socket of the ecs-init
+// process supervising the ECS Agent. Every connection carries a single JSON
+// request and its JSON response.
+package control
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// Command is an operation of the control socket
+type Command string
+
+const (
+	// RestartAgent stops the Agent so that the supervisor starts it again
+	// right away
+	RestartAgent Command = "restart-agent"
+	// ReloadConfig reloads the configuration of the supervisor
+	ReloadConfig Command = "reload-config"
+	// Pause stops the supervisor from restarting the Agent, for maintenance
+	Pause Command = "pause"
+	// Resume undoes Pause
+	Resume Command = "resume"
+	// ReportState reports the state of the supervisor
+	ReportState Command = "report-state"
+)
+
+const (
+	socketDirPerm = 0700
+	socketPerm    = 0600
+	// requestTimeout bounds how long a connection may take to send its
+	// request and read its response
+	requestTimeout   = 30 * time.Second
+	acceptRetryDelay = 100 * time.Millisecond
+)
+
+// ErrNotRunning is returned by Send when no supervisor listens on the socket
+var ErrNotRunning = errors.New("no ecs-init supervisor is listening on the control socket")
+
+// State is the state of the supervisor reported by ReportState
+type State struct {
+	PID            int        `json:"pid"`
+	Paused         bool       `json:"paused"`
+	AgentRunning   bool       `json:"agentRunning"`
+	AgentStartedAt *time.Time `json:"agentStartedAt,omitempty"`
+	// AgentRuns is the number of times the Agent was started by the
+	// supervisor
+	AgentRuns      int        `json:"agentRuns"`
+	LastExitCode   *int       `json:"lastExitCode,omitempty"`
+	ProbationUntil *time.Time `json:"probationUntil,omitempty"`
+	// PendingConfigChanges are the changes to the configuration files that
+	// aren't applied to the running Agent yet
+	PendingConfigChanges []string `json:"pendingConfigChanges,omitempty"`
+}
+
+// Handler carries out the commands received on the control socket
+type Handler interface {
+	RestartAgent() error
+	ReloadConfig() error
+	Pause() error
+	Resume() error
+	State() *State
+}
+
+type request struct {
+	Command Command `json:"command"`
+}
+
+// Response is the result of a command
+type Response struct {
+	Error string `json:"error,omitempty"`
+	State *State `json:"state,omitempty"`
+}
+
+// Server accepts connections on the control socket
+type Server struct {
+	listener net.Listener
+	handler  Handler
+	done     chan struct{}
+}
+
+// Listen creates the control socket at path, accessible to root only, and
+// serves the commands received on it with handler until the server is
+// closed. It fails if another supervisor listens on path already.
+func Listen(path string, handler Handler) (*Server, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, errors.Errorf("another ecs-init supervisor is listening on %s", path)
+	}
+	err := os.MkdirAll(filepath.Dir(path), socketDirPerm)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the control socket directory")
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "could not remove stale control socket")
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the control socket")
+	}
+	err = os.Chmod(path, socketPerm)
+	if err != nil {
+		listener.Close()
+		return nil, errors.Wrap(err, "could not restrict access to the control socket")
+	}
+	server := &Server{
+		listener: listener,
+		handler:  handler,
+		done:     make(chan struct{}),
+	}
+	go server.serve()
+	return server, nil
+}
+
+// Close stops accepting connections and removes the control socket
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	<-s.done
+	return err
+}
+
+func (s *Server) serve() {
+	defer close(s.done)
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(acceptRetryDelay)
+				continue
+			}
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+	req := &request{}
+	err := json.NewDecoder(conn).Decode(req)
+	if err != nil {
+		log.Warnf("Invalid request on the control socket: %v", err)
+		return
+	}
+	log.Infof("Received %s on the control socket", req.Command)
+	resp := s.dispatch(req.Command)
+	err = json.NewEncoder(conn).Encode(resp)
+	if err != nil {
+		log.Warnf("Could not answer %s on the control socket: %v", req.Command, err)
+	}
+}
+
+func (s *Server) dispatch(command Command) *Response {
+	var err error
+	switch command {
+	case RestartAgent:
+		err = s.handler.RestartAgent()
+	case ReloadConfig:
+		err = s.handler.ReloadConfig()
+	case Pause:
+		err = s.handler.Pause()
+	case Resume:
+		err = s.handler.Resume()
+	case ReportState:
+		return &Response{State: s.handler.State()}
+	default:
+		err = errors.Errorf("unknown command %q", command)
+	}
+	if err != nil {
+		return &Response{Error: err.Error()}
+	}
+	return &Response{}
+}
+
+// Send sends the command to the supervisor listening on path. ErrNotRunning
+// is returned if there is none, and the error reported by the supervisor if
+// the command failed.
+func Send(path string, command Command) (*Response, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		if isNotRunning(err) {
+			return nil, ErrNotRunning
+		}
+		return nil, errors.Wrap(err, "could not connect to the control socket")
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+	err = json.NewEncoder(conn).Encode(&request{Command: command})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not send command to the control socket")
+	}
+	resp := &Response{}
+	err = json.NewDecoder(conn).Decode(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read response from the control socket")
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// isNotRunning returns true if connecting failed because the socket doesn't
+// exist or nothing listens on it anymore
+func isNotRunning(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	return sysErr.Err == syscall.ENOENT || sysErr.Err == syscall.ECONNREFUSED
+}
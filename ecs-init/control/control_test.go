@@ -0,0 +1,122 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package control
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHandler struct {
+	lock     sync.Mutex
+	commands []Command
+	err      error
+}
+
+func (h *fakeHandler) record(command Command) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.commands = append(h.commands, command)
+	return h.err
+}
+
+func (h *fakeHandler) RestartAgent() error {
+	return h.record(RestartAgent)
+}
+
+func (h *fakeHandler) ReloadConfig() error {
+	return h.record(ReloadConfig)
+}
+
+func (h *fakeHandler) Pause() error {
+	return h.record(Pause)
+}
+
+func (h *fakeHandler) Resume() error {
+	return h.record(Resume)
+}
+
+func (h *fakeHandler) State() *State {
+	h.record(ReportState)
+	return &State{PID: 42, Paused: true}
+}
+
+func socketPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "control")
+	require.NoError(t, err)
+	return filepath.Join(dir, "ecs-init", "control.sock"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSendCommands(t *testing.T) {
+	path, cleanup := socketPath(t)
+	defer cleanup()
+	handler := &fakeHandler{}
+	server, err := Listen(path, handler)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(socketPerm), info.Mode().Perm())
+
+	for _, command := range []Command{RestartAgent, ReloadConfig, Pause, Resume} {
+		resp, err := Send(path, command)
+		assert.NoError(t, err)
+		assert.Nil(t, resp.State)
+	}
+	resp, err := Send(path, ReportState)
+	assert.NoError(t, err)
+	assert.Equal(t, &State{PID: 42, Paused: true}, resp.State)
+
+	_, err = Send(path, Command("explode"))
+	assert.Error(t, err)
+
+	handler.lock.Lock()
+	handler.err = errors.New("test error")
+	handler.lock.Unlock()
+	_, err = Send(path, Pause)
+	assert.EqualError(t, err, "test error")
+
+	assert.Equal(t, []Command{RestartAgent, ReloadConfig, Pause, Resume, ReportState, Pause}, handler.commands)
+
+	require.NoError(t, server.Close())
+	_, err = Send(path, ReportState)
+	assert.Equal(t, ErrNotRunning, err)
+}
+
+func TestListenTwice(t *testing.T) {
+	path, cleanup := socketPath(t)
+	defer cleanup()
+	server, err := Listen(path, &fakeHandler{})
+	require.NoError(t, err)
+	defer server.Close()
+
+	_, err = Listen(path, &fakeHandler{})
+	assert.Error(t, err, "expected a second supervisor not to take over the control socket")
+}
+
+func TestSendNotRunning(t *testing.T) {
+	path, cleanup := socketPath(t)
+	defer cleanup()
+	_, err := Send(path, ReportState)
+	assert.Equal(t, ErrNotRunning, err)
+}
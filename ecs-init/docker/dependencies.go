@@ -48,6 +48,13 @@ type dockerclient interface {
 	StartContainer(id string, hostConfig *godocker.HostConfig) error
 	WaitContainer(id string) (int, error)
 	StopContainer(id string, timeout uint) error
+	KillContainer(opts godocker.KillContainerOptions) error
+	InspectContainer(id string) (*godocker.Container, error)
+	TagImage(name string, opts godocker.TagImageOptions) error
+	PullImage(opts godocker.PullImageOptions, auth godocker.AuthConfiguration) error
+	InspectImage(name string) (*godocker.Image, error)
+	Version() (*godocker.Env, error)
+	Info() (*godocker.DockerInfo, error)
 	Ping() error
 }
 
@@ -65,8 +72,8 @@ func (client godockerClientFactory) NewVersionedClient(endpoint string, apiVersi
 	return godocker.NewVersionedClient(endpoint, apiVersionString)
 }
 
-func newDockerClient(dockerClientFactory dockerClientFactory, pingBackoff backoff.Backoff) (dockerclient, error) {
-	dockerUnixSocketSourcePath, fromEnv := config.DockerUnixSocket()
+func newDockerClient(dockerClientFactory dockerClientFactory, pingBackoff backoff.Backoff, cfg *config.Config) (dockerclient, error) {
+	dockerUnixSocketSourcePath, fromEnv := cfg.DockerUnixSocket()
 	if !fromEnv {
 		dockerUnixSocketSourcePath = "/var/run/docker.sock"
 	}
@@ -129,6 +136,34 @@ func (d *_dockerclient) StopContainer(id string, timeout uint) error {
 	return d.docker.StopContainer(id, timeout)
 }
 
+func (d *_dockerclient) KillContainer(opts godocker.KillContainerOptions) error {
+	return d.docker.KillContainer(opts)
+}
+
+func (d *_dockerclient) InspectContainer(id string) (*godocker.Container, error) {
+	return d.docker.InspectContainer(id)
+}
+
+func (d *_dockerclient) TagImage(name string, opts godocker.TagImageOptions) error {
+	return d.docker.TagImage(name, opts)
+}
+
+func (d *_dockerclient) PullImage(opts godocker.PullImageOptions, auth godocker.AuthConfiguration) error {
+	return d.docker.PullImage(opts, auth)
+}
+
+func (d *_dockerclient) InspectImage(name string) (*godocker.Image, error) {
+	return d.docker.InspectImage(name)
+}
+
+func (d *_dockerclient) Version() (*godocker.Env, error) {
+	return d.docker.Version()
+}
+
+func (d *_dockerclient) Info() (*godocker.DockerInfo, error) {
+	return d.docker.Info()
+}
+
 func (d *_dockerclient) Ping() error {
 	return d.docker.Ping()
 }
@@ -1,4 +1,4 @@
-// Copyright 2015-2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// Copyright 2015-2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License"). You may
 // not use this file except in compliance with the License. A copy of the
@@ -177,6 +177,108 @@ func (mr *MockdockerclientMockRecorder) StopContainer(id, timeout interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopContainer", reflect.TypeOf((*Mockdockerclient)(nil).StopContainer), id, timeout)
 }
 
+// KillContainer mocks base method
+func (m *Mockdockerclient) KillContainer(opts go_dockerclient.KillContainerOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KillContainer", opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// KillContainer indicates an expected call of KillContainer
+func (mr *MockdockerclientMockRecorder) KillContainer(opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KillContainer", reflect.TypeOf((*Mockdockerclient)(nil).KillContainer), opts)
+}
+
+// InspectContainer mocks base method
+func (m *Mockdockerclient) InspectContainer(id string) (*go_dockerclient.Container, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectContainer", id)
+	ret0, _ := ret[0].(*go_dockerclient.Container)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectContainer indicates an expected call of InspectContainer
+func (mr *MockdockerclientMockRecorder) InspectContainer(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectContainer", reflect.TypeOf((*Mockdockerclient)(nil).InspectContainer), id)
+}
+
+// TagImage mocks base method
+func (m *Mockdockerclient) TagImage(name string, opts go_dockerclient.TagImageOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TagImage", name, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TagImage indicates an expected call of TagImage
+func (mr *MockdockerclientMockRecorder) TagImage(name, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagImage", reflect.TypeOf((*Mockdockerclient)(nil).TagImage), name, opts)
+}
+
+// PullImage mocks base method
+func (m *Mockdockerclient) PullImage(opts go_dockerclient.PullImageOptions, auth go_dockerclient.AuthConfiguration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PullImage", opts, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PullImage indicates an expected call of PullImage
+func (mr *MockdockerclientMockRecorder) PullImage(opts, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PullImage", reflect.TypeOf((*Mockdockerclient)(nil).PullImage), opts, auth)
+}
+
+// InspectImage mocks base method
+func (m *Mockdockerclient) InspectImage(name string) (*go_dockerclient.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectImage", name)
+	ret0, _ := ret[0].(*go_dockerclient.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectImage indicates an expected call of InspectImage
+func (mr *MockdockerclientMockRecorder) InspectImage(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectImage", reflect.TypeOf((*Mockdockerclient)(nil).InspectImage), name)
+}
+
+// Version mocks base method
+func (m *Mockdockerclient) Version() (*go_dockerclient.Env, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Version")
+	ret0, _ := ret[0].(*go_dockerclient.Env)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Version indicates an expected call of Version
+func (mr *MockdockerclientMockRecorder) Version() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Version", reflect.TypeOf((*Mockdockerclient)(nil).Version))
+}
+
+// Info mocks base method
+func (m *Mockdockerclient) Info() (*go_dockerclient.DockerInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Info")
+	ret0, _ := ret[0].(*go_dockerclient.DockerInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Info indicates an expected call of Info
+func (mr *MockdockerclientMockRecorder) Info() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Info", reflect.TypeOf((*Mockdockerclient)(nil).Info))
+}
+
 // Ping mocks base method
 func (m *Mockdockerclient) Ping() error {
 	m.ctrl.T.Helper()
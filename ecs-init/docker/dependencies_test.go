@@ -22,6 +22,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -65,7 +67,7 @@ func TestNewDockerClientRetriesOnPingNetworkError(t *testing.T) {
 		mockDockerClient.EXPECT().Ping().Return(nil),
 	)
 
-	_, err := newDockerClient(mockClientFactory, mockBackoff)
+	_, err := newDockerClient(mockClientFactory, mockBackoff, config.Defaults())
 	assert.NoError(t, err, "Expect no error for creating docker client with retry on network error")
 }
 
@@ -85,7 +87,7 @@ func TestNewDockerClientRetriesOnHTTPStatusNotOKError(t *testing.T) {
 		mockDockerClient.EXPECT().Ping().Return(nil),
 	)
 
-	_, err := newDockerClient(mockClientFactory, mockBackoff)
+	_, err := newDockerClient(mockClientFactory, mockBackoff, config.Defaults())
 	assert.NoError(t, err, "Expect no error for creating docker client with retry on HTTP status not OK")
 }
 
@@ -102,7 +104,7 @@ func TestNewDockerClientDoesnotRetryOnPingNonNetworkError(t *testing.T) {
 		mockDockerClient.EXPECT().Ping().Return(fmt.Errorf("error")),
 	)
 
-	_, err := newDockerClient(mockClientFactory, mockBackoff)
+	_, err := newDockerClient(mockClientFactory, mockBackoff, config.Defaults())
 	assert.Error(t, err, "Expect error when creating docker client with no retry")
 }
 
@@ -123,7 +125,7 @@ func TestNewDockerClientGivesUpRetryingOnPingNetworkError(t *testing.T) {
 		mockBackoff.EXPECT().ShouldRetry().Return(false),
 	)
 
-	_, err := newDockerClient(mockClientFactory, mockBackoff)
+	_, err := newDockerClient(mockClientFactory, mockBackoff, config.Defaults())
 	assert.Error(t, err, "Expect error when creating docker client with no retry")
 }
 
@@ -149,7 +151,7 @@ func TestNewDockerClientGivesUpRetryingOnUnavailableSocket(t *testing.T) {
 		mockBackoff.EXPECT().ShouldRetry().Return(false),
 	)
 
-	_, err := newDockerClient(mockClientFactory, mockBackoff)
+	_, err := newDockerClient(mockClientFactory, mockBackoff, config.Defaults())
 	require.Error(t, err, "expect an error when creating docker client")
 
 	// We expect that the error will be a net.OpError wrapped by a
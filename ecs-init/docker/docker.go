@@ -17,8 +17,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -26,9 +28,11 @@ import (
 	"github.com/aws/amazon-ecs-init/ecs-init/backoff"
 	"github.com/aws/amazon-ecs-init/ecs-init/config"
 	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
 
 	log "github.com/cihub/seelog"
 	godocker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -140,21 +144,37 @@ var (
 	isPathValid     = defaultIsPathValid
 )
 
+// AgentContainerState describes the Agent container as reported by Docker
+type AgentContainerState struct {
+	ID           string    `json:"id"`
+	Image        string    `json:"image"`
+	Status       string    `json:"status"`
+	Running      bool      `json:"running"`
+	ExitCode     int       `json:"exitCode"`
+	OOMKilled    bool      `json:"oomKilled"`
+	Error        string    `json:"error,omitempty"`
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	RestartCount int       `json:"restartCount"`
+}
+
 // client enables business logic for running the Agent inside Docker
 type client struct {
 	docker dockerclient
 	fs     fileSystem
+	config *config.Config
 }
 
-// Client returns the global docker client.
-func Client() (*client, error) {
+// Client returns the global docker client, which is created with the
+// configuration of the first call.
+func Client(cfg *config.Config) (*client, error) {
 	dockerOnce.Do(func() {
 		// Create a backoff for pinging the docker socket. This should result in 17-19
 		// seconds of delay in the worst-case between different actions that depend on
 		// docker
 		pingBackoff := backoff.NewBackoff(minBackoffDuration, maxBackoffDuration, backoffJitterMultiple,
 			backoffMultiple, maxRetries)
-		cl, err := newDockerClient(godockerClientFactory{}, pingBackoff)
+		cl, err := newDockerClient(godockerClientFactory{}, pingBackoff, cfg)
 		if err != nil {
 			dockerClientErr = err
 			return
@@ -162,6 +182,7 @@ func Client() (*client, error) {
 		dockerClient = &client{
 			docker: cl,
 			fs:     standardFS,
+			config: cfg,
 		}
 	})
 	return dockerClient, dockerClientErr
@@ -185,11 +206,37 @@ func (c *client) IsAgentImageLoaded() (bool, error) {
 	return false, nil
 }
 
+// Ping returns an error unless the Docker daemon answers
+func (c *client) Ping() error {
+	return c.docker.Ping()
+}
+
 // LoadImage loads an io.Reader into Docker
 func (c *client) LoadImage(image io.Reader) error {
 	return c.docker.LoadImage(godocker.LoadImageOptions{InputStream: image})
 }
 
+// TagAgentImageKnownGood tags the currently loaded Agent image as the
+// known-good image to roll back to if an upgrade fails
+func (c *client) TagAgentImageKnownGood() error {
+	return c.tagImage(config.AgentImageName, config.AgentKnownGoodImageName)
+}
+
+// RestoreKnownGoodAgentImage tags the known-good Agent image as the Agent
+// image again, so that it's used by the next Agent container
+func (c *client) RestoreKnownGoodAgentImage() error {
+	return c.tagImage(config.AgentKnownGoodImageName, config.AgentImageName)
+}
+
+func (c *client) tagImage(source, target string) error {
+	repository, tag := godocker.ParseRepositoryTag(target)
+	return c.docker.TagImage(source, godocker.TagImageOptions{
+		Repo:  repository,
+		Tag:   tag,
+		Force: true,
+	})
+}
+
 // RemoveExistingAgentContainer remvoes any existing container named
 // "ecs-agent" or returns without error if none is found
 func (c *client) RemoveExistingAgentContainer() error {
@@ -253,6 +300,38 @@ func (c *client) StartAgent() (int, error) {
 	return c.docker.WaitContainer(container.ID)
 }
 
+// GetAgentContainerState inspects the existing Agent container and returns
+// its state, or nil if no Agent container exists
+func (c *client) GetAgentContainerState() (*AgentContainerState, error) {
+	id, err := c.findAgentContainer()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+	container, err := c.docker.InspectContainer(id)
+	if err != nil {
+		return nil, err
+	}
+	state := &AgentContainerState{
+		ID:           container.ID,
+		Image:        container.Image,
+		Status:       container.State.Status,
+		Running:      container.State.Running,
+		ExitCode:     container.State.ExitCode,
+		OOMKilled:    container.State.OOMKilled,
+		Error:        container.State.Error,
+		StartedAt:    container.State.StartedAt,
+		FinishedAt:   container.State.FinishedAt,
+		RestartCount: container.RestartCount,
+	}
+	if container.Config != nil {
+		state.Image = container.Config.Image
+	}
+	return state, nil
+}
+
 // GetContainerLogTail will return the last logWindowSize lines of logs for
 // the Agent Container.
 func (c *client) GetContainerLogTail(logWindowSize string) string {
@@ -278,6 +357,92 @@ func (c *client) GetContainerLogTail(logWindowSize string) string {
 	return containerLogBuf.String()
 }
 
+// GetAgentContainerLogs writes the full logs of the existing Agent container
+// to w
+func (c *client) GetAgentContainerLogs(w io.Writer) error {
+	id, err := c.findAgentContainer()
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("no existing agent container")
+	}
+	return c.docker.Logs(godocker.LogsOptions{
+		Container:    id,
+		OutputStream: w,
+		ErrorStream:  w,
+		Stdout:       true,
+		Stderr:       true,
+		Timestamps:   true,
+	})
+}
+
+// InspectAgentContainer returns the details of the existing Agent container,
+// or nil if no Agent container exists
+func (c *client) InspectAgentContainer() (*godocker.Container, error) {
+	id, err := c.findAgentContainer()
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return c.docker.InspectContainer(id)
+}
+
+// DockerInfo returns the system-wide information of the Docker daemon
+func (c *client) DockerInfo() (*godocker.DockerInfo, error) {
+	return c.docker.Info()
+}
+
+// DockerVersion returns the version information of the Docker daemon
+func (c *client) DockerVersion() (map[string]string, error) {
+	env, err := c.docker.Version()
+	if err != nil {
+		return nil, err
+	}
+	return env.Map(), nil
+}
+
+// CheckServerAPIVersion returns an error if the Docker daemon does not
+// support the minimum API version required by ECS Init
+func (c *client) CheckServerAPIVersion() error {
+	env, err := c.docker.Version()
+	if err != nil {
+		return err
+	}
+	serverVersion, err := godocker.NewAPIVersion(env.Get("ApiVersion"))
+	if err != nil {
+		return errors.Wrap(err, "could not parse docker server API version")
+	}
+	requiredVersion, _ := godocker.NewAPIVersion(dockerClientAPIVersion)
+	if serverVersion.LessThan(requiredVersion) {
+		return errors.Errorf("docker server API version %s is older than the required version %s",
+			serverVersion, requiredVersion)
+	}
+	return nil
+}
+
+// HostConfigBinds returns the host bind mounts of the Agent container as
+// they would be generated by StartAgent. No connection to Docker is needed.
+func HostConfigBinds(cfg *config.Config) []string {
+	c := &client{
+		fs:     standardFS,
+		config: cfg,
+	}
+	return c.getHostConfig(c.LoadEnvVars()).Binds
+}
+
+// AgentContainerEnv returns the environment of the Agent container as it
+// would be generated by StartAgent, sorted by name. No connection to Docker is
+// needed.
+func AgentContainerEnv(cfg *config.Config) []string {
+	c := &client{
+		fs:     standardFS,
+		config: cfg,
+	}
+	env := c.getContainerConfig(c.LoadEnvVars()).Env
+	sort.Strings(env)
+	return env
+}
+
 func (c *client) getContainerConfig(envVarsFromFiles map[string]string) *godocker.Config {
 	// default environment variables
 	envVariables := map[string]string{
@@ -306,7 +471,7 @@ func (c *client) getContainerConfig(envVarsFromFiles map[string]string) *godocke
 	for key, val := range envVarsFromFiles {
 		envVariables[key] = val
 	}
-	if config.RunningInExternal() {
+	if c.config.External {
 		// Task networking is not supported when not running on EC2. Explicitly disable since it's enabled by default.
 		envVariables["ECS_ENABLE_TASK_ENI"] = "false"
 	}
@@ -354,7 +519,8 @@ func (c *client) LoadEnvVars() map[string]string {
 	// merge in user-supplied environment variables
 	for envKey, envValue := range c.loadUsrEnvVars() {
 		if val, ok := envVariables[envKey]; ok {
-			log.Debugf("Overriding instance config %s of value %s to %s", envKey, val, envValue)
+			log.Debugf("Overriding instance config %s of value %s to %s",
+				envKey, redact.Value(envKey, val), redact.Value(envKey, envValue))
 		}
 		envVariables[envKey] = envValue
 	}
@@ -363,31 +529,42 @@ func (c *client) LoadEnvVars() map[string]string {
 
 // loadUsrEnvVars gets user-supplied environment variables
 func (c *client) loadUsrEnvVars() map[string]string {
-	return c.getEnvVars(config.AgentConfigFile())
+	return c.getFilesEnvVars(config.AgentConfigFiles())
 }
 
 // loadCustomInstanceEnvVars gets custom config set in the instance by Amazon
 func (c *client) loadCustomInstanceEnvVars() map[string]string {
-	return c.getEnvVars(config.InstanceConfigFile())
+	return c.getFilesEnvVars(config.InstanceConfigFiles())
 }
 
-func (c *client) getEnvVars(filename string) map[string]string {
+// getFilesEnvVars merges the environment variables of files, the latter
+// files taking precedence
+func (c *client) getFilesEnvVars(files []string, err error) map[string]string {
+	if err != nil {
+		log.Warnf("Could not list the drop-in configuration files: %v", err)
+	}
 	envVariables := make(map[string]string)
+	for _, filename := range files {
+		for envKey, envValue := range c.getEnvVars(filename) {
+			envVariables[envKey] = envValue
+		}
+	}
+	return envVariables
+}
 
+func (c *client) getEnvVars(filename string) map[string]string {
 	file, err := c.fs.ReadFile(filename)
 	if err != nil {
-		return envVariables
+		return make(map[string]string)
 	}
-
-	lines := strings.Split(strings.TrimSpace(string(file)), "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		envVariables[parts[0]] = parts[1]
+	envVariables := make(map[string]string)
+	assignments, diagnostics := config.ParseEnvironmentFile(file)
+	for _, diagnostic := range diagnostics {
+		log.Warnf("%s:%d: %s", filename, diagnostic.Line, diagnostic.Message)
+	}
+	for _, assignment := range assignments {
+		envVariables[assignment.Name] = assignment.Value
 	}
-
 	return envVariables
 }
 
@@ -398,7 +575,7 @@ func generateLabelMap(jsonBlock string) (map[string]string, error) {
 }
 
 func (c *client) getHostConfig(envVarsFromFiles map[string]string) *godocker.HostConfig {
-	dockerSocketBind := getDockerSocketBind(envVarsFromFiles)
+	dockerSocketBind := getDockerSocketBind(c.config, envVarsFromFiles)
 
 	binds := []string{
 		dockerSocketBind,
@@ -418,7 +595,7 @@ func (c *client) getHostConfig(envVarsFromFiles map[string]string) *godocker.Hos
 		binds = append(binds, certsPath)
 	}
 
-	if config.RunningInExternal() {
+	if c.config.External {
 		credsPath := externalEnvCredsHostDir + ":" + externalEnvCredsContainerDir + readOnly
 		binds = append(binds, credsPath)
 	}
@@ -437,20 +614,20 @@ func (c *client) getHostConfig(envVarsFromFiles map[string]string) *godocker.Hos
 	// only add bind mounts when the src file/directory exists on host; otherwise docker API create an empty directory on host
 	binds = append(binds, getCapabilityBinds()...)
 
-	return createHostConfig(binds)
+	return createHostConfig(c.config, binds)
 }
 
 // getDockerSocketBind returns the bind for Docker socket.
 // Value for the bind is as follow:
-// 1. DOCKER_HOST (as in os.Getenv) not set: source /var/run, dest /var/run
-// 2. DOCKER_HOST (as in os.Getenv) set: source DOCKER_HOST (as in os.Getenv, trim unix:// prefix),
+// 1. DOCKER_HOST (as in the ecs-init config) not set: source /var/run, dest /var/run
+// 2. DOCKER_HOST (as in the ecs-init config) set: source DOCKER_HOST (as in the ecs-init config, trim unix:// prefix),
 //   dest DOCKER_HOST (as in /etc/ecs/ecs.config, trim unix:// prefix)
 //
-// On AL2, the value from os.Getenv is the same as the one from /etc/ecs/ecs.config, but on AL1 they might be different, which
+// The ecs-init config takes DOCKER_HOST from its environment over /etc/ecs/ecs.config, so they might be different, which
 // is why I distinguish the two.
-func getDockerSocketBind(envVarsFromFiles map[string]string) string {
+func getDockerSocketBind(cfg *config.Config, envVarsFromFiles map[string]string) string {
 	dockerEndpointAgent := defaultDockerEndpoint
-	dockerUnixSocketSourcePath, fromEnv := config.DockerUnixSocket()
+	dockerUnixSocketSourcePath, fromEnv := cfg.DockerUnixSocket()
 	if fromEnv {
 		if dockerEndpointFromConfig, ok := envVarsFromFiles[config.DockerHostEnvVar]; ok && strings.HasPrefix(dockerEndpointFromConfig, config.UnixSocketPrefix) {
 			dockerEndpointAgent = strings.TrimPrefix(dockerEndpointFromConfig, config.UnixSocketPrefix)
@@ -523,7 +700,8 @@ func FilePatternMatchForGPU(pattern string) ([]string, error) {
 	return filepath.Glob(pattern)
 }
 
-// StopAgent stops the Agent in docker if one is running
+// StopAgent stops the Agent in docker if one is running. The Agent is killed
+// if it can't be stopped gracefully.
 func (c *client) StopAgent() error {
 	id, err := c.findAgentContainer()
 	if err != nil {
@@ -533,11 +711,29 @@ func (c *client) StopAgent() error {
 		log.Info("No running Agent to stop")
 		return nil
 	}
-	stopContainerTimeoutSeconds := uint(10)
+	// docker takes whole seconds, rounded up so that a timeout under a
+	// second still gives the Agent a chance to stop gracefully
+	stopContainerTimeoutSeconds := uint(math.Ceil(c.config.AgentStopTimeout.Seconds()))
 	err = c.docker.StopContainer(id, stopContainerTimeoutSeconds)
 	if _, ok := err.(*godocker.ContainerNotRunning); ok {
 		log.Info("Agent is already stopped")
 		return nil
 	}
+	if err != nil {
+		log.Warnf("Could not stop Agent within %ds, killing it: %v", stopContainerTimeoutSeconds, err)
+		return c.killAgent(id)
+	}
+	return nil
+}
+
+func (c *client) killAgent(id string) error {
+	err := c.docker.KillContainer(godocker.KillContainerOptions{
+		ID:     id,
+		Signal: godocker.SIGKILL,
+	})
+	if _, ok := err.(*godocker.ContainerNotRunning); ok {
+		log.Info("Agent is already stopped")
+		return nil
+	}
 	return err
 }
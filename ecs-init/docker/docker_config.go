@@ -30,7 +30,7 @@ func getPlatformSpecificEnvVariables() map[string]string {
 
 // createHostConfig creates the host config for the ECS Agent container
 // It mounts leases and pid file directories when built for Amazon Linux AMI
-func createHostConfig(binds []string) *godocker.HostConfig {
+func createHostConfig(cfg *config.Config, binds []string) *godocker.HostConfig {
 	binds = append(binds,
 		config.ProcFS+":"+hostProcDir+readOnly,
 		iptablesUsrLibDir+":"+iptablesUsrLibDir+readOnly,
@@ -42,10 +42,10 @@ func createHostConfig(binds []string) *godocker.HostConfig {
 		iptablesLegacyDir+":"+iptablesLegacyDir+readOnly,
 	)
 
-	logConfig := config.AgentDockerLogDriverConfiguration()
+	logConfig := cfg.AgentDockerLogDriverConfiguration()
 
 	var caps []string
-	if !config.RunningInExternal() {
+	if !cfg.External {
 		// CapNetAdmin and CapSysAdmin are needed for running task in awsvpc network mode.
 		// This network mode is (at least currently) not supported in external environment,
 		// hence not adding them in that case.
@@ -61,7 +61,7 @@ func createHostConfig(binds []string) *godocker.HostConfig {
 		Init:        true,
 	}
 
-	if config.RunPrivileged() {
+	if cfg.RunPrivileged {
 		hostConfig.Privileged = true
 	}
 
@@ -14,11 +14,13 @@
 package docker
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-init/ecs-init/config"
 	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
@@ -48,6 +50,7 @@ func TestIsAgentImageLoadedListFailure(t *testing.T) {
 	mockDocker.EXPECT().ListImages(godocker.ListImagesOptions{All: true}).Return(nil, errors.New("test error"))
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 	}
 	loaded, err := client.IsAgentImageLoaded()
@@ -67,6 +70,7 @@ func TestIsAgentImageLoadedNoMatches(t *testing.T) {
 		}), nil)
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 	}
 	loaded, err := client.IsAgentImageLoaded()
@@ -86,6 +90,7 @@ func TestIsImageLoadedMatches(t *testing.T) {
 		}), nil)
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 	}
 	loaded, err := client.IsAgentImageLoaded()
@@ -102,12 +107,40 @@ func TestLoadImage(t *testing.T) {
 	mockDocker.EXPECT().LoadImage(godocker.LoadImageOptions{})
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 	}
 	err := client.LoadImage(nil)
 	assert.NoError(t, err, "no errors should be returned on load image with nil image")
 }
 
+func TestTagAgentImageKnownGood(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerclient(mockCtrl)
+
+	gomock.InOrder(
+		mockDocker.EXPECT().TagImage(config.AgentImageName, godocker.TagImageOptions{
+			Repo:  "amazon/amazon-ecs-agent",
+			Tag:   "known-good",
+			Force: true,
+		}),
+		mockDocker.EXPECT().TagImage(config.AgentKnownGoodImageName, godocker.TagImageOptions{
+			Repo:  "amazon/amazon-ecs-agent",
+			Tag:   "latest",
+			Force: true,
+		}),
+	)
+
+	client := &client{
+		config: config.Defaults(),
+		docker: mockDocker,
+	}
+	assert.NoError(t, client.TagAgentImageKnownGood())
+	assert.NoError(t, client.RestoreKnownGoodAgentImage())
+}
+
 func TestRemoveExistingAgentContainerListContainersFailure(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -122,6 +155,7 @@ func TestRemoveExistingAgentContainerListContainersFailure(t *testing.T) {
 	}).Return(nil, errors.New("test error"))
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 	}
 	err := client.RemoveExistingAgentContainer()
@@ -144,6 +178,7 @@ func TestRemoveExistingAgentContainerNoneFound(t *testing.T) {
 	})
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 	}
 	err := client.RemoveExistingAgentContainer()
@@ -175,6 +210,7 @@ func TestRemoveExistingAgentContainer(t *testing.T) {
 	})
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 	}
 	err := client.RemoveExistingAgentContainer()
@@ -209,6 +245,7 @@ func TestStartAgentNoEnvFile(t *testing.T) {
 	mockDocker.EXPECT().WaitContainer(containerID)
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 		fs:     mockFS,
 	}
@@ -265,7 +302,7 @@ func validateCommonCreateContainerOptions(opts godocker.CreateContainerOptions,
 	for _, binding := range hostCfg.Binds {
 		binds[binding] = struct{}{}
 	}
-	defaultDockerSocket, _ := config.DockerUnixSocket()
+	defaultDockerSocket, _ := config.Defaults().DockerUnixSocket()
 	expectKey(defaultDockerSocket+":"+defaultDockerSocket, binds, t)
 	expectKey(config.LogDirectory()+":/log", binds, t)
 	expectKey(config.AgentDataDirectory()+":/data", binds, t)
@@ -355,6 +392,7 @@ func TestStartAgentEnvFile(t *testing.T) {
 	mockDocker.EXPECT().WaitContainer(containerID)
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 		fs:     mockFS,
 	}
@@ -415,6 +453,7 @@ func TestStartAgentWithGPUConfig(t *testing.T) {
 	mockDocker.EXPECT().WaitContainer(containerID)
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 		fs:     mockFS,
 	}
@@ -464,6 +503,7 @@ func TestStartAgentWithGPUConfigNoDevices(t *testing.T) {
 	mockDocker.EXPECT().WaitContainer(containerID)
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 		fs:     mockFS,
 	}
@@ -484,7 +524,8 @@ func TestGetContainerConfigWithFileOverrides(t *testing.T) {
 	mockFS.EXPECT().ReadFile(config.AgentConfigFile()).Return([]byte(envFile), nil)
 
 	client := &client{
-		fs: mockFS,
+		config: config.Defaults(),
+		fs:     mockFS,
 	}
 	envVarsFromFiles := client.LoadEnvVars()
 	cfg := client.getContainerConfig(envVarsFromFiles)
@@ -494,7 +535,7 @@ func TestGetContainerConfigWithFileOverrides(t *testing.T) {
 		envVariables[envVar] = struct{}{}
 	}
 	expectKey("ECS_UPDATES_ENABLED=false", envVariables, t)
-	expectKey("AGENT_TEST_VAR2=\"val2\"=val2", envVariables, t)
+	expectKey("AGENT_TEST_VAR2=val2=val2", envVariables, t)
 	if _, ok := envVariables["ECS_UPDATES_ENABLED=true"]; ok {
 		t.Errorf("Did not expect ECS_UPDATES_ENABLED=true to be defined")
 	}
@@ -502,10 +543,9 @@ func TestGetContainerConfigWithFileOverrides(t *testing.T) {
 }
 
 func TestGetContainerConfigExternal(t *testing.T) {
-	os.Setenv(config.ExternalEnvVar, "true")
-	defer os.Unsetenv(config.ExternalEnvVar)
-
-	client := &client{}
+	client := &client{
+		config: &config.Config{External: true},
+	}
 	cfg := client.getContainerConfig(map[string]string{})
 	assert.Contains(t, cfg.Env, "ECS_ENABLE_TASK_ENI=false")
 }
@@ -529,7 +569,8 @@ func TestGetInstanceConfig(t *testing.T) {
 	mockFS.EXPECT().ReadFile(config.AgentConfigFile()).Return(nil, errors.New("not found"))
 
 	client := &client{
-		fs: mockFS,
+		config: config.Defaults(),
+		fs:     mockFS,
 	}
 	envVarsFromFiles := client.LoadEnvVars()
 	cfg := client.getContainerConfig(envVarsFromFiles)
@@ -560,7 +601,8 @@ func TestGetNonGPUInstanceConfig(t *testing.T) {
 	mockFS.EXPECT().ReadFile(config.AgentConfigFile()).Return(nil, errors.New("not found"))
 
 	client := &client{
-		fs: mockFS,
+		config: config.Defaults(),
+		fs:     mockFS,
 	}
 	envVarsFromFiles := client.LoadEnvVars()
 	cfg := client.getContainerConfig(envVarsFromFiles)
@@ -587,7 +629,8 @@ func TestGetConfigOverrides(t *testing.T) {
 	mockFS.EXPECT().ReadFile(config.AgentConfigFile()).Return([]byte(userEnvFile), nil)
 
 	client := &client{
-		fs: mockFS,
+		config: config.Defaults(),
+		fs:     mockFS,
 	}
 	envVarsFromFiles := client.LoadEnvVars()
 	cfg := client.getContainerConfig(envVarsFromFiles)
@@ -606,6 +649,7 @@ func TestStopAgent(t *testing.T) {
 		listEmpty            bool
 		stopFailedNotRunning bool
 		stopFailedOther      bool
+		killSucceeded        bool
 		expectedError        bool
 	}{
 		{
@@ -627,6 +671,11 @@ func TestStopAgent(t *testing.T) {
 			name:            "List containers succeeded, stop agent failed on error other than not running",
 			stopFailedOther: true,
 		},
+		{
+			name:            "List containers succeeded, stop agent failed, kill agent succeeded",
+			stopFailedOther: true,
+			killSucceeded:   true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -636,6 +685,7 @@ func TestStopAgent(t *testing.T) {
 
 			mockDocker := NewMockdockerclient(mockCtrl)
 			client := &client{
+				config: config.Defaults(),
 				docker: mockDocker,
 			}
 
@@ -675,8 +725,18 @@ func TestStopAgent(t *testing.T) {
 			if !tc.listEmpty && !tc.listFailed {
 				mockDocker.EXPECT().StopContainer("id", uint(10)).Return(stopErr)
 			}
+			if tc.stopFailedOther {
+				var killErr error
+				if !tc.killSucceeded {
+					killErr = errors.New("test error")
+				}
+				mockDocker.EXPECT().KillContainer(godocker.KillContainerOptions{
+					ID:     "id",
+					Signal: godocker.SIGKILL,
+				}).Return(killErr)
+			}
 
-			if tc.listFailed || tc.stopFailedOther {
+			if tc.listFailed || (tc.stopFailedOther && !tc.killSucceeded) {
 				assert.Error(t, client.StopAgent())
 			} else {
 				assert.NoError(t, client.StopAgent())
@@ -685,6 +745,180 @@ func TestStopAgent(t *testing.T) {
 	}
 }
 
+func TestStopAgentTimeoutRoundedUp(t *testing.T) {
+	testCases := []struct {
+		timeout  time.Duration
+		expected uint
+	}{
+		{500 * time.Millisecond, 1},
+		{1500 * time.Millisecond, 2},
+		{2 * time.Second, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.timeout.String(), func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockDocker := NewMockdockerclient(mockCtrl)
+			cfg := config.Defaults()
+			cfg.AgentStopTimeout = tc.timeout
+			client := &client{
+				config: cfg,
+				docker: mockDocker,
+			}
+
+			mockDocker.EXPECT().ListContainers(gomock.Any()).Return([]godocker.APIContainers{
+				{
+					Names: []string{"/" + config.AgentContainerName},
+					ID:    "id",
+				},
+			}, nil)
+			mockDocker.EXPECT().StopContainer("id", tc.expected)
+
+			assert.NoError(t, client.StopAgent())
+		})
+	}
+}
+
+func TestGetAgentContainerState(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerclient(mockCtrl)
+	client := &client{
+		config: config.Defaults(),
+		docker: mockDocker,
+	}
+
+	finishedAt := time.Now()
+	gomock.InOrder(
+		mockDocker.EXPECT().ListContainers(gomock.Any()).Return([]godocker.APIContainers{
+			{
+				Names: []string{"/" + config.AgentContainerName},
+				ID:    "id",
+			},
+		}, nil),
+		mockDocker.EXPECT().InspectContainer("id").Return(&godocker.Container{
+			ID:     "id",
+			Config: &godocker.Config{Image: config.AgentImageName},
+			State: godocker.State{
+				Status:     "exited",
+				ExitCode:   2,
+				OOMKilled:  true,
+				FinishedAt: finishedAt,
+			},
+		}, nil),
+	)
+
+	state, err := client.GetAgentContainerState()
+	assert.NoError(t, err)
+	assert.Equal(t, "id", state.ID)
+	assert.Equal(t, config.AgentImageName, state.Image)
+	assert.False(t, state.Running)
+	assert.Equal(t, 2, state.ExitCode)
+	assert.True(t, state.OOMKilled)
+	assert.Equal(t, finishedAt, state.FinishedAt)
+}
+
+func TestGetAgentContainerStateNoContainer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerclient(mockCtrl)
+	client := &client{
+		config: config.Defaults(),
+		docker: mockDocker,
+	}
+
+	mockDocker.EXPECT().ListContainers(gomock.Any()).Return([]godocker.APIContainers{}, nil)
+
+	state, err := client.GetAgentContainerState()
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestGetAgentContainerLogs(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerclient(mockCtrl)
+	client := &client{
+		config: config.Defaults(),
+		docker: mockDocker,
+	}
+
+	gomock.InOrder(
+		mockDocker.EXPECT().ListContainers(gomock.Any()).Return([]godocker.APIContainers{
+			{
+				Names: []string{"/" + config.AgentContainerName},
+				ID:    "id",
+			},
+		}, nil),
+		mockDocker.EXPECT().Logs(gomock.Any()).Do(func(opts godocker.LogsOptions) {
+			assert.Equal(t, "id", opts.Container)
+			assert.Empty(t, opts.Tail, "expected the full logs")
+			opts.OutputStream.Write([]byte("agent log\n"))
+		}),
+	)
+
+	var logs bytes.Buffer
+	assert.NoError(t, client.GetAgentContainerLogs(&logs))
+	assert.Equal(t, "agent log\n", logs.String())
+}
+
+func TestInspectAgentContainerNoContainer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerclient(mockCtrl)
+	client := &client{
+		config: config.Defaults(),
+		docker: mockDocker,
+	}
+
+	mockDocker.EXPECT().ListContainers(gomock.Any()).Return([]godocker.APIContainers{}, nil)
+
+	container, err := client.InspectAgentContainer()
+	assert.NoError(t, err)
+	assert.Nil(t, container)
+}
+
+func TestCheckServerAPIVersion(t *testing.T) {
+	testCases := []struct {
+		apiVersion    string
+		expectedError bool
+	}{
+		{apiVersion: "1.41"},
+		{apiVersion: dockerClientAPIVersion},
+		{apiVersion: "1.24", expectedError: true},
+		{apiVersion: "", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.apiVersion, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockDocker := NewMockdockerclient(mockCtrl)
+			client := &client{
+				config: config.Defaults(),
+				docker: mockDocker,
+			}
+			env := &godocker.Env{}
+			env.Set("ApiVersion", tc.apiVersion)
+			mockDocker.EXPECT().Version().Return(env, nil)
+
+			err := client.CheckServerAPIVersion()
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestContainerLabels(t *testing.T) {
 	testData := `{"test.label.1":"value1","test.label.2":"value2"}`
 	out, err := generateLabelMap(testData)
@@ -781,18 +1015,14 @@ func TestGetDockerSocketBind(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			os.Setenv("DOCKER_HOST", tc.dockerHostFromEnv)
-			defer os.Unsetenv("DOCKER_HOST")
-
-			bind := getDockerSocketBind(map[string]string{"DOCKER_HOST": tc.dockerHostFromConfigFile})
+			cfg := &config.Config{DockerHost: tc.dockerHostFromEnv}
+			bind := getDockerSocketBind(cfg, map[string]string{"DOCKER_HOST": tc.dockerHostFromConfigFile})
 			assert.Equal(t, tc.expectedBind, bind)
 		})
 	}
 }
 
 func TestGetHostConfigExternal(t *testing.T) {
-	os.Setenv(config.ExternalEnvVar, "true")
-	defer os.Unsetenv(config.ExternalEnvVar)
 
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -803,14 +1033,17 @@ func TestGetHostConfigExternal(t *testing.T) {
 
 	credsBind := "/root/.aws:/rotatingcreds:ro"
 
+	cfg := config.Defaults()
+	cfg.External = true
 	client := &client{
-		fs: mockFS,
+		config: cfg,
+		fs:     mockFS,
 	}
 	hostConfig := client.getHostConfig(map[string]string{})
 	assert.Contains(t, hostConfig.Binds, credsBind)
 	assert.Empty(t, hostConfig.CapAdd)
 
-	os.Setenv(config.ExternalEnvVar, "false")
+	cfg.External = false
 	hostConfig = client.getHostConfig(map[string]string{})
 	assert.NotContains(t, hostConfig.Binds, credsBind)
 	assert.NotEmpty(t, hostConfig.CapAdd)
@@ -861,6 +1094,7 @@ func TestStartAgentWithExecBinds(t *testing.T) {
 	mockDocker.EXPECT().WaitContainer(containerID)
 
 	client := &client{
+		config: config.Defaults(),
 		docker: mockDocker,
 		fs:     mockFS,
 	}
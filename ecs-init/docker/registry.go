@@ -0,0 +1,186 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	log "github.com/cihub/seelog"
+	godocker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+)
+
+const (
+	// pullTimeout bounds how long pulling the Agent image may take
+	pullTimeout = 15 * time.Minute
+	// pullInactivityTimeout is how long a pull may go without progress
+	// before the registry is considered unreachable
+	pullInactivityTimeout = 1 * time.Minute
+	// dockerHubRegistry is the registry of the references without a
+	// registry host, as written in Docker config.json files
+	dockerHubRegistry = "index.docker.io"
+)
+
+// Injection point for testing purposes
+var loadDefaultAuthConfigurations = godocker.NewAuthConfigurationsFromDockerCfg
+
+// DigestMismatchError is returned when the image pulled from the registry
+// doesn't have the digest its reference is pinned to
+type DigestMismatchError struct {
+	Reference string
+	Digests   []string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("pulled image %s has digests %s instead of the pinned digest",
+		e.Reference, strings.Join(e.Digests, ", "))
+}
+
+// imageReference is a reference to an image in a registry
+type imageReference struct {
+	// repository includes the registry host, if any
+	repository string
+	tag        string
+	digest     string
+}
+
+func parseImageReference(reference string) *imageReference {
+	name, digest := reference, ""
+	if i := strings.Index(reference, "@"); i >= 0 {
+		name, digest = reference[:i], reference[i+1:]
+	}
+	repository, tag := godocker.ParseRepositoryTag(name)
+	return &imageReference{repository: repository, tag: tag, digest: digest}
+}
+
+// pinned returns the reference of the image by digest if it's pinned to one,
+// by tag otherwise
+func (r *imageReference) pinned() string {
+	if r.digest != "" {
+		return r.repository + "@" + r.digest
+	}
+	if r.tag == "" {
+		return r.repository + ":latest"
+	}
+	return r.repository + ":" + r.tag
+}
+
+// registry returns the host of the registry of the image. Like Docker, the
+// first component of the repository is only a host if it's localhost or has
+// a dot or a port.
+func (r *imageReference) registry() string {
+	i := strings.Index(r.repository, "/")
+	if i < 0 {
+		return dockerHubRegistry
+	}
+	host := r.repository[:i]
+	if host != "localhost" && !strings.ContainsAny(host, ".:") {
+		return dockerHubRegistry
+	}
+	return host
+}
+
+// PullAgentImage pulls the image of reference from its registry and tags it
+// as the Agent image. The credentials of the registry are read from
+// authFile, a Docker config.json, or from the default Docker config.json if
+// authFile is empty. If reference is pinned to a digest, the pulled image
+// must have it, or a *DigestMismatchError is returned.
+func (c *client) PullAgentImage(reference, authFile string) error {
+	ref := parseImageReference(reference)
+	auth, err := registryAuth(ref.registry(), authFile)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pullTimeout)
+	defer cancel()
+	pinned := ref.pinned()
+	log.Infof("Pulling the Agent image %s", pinned)
+	err = c.docker.PullImage(godocker.PullImageOptions{
+		Repository:        pinned,
+		InactivityTimeout: pullInactivityTimeout,
+		Context:           ctx,
+	}, auth)
+	if err != nil {
+		return errors.Wrapf(err, "could not pull %s", pinned)
+	}
+	if ref.digest != "" {
+		err = c.checkDigest(pinned, ref)
+		if err != nil {
+			return err
+		}
+	}
+	return c.tagImage(pinned, config.AgentImageName)
+}
+
+// checkDigest returns a *DigestMismatchError unless the pulled image has the
+// digest of ref
+func (c *client) checkDigest(pinned string, ref *imageReference) error {
+	image, err := c.docker.InspectImage(pinned)
+	if err != nil {
+		return errors.Wrapf(err, "could not inspect %s", pinned)
+	}
+	for _, repoDigest := range image.RepoDigests {
+		if strings.HasSuffix(repoDigest, "@"+ref.digest) {
+			return nil
+		}
+	}
+	return &DigestMismatchError{Reference: pinned, Digests: image.RepoDigests}
+}
+
+// registryAuth returns the credentials of registry in authFile, or in the
+// default Docker config.json if authFile is empty. The registry is pulled
+// from anonymously if there aren't any.
+func registryAuth(registry, authFile string) (godocker.AuthConfiguration, error) {
+	var auths *godocker.AuthConfigurations
+	var err error
+	if authFile != "" {
+		auths, err = godocker.NewAuthConfigurationsFromFile(authFile)
+		if err != nil {
+			return godocker.AuthConfiguration{}, errors.Wrapf(err, "could not read the registry credentials in %s", authFile)
+		}
+	} else {
+		auths, err = loadDefaultAuthConfigurations()
+		if err != nil {
+			log.Debugf("No registry credentials in the default Docker config.json: %v", err)
+			return godocker.AuthConfiguration{}, nil
+		}
+	}
+	for server, auth := range auths.Configs {
+		if registryHost(server) == registry {
+			return auth, nil
+		}
+	}
+	log.Infof("No credentials for registry %s, pulling anonymously", registry)
+	return godocker.AuthConfiguration{}, nil
+}
+
+// registryHost returns the host of a registry as written in the auths of a
+// Docker config.json, which may be a URL such as
+// "https://index.docker.io/v1/"
+func registryHost(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	switch host {
+	case "docker.io", "registry-1.docker.io":
+		return dockerHubRegistry
+	}
+	return host
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	godocker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDigest = "sha256:" + strings.Repeat("0123456789abcdef", 4)
+
+func TestParseImageReference(t *testing.T) {
+	for reference, expected := range map[string][2]string{
+		"amazon/amazon-ecs-agent":                           {dockerHubRegistry, "amazon/amazon-ecs-agent:latest"},
+		"public.ecr.aws/ecs/amazon-ecs-agent:v1.2.3":        {"public.ecr.aws", "public.ecr.aws/ecs/amazon-ecs-agent:v1.2.3"},
+		"localhost:5000/agent:v1@" + testDigest:             {"localhost:5000", "localhost:5000/agent@" + testDigest},
+		"registry.example.com:5000/ecs/agent@" + testDigest: {"registry.example.com:5000", "registry.example.com:5000/ecs/agent@" + testDigest},
+	} {
+		ref := parseImageReference(reference)
+		assert.Equal(t, expected[0], ref.registry(), reference)
+		assert.Equal(t, expected[1], ref.pinned(), reference)
+	}
+}
+
+func TestRegistryAuthFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", testTempDirPrefix)
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	authFile := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(authFile, []byte(`{"auths": {
+		"https://index.docker.io/v1/": {"auth": "aHViOnNlY3JldA=="},
+		"registry.example.com:5000": {"auth": "dXNlcjpwYXNzd29yZA=="}
+	}}`), 0600))
+
+	auth, err := registryAuth("registry.example.com:5000", authFile)
+	require.NoError(t, err)
+	assert.Equal(t, "user", auth.Username)
+	assert.Equal(t, "password", auth.Password)
+
+	auth, err = registryAuth(dockerHubRegistry, authFile)
+	require.NoError(t, err)
+	assert.Equal(t, "hub", auth.Username)
+
+	auth, err = registryAuth("public.ecr.aws", authFile)
+	require.NoError(t, err)
+	assert.Equal(t, godocker.AuthConfiguration{}, auth)
+
+	_, err = registryAuth(dockerHubRegistry, filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestRegistryAuthWithoutDefaultConfig(t *testing.T) {
+	loadDefaultAuthConfigurationsBkp := loadDefaultAuthConfigurations
+	defer func() {
+		loadDefaultAuthConfigurations = loadDefaultAuthConfigurationsBkp
+	}()
+	loadDefaultAuthConfigurations = func() (*godocker.AuthConfigurations, error) {
+		return nil, errors.New("no docker configuration found")
+	}
+
+	auth, err := registryAuth(dockerHubRegistry, "")
+	assert.NoError(t, err)
+	assert.Equal(t, godocker.AuthConfiguration{}, auth)
+}
+
+func TestPullAgentImage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDocker := NewMockdockerclient(mockCtrl)
+	pinned := "public.ecr.aws/ecs/amazon-ecs-agent@" + testDigest
+
+	gomock.InOrder(
+		mockDocker.EXPECT().PullImage(gomock.Any(), godocker.AuthConfiguration{}).Do(
+			func(opts godocker.PullImageOptions, auth godocker.AuthConfiguration) {
+				assert.Equal(t, pinned, opts.Repository)
+				assert.Equal(t, pullInactivityTimeout, opts.InactivityTimeout)
+			}),
+		mockDocker.EXPECT().InspectImage(pinned).Return(&godocker.Image{RepoDigests: []string{pinned}}, nil),
+		mockDocker.EXPECT().TagImage(pinned, godocker.TagImageOptions{
+			Repo:  "amazon/amazon-ecs-agent",
+			Tag:   "latest",
+			Force: true,
+		}),
+	)
+
+	client := &client{config: config.Defaults(), docker: mockDocker}
+	dir, err := ioutil.TempDir("", testTempDirPrefix)
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	authFile := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(authFile, []byte(`{"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`), 0600))
+	assert.NoError(t, client.PullAgentImage("public.ecr.aws/ecs/amazon-ecs-agent:v1@"+testDigest, authFile))
+}
+
+func TestPullAgentImageDigestMismatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDocker := NewMockdockerclient(mockCtrl)
+	pinned := "localhost:5000/agent@" + testDigest
+	loadDefaultAuthConfigurationsBkp := loadDefaultAuthConfigurations
+	defer func() {
+		loadDefaultAuthConfigurations = loadDefaultAuthConfigurationsBkp
+	}()
+	loadDefaultAuthConfigurations = func() (*godocker.AuthConfigurations, error) {
+		return &godocker.AuthConfigurations{}, nil
+	}
+
+	mockDocker.EXPECT().PullImage(gomock.Any(), gomock.Any())
+	mockDocker.EXPECT().InspectImage(pinned).Return(&godocker.Image{
+		RepoDigests: []string{"localhost:5000/agent@sha256:" + strings.Repeat("f", 64)},
+	}, nil)
+
+	client := &client{config: config.Defaults(), docker: mockDocker}
+	err := client.PullAgentImage(pinned, "")
+	require.Error(t, err)
+	_, ok := err.(*DigestMismatchError)
+	assert.True(t, ok, "expected a DigestMismatchError, got %v", err)
+}
+
+func TestPullAgentImageFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDocker := NewMockdockerclient(mockCtrl)
+	loadDefaultAuthConfigurationsBkp := loadDefaultAuthConfigurations
+	defer func() {
+		loadDefaultAuthConfigurations = loadDefaultAuthConfigurationsBkp
+	}()
+	loadDefaultAuthConfigurations = func() (*godocker.AuthConfigurations, error) {
+		return &godocker.AuthConfigurations{}, nil
+	}
+
+	mockDocker.EXPECT().PullImage(gomock.Any(), gomock.Any()).Return(errors.New("registry unreachable"))
+
+	client := &client{config: config.Defaults(), docker: mockDocker}
+	err := client.PullAgentImage("amazon/amazon-ecs-agent:latest", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/exec"
+)
+
+const (
+	procSysDir = "/proc/sys"
+	mebibyte   = 1024 * 1024
+)
+
+// Injection points for testing purposes
+var (
+	statPath = os.Stat
+	statFS   = syscall.Statfs
+)
+
+// ExecutableCheck verifies that an executable can be found in the PATH
+type ExecutableCheck struct {
+	Exec       exec.Exec
+	Executable string
+	Package    string
+}
+
+// Name returns a short description of what is being checked
+func (c *ExecutableCheck) Name() string {
+	return fmt.Sprintf("%s executable", c.Executable)
+}
+
+// Run performs the check
+func (c *ExecutableCheck) Run() Finding {
+	path, err := c.Exec.LookPath(c.Executable)
+	if err != nil {
+		return Finding{
+			Result:      Fail,
+			Message:     fmt.Sprintf("not found in PATH: %v", err),
+			Remediation: fmt.Sprintf("install the %s package", c.Package),
+		}
+	}
+	return Finding{Result: Pass, Message: fmt.Sprintf("found at %s", path)}
+}
+
+// PathCheck verifies that a file or directory exists on the host
+type PathCheck struct {
+	Path string
+	// Description is what the path is used for
+	Description string
+	// Missing is the result reported when the path does not exist
+	Missing     Result
+	Remediation string
+}
+
+// Name returns a short description of what is being checked
+func (c *PathCheck) Name() string {
+	return c.Description
+}
+
+// Run performs the check
+func (c *PathCheck) Run() Finding {
+	_, err := statPath(c.Path)
+	if err != nil {
+		return Finding{
+			Result:      c.Missing,
+			Message:     fmt.Sprintf("%s does not exist", c.Path),
+			Remediation: c.Remediation,
+		}
+	}
+	return Finding{Result: Pass, Message: fmt.Sprintf("%s exists", c.Path)}
+}
+
+// SysctlKeyCheck verifies that the kernel exposes a sysctl key
+type SysctlKeyCheck struct {
+	Key string
+	// Missing is the result reported when the key does not exist
+	Missing     Result
+	Remediation string
+}
+
+// Name returns a short description of what is being checked
+func (c *SysctlKeyCheck) Name() string {
+	return fmt.Sprintf("sysctl %s", c.Key)
+}
+
+// Run performs the check
+func (c *SysctlKeyCheck) Run() Finding {
+	path := filepath.Join(procSysDir, strings.Replace(c.Key, ".", "/", -1))
+	_, err := statPath(path)
+	if err != nil {
+		return Finding{
+			Result:      c.Missing,
+			Message:     "key is not present in the running kernel",
+			Remediation: c.Remediation,
+		}
+	}
+	return Finding{Result: Pass, Message: "key is present"}
+}
+
+// FreeSpaceCheck verifies that the filesystem holding a directory has enough
+// free space. If the directory doesn't exist yet, its closest existing
+// parent is checked instead.
+type FreeSpaceCheck struct {
+	Path        string
+	WarnBelowMB uint64
+	FailBelowMB uint64
+}
+
+// Name returns a short description of what is being checked
+func (c *FreeSpaceCheck) Name() string {
+	return fmt.Sprintf("free disk space in %s", c.Path)
+}
+
+// Run performs the check
+func (c *FreeSpaceCheck) Run() Finding {
+	var stat syscall.Statfs_t
+	path := c.Path
+	err := statFS(path, &stat)
+	for err != nil && path != filepath.Dir(path) {
+		path = filepath.Dir(path)
+		err = statFS(path, &stat)
+	}
+	if err != nil {
+		return Finding{Result: Warn, Message: fmt.Sprintf("could not determine free space: %v", err)}
+	}
+	freeMB := stat.Bavail * uint64(stat.Bsize) / mebibyte
+	message := fmt.Sprintf("%d MiB available", freeMB)
+	remediation := fmt.Sprintf("free up space on the filesystem holding %s", c.Path)
+	switch {
+	case freeMB < c.FailBelowMB:
+		return Finding{Result: Fail, Message: message, Remediation: remediation}
+	case freeMB < c.WarnBelowMB:
+		return Finding{Result: Warn, Message: message, Remediation: remediation}
+	}
+	return Finding{Result: Pass, Message: message}
+}
+
+// FuncCheck adapts a function returning an error to the Check interface
+type FuncCheck struct {
+	Description string
+	Func        func() error
+	// Failed is the result reported when the function returns an error
+	Failed      Result
+	Remediation string
+	// Success is the message reported when the function succeeds
+	Success string
+}
+
+// Name returns a short description of what is being checked
+func (c *FuncCheck) Name() string {
+	return c.Description
+}
+
+// Run performs the check
+func (c *FuncCheck) Run() Finding {
+	err := c.Func()
+	if err != nil {
+		return Finding{Result: c.Failed, Message: err.Error(), Remediation: c.Remediation}
+	}
+	return Finding{Result: Pass, Message: c.Success}
+}
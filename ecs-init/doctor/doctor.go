@@ -0,0 +1,114 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package doctor provides preflight checks that validate the host
+// prerequisites of the ECS Agent before it is started.
+package doctor
+
+import (
+	"fmt"
+	"io"
+)
+
+// Result is the outcome of a single check
+type Result int
+
+const (
+	// Pass indicates that the prerequisite is met
+	Pass Result = iota
+	// Warn indicates that the prerequisite is not met, but the ECS Agent
+	// may still be able to run
+	Warn
+	// Fail indicates that the prerequisite is not met and the ECS Agent is
+	// not expected to run
+	Fail
+)
+
+func (r Result) String() string {
+	switch r {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// Finding describes the outcome of a check
+type Finding struct {
+	Result      Result
+	Message     string
+	Remediation string
+}
+
+// Check is a single host prerequisite
+type Check interface {
+	// Name returns a short description of what is being checked
+	Name() string
+	// Run performs the check
+	Run() Finding
+}
+
+// Report holds the findings of a set of checks, in the order in which the
+// checks were run
+type Report struct {
+	Checks   []Check
+	Findings []Finding
+}
+
+// Run runs all of the checks and returns their findings
+func Run(checks []Check) *Report {
+	report := &Report{}
+	for _, check := range checks {
+		report.Checks = append(report.Checks, check)
+		report.Findings = append(report.Findings, check.Run())
+	}
+	return report
+}
+
+// Count returns the number of findings with the given result
+func (r *Report) Count(result Result) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Result == result {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed returns true if any of the checks failed
+func (r *Report) Failed() bool {
+	return r.Count(Fail) > 0
+}
+
+// Write prints the findings along with remediation text for the checks
+// that did not pass
+func (r *Report) Write(w io.Writer) error {
+	for i, finding := range r.Findings {
+		_, err := fmt.Fprintf(w, "[%s] %s: %s\n", finding.Result, r.Checks[i].Name(), finding.Message)
+		if err != nil {
+			return err
+		}
+		if finding.Result != Pass && finding.Remediation != "" {
+			_, err = fmt.Fprintf(w, "       remediation: %s\n", finding.Remediation)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n",
+		r.Count(Pass), r.Count(Warn), r.Count(Fail))
+	return err
+}
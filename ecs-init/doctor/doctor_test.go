@@ -0,0 +1,125 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statMocks replaces the path and filesystem stat functions with test
+// doubles that only know about the given paths. The backup can be restored
+// by executing the returned function in a deferred manner.
+func statMocks(paths map[string]bool, freeBytes map[string]uint64) func() {
+	statPathBkp := statPath
+	statFSBkp := statFS
+	statPath = func(name string) (os.FileInfo, error) {
+		if paths[name] {
+			return nil, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	statFS = func(path string, stat *syscall.Statfs_t) error {
+		free, ok := freeBytes[path]
+		if !ok {
+			return os.ErrNotExist
+		}
+		stat.Bsize = 4096
+		stat.Bavail = free / 4096
+		return nil
+	}
+	return func() {
+		statPath = statPathBkp
+		statFS = statFSBkp
+	}
+}
+
+func TestExecutableCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockExec := NewMockExec(ctrl)
+	gomock.InOrder(
+		mockExec.EXPECT().LookPath("iptables").Return("/sbin/iptables", nil),
+		mockExec.EXPECT().LookPath("iptables").Return("", errors.New("not found")),
+	)
+	check := &ExecutableCheck{Exec: mockExec, Executable: "iptables", Package: "iptables"}
+
+	assert.Equal(t, Pass, check.Run().Result)
+	finding := check.Run()
+	assert.Equal(t, Fail, finding.Result)
+	assert.Equal(t, "install the iptables package", finding.Remediation)
+}
+
+func TestPathAndSysctlKeyChecks(t *testing.T) {
+	defer statMocks(map[string]bool{
+		"/var/run/docker.sock":                       true,
+		"/proc/sys/net/ipv4/conf/all/route_localnet": true,
+	}, nil)()
+
+	assert.Equal(t, Pass, (&PathCheck{Path: "/var/run/docker.sock", Missing: Fail}).Run().Result)
+	assert.Equal(t, Warn, (&PathCheck{Path: "/var/lib/ecs/data", Missing: Warn}).Run().Result)
+	assert.Equal(t, Pass, (&SysctlKeyCheck{Key: "net.ipv4.conf.all.route_localnet", Missing: Fail}).Run().Result)
+	assert.Equal(t, Fail, (&SysctlKeyCheck{Key: "net.ipv4.conf.default.route_localnet", Missing: Fail}).Run().Result)
+}
+
+func TestFreeSpaceCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		free     uint64
+		expected Result
+	}{
+		{"plenty", 2048 * mebibyte, Pass},
+		{"low", 512 * mebibyte, Warn},
+		{"exhausted", 128 * mebibyte, Fail},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the cache directory doesn't exist, so its parent is checked
+			defer statMocks(nil, map[string]uint64{"/var": tc.free})()
+			check := &FreeSpaceCheck{Path: "/var/cache/ecs", WarnBelowMB: 1024, FailBelowMB: 256}
+			assert.Equal(t, tc.expected, check.Run().Result)
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	checks := []Check{
+		&FuncCheck{Description: "ok", Func: func() error { return nil }, Success: "fine"},
+		&FuncCheck{Description: "meh", Func: func() error { return errors.New("meh") }, Failed: Warn},
+		&FuncCheck{
+			Description: "broken",
+			Func:        func() error { return errors.New("broken") },
+			Failed:      Fail,
+			Remediation: "fix it",
+		},
+	}
+	report := Run(checks)
+	assert.True(t, report.Failed())
+	assert.Equal(t, 1, report.Count(Pass))
+	assert.Equal(t, 1, report.Count(Warn))
+
+	out := &bytes.Buffer{}
+	require.NoError(t, report.Write(out))
+	assert.Contains(t, out.String(), "[PASS] ok: fine\n")
+	assert.Contains(t, out.String(), "[FAIL] broken: broken\n       remediation: fix it\n")
+	assert.Contains(t, out.String(), "1 passed, 1 warnings, 1 failed")
+}
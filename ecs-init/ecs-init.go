@@ -17,9 +17,14 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/control"
 	"github.com/aws/amazon-ecs-init/ecs-init/engine"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+	"github.com/aws/amazon-ecs-init/ecs-init/metrics"
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
 	"github.com/aws/amazon-ecs-init/ecs-init/version"
 
 	log "github.com/cihub/seelog"
@@ -27,13 +32,79 @@ import (
 
 // all supported commands
 const (
-	VERSION  = "version"
-	PRESTART = "pre-start"
-	START    = "start"
-	PRESTOP  = "pre-stop"
-	STOP     = "stop"
-	POSTSTOP = "post-stop"
-	RECACHE  = "reload-cache"
+	VERSION     = "version"
+	PRESTART    = "pre-start"
+	START       = "start"
+	PRESTOP     = "pre-stop"
+	STOP        = "stop"
+	POSTSTOP    = "post-stop"
+	RECACHE     = "reload-cache"
+	STATUS      = "status"
+	DOCTOR      = "doctor"
+	HISTORY     = "history"
+	COLLECTLOGS = "collect-logs"
+	CONFIG      = "config"
+
+	// actions sent to the running supervisor through its control socket
+	RESTARTAGENT = "restart-agent"
+	RELOADCONFIG = "reload-config"
+	PAUSE        = "pause"
+	RESUME       = "resume"
+	REPORTSTATE  = "report-state"
+
+	// subcommands of the config action
+	CONFIGVALIDATE = "validate"
+	CONFIGSHOW     = "show"
+)
+
+// reportActions are the actions that print a report to stdout
+var reportActions = map[string]bool{
+	STATUS:      true,
+	DOCTOR:      true,
+	REPORTSTATE: true,
+	HISTORY:     true,
+	COLLECTLOGS: true,
+	CONFIG:      true,
+}
+
+// controlCommands maps the actions that are sent to the running supervisor
+// to their control socket commands. Restart, pause and resume have no
+// one-shot action.
+var controlCommands = map[string]control.Command{
+	RESTARTAGENT: control.RestartAgent,
+	RELOADCONFIG: control.ReloadConfig,
+	PAUSE:        control.Pause,
+	RESUME:       control.Resume,
+	REPORTSTATE:  control.ReportState,
+}
+
+// per-action flags
+var (
+	statusFlags  = flag.NewFlagSet(STATUS, flag.ExitOnError)
+	statusOutput = statusFlags.String("output", engine.StatusOutputText,
+		"Output format of the status report, one of text or json")
+	historyFlags = flag.NewFlagSet(HISTORY, flag.ExitOnError)
+	historyTypes = historyFlags.String("type", "",
+		"Comma separated event types to print, such as agent-start,agent-exit")
+	historySince = historyFlags.String("since", "",
+		"Print events since a duration ago, such as 24h, or since an RFC 3339 timestamp")
+	historyCorrelation = historyFlags.String("correlation", "",
+		"Print the events with this correlation or invocation ID")
+	historyOutput = historyFlags.String("output", engine.StatusOutputText,
+		"Output format of the events, one of text or json")
+	collectLogsFlags  = flag.NewFlagSet(COLLECTLOGS, flag.ExitOnError)
+	collectLogsOutput = collectLogsFlags.String("output", "",
+		"Path of the support bundle, a timestamped file in the current directory by default")
+	collectLogsExclude = collectLogsFlags.String("exclude", "",
+		"Comma separated categories not to collect, of logs, config, state, docker and network")
+	configValidateFlags  = flag.NewFlagSet(CONFIG+" "+CONFIGVALIDATE, flag.ExitOnError)
+	configValidateOutput = configValidateFlags.String("output", engine.StatusOutputText,
+		"Output format of the problems found, one of text or json")
+	configShowFlags  = flag.NewFlagSet(CONFIG+" "+CONFIGSHOW, flag.ExitOnError)
+	configShowOrigin = configShowFlags.Bool("origin", false,
+		"Print the file and line each variable comes from")
+	configShowOutput = configShowFlags.String("output", engine.StatusOutputText,
+		"Output format of the variables, one of text or json")
 )
 
 func main() {
@@ -42,15 +113,24 @@ func main() {
 	args := flag.Args()
 
 	if len(args) == 0 {
-		usage(actions(nil))
+		usage(actions(nil, nil))
 		os.Exit(1)
 	}
 
-	logger, err := log.LoggerFromConfigAsString(config.Logger())
+	err := redact.RegisterFormatter()
+	if err != nil {
+		die(err, engine.DefaultInitErrorExitCode)
+	}
+	logger, err := log.LoggerFromConfigAsString(loggerConfig(args[0]))
 	if err != nil {
 		die(err, engine.DefaultInitErrorExitCode)
 	}
 	log.ReplaceLogger(logger)
+	journal.SetDefault(journal.New(config.EventJournalFile(), journal.DefaultMaxSize, journal.DefaultMaxFiles))
+	cfg := loadConfig(args[0])
+	if cfg.MetricsEnabled() {
+		metrics.SetDefault(metrics.New(config.MetricsStateFile(), cfg.MetricsTextfile))
+	}
 
 	if args[0] == VERSION {
 		err := version.PrintVersion()
@@ -60,22 +140,86 @@ func main() {
 		return
 	}
 
-	init, err := engine.New()
+	// history only reads the event journal
+	if args[0] == HISTORY {
+		historyFlags.Parse(args[1:])
+		err := runHistory()
+		if err != nil {
+			die(err, engine.DefaultInitErrorExitCode)
+		}
+		return
+	}
+
+	// collect-logs gathers what it can, even if creating the engine fails
+	if args[0] == COLLECTLOGS {
+		collectLogsFlags.Parse(args[1:])
+		err := runCollectLogs(cfg)()
+		if err != nil {
+			die(err, engine.DefaultInitErrorExitCode)
+		}
+		return
+	}
+
+	// config only reads the configuration files
+	if args[0] == CONFIG {
+		if len(args) < 2 || (args[1] != CONFIGVALIDATE && args[1] != CONFIGSHOW) {
+			configUsage()
+			os.Exit(1)
+		}
+		err := runConfig(args[1:])
+		if err != nil {
+			die(err, engine.DefaultInitErrorExitCode)
+		}
+		return
+	}
+
+	// doctor runs before the engine is created, as creating it requires
+	// some of the prerequisites being checked
+	if args[0] == DOCTOR {
+		err := runDoctor(cfg)()
+		if err != nil {
+			die(err, engine.DefaultInitErrorExitCode)
+		}
+		return
+	}
+
+	// control actions don't need an engine unless there is no running
+	// supervisor, in which case their one-shot action is run
+	if command, ok := controlCommands[args[0]]; ok {
+		err := engine.Control(command)
+		if err == nil {
+			return
+		}
+		if err == control.ErrNotRunning && args[0] == RESTARTAGENT {
+			// stopping the Agent isn't restarting it, nor is the stop
+			// a failure for a supervisor that couldn't listen
+			die(fmt.Errorf("%v, use systemctl restart ecs instead", err), engine.DefaultInitErrorExitCode)
+		}
+		if err != control.ErrNotRunning || args[0] == PAUSE || args[0] == RESUME {
+			die(err, engine.DefaultInitErrorExitCode)
+		}
+		log.Infof("%v, running the one-shot %s action", err, args[0])
+	}
+
+	init, err := engine.New(cfg)
 	if err != nil {
 		die(err, engine.DefaultInitErrorExitCode)
 	}
 	log.Info(args[0])
-	actions := actions(init)
+	actions := actions(init, cfg)
 	action, ok := actions[args[0]]
 	if !ok {
 		usage(actions)
 		os.Exit(1)
 	}
+	if action.flags != nil {
+		action.flags.Parse(args[1:])
+	}
 	err = action.function()
 
 	if err != nil {
 		if err, ok := err.(*engine.TerminalError); ok {
-			die(err, engine.TerminalFailureAgentExitCode)
+			die(err, err.ExitCode())
 		}
 		die(err, engine.DefaultInitErrorExitCode)
 	}
@@ -84,9 +228,10 @@ func main() {
 type action struct {
 	function    func() error
 	description string
+	flags       *flag.FlagSet
 }
 
-func actions(engine *engine.Engine) map[string]action {
+func actions(engine *engine.Engine, cfg *config.Config) map[string]action {
 	return map[string]action{
 		PRESTART: action{
 			function:    engine.PreStart,
@@ -114,9 +259,134 @@ func actions(engine *engine.Engine) map[string]action {
 			function:    engine.PostStop,
 			description: "Cleanup procedure for the ECS Agent",
 		},
+		STATUS: action{
+			function: func() error {
+				return engine.Status(*statusOutput)
+			},
+			description: "Report the health of the ECS Agent and its host setup",
+			flags:       statusFlags,
+		},
+		DOCTOR: action{
+			function:    runDoctor(cfg),
+			description: "Check the host prerequisites of the ECS Agent",
+		},
+		HISTORY: action{
+			function:    runHistory,
+			description: "Print the lifecycle events of the ECS Agent",
+			flags:       historyFlags,
+		},
+		COLLECTLOGS: action{
+			function:    runCollectLogs(cfg),
+			description: "Collect the logs, configuration and state of the ECS Agent into a support bundle",
+			flags:       collectLogsFlags,
+		},
+		CONFIG: action{
+			function: func() error {
+				return runConfig(flag.Args()[1:])
+			},
+			description: "Check or show the configuration files of the ECS Agent and ecs-init",
+		},
+		RESTARTAGENT: action{
+			function:    sendControl(RESTARTAGENT),
+			description: "Restart the ECS Agent through the running supervisor",
+		},
+		RELOADCONFIG: action{
+			function:    engine.ReloadConfig,
+			description: "Reload the configuration of the running supervisor",
+		},
+		PAUSE: action{
+			function:    sendControl(PAUSE),
+			description: "Stop restarting the ECS Agent, for maintenance",
+		},
+		RESUME: action{
+			function:    sendControl(RESUME),
+			description: "Restart the ECS Agent again after a pause",
+		},
+		REPORTSTATE: action{
+			function:    engine.ReportState,
+			description: "Report the state of the running supervisor",
+		},
+	}
+}
+
+func sendControl(action string) func() error {
+	return func() error {
+		return engine.Control(controlCommands[action])
+	}
+}
+
+func runHistory() error {
+	return engine.History(&engine.HistoryOptions{
+		Types:       *historyTypes,
+		Since:       *historySince,
+		Correlation: *historyCorrelation,
+		Output:      *historyOutput,
+	})
+}
+
+func runCollectLogs(cfg *config.Config) func() error {
+	return func() error {
+		return engine.CollectLogs(cfg, &engine.CollectLogsOptions{
+			Output:  *collectLogsOutput,
+			Exclude: *collectLogsExclude,
+		})
 	}
 }
 
+// runConfig runs the config subcommand named by the first argument
+func runConfig(args []string) error {
+	if len(args) > 0 && args[0] == CONFIGSHOW {
+		configShowFlags.Parse(args[1:])
+		return engine.ShowConfig(&engine.ShowConfigOptions{
+			Origin: *configShowOrigin,
+			Output: *configShowOutput,
+		})
+	}
+	if len(args) == 0 || args[0] != CONFIGVALIDATE {
+		return fmt.Errorf("unsupported %s subcommand, expected %s or %s", CONFIG, CONFIGVALIDATE, CONFIGSHOW)
+	}
+	configValidateFlags.Parse(args[1:])
+	return engine.ValidateConfig(&engine.ValidateConfigOptions{
+		Files:  configValidateFlags.Args(),
+		Output: *configValidateOutput,
+	})
+}
+
+func runDoctor(cfg *config.Config) func() error {
+	return func() error {
+		return engine.Doctor(cfg)
+	}
+}
+
+// loadConfig loads the configuration of ecs-init. Invalid settings fail the
+// actions that start the Agent, so that they aren't silently ignored, except
+// for the settings ecs-init always read leniently. Other actions warn and use
+// the defaults of these settings, so that the Agent can still be stopped and
+// diagnosed.
+func loadConfig(action string) *config.Config {
+	cfg, err := config.Load()
+	if err == nil {
+		return cfg
+	}
+	settingErrs, ok := err.(config.SettingErrors)
+	fatal := !ok || settingErrs.Fatal()
+	if fatal && (action == PRESTART || action == START || action == RECACHE) {
+		die(fmt.Errorf("invalid configuration: %v", err), engine.DefaultInitErrorExitCode)
+	}
+	log.Warnf("Invalid configuration, using defaults for the invalid settings: %v", err)
+	return cfg
+}
+
+// loggerConfig returns the seelog configuration for the action. Actions
+// that print a report to stdout only log to the log file, so that their
+// output isn't interleaved with log messages.
+func loggerConfig(action string) string {
+	if !reportActions[action] {
+		return config.Logger()
+	}
+	return strings.Replace(config.Logger(), `<console formatid="console" />`, "", 1)
+}
+
 func usage(actions map[string]action) {
 	fmt.Printf("Usage: %s ACTION\n", os.Args[0])
 	fmt.Println("")
@@ -127,6 +397,17 @@ func usage(actions map[string]action) {
 	fmt.Println("")
 }
 
+func configUsage() {
+	fmt.Printf("Usage: %s %s SUBCOMMAND\n", os.Args[0], CONFIG)
+	fmt.Println("")
+	fmt.Println(" Available subcommands:")
+	fmt.Printf("  %-15s  %s\n", CONFIGVALIDATE+" [--output json] [FILE...]",
+		"Check the configuration files for unknown keys, invalid values, duplicate keys and lines that can't be parsed")
+	fmt.Printf("  %-15s  %s\n", CONFIGSHOW+" [--origin] [--output json]",
+		"Print the effective variables of the configuration, with the file and line they come from with --origin")
+	fmt.Println("")
+}
+
 func die(err error, exitCode int) {
 	log.Error(err.Error())
 	log.Flush()
@@ -0,0 +1,227 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/bundle"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
+	"github.com/aws/amazon-ecs-init/ecs-init/volumes"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// Support bundle categories
+const (
+	// CategoryLogs are the logs of ecs-init, the Agent and the volume plugin
+	CategoryLogs = "logs"
+	// CategoryConfig are the configuration files of the Agent
+	CategoryConfig = "config"
+	// CategoryState are the state files of the agent cache, the volume
+	// plugin and the GPU setup
+	CategoryState = "state"
+	// CategoryDocker is the information of the Docker daemon and the Agent
+	// container
+	CategoryDocker = "docker"
+	// CategoryNetwork are the iptables rules and sysctl settings
+	CategoryNetwork = "network"
+)
+
+// collectCategories are the support bundle categories in the order they are
+// gathered
+var collectCategories = []string{CategoryLogs, CategoryConfig, CategoryState, CategoryDocker, CategoryNetwork}
+
+// collectLogPatterns are the patterns of the log files in the log directory
+// that are gathered, including rotated files
+var collectLogPatterns = []string{"ecs-init.log*", "ecs-init-events.log*", "ecs-agent.log*", "ecs-volume-plugin.log*"}
+
+// Injection point for testing purposes
+var collectLogDirectory = config.LogDirectory
+
+// CollectLogsOptions select what the collect-logs action gathers
+type CollectLogsOptions struct {
+	// Output is the path of the support bundle. It defaults to a
+	// timestamped file in the current directory.
+	Output string
+	// Exclude is a comma separated list of categories not to gather
+	Exclude string
+}
+
+// CollectLogs gathers the logs, configuration and state of ecs-init, the
+// Agent and the host into a support bundle, and prints its path. Failures to
+// gather individual files are listed in the bundle rather than failing it.
+func CollectLogs(cfg *config.Config, options *CollectLogsOptions) error {
+	categories, err := collectedCategories(options.Exclude)
+	if err != nil {
+		return err
+	}
+	path := options.Output
+	if path == "" {
+		path = fmt.Sprintf("ecs-init-support-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	w, err := bundle.Create(path)
+	if err != nil {
+		return engineError("could not create support bundle", err)
+	}
+	collect := collectors(cfg)
+	for _, category := range categories {
+		log.Infof("collect-logs: gathering %s", category)
+		collect[category](w)
+	}
+	err = w.Close()
+	if err != nil {
+		return engineError("could not write support bundle", err)
+	}
+	_, err = fmt.Fprintln(reportOutput, path)
+	return err
+}
+
+// collectedCategories returns the categories that aren't excluded
+func collectedCategories(exclude string) ([]string, error) {
+	excluded := map[string]bool{}
+	for _, category := range strings.Split(exclude, ",") {
+		category = strings.TrimSpace(category)
+		if category == "" {
+			continue
+		}
+		if !isCollectCategory(category) {
+			return nil, errors.Errorf("unknown category %q, expected one of %s",
+				category, strings.Join(collectCategories, ", "))
+		}
+		excluded[category] = true
+	}
+	var categories []string
+	for _, category := range collectCategories {
+		if !excluded[category] {
+			categories = append(categories, category)
+		}
+	}
+	return categories, nil
+}
+
+func isCollectCategory(category string) bool {
+	for _, c := range collectCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// collectors returns the functions gathering each category
+func collectors(cfg *config.Config) map[string]func(w *bundle.Writer) {
+	return map[string]func(w *bundle.Writer){
+		CategoryLogs:   collectLogFiles,
+		CategoryConfig: collectConfigFiles,
+		CategoryState:  collectStateFiles,
+		CategoryDocker: func(w *bundle.Writer) {
+			collectDocker(w, cfg)
+		},
+		CategoryNetwork: addHostNetworkState,
+	}
+}
+
+func collectLogFiles(w *bundle.Writer) {
+	var paths []string
+	for _, pattern := range collectLogPatterns {
+		matches, err := filepath.Glob(filepath.Join(collectLogDirectory(), pattern))
+		if err != nil {
+			w.Fail(pattern, err)
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		name := "logs/" + filepath.Base(path)
+		err := w.AddFile(name, path, diagnosticsMaxFileSize)
+		if err != nil {
+			w.Fail(name, err)
+		}
+	}
+}
+
+func collectConfigFiles(w *bundle.Writer) {
+	// both files are named ecs.config
+	addOptionalFile(w, "config/ecs.config", config.AgentConfigFile())
+	addOptionalFile(w, "config/instance.config", config.InstanceConfigFile())
+	agentFiles, err := config.AgentConfigFiles()
+	addDropInFiles(w, "config/ecs.config.d", agentFiles[1:], err)
+	instanceFiles, err := config.InstanceConfigFiles()
+	addDropInFiles(w, "config/instance.config.d", instanceFiles[1:], err)
+}
+
+// addDropInFiles adds the drop-in configuration files to the directory dir
+// of the bundle, along with the error listing them if any
+func addDropInFiles(w *bundle.Writer, dir string, paths []string, err error) {
+	if err != nil {
+		w.Fail(dir, err)
+	}
+	for _, path := range paths {
+		addOptionalFile(w, dir+"/"+filepath.Base(path), path)
+	}
+}
+
+func collectStateFiles(w *bundle.Writer) {
+	addOptionalFile(w, "state/cache-state.json", config.CacheState())
+	addOptionalFile(w, "state/ecs_volume_plugin.json", volumes.PluginStateFileAbsPath)
+	addOptionalFile(w, "state/nvidia-gpu-info.json", gpu.NvidiaGPUInfoFilePath)
+}
+
+// addOptionalFile adds the file at path to the bundle unless it doesn't exist
+func addOptionalFile(w *bundle.Writer, name, path string) {
+	data, err := readStatusFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = w.AddBytes(name, data)
+	}
+	if err != nil {
+		w.Fail(name, err)
+	}
+}
+
+func collectDocker(w *bundle.Writer, cfg *config.Config) {
+	docker, err := getDockerClient(cfg)
+	if err != nil {
+		w.Fail("docker", err)
+		return
+	}
+	w.Add("docker/info.json", func() ([]byte, error) {
+		info, err := docker.DockerInfo()
+		if err != nil {
+			return nil, err
+		}
+		return marshalIndent(info)
+	})
+	w.Add("docker/version.json", func() ([]byte, error) {
+		version, err := docker.DockerVersion()
+		if err != nil {
+			return nil, err
+		}
+		return marshalIndent(version)
+	})
+	w.Add("docker/agent-container-inspect.json", func() ([]byte, error) {
+		return inspectAgentContainer(docker)
+	})
+}
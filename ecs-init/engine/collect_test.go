@@ -0,0 +1,120 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
+
+	godocker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectMocks replaces the log directory with dir. The backup can be
+// restored by executing the returned function in a deferred manner.
+func collectMocks(dir string) func() {
+	collectLogDirectoryBkp := collectLogDirectory
+	collectLogDirectory = func() string {
+		return dir
+	}
+	return func() {
+		collectLogDirectory = collectLogDirectoryBkp
+	}
+}
+
+func TestCollectLogs(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	defer diagnosticsMocks()()
+
+	dir, err := ioutil.TempDir("", "collect")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer collectMocks(dir)()
+	for _, name := range []string{"ecs-init.log", "ecs-init.log.2026-10-16-01", "ecs-agent.log", "unrelated.log"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0600))
+	}
+	out := &bytes.Buffer{}
+	defer statusMocks(out, map[string]string{
+		config.AgentConfigFile():  "ECS_CLUSTER=test\nAWS_SECRET_ACCESS_KEY=secret\n",
+		config.CacheState():       "1",
+		gpu.NvidiaGPUInfoFilePath: `{"GPUIDs":["0"]}`,
+	})()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDocker.EXPECT().DockerInfo().Return(&godocker.DockerInfo{ServerVersion: "20.10.7"}, nil)
+	mockDocker.EXPECT().DockerVersion().Return(map[string]string{"ApiVersion": "1.41"}, nil)
+	mockDocker.EXPECT().InspectAgentContainer().Return(nil, nil)
+
+	path := filepath.Join(dir, "support.tar.gz")
+	require.NoError(t, CollectLogs(config.Defaults(), &CollectLogsOptions{Output: path}))
+	assert.Equal(t, path+"\n", out.String())
+
+	files := readBundle(t, path)
+	assert.Equal(t, "ecs-init.log\n", files["logs/ecs-init.log"])
+	assert.Equal(t, "ecs-init.log.2026-10-16-01\n", files["logs/ecs-init.log.2026-10-16-01"])
+	assert.Equal(t, "ecs-agent.log\n", files["logs/ecs-agent.log"])
+	assert.NotContains(t, files, "logs/unrelated.log")
+	assert.Equal(t, "ECS_CLUSTER=test\nAWS_SECRET_ACCESS_KEY=REDACTED\n", files["config/ecs.config"])
+	assert.NotContains(t, files, "config/instance.config")
+	assert.Equal(t, "1", files["state/cache-state.json"])
+	assert.Equal(t, `{"GPUIDs":["0"]}`, files["state/nvidia-gpu-info.json"])
+	assert.Contains(t, files["docker/info.json"], `"ServerVersion": "20.10.7"`)
+	assert.Contains(t, files["docker/version.json"], `"ApiVersion": "1.41"`)
+	assert.Equal(t, "*nat\nCOMMIT\n", files["iptables-nat.txt"])
+	assert.Contains(t, files["errors.txt"], "docker/agent-container-inspect.json: no existing agent container")
+}
+
+func TestCollectLogsExclude(t *testing.T) {
+	defer diagnosticsMocks()()
+
+	dir, err := ioutil.TempDir("", "collect")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer collectMocks(dir)()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ecs-init.log"), []byte("log\n"), 0600))
+	defer statusMocks(&bytes.Buffer{}, map[string]string{})()
+
+	// Docker isn't queried when excluded
+	path := filepath.Join(dir, "support.tar.gz")
+	require.NoError(t, CollectLogs(config.Defaults(), &CollectLogsOptions{Output: path, Exclude: "config, docker,network"}))
+
+	files := readBundle(t, path)
+	for name := range files {
+		assert.True(t, strings.HasPrefix(name, "logs/"), name)
+	}
+	assert.Equal(t, "log\n", files["logs/ecs-init.log"])
+}
+
+func TestCollectLogsUnknownCategory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collect")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "support.tar.gz")
+	err = CollectLogs(config.Defaults(), &CollectLogsOptions{Output: path, Exclude: "secrets"})
+	assert.EqualError(t, err, `unknown category "secrets", expected one of logs, config, state, docker, network`)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,188 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/inotify"
+
+	log "github.com/cihub/seelog"
+)
+
+// configReloadDelay is how long the configuration files have to be left
+// alone before their changes are applied, so that files being written by a
+// provisioning tool aren't read half way
+var configReloadDelay = 2 * time.Second
+
+// dropInSuffix turns the path of a configuration file into the path of its
+// drop-in directory
+const dropInSuffix = ".d"
+
+// watchedConfigFiles returns the configuration files watched by the
+// supervisor, none if the reload policy disables watching them
+func watchedConfigFiles(cfg *config.Config) []string {
+	if cfg.ConfigReload == config.ConfigReloadNone {
+		return nil
+	}
+	return []string{config.InstanceConfigFile(), config.AgentConfigFile()}
+}
+
+// watchConfig watches the configuration files while the Agent is supervised,
+// applying their changes according to the reload policy. The returned
+// function stops watching.
+func (e *Engine) watchConfig(docker dockerClient) func() {
+	if len(e.configFiles) == 0 {
+		return func() {}
+	}
+	watcher, err := inotify.New()
+	if err != nil {
+		log.Warnf("Could not watch the configuration files: %v", err)
+		return func() {}
+	}
+	watched := 0
+	for _, file := range e.configFiles {
+		for _, dir := range []string{filepath.Dir(file), file + dropInSuffix} {
+			err := watcher.Add(dir)
+			if err == nil {
+				watched++
+			} else if dir == filepath.Dir(file) {
+				log.Warnf("Could not watch configuration file %s: %v", file, err)
+			}
+		}
+	}
+	if watched == 0 {
+		watcher.Close()
+		return func() {}
+	}
+	e.supervisor.watchConfig()
+	log.Infof("Watching the configuration files, changes are applied with the %q policy", e.config.ConfigReload)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.handleConfigEvents(docker, watcher)
+	}()
+	return func() {
+		watcher.Close()
+		<-done
+	}
+}
+
+// handleConfigEvents applies the changes to the configuration files once
+// they're left alone for configReloadDelay
+func (e *Engine) handleConfigEvents(docker dockerClient, watcher *inotify.Watcher) {
+	timer := time.NewTimer(configReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events():
+			if !ok {
+				return
+			}
+			if !e.isConfigEvent(watcher, event) {
+				continue
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(configReloadDelay)
+		case <-timer.C:
+			err := e.applyConfigChanges(docker)
+			if err != nil {
+				log.Warnf("Could not apply the configuration changes: %v", err)
+			}
+		}
+	}
+}
+
+// isConfigEvent returns true if the event is about a configuration file or
+// a drop-in file. Drop-in directories created after the watch started are
+// watched from then on.
+func (e *Engine) isConfigEvent(watcher *inotify.Watcher, event inotify.Event) bool {
+	for _, file := range e.configFiles {
+		if event.Dir == file+dropInSuffix {
+			return true
+		}
+		if event.Dir != filepath.Dir(file) {
+			continue
+		}
+		switch event.Name {
+		case filepath.Base(file):
+			return true
+		case filepath.Base(file) + dropInSuffix:
+			if event.IsDir() {
+				// fails if the directory was removed rather than created
+				watcher.Add(file + dropInSuffix)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// applyConfigChanges compares the configuration files with the environment
+// the running Agent container was created with. Depending on the reload
+// policy, the Agent container is recreated with the new environment, or the
+// changes are only logged until the Agent is restarted.
+func (e *Engine) applyConfigChanges(docker dockerClient) error {
+	changes := e.supervisor.configChanged(docker.LoadEnvVars())
+	if len(changes) == 0 {
+		log.Debug("Configuration files changed without changing the environment of the running Agent")
+		return nil
+	}
+	log.Infof("Configuration changed since the Agent was started: %s", strings.Join(changes, ", "))
+	for _, change := range changes {
+		name := strings.Fields(change)[0]
+		if config.IsSetting(name) {
+			log.Warnf("%s is a setting of ecs-init, restart the ecs service to apply it", name)
+		}
+	}
+	if e.config.ConfigReload != config.ConfigReloadRestart || e.supervisor.isPaused() {
+		log.Info("Agent restart pending, restart the Agent to apply the configuration changes")
+		notifyStatus("Agent restart pending to apply configuration changes")
+		return nil
+	}
+	log.Info("Recreating the Agent container to apply the configuration changes")
+	return e.restartAgent(docker)
+}
+
+// diffEnv returns the variables that are added, removed or changed in env
+// compared to previous, sorted by name. Values aren't included, as they may
+// be secrets.
+func diffEnv(previous, env map[string]string) []string {
+	var changes []string
+	for name, value := range env {
+		previousValue, ok := previous[name]
+		if !ok {
+			changes = append(changes, name+" added")
+		} else if value != previousValue {
+			changes = append(changes, name+" changed")
+		}
+	}
+	for name := range previous {
+		if _, ok := env[name]; !ok {
+			changes = append(changes, name+" removed")
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
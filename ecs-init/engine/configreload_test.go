@@ -0,0 +1,143 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runningAgentEngine returns an engine whose supervisor runs an Agent
+// created with env, applying configuration changes with the reload policy
+func runningAgentEngine(reload string, env map[string]string) *Engine {
+	cfg := config.Defaults()
+	cfg.ConfigReload = reload
+	engine := &Engine{config: cfg}
+	engine.supervisor = newSupervisor(exitpolicy.Default())
+	engine.supervisor.watchConfig()
+	engine.supervisor.agentStarted(nil, env)
+	return engine
+}
+
+func TestDiffEnv(t *testing.T) {
+	changes := diffEnv(
+		map[string]string{"ECS_CLUSTER": "a", "ECS_LOGLEVEL": "info", "ECS_RESERVED_MEMORY": "32"},
+		map[string]string{"ECS_CLUSTER": "b", "ECS_LOGLEVEL": "info", "ECS_ENABLE_TASK_ENI": "true"},
+	)
+	assert.Equal(t, []string{"ECS_CLUSTER changed", "ECS_ENABLE_TASK_ENI added", "ECS_RESERVED_MEMORY removed"}, changes)
+	assert.Empty(t, diffEnv(map[string]string{"ECS_CLUSTER": "a"}, map[string]string{"ECS_CLUSTER": "a"}))
+}
+
+func TestApplyConfigChangesLogPolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDocker.EXPECT().LoadEnvVars().Return(map[string]string{"ECS_CLUSTER": "b"})
+
+	engine := runningAgentEngine(config.ConfigReloadLog, map[string]string{"ECS_CLUSTER": "a"})
+	require.NoError(t, engine.applyConfigChanges(mockDocker))
+
+	state := (&controlHandler{engine: engine}).State()
+	assert.Equal(t, []string{"ECS_CLUSTER changed"}, state.PendingConfigChanges)
+	assert.False(t, engine.supervisor.restartRequested)
+}
+
+func TestApplyConfigChangesRestartPolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDocker := NewMockdockerClient(mockCtrl)
+	gomock.InOrder(
+		mockDocker.EXPECT().LoadEnvVars().Return(map[string]string{"ECS_CLUSTER": "a"}),
+		mockDocker.EXPECT().LoadEnvVars().Return(map[string]string{"ECS_CLUSTER": "b"}),
+		mockDocker.EXPECT().StopAgent(),
+	)
+
+	engine := runningAgentEngine(config.ConfigReloadRestart, map[string]string{"ECS_CLUSTER": "a"})
+	require.NoError(t, engine.applyConfigChanges(mockDocker))
+	assert.False(t, engine.supervisor.restartRequested, "expected no restart without changes")
+	require.NoError(t, engine.applyConfigChanges(mockDocker))
+	assert.True(t, engine.supervisor.restartRequested)
+}
+
+func TestApplyConfigChangesAgentNotRunning(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDocker.EXPECT().LoadEnvVars().Return(map[string]string{"ECS_CLUSTER": "b"})
+
+	engine := runningAgentEngine(config.ConfigReloadRestart, map[string]string{"ECS_CLUSTER": "a"})
+	engine.supervisor.agentExited(1)
+	require.NoError(t, engine.applyConfigChanges(mockDocker))
+	assert.Empty(t, (&controlHandler{engine: engine}).State().PendingConfigChanges)
+}
+
+func TestWatchConfigDropInDirectory(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir, err := ioutil.TempDir("", "config-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "ecs.config")
+	configReloadDelayBkp := configReloadDelay
+	configReloadDelay = 10 * time.Millisecond
+	defer func() {
+		configReloadDelay = configReloadDelayBkp
+	}()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	agentStopped := make(chan struct{})
+	dropInFile := filepath.Join(configFile+dropInSuffix, "cluster.conf")
+	mockDocker.EXPECT().LoadEnvVars().DoAndReturn(func() map[string]string {
+		if _, err := os.Stat(dropInFile); err == nil {
+			return map[string]string{"ECS_CLUSTER": "b"}
+		}
+		return map[string]string{"ECS_CLUSTER": "a"}
+	}).MinTimes(1)
+	mockDocker.EXPECT().StopAgent().Do(func() {
+		close(agentStopped)
+	})
+
+	engine := runningAgentEngine(config.ConfigReloadRestart, nil)
+	engine.configFiles = []string{configFile}
+	stop := engine.watchConfig(mockDocker)
+	defer stop()
+	engine.supervisor.agentStarted(nil, map[string]string{"ECS_CLUSTER": "a"})
+
+	// the drop-in directory is created after the watch started
+	require.NoError(t, os.Mkdir(configFile+dropInSuffix, 0755))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, ioutil.WriteFile(dropInFile, []byte("ECS_CLUSTER=b\n"), 0644))
+	select {
+	case <-agentStopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the Agent to be restarted")
+	}
+}
+
+func TestWatchedConfigFiles(t *testing.T) {
+	cfg := config.Defaults()
+	assert.Equal(t, []string{config.InstanceConfigFile(), config.AgentConfigFile()}, watchedConfigFiles(cfg))
+	cfg.ConfigReload = config.ConfigReloadNone
+	assert.Empty(t, watchedConfigFiles(cfg))
+}
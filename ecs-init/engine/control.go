@@ -0,0 +1,327 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/control"
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// Control sends the command to the ecs-init process supervising the Agent
+// through its control socket, printing the state it reports if any.
+// control.ErrNotRunning is returned if no ecs-init process supervises the
+// Agent, in which case the one-shot action should be run instead.
+func Control(command control.Command) error {
+	resp, err := control.Send(config.ControlSocket(), command)
+	if err != nil {
+		return err
+	}
+	if resp.State == nil {
+		return nil
+	}
+	encoder := json.NewEncoder(reportOutput)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(resp.State)
+}
+
+// ReloadConfig is the one-shot reload-config action. Without a supervisor
+// there is nothing to reload, so it only validates the exit code policy that
+// the next start uses.
+func (e *Engine) ReloadConfig() error {
+	_, err := exitpolicy.Load(e.exitPolicyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return engineError("could not load exit code policy", err)
+	}
+	log.Info("No running ecs-init supervisor, the configuration is read when the Agent is started")
+	return nil
+}
+
+// ReportState is the one-shot report-state action, which reports the
+// status of the node in JSON
+func (e *Engine) ReportState() error {
+	return e.Status(StatusOutputJSON)
+}
+
+// supervisor is the state of the supervision loop that is shared with the
+// control socket
+type supervisor struct {
+	lock   sync.Mutex
+	policy *exitpolicy.Policy
+	paused bool
+	// resumed is closed when the supervision is resumed
+	resumed chan struct{}
+	// restartRequested is set while the Agent is stopped on request
+	restartRequested bool
+	// restartNow cuts the backoff before restarting the Agent short
+	restartNow     chan struct{}
+	agentRunning   bool
+	agentStartedAt time.Time
+	agentRuns      int
+	lastExitCode   *int
+	probationUntil time.Time
+	// watchingConfig is set once the configuration files are watched, in
+	// which case agentEnv is the environment of the configuration files
+	// the running Agent container was created with
+	watchingConfig bool
+	agentEnv       map[string]string
+	// pendingConfigChanges are the changes to agentEnv that aren't applied
+	// to the running Agent
+	pendingConfigChanges []string
+}
+
+func newSupervisor(policy *exitpolicy.Policy) *supervisor {
+	return &supervisor{policy: policy, restartNow: make(chan struct{}, 1)}
+}
+
+func (s *supervisor) exitPolicy() *exitpolicy.Policy {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.policy
+}
+
+func (s *supervisor) isPaused() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.paused
+}
+
+func (s *supervisor) watchConfig() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.watchingConfig = true
+}
+
+func (s *supervisor) isWatchingConfig() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.watchingConfig
+}
+
+// agentStarted records the start of the Agent, whose container is created
+// with env unless the configuration files aren't watched
+func (s *supervisor) agentStarted(probation *upgradeProbation, env map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.agentEnv = env
+	s.pendingConfigChanges = nil
+	s.agentRunning = true
+	s.restartRequested = false
+	select {
+	case <-s.restartNow:
+	default:
+	}
+	s.agentStartedAt = time.Now()
+	s.agentRuns++
+	s.probationUntil = time.Time{}
+	if probation != nil {
+		s.probationUntil = probation.until
+	}
+}
+
+// configChanged records the changes of env compared to the environment the
+// running Agent container was created with, and returns them. There are none
+// if the Agent isn't running, as it picks up env when it's started.
+func (s *supervisor) configChanged(env map[string]string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.agentRunning || s.agentEnv == nil {
+		return nil
+	}
+	s.pendingConfigChanges = diffEnv(s.agentEnv, env)
+	return s.pendingConfigChanges
+}
+
+// agentExited returns true if the Agent was stopped on request or while the
+// supervision was paused, in which case the exit isn't the Agent's doing
+func (s *supervisor) agentExited(exitCode int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.agentRunning = false
+	s.lastExitCode = &exitCode
+	requested := s.restartRequested || s.paused
+	s.restartRequested = false
+	return requested
+}
+
+// waitWhilePaused blocks until the supervision is resumed. It returns false
+// if a stop signal was received in the meantime.
+func (e *Engine) waitWhilePaused(stop <-chan struct{}) bool {
+	e.supervisor.lock.Lock()
+	paused, resumed := e.supervisor.paused, e.supervisor.resumed
+	e.supervisor.lock.Unlock()
+	if !paused {
+		return !stopRequested(stop)
+	}
+	log.Info("Supervision is paused, waiting for it to be resumed before starting the Agent")
+	notifyStatus("Supervision of Amazon Elastic Container Service Agent is paused")
+	e.watchdog.wait()
+	defer e.watchdog.busy()
+	select {
+	case <-resumed:
+		return !stopRequested(stop)
+	case <-stop:
+		return false
+	}
+}
+
+// serveControl listens on the control socket while the Agent is supervised.
+// The returned function closes the control socket.
+func (e *Engine) serveControl(docker dockerClient) func() {
+	if e.controlSocket == "" {
+		return func() {}
+	}
+	server, err := control.Listen(e.controlSocket, &controlHandler{engine: e, docker: docker})
+	if err != nil {
+		log.Warnf("Could not listen on the control socket: %v", err)
+		return func() {}
+	}
+	return func() {
+		server.Close()
+	}
+}
+
+// controlHandler carries out the commands received on the control socket
+type controlHandler struct {
+	engine *Engine
+	docker dockerClient
+}
+
+// RestartAgent stops the Agent, which the supervision loop restarts right
+// away without counting it as a failure
+func (h *controlHandler) RestartAgent() error {
+	return h.engine.restartAgent(h.docker)
+}
+
+// restartAgent stops the Agent, which the supervision loop recreates right
+// away without counting it as a failure. If the Agent isn't running, the
+// backoff before restarting it is cut short.
+func (e *Engine) restartAgent(docker dockerClient) error {
+	s := e.supervisor
+	s.lock.Lock()
+	if s.paused {
+		s.lock.Unlock()
+		return errors.New("supervision is paused, resume it to restart the agent")
+	}
+	if !s.agentRunning {
+		s.lock.Unlock()
+		select {
+		case s.restartNow <- struct{}{}:
+		default:
+		}
+		log.Info("Agent is not running, restarting it without waiting for the backoff")
+		return nil
+	}
+	// the exit of the Agent may be handled before StopAgent returns, so the
+	// restart is marked as requested beforehand, and unmarked if the Agent
+	// couldn't be stopped
+	s.restartRequested = true
+	s.lock.Unlock()
+	err := docker.StopAgent()
+	if err != nil {
+		s.lock.Lock()
+		s.restartRequested = false
+		s.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// ReloadConfig reloads the exit code policy, and applies the changes to the
+// configuration files if they're watched. The current policy is kept if the
+// policy file is invalid.
+func (h *controlHandler) ReloadConfig() error {
+	policy := exitpolicy.Default()
+	if path := h.engine.exitPolicyFile; path != "" {
+		loaded, err := exitpolicy.Load(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			policy = loaded
+		}
+	}
+	s := h.engine.supervisor
+	s.lock.Lock()
+	s.policy = policy
+	watchingConfig := s.watchingConfig
+	s.lock.Unlock()
+	log.Info("Reloaded exit code policy")
+	if watchingConfig {
+		return h.engine.applyConfigChanges(h.docker)
+	}
+	return nil
+}
+
+// Pause stops the supervision loop from restarting the Agent once it exits
+func (h *controlHandler) Pause() error {
+	s := h.engine.supervisor
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.paused {
+		return nil
+	}
+	s.paused = true
+	s.resumed = make(chan struct{})
+	log.Info("Paused supervision of the Agent")
+	return nil
+}
+
+// Resume lets the supervision loop restart the Agent again
+func (h *controlHandler) Resume() error {
+	s := h.engine.supervisor
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.paused {
+		return nil
+	}
+	s.paused = false
+	close(s.resumed)
+	log.Info("Resumed supervision of the Agent")
+	return nil
+}
+
+// State reports the state of the supervision loop
+func (h *controlHandler) State() *control.State {
+	s := h.engine.supervisor
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state := &control.State{
+		PID:          os.Getpid(),
+		Paused:       s.paused,
+		AgentRunning: s.agentRunning,
+		AgentRuns:    s.agentRuns,
+		LastExitCode: s.lastExitCode,
+	}
+	if len(s.pendingConfigChanges) > 0 {
+		state.PendingConfigChanges = append([]string(nil), s.pendingConfigChanges...)
+	}
+	if s.agentRunning {
+		startedAt := s.agentStartedAt
+		state.AgentStartedAt = &startedAt
+	}
+	if !s.probationUntil.IsZero() {
+		until := s.probationUntil
+		state.ProbationUntil = &until
+	}
+	return state
+}
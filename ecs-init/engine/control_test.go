@@ -0,0 +1,180 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/control"
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartSupervisedControlSocket(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir, err := ioutil.TempDir("", "control")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "control.sock")
+	historyFile := filepath.Join(dir, "restart-history.json")
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	agentStopped := make(chan struct{})
+	mockDocker.EXPECT().RemoveExistingAgentContainer().Times(3)
+	gomock.InOrder(
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			resp, err := control.Send(socket, control.ReportState)
+			require.NoError(t, err)
+			assert.True(t, resp.State.AgentRunning)
+			assert.Equal(t, 1, resp.State.AgentRuns)
+			_, err = control.Send(socket, control.RestartAgent)
+			assert.NoError(t, err)
+			<-agentStopped
+			return 143, nil
+		}),
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			_, err := control.Send(socket, control.Pause)
+			assert.NoError(t, err)
+			_, err = control.Send(socket, control.RestartAgent)
+			assert.Error(t, err, "expected restart to be refused while paused")
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				_, err := control.Send(socket, control.Resume)
+				assert.NoError(t, err)
+			}()
+			// the Agent is stopped for maintenance
+			return 137, nil
+		}),
+		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
+	)
+	mockDocker.EXPECT().StopAgent().Do(func() {
+		close(agentStopped)
+	})
+
+	engine := &Engine{config: config.Defaults(), controlSocket: socket, restartHistoryFile: historyFile}
+	err = engine.StartSupervised()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, engine.supervisor.agentRuns)
+	history := loadRestartHistory(historyFile)
+	assert.Len(t, history.Restarts, 3)
+	assert.Equal(t, 0, history.failuresSince(time.Time{}, exitpolicy.Default()), "expected requested restarts not to count as failures")
+
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err), "expected the control socket to be removed")
+}
+
+// restartWhenBackingOff requests a restart through the control socket once
+// the Agent exited and is waiting for its backoff
+func restartWhenBackingOff(t *testing.T, socket string) {
+	for {
+		resp, err := control.Send(socket, control.ReportState)
+		require.NoError(t, err)
+		if !resp.State.AgentRunning && resp.State.LastExitCode != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_, err := control.Send(socket, control.RestartAgent)
+	assert.NoError(t, err)
+}
+
+func TestStartSupervisedRestartDuringBackoff(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir, err := ioutil.TempDir("", "control")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "control.sock")
+	historyFile := filepath.Join(dir, "restart-history.json")
+	// earlier failures make the backoff longer than the test
+	history := &restartHistory{}
+	for i := 0; i < 10; i++ {
+		history.record(restartRecord{ExitCode: 1, StartedAt: time.Now(), ExitedAt: time.Now()})
+	}
+	require.NoError(t, history.save(historyFile))
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDocker.EXPECT().RemoveExistingAgentContainer().Times(3)
+	mockDocker.EXPECT().GetContainerLogTail(gomock.Any()).AnyTimes()
+	gomock.InOrder(
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			go restartWhenBackingOff(t, socket)
+			return 1, nil
+		}),
+		// the Agent crashes after the restart cut its backoff short
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			go restartWhenBackingOff(t, socket)
+			return 1, nil
+		}),
+		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
+	)
+
+	start := time.Now()
+	engine := &Engine{config: config.Defaults(), controlSocket: socket, restartHistoryFile: historyFile}
+	err = engine.StartSupervised()
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "expected the restarts not to wait for the backoff")
+	history = loadRestartHistory(historyFile)
+	require.Len(t, history.Restarts, 13)
+	assert.False(t, history.Restarts[11].Requested, "expected the crash after a restart not to be requested")
+	assert.Equal(t, 12, history.failuresSince(time.Time{}, exitpolicy.Default()))
+}
+
+func TestRestartAgentStopFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDocker.EXPECT().StopAgent().Return(errors.New("no such container"))
+
+	engine := &Engine{config: config.Defaults(), supervisor: newSupervisor(exitpolicy.Default())}
+	engine.supervisor.agentStarted(nil, nil)
+	assert.Error(t, engine.restartAgent(mockDocker))
+	assert.False(t, engine.supervisor.agentExited(1), "expected a failed restart not to mark the crash as requested")
+}
+
+func TestControlReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "control")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	policyFile := filepath.Join(dir, "exit-code-policy.json")
+
+	engine := &Engine{config: config.Defaults(), exitPolicyFile: policyFile}
+	engine.supervisor = newSupervisor(loadExitPolicy(policyFile))
+	handler := &controlHandler{engine: engine}
+
+	require.NoError(t, ioutil.WriteFile(policyFile, []byte(`{"rules": [{"exitCodes": ["3"], "actions": ["terminal"]}]}`), 0644))
+	assert.NoError(t, handler.ReloadConfig())
+	assert.Len(t, engine.supervisor.exitPolicy().Rules, 5)
+	assert.NoError(t, engine.ReloadConfig())
+
+	require.NoError(t, ioutil.WriteFile(policyFile, []byte(`{"rules": [{"exitCodes": ["3"]}]}`), 0644))
+	assert.Error(t, handler.ReloadConfig())
+	assert.Len(t, engine.supervisor.exitPolicy().Rules, 5, "expected an invalid policy not to replace the current one")
+	assert.Error(t, engine.ReloadConfig())
+}
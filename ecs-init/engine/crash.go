@@ -0,0 +1,164 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/bundle"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/docker"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	crashBundlePrefix = "ecs-agent-crash-"
+	// diagnosticsMaxFileSize bounds the size of the log files added to
+	// diagnostics bundles, of which the tail is kept
+	diagnosticsMaxFileSize = 20 * 1024 * 1024
+	procSysDir             = "/proc/sys"
+)
+
+// Injection points for testing purposes
+var (
+	agentContainerEnv    = docker.AgentContainerEnv
+	runDiagnosticCommand = func(name string, arg ...string) ([]byte, error) {
+		return newExec().Command(name, arg...).CombinedOutput()
+	}
+)
+
+// diagnosticSysctlKeys are the sysctl settings changed by ecs-init, which are
+// captured in diagnostics bundles
+var diagnosticSysctlKeys = []string{
+	"net.ipv4.conf.all.route_localnet",
+	"net.ipv4.conf.default.route_localnet",
+	"net.ipv6.conf.docker0.accept_ra",
+}
+
+// captureCrashBundle writes a crash bundle of the Agent container that exited
+// with exitCode to the crash bundle directory and removes the oldest bundles
+// beyond the retention limits. Failing to write the bundle is logged and
+// otherwise ignored.
+func (e *Engine) captureCrashBundle(docker dockerClient, exitCode int) {
+	if e.crashBundleDir == "" {
+		return
+	}
+	path, err := e.writeCrashBundle(docker, exitCode)
+	journal.Record(journal.CrashBundle, "", journal.Fields{
+		"path":     path,
+		"exitCode": strconv.Itoa(exitCode),
+	}, err)
+	if err != nil {
+		log.Errorf("Could not write crash bundle: %v", err)
+		return
+	}
+	log.Infof("Wrote crash bundle of the agent container to %s", path)
+	err = bundle.Prune(e.crashBundleDir, crashBundlePrefix, e.config.CrashBundleMaxCount, e.config.CrashBundleMaxTotalSize)
+	if err != nil {
+		log.Warnf("Could not remove old crash bundles: %v", err)
+	}
+}
+
+func (e *Engine) writeCrashBundle(docker dockerClient, exitCode int) (string, error) {
+	name := fmt.Sprintf("%s%s-exit-%d.tar.gz", crashBundlePrefix, time.Now().UTC().Format("20060102T150405Z"), exitCode)
+	path := filepath.Join(e.crashBundleDir, name)
+	w, err := bundle.Create(path)
+	if err != nil {
+		return path, err
+	}
+	w.Add("agent-container.log", func() ([]byte, error) {
+		var logs bytes.Buffer
+		err := docker.GetAgentContainerLogs(&logs)
+		return logs.Bytes(), err
+	})
+	w.Add("agent-container-inspect.json", func() ([]byte, error) {
+		return inspectAgentContainer(docker)
+	})
+	w.Add("agent-env.txt", func() ([]byte, error) {
+		return joinLines(redact.Env(agentContainerEnv(e.config))), nil
+	})
+	w.Add("host-config-binds.txt", func() ([]byte, error) {
+		return joinLines(agentHostBinds(e.config)), nil
+	})
+	err = w.AddFile(config.AgentLogFile, filepath.Join(config.LogDirectory(), config.AgentLogFile), diagnosticsMaxFileSize)
+	if err != nil {
+		w.Fail(config.AgentLogFile, err)
+	}
+	addHostNetworkState(w)
+	return path, w.Close()
+}
+
+// addHostNetworkState adds the iptables rules and sysctl settings that
+// ecs-init sets up for the credentials endpoint to the bundle
+func addHostNetworkState(w *bundle.Writer) {
+	for _, table := range []string{"nat", "filter"} {
+		w.Add("iptables-"+table+".txt", func() ([]byte, error) {
+			return runDiagnosticCommand("iptables-save", "-t", table)
+		})
+	}
+	w.Add("sysctl.txt", func() ([]byte, error) {
+		var lines []string
+		for _, key := range diagnosticSysctlKeys {
+			value, err := readStatusFile(filepath.Join(procSysDir, strings.Replace(key, ".", "/", -1)))
+			if err != nil {
+				lines = append(lines, fmt.Sprintf("%s: %v", key, err))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s = %s", key, strings.TrimSpace(string(value))))
+		}
+		return joinLines(lines), nil
+	})
+}
+
+// inspectAgentContainer returns the details of the existing Agent container
+// as JSON. Its environment is redacted before it is encoded, as the secrets
+// of escaped JSON values can't be told apart once encoded.
+func inspectAgentContainer(docker dockerClient) ([]byte, error) {
+	container, err := docker.InspectAgentContainer()
+	if err != nil {
+		return nil, err
+	}
+	if container == nil {
+		return nil, errors.New("no existing agent container")
+	}
+	if container.Config != nil {
+		container.Config.Env = redact.Env(container.Config.Env)
+	}
+	return marshalIndent(container)
+}
+
+func marshalIndent(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func joinLines(lines []string) []byte {
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/bundle"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
+
+	godocker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diagnosticsMocks replaces the host state gathered in diagnostics bundles.
+// The backup can be restored by executing the returned function in a
+// deferred manner.
+func diagnosticsMocks() func() {
+	agentContainerEnvBkp := agentContainerEnv
+	agentHostBindsBkp := agentHostBinds
+	runDiagnosticCommandBkp := runDiagnosticCommand
+	readStatusFileBkp := readStatusFile
+	agentContainerEnv = func(cfg *config.Config) []string {
+		return []string{"ECS_CLUSTER=test", "ECS_ENGINE_AUTH_DATA={\"secret\":true}"}
+	}
+	agentHostBinds = func(cfg *config.Config) []string {
+		return []string{"/var/run:/var/run"}
+	}
+	runDiagnosticCommand = func(name string, arg ...string) ([]byte, error) {
+		if arg[1] == "filter" {
+			return nil, errors.New("iptables-save not found")
+		}
+		return []byte("*" + arg[1] + "\nCOMMIT\n"), nil
+	}
+	readStatusFile = func(path string) ([]byte, error) {
+		if strings.HasSuffix(path, "accept_ra") {
+			return nil, os.ErrNotExist
+		}
+		return []byte("1\n"), nil
+	}
+	return func() {
+		agentContainerEnv = agentContainerEnvBkp
+		agentHostBinds = agentHostBindsBkp
+		runDiagnosticCommand = runDiagnosticCommandBkp
+		readStatusFile = readStatusFileBkp
+	}
+}
+
+func readBundle(t *testing.T, path string) map[string]string {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+	files := map[string]string{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(tarReader)
+		require.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+}
+
+func TestCaptureCrashBundle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	defer diagnosticsMocks()()
+
+	dir, err := ioutil.TempDir("", "crash")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDocker.EXPECT().GetAgentContainerLogs(gomock.Any()).DoAndReturn(func(w io.Writer) error {
+		_, err := w.Write([]byte("agent panicked\n"))
+		return err
+	})
+	mockDocker.EXPECT().InspectAgentContainer().Return(&godocker.Container{
+		ID:     "id",
+		Config: &godocker.Config{Env: []string{"AWS_SECRET_ACCESS_KEY=secret", "ECS_CLUSTER=test"}},
+		State:  godocker.State{ExitCode: 2, OOMKilled: true},
+	}, nil)
+
+	engine := &Engine{config: config.Defaults(), crashBundleDir: dir}
+	action := engine.applyExitRule(mockDocker, &exitpolicy.Rule{
+		Actions: []exitpolicy.Action{exitpolicy.CaptureBundle, exitpolicy.RestartWithBackoff},
+	}, containerFailureAgentExitCode)
+	assert.Equal(t, exitpolicy.RestartWithBackoff, action)
+
+	bundles, err := filepath.Glob(filepath.Join(dir, crashBundlePrefix+"*-exit-2.tar.gz"))
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	files := readBundle(t, bundles[0])
+	assert.Equal(t, "agent panicked\n", files["agent-container.log"])
+	assert.Contains(t, files["agent-container-inspect.json"], `"OOMKilled": true`)
+	assert.Contains(t, files["agent-container-inspect.json"], `"AWS_SECRET_ACCESS_KEY=REDACTED"`)
+	assert.NotContains(t, files["agent-container-inspect.json"], "=secret")
+	assert.Equal(t, "ECS_CLUSTER=test\nECS_ENGINE_AUTH_DATA=REDACTED\n", files["agent-env.txt"])
+	assert.Equal(t, "/var/run:/var/run\n", files["host-config-binds.txt"])
+	assert.Equal(t, "*nat\nCOMMIT\n", files["iptables-nat.txt"])
+	assert.Contains(t, files["sysctl.txt"], "net.ipv4.conf.all.route_localnet = 1\n")
+	assert.Contains(t, files[bundle.ErrorsFile], "iptables-filter.txt: iptables-save not found")
+}
+
+func TestCaptureCrashBundleDisabled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// no crash bundle directory, the Agent container isn't looked at
+	engine := &Engine{config: config.Defaults()}
+	engine.captureCrashBundle(NewMockdockerClient(mockCtrl), containerFailureAgentExitCode)
+}
@@ -17,6 +17,9 @@ import (
 	"io"
 
 	"github.com/aws/amazon-ecs-init/ecs-init/cache"
+	"github.com/aws/amazon-ecs-init/ecs-init/docker"
+
+	godocker "github.com/fsouza/go-dockerclient"
 )
 
 //go:generate mockgen.sh $GOPACKAGE $GOFILE
@@ -26,14 +29,27 @@ type downloader interface {
 	DownloadAgent() error
 	LoadCachedAgent() (io.ReadCloser, error)
 	LoadDesiredAgent() (io.ReadCloser, error)
+	DesiredAgentVersion() (string, error)
 	RecordCachedAgent() error
+	RecordRollback(rollback *cache.Rollback) error
+	LastRollback() (*cache.Rollback, error)
 	AgentCacheStatus() cache.CacheStatus
 }
 
 type dockerClient interface {
 	GetContainerLogTail(logWindowSize string) string
+	GetAgentContainerLogs(w io.Writer) error
+	InspectAgentContainer() (*godocker.Container, error)
+	DockerInfo() (*godocker.DockerInfo, error)
+	DockerVersion() (map[string]string, error)
+	GetAgentContainerState() (*docker.AgentContainerState, error)
+	CheckServerAPIVersion() error
+	Ping() error
 	IsAgentImageLoaded() (bool, error)
 	LoadImage(image io.Reader) error
+	PullAgentImage(reference, authFile string) error
+	TagAgentImageKnownGood() error
+	RestoreKnownGoodAgentImage() error
 	RemoveExistingAgentContainer() error
 	StartAgent() (int, error)
 	StopAgent() error
@@ -42,11 +58,13 @@ type dockerClient interface {
 
 type loopbackRouting interface {
 	Enable() error
+	IsEnabled() (bool, error)
 	RestoreDefault() error
 }
 
 type credentialsProxyRoute interface {
 	Create() error
+	Check() error
 	Remove() error
 }
 
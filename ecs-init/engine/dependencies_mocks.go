@@ -1,4 +1,4 @@
-// Copyright 2015-2020 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// Copyright 2015-2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License"). You may
 // not use this file except in compliance with the License. A copy of the
@@ -22,6 +22,8 @@ import (
 	reflect "reflect"
 
 	cache "github.com/aws/amazon-ecs-init/ecs-init/cache"
+	docker "github.com/aws/amazon-ecs-init/ecs-init/docker"
+	go_dockerclient "github.com/fsouza/go-dockerclient"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -106,6 +108,21 @@ func (mr *MockdownloaderMockRecorder) LoadDesiredAgent() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadDesiredAgent", reflect.TypeOf((*Mockdownloader)(nil).LoadDesiredAgent))
 }
 
+// DesiredAgentVersion mocks base method
+func (m *Mockdownloader) DesiredAgentVersion() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DesiredAgentVersion")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DesiredAgentVersion indicates an expected call of DesiredAgentVersion
+func (mr *MockdownloaderMockRecorder) DesiredAgentVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DesiredAgentVersion", reflect.TypeOf((*Mockdownloader)(nil).DesiredAgentVersion))
+}
+
 // RecordCachedAgent mocks base method
 func (m *Mockdownloader) RecordCachedAgent() error {
 	m.ctrl.T.Helper()
@@ -120,6 +137,35 @@ func (mr *MockdownloaderMockRecorder) RecordCachedAgent() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordCachedAgent", reflect.TypeOf((*Mockdownloader)(nil).RecordCachedAgent))
 }
 
+// RecordRollback mocks base method
+func (m *Mockdownloader) RecordRollback(rollback *cache.Rollback) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordRollback", rollback)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordRollback indicates an expected call of RecordRollback
+func (mr *MockdownloaderMockRecorder) RecordRollback(rollback interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordRollback", reflect.TypeOf((*Mockdownloader)(nil).RecordRollback), rollback)
+}
+
+// LastRollback mocks base method
+func (m *Mockdownloader) LastRollback() (*cache.Rollback, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastRollback")
+	ret0, _ := ret[0].(*cache.Rollback)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastRollback indicates an expected call of LastRollback
+func (mr *MockdownloaderMockRecorder) LastRollback() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastRollback", reflect.TypeOf((*Mockdownloader)(nil).LastRollback))
+}
+
 // AgentCacheStatus mocks base method
 func (m *Mockdownloader) AgentCacheStatus() cache.CacheStatus {
 	m.ctrl.T.Helper()
@@ -171,6 +217,108 @@ func (mr *MockdockerClientMockRecorder) GetContainerLogTail(logWindowSize interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContainerLogTail", reflect.TypeOf((*MockdockerClient)(nil).GetContainerLogTail), logWindowSize)
 }
 
+// GetAgentContainerLogs mocks base method
+func (m *MockdockerClient) GetAgentContainerLogs(w io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentContainerLogs", w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetAgentContainerLogs indicates an expected call of GetAgentContainerLogs
+func (mr *MockdockerClientMockRecorder) GetAgentContainerLogs(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentContainerLogs", reflect.TypeOf((*MockdockerClient)(nil).GetAgentContainerLogs), w)
+}
+
+// InspectAgentContainer mocks base method
+func (m *MockdockerClient) InspectAgentContainer() (*go_dockerclient.Container, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectAgentContainer")
+	ret0, _ := ret[0].(*go_dockerclient.Container)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectAgentContainer indicates an expected call of InspectAgentContainer
+func (mr *MockdockerClientMockRecorder) InspectAgentContainer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectAgentContainer", reflect.TypeOf((*MockdockerClient)(nil).InspectAgentContainer))
+}
+
+// DockerInfo mocks base method
+func (m *MockdockerClient) DockerInfo() (*go_dockerclient.DockerInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DockerInfo")
+	ret0, _ := ret[0].(*go_dockerclient.DockerInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DockerInfo indicates an expected call of DockerInfo
+func (mr *MockdockerClientMockRecorder) DockerInfo() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DockerInfo", reflect.TypeOf((*MockdockerClient)(nil).DockerInfo))
+}
+
+// DockerVersion mocks base method
+func (m *MockdockerClient) DockerVersion() (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DockerVersion")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DockerVersion indicates an expected call of DockerVersion
+func (mr *MockdockerClientMockRecorder) DockerVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DockerVersion", reflect.TypeOf((*MockdockerClient)(nil).DockerVersion))
+}
+
+// GetAgentContainerState mocks base method
+func (m *MockdockerClient) GetAgentContainerState() (*docker.AgentContainerState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentContainerState")
+	ret0, _ := ret[0].(*docker.AgentContainerState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgentContainerState indicates an expected call of GetAgentContainerState
+func (mr *MockdockerClientMockRecorder) GetAgentContainerState() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentContainerState", reflect.TypeOf((*MockdockerClient)(nil).GetAgentContainerState))
+}
+
+// CheckServerAPIVersion mocks base method
+func (m *MockdockerClient) CheckServerAPIVersion() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckServerAPIVersion")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckServerAPIVersion indicates an expected call of CheckServerAPIVersion
+func (mr *MockdockerClientMockRecorder) CheckServerAPIVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckServerAPIVersion", reflect.TypeOf((*MockdockerClient)(nil).CheckServerAPIVersion))
+}
+
+// Ping mocks base method
+func (m *MockdockerClient) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockdockerClientMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockdockerClient)(nil).Ping))
+}
+
 // IsAgentImageLoaded mocks base method
 func (m *MockdockerClient) IsAgentImageLoaded() (bool, error) {
 	m.ctrl.T.Helper()
@@ -200,6 +348,48 @@ func (mr *MockdockerClientMockRecorder) LoadImage(image interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadImage", reflect.TypeOf((*MockdockerClient)(nil).LoadImage), image)
 }
 
+// PullAgentImage mocks base method
+func (m *MockdockerClient) PullAgentImage(reference, authFile string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PullAgentImage", reference, authFile)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PullAgentImage indicates an expected call of PullAgentImage
+func (mr *MockdockerClientMockRecorder) PullAgentImage(reference, authFile interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PullAgentImage", reflect.TypeOf((*MockdockerClient)(nil).PullAgentImage), reference, authFile)
+}
+
+// TagAgentImageKnownGood mocks base method
+func (m *MockdockerClient) TagAgentImageKnownGood() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TagAgentImageKnownGood")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TagAgentImageKnownGood indicates an expected call of TagAgentImageKnownGood
+func (mr *MockdockerClientMockRecorder) TagAgentImageKnownGood() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagAgentImageKnownGood", reflect.TypeOf((*MockdockerClient)(nil).TagAgentImageKnownGood))
+}
+
+// RestoreKnownGoodAgentImage mocks base method
+func (m *MockdockerClient) RestoreKnownGoodAgentImage() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreKnownGoodAgentImage")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreKnownGoodAgentImage indicates an expected call of RestoreKnownGoodAgentImage
+func (mr *MockdockerClientMockRecorder) RestoreKnownGoodAgentImage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreKnownGoodAgentImage", reflect.TypeOf((*MockdockerClient)(nil).RestoreKnownGoodAgentImage))
+}
+
 // RemoveExistingAgentContainer mocks base method
 func (m *MockdockerClient) RemoveExistingAgentContainer() error {
 	m.ctrl.T.Helper()
@@ -294,6 +484,21 @@ func (mr *MockloopbackRoutingMockRecorder) Enable() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enable", reflect.TypeOf((*MockloopbackRouting)(nil).Enable))
 }
 
+// IsEnabled mocks base method
+func (m *MockloopbackRouting) IsEnabled() (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsEnabled")
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsEnabled indicates an expected call of IsEnabled
+func (mr *MockloopbackRoutingMockRecorder) IsEnabled() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsEnabled", reflect.TypeOf((*MockloopbackRouting)(nil).IsEnabled))
+}
+
 // RestoreDefault mocks base method
 func (m *MockloopbackRouting) RestoreDefault() error {
 	m.ctrl.T.Helper()
@@ -345,6 +550,20 @@ func (mr *MockcredentialsProxyRouteMockRecorder) Create() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockcredentialsProxyRoute)(nil).Create))
 }
 
+// Check mocks base method
+func (m *MockcredentialsProxyRoute) Check() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check
+func (mr *MockcredentialsProxyRouteMockRecorder) Check() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockcredentialsProxyRoute)(nil).Check))
+}
+
 // Remove mocks base method
 func (m *MockcredentialsProxyRoute) Remove() error {
 	m.ctrl.T.Helper()
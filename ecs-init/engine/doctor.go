@@ -0,0 +1,199 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/cache"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/docker"
+	"github.com/aws/amazon-ecs-init/ecs-init/doctor"
+	"github.com/aws/amazon-ecs-init/ecs-init/exec"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultDockerSocketPath = "/var/run/docker.sock"
+	cacheFreeSpaceWarnMB    = 1024
+	cacheFreeSpaceFailMB    = 256
+)
+
+// Injection points for testing purposes
+var (
+	newExec        = exec.NewExec
+	agentHostBinds = docker.HostConfigBinds
+)
+
+// Doctor validates the host prerequisites of the ECS Agent and prints the
+// findings along with remediation text. An error is returned if any of the
+// prerequisites is not met.
+func Doctor(cfg *config.Config) error {
+	report := doctor.Run(doctorChecks(newExec(), cfg))
+	err := report.Write(reportOutput)
+	if err != nil {
+		return engineError("could not write doctor report", err)
+	}
+	if report.Failed() {
+		return errors.New("one or more host prerequisites are not met")
+	}
+	return nil
+}
+
+// preflight runs the doctor checks in warn-only mode when enabled, logging
+// the checks that didn't pass without failing pre-start
+func (e *Engine) preflight() {
+	if !e.config.PreflightChecks {
+		return
+	}
+	log.Info("pre-start: running preflight checks")
+	report := doctor.Run(doctorChecks(newExec(), e.config))
+	for i, finding := range report.Findings {
+		if finding.Result == doctor.Pass {
+			continue
+		}
+		log.Warnf("pre-start: preflight check %q: [%s] %s; remediation: %s",
+			report.Checks[i].Name(), finding.Result, finding.Message, finding.Remediation)
+	}
+}
+
+func doctorChecks(cmdExec exec.Exec, cfg *config.Config) []doctor.Check {
+	checks := []doctor.Check{
+		&doctor.ExecutableCheck{Exec: cmdExec, Executable: "iptables", Package: "iptables"},
+		&doctor.ExecutableCheck{Exec: cmdExec, Executable: "sysctl", Package: "procps"},
+		&doctor.PathCheck{
+			Path:        dockerSocketPath(cfg),
+			Description: "docker socket",
+			Missing:     doctor.Fail,
+			Remediation: fmt.Sprintf("start docker, or set %s to the docker socket in use", config.DockerHostEnvVar),
+		},
+		&doctor.FuncCheck{
+			Description: "docker API version",
+			Func:        func() error { return checkDockerAPIVersion(cfg) },
+			Failed:      doctor.Fail,
+			Remediation: "upgrade docker to a version that supports the required API version",
+			Success:     "docker API version is supported",
+		},
+		&doctor.PathCheck{
+			Path:        config.CgroupMountpoint(),
+			Description: "cgroup mountpoint",
+			Missing:     doctor.Fail,
+			Remediation: fmt.Sprintf("mount the cgroup filesystem at %s", config.CgroupMountpoint()),
+		},
+	}
+	if pkiDir := config.PlatformHostPKIDirPath(); pkiDir != "" {
+		checks = append(checks, &doctor.PathCheck{
+			Path:        pkiDir,
+			Description: "host CA store",
+			Missing:     doctor.Fail,
+			Remediation: "install the CA certificates package",
+		})
+	}
+	checks = append(checks, signatureChecks(cmdExec, cfg)...)
+	checks = append(checks, bindChecks(cfg)...)
+	checks = append(checks,
+		&doctor.SysctlKeyCheck{
+			Key:         "net.ipv4.conf.all.route_localnet",
+			Missing:     doctor.Fail,
+			Remediation: "use a kernel that supports route_localnet",
+		},
+		&doctor.SysctlKeyCheck{
+			Key:         "net.ipv4.conf.default.route_localnet",
+			Missing:     doctor.Fail,
+			Remediation: "use a kernel that supports route_localnet",
+		},
+		&doctor.SysctlKeyCheck{
+			Key:         "net.ipv6.conf.docker0.accept_ra",
+			Missing:     doctor.Warn,
+			Remediation: "start docker with its default bridge network and IPv6 enabled in the kernel",
+		},
+		&doctor.FreeSpaceCheck{
+			Path:        config.CacheDirectory(),
+			WarnBelowMB: cacheFreeSpaceWarnMB,
+			FailBelowMB: cacheFreeSpaceFailMB,
+		},
+	)
+	return checks
+}
+
+// signatureChecks verifies that the signatures of the Agent images can be
+// checked, unless unsigned images are accepted. Images whose signature can't
+// be checked are handled like unsigned images.
+func signatureChecks(cmdExec exec.Exec, cfg *config.Config) []doctor.Check {
+	if cfg.AgentSignaturePolicy == config.SignaturePolicyAccept {
+		return nil
+	}
+	missing := doctor.Warn
+	if cfg.AgentSignaturePolicy == config.SignaturePolicyRequire {
+		missing = doctor.Fail
+	}
+	return []doctor.Check{
+		&doctor.ExecutableCheck{Exec: cmdExec, Executable: "gpg", Package: "gnupg2"},
+		&doctor.PathCheck{
+			Path:        cfg.AgentTrustedKeys,
+			Description: "agent signature trusted keys",
+			Missing:     missing,
+			Remediation: "install the public key of the agent images, such as amazon-ecs-agent.gpg, in the directory",
+		},
+		&doctor.FuncCheck{
+			Description: "agent signature verification",
+			Func:        func() error { return cache.CheckTrustedKeys(cfg.AgentTrustedKeys) },
+			Failed:      missing,
+			Remediation: "install a gpg that supports --no-autostart and the public keys of the agent images, " +
+				"or agent images are loaded as if unsigned",
+			Success: "the trusted keys can be imported by gpg",
+		},
+	}
+}
+
+// bindChecks verifies that the source of every bind mount of the Agent
+// container exists. Docker creates missing sources as empty directories, so
+// these only warn.
+func bindChecks(cfg *config.Config) []doctor.Check {
+	var checks []doctor.Check
+	seen := make(map[string]struct{})
+	for _, bind := range agentHostBinds(cfg) {
+		source := strings.SplitN(bind, ":", 2)[0]
+		if _, ok := seen[source]; ok {
+			continue
+		}
+		seen[source] = struct{}{}
+		checks = append(checks, &doctor.PathCheck{
+			Path:        source,
+			Description: fmt.Sprintf("agent bind mount %s", source),
+			Missing:     doctor.Warn,
+			Remediation: "docker will create it as an empty directory; create it if the agent needs its contents",
+		})
+	}
+	return checks
+}
+
+func dockerSocketPath(cfg *config.Config) string {
+	socketPath, fromEnv := cfg.DockerUnixSocket()
+	if !fromEnv {
+		return defaultDockerSocketPath
+	}
+	return socketPath
+}
+
+func checkDockerAPIVersion(cfg *config.Config) error {
+	docker, err := getDockerClient(cfg)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to docker")
+	}
+	return docker.CheckServerAPIVersion()
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/doctor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindChecksDeduplicatesSources(t *testing.T) {
+	agentHostBindsBkp := agentHostBinds
+	defer func() {
+		agentHostBinds = agentHostBindsBkp
+	}()
+	agentHostBinds = func(cfg *config.Config) []string {
+		return []string{
+			"/var/run:/var/run",
+			"/var/log/ecs:/log",
+			"/var/run:/host/var/run:ro",
+		}
+	}
+
+	checks := bindChecks(config.Defaults())
+	require.Len(t, checks, 2)
+	for i, path := range []string{"/var/run", "/var/log/ecs"} {
+		check, ok := checks[i].(*doctor.PathCheck)
+		require.True(t, ok)
+		assert.Equal(t, path, check.Path)
+		assert.Equal(t, doctor.Warn, check.Missing)
+	}
+}
+
+func TestSignatureChecks(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.AgentSignaturePolicy = config.SignaturePolicyAccept
+	assert.Empty(t, signatureChecks(nil, cfg))
+
+	for policy, missing := range map[string]doctor.Result{
+		config.SignaturePolicyWarn:    doctor.Warn,
+		config.SignaturePolicyRequire: doctor.Fail,
+	} {
+		cfg.AgentSignaturePolicy = policy
+		checks := signatureChecks(nil, cfg)
+		require.Len(t, checks, 3)
+		check, ok := checks[1].(*doctor.PathCheck)
+		require.True(t, ok)
+		assert.Equal(t, config.TrustedKeysDirectory(), check.Path)
+		assert.Equal(t, missing, check.Missing, policy)
+		verification, ok := checks[2].(*doctor.FuncCheck)
+		require.True(t, ok)
+		assert.Equal(t, missing, verification.Failed, policy)
+	}
+}
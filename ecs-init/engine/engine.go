@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/aws/amazon-ecs-init/ecs-init/backoff"
@@ -27,7 +28,10 @@ import (
 	"github.com/aws/amazon-ecs-init/ecs-init/exec"
 	"github.com/aws/amazon-ecs-init/ecs-init/exec/iptables"
 	"github.com/aws/amazon-ecs-init/ecs-init/exec/sysctl"
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
 	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+	"github.com/aws/amazon-ecs-init/ecs-init/metrics"
 
 	log "github.com/cihub/seelog"
 )
@@ -36,6 +40,7 @@ const (
 	terminalSuccessAgentExitCode  = 0
 	containerFailureAgentExitCode = 2
 	TerminalFailureAgentExitCode  = 5
+	CrashLoopExitCode             = 6
 	DefaultInitErrorExitCode      = -1
 	upgradeAgentExitCode          = 42
 	serviceStartMinRetryTime      = time.Millisecond * 500
@@ -47,8 +52,8 @@ const (
 )
 
 // Injection point for testing purposes
-var getDockerClient = func() (dockerClient, error) {
-	return docker.Client()
+var getDockerClient = func(cfg *config.Config) (dockerClient, error) {
+	return docker.Client(cfg)
 }
 
 func dockerError(err error) error {
@@ -57,11 +62,24 @@ func dockerError(err error) error {
 
 // Engine contains methods invoked when ecs-init is run
 type Engine struct {
+	config                   *config.Config
 	downloader               downloader
 	loopbackRouting          loopbackRouting
 	credentialsProxyRoute    credentialsProxyRoute
 	ipv6RouterAdvertisements ipv6RouterAdvertisements
 	nvidiaGPUManager         gpu.GPUManager
+	restartHistoryFile       string
+	exitPolicyFile           string
+	controlSocket            string
+	metricsAddress           string
+	crashBundleDir           string
+	// configFiles are the configuration files watched by the supervisor,
+	// along with their drop-in directories
+	configFiles   []string
+	supervisor    *supervisor
+	probation     *upgradeProbation
+	notifiedReady bool
+	watchdog      *supervisorWatchdog
 }
 
 type TerminalError struct {
@@ -73,9 +91,14 @@ func (e *TerminalError) Error() string {
 	return fmt.Sprintf("%s: %d", e.err, e.exitCode)
 }
 
+// ExitCode returns the exit code that ecs-init should exit with
+func (e *TerminalError) ExitCode() int {
+	return e.exitCode
+}
+
 // New creates an instance of Engine
-func New() (*Engine, error) {
-	downloader, err := cache.NewDownloader()
+func New(cfg *config.Config) (*Engine, error) {
+	downloader, err := cache.NewDownloader(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -89,16 +112,23 @@ func New() (*Engine, error) {
 	if err != nil {
 		return nil, err
 	}
-	credentialsProxyRoute, err := iptables.NewNetfilterRoute(cmdExec)
+	credentialsProxyRoute, err := iptables.NewNetfilterRoute(cmdExec, cfg)
 	if err != nil {
 		return nil, err
 	}
 	return &Engine{
+		config:                   cfg,
 		downloader:               downloader,
 		loopbackRouting:          loopbackRouting,
 		credentialsProxyRoute:    credentialsProxyRoute,
 		ipv6RouterAdvertisements: ipv6RouterAdvertisements,
 		nvidiaGPUManager:         gpu.NewNvidiaGPUManager(),
+		restartHistoryFile:       config.RestartHistoryFile(),
+		exitPolicyFile:           config.AgentExitPolicyFile(),
+		controlSocket:            config.ControlSocket(),
+		metricsAddress:           cfg.MetricsListenAddress,
+		crashBundleDir:           config.CrashBundleDirectory(),
+		configFiles:              watchedConfigFiles(cfg),
 	}, nil
 }
 
@@ -106,6 +136,13 @@ func New() (*Engine, error) {
 // to handle credentials requests from containers by rerouting these requests to
 // to the ECS Agent's credentials endpoint
 func (e *Engine) PreStart() error {
+	err := e.preStart()
+	journal.Record(journal.PreStart, "", nil, err)
+	return err
+}
+
+func (e *Engine) preStart() error {
+	e.preflight()
 	// setup gpu if necessary
 	err := e.PreStartGPU()
 	if err != nil {
@@ -114,23 +151,26 @@ func (e *Engine) PreStart() error {
 	// Enable use of loopback addresses for local routing purposes
 	log.Info("pre-start: enabling loopback routing")
 	err = e.loopbackRouting.Enable()
+	recordHostSetup(journal.Sysctl, journal.Fields{"setting": "route_localnet", "action": "enable"}, err)
 	if err != nil {
 		return engineError("could not enable loopback routing", err)
 	}
 	// Disable ipv6 router advertisements
 	log.Info("pre-start: disabling ipv6 router advertisements")
 	err = e.ipv6RouterAdvertisements.Disable()
+	recordHostSetup(journal.Sysctl, journal.Fields{"setting": "accept_ra", "action": "disable"}, err)
 	if err != nil {
 		return engineError("could not disable ipv6 router advertisements", err)
 	}
 	// Add the rerouting netfilter rule for credentials endpoint
 	log.Info("pre-start: creating credentials proxy route")
 	err = e.credentialsProxyRoute.Create()
+	recordHostSetup(journal.Iptables, journal.Fields{"rule": "credentials-proxy", "action": "create"}, err)
 	if err != nil {
 		return engineError("could not create route to the credentials proxy", err)
 	}
 
-	docker, err := getDockerClient()
+	docker, err := getDockerClient(e.config)
 	if err != nil {
 		return dockerError(err)
 	}
@@ -140,18 +180,39 @@ func (e *Engine) PreStart() error {
 		return engineError("could not check Docker for Agent image presence", err)
 	}
 	log.Infof("pre-start: ecs agent container image loaded presence: %s", imageLoaded)
+	pulled, err := e.pullAgentImage(docker)
+	if pulled || err != nil {
+		return err
+	}
+	return e.loadCachedAgent(docker, imageLoaded)
+}
 
-	switch e.downloader.AgentCacheStatus() {
+// loadCachedAgent loads the Agent of the configured version into Docker,
+// downloading it first if it isn't cached, unless the image already loaded
+// is the cached one. Agents older than the minimum version are refused.
+func (e *Engine) loadCachedAgent(docker dockerClient, imageLoaded bool) error {
+	err := config.CheckMinimumAgentVersion(e.config.AgentVersion, e.config.AgentMinVersion)
+	if err != nil {
+		return engineError("refusing to start the Amazon Elastic Container Service Agent", err)
+	}
+	cacheStatus := e.downloader.AgentCacheStatus()
+	journal.Record(journal.CacheState, "", journal.Fields{
+		"status":      cacheStatusName(cacheStatus),
+		"version":     e.config.AgentVersion,
+		"imageLoaded": fmt.Sprint(imageLoaded),
+	}, nil)
+	metrics.CacheStatus(cacheStatusName(cacheStatus))
+	switch cacheStatus {
 	// Uncached, go get the Agent.
 	case cache.StatusUncached:
-		log.Info("pre-start: downloading agent")
+		log.Infof("pre-start: downloading agent %s", e.config.AgentVersion)
 		return e.downloadAndLoadCache(docker)
 
 	// The Agent is cached, and mandates a reload regardless of the
 	// already loaded image.
 	case cache.StatusReloadNeeded:
 		log.Info("pre-start: reloading agent")
-		return e.load(docker, e.downloader.LoadCachedAgent)
+		return e.load(docker, e.cachedAgent)
 
 	// Agent is cached, respect the already loaded Agent.
 	case cache.StatusCached:
@@ -159,7 +220,7 @@ func (e *Engine) PreStart() error {
 			return nil
 		}
 		log.Info("pre-start: loading cached agent")
-		return e.load(docker, e.downloader.LoadCachedAgent)
+		return e.load(docker, e.cachedAgent)
 
 	// There shouldn't be unhandled cache states.
 	default:
@@ -167,9 +228,57 @@ func (e *Engine) PreStart() error {
 	}
 }
 
+// pullAgentImage pulls the Agent image from the registry it's configured to
+// be pulled from, if any. It returns false if the image isn't pulled and has
+// to be loaded from the cache instead, which is also the case when the
+// registry can't be reached. An image that doesn't have the pinned digest is
+// an error rather than a reason to fall back to the cache. Pulled images have
+// neither a signature nor a known version, so only a reference pinned to a
+// digest may be pulled when signatures are required or a minimum version is
+// set.
+func (e *Engine) pullAgentImage(docker dockerClient) (bool, error) {
+	reference := e.config.AgentImageReference
+	if reference == "" {
+		return false, nil
+	}
+	if !isDigestPinned(reference) {
+		if e.config.AgentSignaturePolicy == config.SignaturePolicyRequire {
+			return false, engineError("refusing to pull the Agent image",
+				fmt.Errorf("%s isn't pinned to a digest and signatures are required", reference))
+		}
+		if e.config.AgentMinVersion != "" {
+			return false, engineError("refusing to pull the Agent image",
+				fmt.Errorf("%s isn't pinned to a digest and the minimum version %s is set", reference, e.config.AgentMinVersion))
+		}
+	}
+	log.Infof("pre-start: pulling agent image %s", reference)
+	err := docker.PullAgentImage(reference, e.config.AgentImageAuthFile)
+	journal.Record(journal.ImagePull, "", journal.Fields{"image": reference}, err)
+	if err == nil {
+		return true, nil
+	}
+	if isDigestMismatch(err) {
+		return false, engineError("refusing to run the Agent image pulled from the registry", err)
+	}
+	log.Warnf("pre-start: could not pull agent image, falling back to the cached agent: %v", err)
+	return false, nil
+}
+
+// isDigestPinned returns true if the image reference is pinned to a digest
+func isDigestPinned(reference string) bool {
+	return strings.Contains(reference, "@sha256:")
+}
+
+// isDigestMismatch returns true if err is about a pulled image that doesn't
+// have the digest its reference is pinned to
+func isDigestMismatch(err error) bool {
+	_, ok := err.(*docker.DigestMismatchError)
+	return ok
+}
+
 // PreStartGPU sets up the nvidia gpu manager if it's enabled.
 func (e *Engine) PreStartGPU() error {
-	docker, err := getDockerClient()
+	docker, err := getDockerClient(e.config)
 	if err != nil {
 		return dockerError(err)
 	}
@@ -180,6 +289,7 @@ func (e *Engine) PreStartGPU() error {
 			defer log.Info("pre-start: done setting up GPUs")
 			err := e.nvidiaGPUManager.Setup()
 			if err != nil {
+				metrics.HostSetupFailed("gpu-setup")
 				log.Errorf("Nvidia GPU Manager: %v", err)
 				return engineError("Nvidia GPU Manager", err)
 			}
@@ -190,7 +300,7 @@ func (e *Engine) PreStartGPU() error {
 
 // ReloadCache reloads the cached image of the ECS Agent into Docker
 func (e *Engine) ReloadCache() error {
-	docker, err := getDockerClient()
+	docker, err := getDockerClient(e.config)
 	if err != nil {
 		return dockerError(err)
 	}
@@ -198,7 +308,22 @@ func (e *Engine) ReloadCache() error {
 	if !cached {
 		return e.downloadAndLoadCache(docker)
 	}
-	return e.load(docker, e.downloader.LoadCachedAgent)
+	return e.load(docker, e.cachedAgent)
+}
+
+// cachedAgent returns the cached Agent, downloading it again first if the
+// cached tarball was corrupted or changed since it was cached
+func (e *Engine) cachedAgent() (io.ReadCloser, error) {
+	image, err := e.downloader.LoadCachedAgent()
+	if _, ok := err.(*cache.DigestMismatchError); !ok {
+		return image, err
+	}
+	log.Warnf("Cached agent doesn't match its recorded digest, downloading it again: %v", err)
+	err = e.downloadAgent()
+	if err != nil {
+		return nil, err
+	}
+	return e.downloader.LoadCachedAgent()
 }
 
 func (e *Engine) downloadAndLoadCache(docker dockerClient) error {
@@ -214,6 +339,7 @@ func (e *Engine) downloadAndLoadCache(docker dockerClient) error {
 func (e *Engine) downloadAgent() error {
 	log.Info("Downloading Amazon Elastic Container Service Agent")
 	err := e.downloader.DownloadAgent()
+	journal.Record(journal.Download, "", journal.Fields{"destination": config.AgentVersionTarball(e.config.AgentVersion)}, err)
 	if err != nil {
 		return engineError("could not download Amazon Elastic Container Service Agent", err)
 	}
@@ -226,71 +352,144 @@ func (e *Engine) load(docker dockerClient, agentLoader func() (io.ReadCloser, er
 		return engineError("could not load Amazon Elastic Container Service Agent from cache", err)
 	}
 	defer image.Close()
+	loadStart := time.Now()
 	err = docker.LoadImage(image)
+	metrics.ImageLoaded(time.Since(loadStart), err)
+	journal.Record(journal.ImageLoad, "", journal.Fields{"image": config.AgentImageName}, err)
 	if err != nil {
 		return engineError("could not load Amazon Elastic Container Service Agent into Docker", err)
 	}
-	return e.downloader.RecordCachedAgent()
+	err = e.downloader.RecordCachedAgent()
+	journal.Record(journal.CacheState, "", journal.Fields{"status": cacheStatusName(cache.StatusCached)}, err)
+	if err == nil {
+		metrics.CacheStatus(cacheStatusName(cache.StatusCached))
+	}
+	return err
 }
 
-// StartSupervised starts the ECS Agent and ensures it stays running, except for terminal errors (indicated by an agent exit code of 5)
+// StartSupervised starts the ECS Agent and ensures it stays running, except for terminal errors (indicated by an agent
+// exit code of 5) or when the Agent keeps failing, in which case the circuit breaker trips. What is done when the Agent
+// exits is decided by the exit code policy.
 func (e *Engine) StartSupervised() error {
-	docker, err := getDockerClient()
+	docker, err := getDockerClient(e.config)
 	if err != nil {
 		return dockerError(err)
 	}
-	agentExitCode := -1
 	retryBackoff := backoff.NewBackoff(serviceStartMinRetryTime, serviceStartMaxRetryTime,
 		serviceStartRetryJitter, serviceStartRetryMultiplier, serviceStartMaxRetries)
+	e.supervisor = newSupervisor(loadExitPolicy(e.exitPolicyFile))
+	history := loadRestartHistory(e.restartHistoryFile)
+	e.resumeBackoff(history, retryBackoff)
+	e.resumeProbation(history)
+	stop, cancel := e.handleStopSignals()
+	defer cancel()
+	stopWatchdog := e.startWatchdog(docker)
+	defer stopWatchdog()
+	stopControl := e.serveControl(docker)
+	defer stopControl()
+	stopMetrics := e.serveMetrics()
+	defer stopMetrics()
+	stopConfigWatch := e.watchConfig(docker)
+	defer stopConfigWatch()
 	for {
+		if !e.waitWhilePaused(stop) {
+			return nil
+		}
 		err := docker.RemoveExistingAgentContainer()
 		if err != nil {
 			return engineError("could not remove existing Agent container", err)
 		}
 
+		if stopRequested(stop) {
+			return nil
+		}
+
 		log.Info("Starting Amazon Elastic Container Service Agent")
-		agentExitCode, err = docker.StartAgent()
+		notifyStatus("Starting Amazon Elastic Container Service Agent")
+		run, err := e.runAgent(docker, stop)
 		if err != nil {
 			return engineError("could not start Agent", err)
 		}
-		log.Infof("Agent exited with code %d", agentExitCode)
-
-		switch agentExitCode {
-		case upgradeAgentExitCode:
-			err = e.upgradeAgent(docker)
-			if err != nil {
-				log.Error("could not upgrade agent", err)
-			} else {
-				// continuing here because a successful upgrade doesn't need to backoff retries
-				continue
-			}
-		case containerFailureAgentExitCode:
-			// capture the tail of the failed agent container
-			log.Infof("Captured the last %s lines of the agent container logs====>\n", failedContainerLogWindowSize)
-			log.Info(docker.GetContainerLogTail(failedContainerLogWindowSize))
-			log.Infof("<====end %s lines of the failed agent container logs\n", failedContainerLogWindowSize)
-		case TerminalFailureAgentExitCode:
-			return &TerminalError{
-				err:      "agent exited with terminal exit code",
-				exitCode: TerminalFailureAgentExitCode,
-			}
-		case terminalSuccessAgentExitCode:
+		log.Infof("Agent exited with code %d", run.ExitCode)
+		e.recordAgentRun(history, retryBackoff, run)
+		if stopRequested(stop) {
+			logAgentContainerTail(docker)
 			return nil
 		}
+
+		outcome, err := e.handleAgentExit(docker, history, run)
+		if err != nil || outcome == exitpolicy.Terminal {
+			return err
+		}
+		if outcome == exitpolicy.Restart {
+			log.Info("Restarting ECS Agent right away")
+			notifyStatus("Agent exited with code %d, restarting", run.ExitCode)
+			continue
+		}
 		d := retryBackoff.Duration()
 		log.Warnf("ECS Agent failed to start, retrying in %s", d)
-		time.Sleep(d)
+		notifyStatus("Agent exited with code %d, restarting in %s", run.ExitCode, d)
+		e.watchdog.wait()
+		if !waitBeforeRestart(d, stop, e.supervisor.restartNow) {
+			return nil
+		}
+		e.watchdog.busy()
+	}
+}
+
+// handleAgentExit ends the probation of an upgraded Agent and applies the
+// exit code policy, unless the Agent was stopped on request or by the
+// liveness probe. It returns whether the Agent has to be restarted right
+// away, restarted with a backoff or is no longer supervised, in which case
+// the error is the result of StartSupervised.
+func (e *Engine) handleAgentExit(docker dockerClient, history *restartHistory, run restartRecord) (exitpolicy.Action, error) {
+	if run.Requested {
+		// the upgrade probation, if any, carries over to the restarted Agent
+		log.Info("Agent was stopped on request, restarting it right away")
+		return exitpolicy.Restart, nil
+	}
+	if e.probation != nil {
+		rolledBack := e.endProbation(docker, run)
+		e.saveProbation(history)
+		if rolledBack {
+			// the known-good Agent is restarted right away
+			return exitpolicy.Restart, nil
+		}
+	}
+	outcome := exitpolicy.RestartWithBackoff
+	if run.Unresponsive {
+		// the exit code is the result of stopping the Agent, which is
+		// restarted regardless of the exit code policy
+		log.Info("Agent was stopped by the liveness probe, restarting it")
+	} else {
+		outcome = e.applyExitRule(docker, e.supervisor.exitPolicy().Match(run.ExitCode), run.ExitCode)
+	}
+	switch outcome {
+	case exitpolicy.Upgrade:
+		// a successful upgrade doesn't need to backoff retries, and its
+		// probation outlives ecs-init
+		e.saveProbation(history)
+		return exitpolicy.Restart, nil
+	case exitpolicy.Terminal:
+		return outcome, terminalExit(run.ExitCode)
+	}
+	err := e.checkCrashLoop(docker, history)
+	if err != nil {
+		return exitpolicy.Terminal, err
 	}
+	return outcome, nil
 }
 
-func (e *Engine) upgradeAgent(docker dockerClient) error {
-	log.Info("Loading new desired Amazon Elastic Container Service Agent into Docker")
-	return e.load(docker, e.downloader.LoadDesiredAgent)
+// logAgentContainerTail captures the tail of the failed agent container
+func logAgentContainerTail(docker dockerClient) {
+	log.Infof("Captured the last %s lines of the agent container logs====>\n", failedContainerLogWindowSize)
+	log.Info(docker.GetContainerLogTail(failedContainerLogWindowSize))
+	log.Infof("<====end %s lines of the failed agent container logs\n", failedContainerLogWindowSize)
 }
 
 // PreStop sends commands to Docker to stop the ECS Agent
 func (e *Engine) PreStop() error {
-	docker, err := getDockerClient()
+	docker, err := getDockerClient(e.config)
 	if err != nil {
 		return dockerError(err)
 	}
@@ -307,10 +506,13 @@ func (e *Engine) PreStop() error {
 func (e *Engine) PostStop() error {
 	log.Info("Cleaning up the credentials endpoint setup for Amazon Elastic Container Service Agent")
 	err := e.loopbackRouting.RestoreDefault()
+	recordHostSetup(journal.Sysctl, journal.Fields{"setting": "route_localnet", "action": "restore-default"}, err)
 
 	// Ignore error from Remove() as the netfilter might never have been
 	// added in the first place
-	e.credentialsProxyRoute.Remove()
+	removeErr := e.credentialsProxyRoute.Remove()
+	recordHostSetup(journal.Iptables, journal.Fields{"rule": "credentials-proxy", "action": "remove"}, removeErr)
+	journal.Record(journal.PostStop, "", nil, err)
 	return err
 }
 
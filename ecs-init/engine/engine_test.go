@@ -19,9 +19,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/aws/amazon-ecs-init/ecs-init/cache"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/docker"
 	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
 	"github.com/golang/mock/gomock"
 )
@@ -31,7 +34,7 @@ import (
 // (e.g. defer getDockerClientMock(mock)() )
 func getDockerClientMock(mockDocker dockerClient) func() {
 	getDockerClientBkp := getDockerClient
-	getDockerClient = func() (dockerClient, error) {
+	getDockerClient = func(cfg *config.Config) (dockerClient, error) {
 		return mockDocker, nil
 	}
 	return func() {
@@ -61,6 +64,7 @@ func TestPreStartImageAlreadyCachedAndLoaded(t *testing.T) {
 	mockRoute.EXPECT().Create().Return(nil)
 
 	engine := &Engine{
+		config:                   config.Defaults(),
 		downloader:               mockDownloader,
 		loopbackRouting:          mockLoopbackRouting,
 		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
@@ -99,6 +103,7 @@ func TestPreStartReloadNeeded(t *testing.T) {
 	mockRoute.EXPECT().Create().Return(nil)
 
 	engine := &Engine{
+		config:                   config.Defaults(),
 		downloader:               mockDownloader,
 		loopbackRouting:          mockLoopbackRouting,
 		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
@@ -134,6 +139,47 @@ func TestPreStartImageNotLoadedCached(t *testing.T) {
 	mockDownloader.EXPECT().RecordCachedAgent()
 
 	engine := &Engine{
+		config:                   config.Defaults(),
+		downloader:               mockDownloader,
+		loopbackRouting:          mockLoopbackRouting,
+		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
+		credentialsProxyRoute:    mockRoute,
+	}
+	err := engine.PreStart()
+	if err != nil {
+		t.Errorf("engine pre-start error: %v", err)
+	}
+}
+
+func TestPreStartCachedAgentDigestMismatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cachedAgentBuffer := ioutil.NopCloser(&bytes.Buffer{})
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDownloader := NewMockdownloader(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+
+	mockDocker.EXPECT().LoadEnvVars().Return(nil)
+	mockRoute.EXPECT().Create().Return(nil)
+	mockLoopbackRouting.EXPECT().Enable().Return(nil)
+	mockIpv6RouterAdvertisements := NewMockipv6RouterAdvertisements(mockCtrl)
+	mockIpv6RouterAdvertisements.EXPECT().Disable().Return(nil)
+	mockDocker.EXPECT().IsAgentImageLoaded().Return(false, nil)
+	mockDownloader.EXPECT().AgentCacheStatus().Return(cache.StatusCached)
+	gomock.InOrder(
+		mockDownloader.EXPECT().LoadCachedAgent().Return(nil, &cache.DigestMismatchError{File: config.AgentTarball()}),
+		mockDownloader.EXPECT().DownloadAgent(),
+		mockDownloader.EXPECT().LoadCachedAgent().Return(cachedAgentBuffer, nil),
+		mockDocker.EXPECT().LoadImage(cachedAgentBuffer),
+		mockDownloader.EXPECT().RecordCachedAgent(),
+	)
+
+	engine := &Engine{
+		config:                   config.Defaults(),
 		downloader:               mockDownloader,
 		loopbackRouting:          mockLoopbackRouting,
 		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
@@ -171,6 +217,45 @@ func TestPreStartImageNotCached(t *testing.T) {
 	mockRoute.EXPECT().Create().Return(nil)
 
 	engine := &Engine{
+		config:                   config.Defaults(),
+		downloader:               mockDownloader,
+		loopbackRouting:          mockLoopbackRouting,
+		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
+		credentialsProxyRoute:    mockRoute,
+	}
+	err := engine.PreStart()
+	if err != nil {
+		t.Errorf("engine pre-start error: %v", err)
+	}
+}
+
+// testAgentImageReference is a registry reference of the Agent image
+const testAgentImageReference = "public.ecr.aws/ecs/amazon-ecs-agent:latest"
+
+func TestPreStartPullAgentImage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	mockDocker.EXPECT().LoadEnvVars().Return(nil)
+	mockDocker.EXPECT().IsAgentImageLoaded().Return(true, nil)
+	mockDocker.EXPECT().PullAgentImage(testAgentImageReference, "/etc/ecs/registry.json").Return(nil)
+
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockLoopbackRouting.EXPECT().Enable().Return(nil)
+	mockIpv6RouterAdvertisements := NewMockipv6RouterAdvertisements(mockCtrl)
+	mockIpv6RouterAdvertisements.EXPECT().Disable().Return(nil)
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+	mockRoute.EXPECT().Create().Return(nil)
+
+	cfg := config.Defaults()
+	cfg.AgentImageReference = testAgentImageReference
+	cfg.AgentImageAuthFile = "/etc/ecs/registry.json"
+	engine := &Engine{
+		config:                   cfg,
 		downloader:               mockDownloader,
 		loopbackRouting:          mockLoopbackRouting,
 		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
@@ -182,6 +267,169 @@ func TestPreStartImageNotCached(t *testing.T) {
 	}
 }
 
+func TestPreStartPullAgentImageRegistryUnreachable(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	mockDocker.EXPECT().LoadEnvVars().Return(nil)
+	mockDocker.EXPECT().IsAgentImageLoaded().Return(true, nil)
+	mockDocker.EXPECT().PullAgentImage(testAgentImageReference, "/etc/ecs/registry.json").Return(errors.New("registry unreachable"))
+	mockDownloader.EXPECT().AgentCacheStatus().Return(cache.StatusCached)
+
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockLoopbackRouting.EXPECT().Enable().Return(nil)
+	mockIpv6RouterAdvertisements := NewMockipv6RouterAdvertisements(mockCtrl)
+	mockIpv6RouterAdvertisements.EXPECT().Disable().Return(nil)
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+	mockRoute.EXPECT().Create().Return(nil)
+
+	cfg := config.Defaults()
+	cfg.AgentImageReference = testAgentImageReference
+	cfg.AgentImageAuthFile = "/etc/ecs/registry.json"
+	engine := &Engine{
+		config:                   cfg,
+		downloader:               mockDownloader,
+		loopbackRouting:          mockLoopbackRouting,
+		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
+		credentialsProxyRoute:    mockRoute,
+	}
+	err := engine.PreStart()
+	if err != nil {
+		t.Errorf("engine pre-start error, expected the cached agent to be used: %v", err)
+	}
+}
+
+func TestPreStartPullAgentImageDigestMismatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	mockDocker.EXPECT().LoadEnvVars().Return(nil)
+	mockDocker.EXPECT().IsAgentImageLoaded().Return(true, nil)
+	mockDocker.EXPECT().PullAgentImage(testAgentImageReference, "/etc/ecs/registry.json").Return(&docker.DigestMismatchError{Reference: testAgentImageReference})
+
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockLoopbackRouting.EXPECT().Enable().Return(nil)
+	mockIpv6RouterAdvertisements := NewMockipv6RouterAdvertisements(mockCtrl)
+	mockIpv6RouterAdvertisements.EXPECT().Disable().Return(nil)
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+	mockRoute.EXPECT().Create().Return(nil)
+
+	cfg := config.Defaults()
+	cfg.AgentImageReference = testAgentImageReference
+	cfg.AgentImageAuthFile = "/etc/ecs/registry.json"
+	engine := &Engine{
+		config:                   cfg,
+		downloader:               mockDownloader,
+		loopbackRouting:          mockLoopbackRouting,
+		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
+		credentialsProxyRoute:    mockRoute,
+	}
+	err := engine.PreStart()
+	if err == nil {
+		t.Error("expected an engine pre-start error rather than a fallback to the cached agent")
+	}
+}
+
+func TestPreStartPullAgentImageNotPinned(t *testing.T) {
+	pinnedReference := "public.ecr.aws/ecs/amazon-ecs-agent@sha256:" + strings.Repeat("0", 64)
+	testCases := []struct {
+		name            string
+		reference       string
+		signaturePolicy string
+		minVersion      string
+		pulled          bool
+	}{
+		{"signatures required", testAgentImageReference, config.SignaturePolicyRequire, "", false},
+		{"minimum version", testAgentImageReference, config.SignaturePolicyWarn, "1.50.0", false},
+		{"pinned, signatures required", pinnedReference, config.SignaturePolicyRequire, "1.50.0", true},
+		{"signatures not required", testAgentImageReference, config.SignaturePolicyWarn, "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockDocker := NewMockdockerClient(mockCtrl)
+			defer getDockerClientMock(mockDocker)()
+			mockDownloader := NewMockdownloader(mockCtrl)
+
+			mockDocker.EXPECT().LoadEnvVars().Return(nil)
+			mockDocker.EXPECT().IsAgentImageLoaded().Return(true, nil)
+			if tc.pulled {
+				mockDocker.EXPECT().PullAgentImage(tc.reference, "").Return(nil)
+			}
+
+			mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+			mockLoopbackRouting.EXPECT().Enable().Return(nil)
+			mockIpv6RouterAdvertisements := NewMockipv6RouterAdvertisements(mockCtrl)
+			mockIpv6RouterAdvertisements.EXPECT().Disable().Return(nil)
+			mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+			mockRoute.EXPECT().Create().Return(nil)
+
+			cfg := config.Defaults()
+			cfg.AgentImageReference = tc.reference
+			cfg.AgentSignaturePolicy = tc.signaturePolicy
+			cfg.AgentMinVersion = tc.minVersion
+			engine := &Engine{
+				config:                   cfg,
+				downloader:               mockDownloader,
+				loopbackRouting:          mockLoopbackRouting,
+				ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
+				credentialsProxyRoute:    mockRoute,
+			}
+			err := engine.PreStart()
+			if tc.pulled && err != nil {
+				t.Errorf("engine pre-start error: %v", err)
+			}
+			if !tc.pulled && err == nil {
+				t.Error("expected an engine pre-start error for an image reference that isn't pinned to a digest")
+			}
+		})
+	}
+}
+
+func TestPreStartAgentOlderThanMinimumVersion(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	mockDocker.EXPECT().LoadEnvVars().Return(nil)
+	mockDocker.EXPECT().IsAgentImageLoaded().Return(true, nil)
+
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockLoopbackRouting.EXPECT().Enable().Return(nil)
+	mockIpv6RouterAdvertisements := NewMockipv6RouterAdvertisements(mockCtrl)
+	mockIpv6RouterAdvertisements.EXPECT().Disable().Return(nil)
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+	mockRoute.EXPECT().Create().Return(nil)
+
+	cfg := config.Defaults()
+	cfg.AgentVersion = "v1.50.0"
+	cfg.AgentMinVersion = "v1.60.0"
+	engine := &Engine{
+		config:                   cfg,
+		downloader:               mockDownloader,
+		loopbackRouting:          mockLoopbackRouting,
+		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
+		credentialsProxyRoute:    mockRoute,
+	}
+	err := engine.PreStart()
+	if err == nil {
+		t.Error("expected an engine pre-start error for an agent older than the minimum version")
+	}
+}
+
 func TestPreStartGPUSetupSuccessful(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -208,6 +456,7 @@ func TestPreStartGPUSetupSuccessful(t *testing.T) {
 	mockRoute.EXPECT().Create().Return(nil)
 
 	engine := &Engine{
+		config:                   config.Defaults(),
 		downloader:               mockDownloader,
 		loopbackRouting:          mockLoopbackRouting,
 		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
@@ -233,6 +482,7 @@ func TestPreStartGPUSetupError(t *testing.T) {
 	})
 	mockGPUManager.EXPECT().Setup().Return(errors.New("gpu setup failed"))
 	engine := &Engine{
+		config:           config.Defaults(),
 		nvidiaGPUManager: mockGPUManager,
 	}
 	err := engine.PreStart()
@@ -250,7 +500,7 @@ func TestStartSupervisedCannotStart(t *testing.T) {
 	mockDocker.EXPECT().RemoveExistingAgentContainer()
 	mockDocker.EXPECT().StartAgent().Return(0, errors.New("test error"))
 
-	engine := &Engine{}
+	engine := &Engine{config: config.Defaults()}
 	err := engine.StartSupervised()
 	if err == nil {
 		t.Error("Expected error to be returned but was nil")
@@ -275,7 +525,7 @@ func TestStartSupervisedExitsWhenTerminalFailure(t *testing.T) {
 		mockDocker.EXPECT().StartAgent().Return(TerminalFailureAgentExitCode, nil),
 	)
 
-	engine := &Engine{}
+	engine := &Engine{config: config.Defaults()}
 	err := engine.StartSupervised()
 
 	if err == nil {
@@ -300,7 +550,7 @@ func TestLogContainerFailureAgentExitCodeFailure(t *testing.T) {
 	mockDocker.EXPECT().RemoveExistingAgentContainer()
 	mockDocker.EXPECT().StartAgent().Return(0, errors.New("test error"))
 
-	engine := &Engine{}
+	engine := &Engine{config: config.Defaults()}
 	err := engine.StartSupervised()
 	if err == nil {
 		t.Error("Expected error to be returned but was nil")
@@ -324,7 +574,7 @@ func TestStartSupervisedExitsWhenTerminalSuccess(t *testing.T) {
 		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
 	)
 
-	engine := &Engine{}
+	engine := &Engine{config: config.Defaults()}
 	err := engine.StartSupervised()
 	if err != nil {
 		t.Error("Expected error to be nil but was returned")
@@ -342,12 +592,14 @@ func TestStartSupervisedUpgradeOpenFailure(t *testing.T) {
 	gomock.InOrder(
 		mockDocker.EXPECT().RemoveExistingAgentContainer(),
 		mockDocker.EXPECT().StartAgent().Return(upgradeAgentExitCode, nil),
+		mockDocker.EXPECT().TagAgentImageKnownGood(),
 		mockDownloader.EXPECT().LoadDesiredAgent().Return(nil, errors.New("test error")),
 		mockDocker.EXPECT().RemoveExistingAgentContainer(),
 		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
 	)
 
 	engine := &Engine{
+		config:     config.Defaults(),
 		downloader: mockDownloader,
 	}
 	err := engine.StartSupervised()
@@ -367,6 +619,7 @@ func TestStartSupervisedUpgradeLoadFailure(t *testing.T) {
 	gomock.InOrder(
 		mockDocker.EXPECT().RemoveExistingAgentContainer(),
 		mockDocker.EXPECT().StartAgent().Return(upgradeAgentExitCode, nil),
+		mockDocker.EXPECT().TagAgentImageKnownGood(),
 		mockDownloader.EXPECT().LoadDesiredAgent().Return(&os.File{}, nil),
 		mockDocker.EXPECT().LoadImage(gomock.Any()).Return(errors.New("test error")),
 		mockDocker.EXPECT().RemoveExistingAgentContainer(),
@@ -374,6 +627,7 @@ func TestStartSupervisedUpgradeLoadFailure(t *testing.T) {
 	)
 
 	engine := &Engine{
+		config:     config.Defaults(),
 		downloader: mockDownloader,
 	}
 	err := engine.StartSupervised()
@@ -393,6 +647,7 @@ func TestStartSupervisedUpgrade(t *testing.T) {
 	gomock.InOrder(
 		mockDocker.EXPECT().RemoveExistingAgentContainer(),
 		mockDocker.EXPECT().StartAgent().Return(upgradeAgentExitCode, nil),
+		mockDocker.EXPECT().TagAgentImageKnownGood(),
 		mockDownloader.EXPECT().LoadDesiredAgent().Return(&os.File{}, nil),
 		mockDocker.EXPECT().LoadImage(gomock.Any()),
 		mockDownloader.EXPECT().RecordCachedAgent(),
@@ -401,6 +656,7 @@ func TestStartSupervisedUpgrade(t *testing.T) {
 	)
 
 	engine := &Engine{
+		config:     config.Defaults(),
 		downloader: mockDownloader,
 	}
 	err := engine.StartSupervised()
@@ -417,7 +673,7 @@ func TestPreStop(t *testing.T) {
 	defer getDockerClientMock(mockDocker)()
 	mockDocker.EXPECT().StopAgent()
 
-	engine := &Engine{}
+	engine := &Engine{config: config.Defaults()}
 	err := engine.PreStop()
 	if err != nil {
 		t.Errorf("engine pre-stop error: %v", err)
@@ -441,6 +697,7 @@ func TestReloadCacheNotCached(t *testing.T) {
 	mockDownloader.EXPECT().RecordCachedAgent()
 
 	engine := &Engine{
+		config:     config.Defaults(),
 		downloader: mockDownloader,
 	}
 	err := engine.ReloadCache()
@@ -465,6 +722,7 @@ func TestReloadCacheCached(t *testing.T) {
 	mockDownloader.EXPECT().RecordCachedAgent()
 
 	engine := &Engine{
+		config:     config.Defaults(),
 		downloader: mockDownloader,
 	}
 	err := engine.ReloadCache()
@@ -487,6 +745,7 @@ func TestPrestartLoopbackRoutingNotEnabled(t *testing.T) {
 	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
 
 	engine := &Engine{
+		config:                config.Defaults(),
 		downloader:            mockDownloader,
 		loopbackRouting:       mockLoopbackRouting,
 		credentialsProxyRoute: mockRoute,
@@ -514,6 +773,7 @@ func TestPrestartCredentialsProxyRouteNotCreated(t *testing.T) {
 	mockRoute.EXPECT().Create().Return(fmt.Errorf("iptables not found"))
 
 	engine := &Engine{
+		config:                   config.Defaults(),
 		downloader:               mockDownloader,
 		loopbackRouting:          mockLoopbackRouting,
 		ipv6RouterAdvertisements: mockIpv6RouterAdvertisements,
@@ -535,6 +795,7 @@ func TestPostStop(t *testing.T) {
 	mockRoute.EXPECT().Remove().Return(nil)
 
 	engine := &Engine{
+		config:                config.Defaults(),
 		loopbackRouting:       mockLoopbackRouting,
 		credentialsProxyRoute: mockRoute,
 	}
@@ -554,6 +815,7 @@ func TestPostStopLoopbackRoutingError(t *testing.T) {
 	mockRoute.EXPECT().Remove().Return(nil)
 
 	engine := &Engine{
+		config:                config.Defaults(),
 		loopbackRouting:       mockLoopbackRouting,
 		credentialsProxyRoute: mockRoute,
 	}
@@ -573,6 +835,7 @@ func TestPostStopCredentialsProxyRouteRemoveError(t *testing.T) {
 	mockRoute.EXPECT().Remove().Return(fmt.Errorf("cannot remove"))
 
 	engine := &Engine{
+		config:                config.Defaults(),
 		loopbackRouting:       mockLoopbackRouting,
 		credentialsProxyRoute: mockRoute,
 	}
@@ -0,0 +1,102 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
+
+	log "github.com/cihub/seelog"
+)
+
+const (
+	exitHookTimeout        = time.Minute
+	exitHookExitCodeEnvVar = "ECS_AGENT_EXIT_CODE"
+)
+
+// Injection point for testing purposes
+var runExitHook = runExitHookCommand
+
+// loadExitPolicy reads the exit code policy from path, falling back to the
+// built-in policy if there is no policy file or if it is invalid
+func loadExitPolicy(path string) *exitpolicy.Policy {
+	if path == "" {
+		return exitpolicy.Default()
+	}
+	policy, err := exitpolicy.Load(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Could not load exit code policy, using the built-in policy: %v", err)
+		}
+		return exitpolicy.Default()
+	}
+	log.Infof("Loaded exit code policy from %s", path)
+	return policy
+}
+
+// applyExitRule takes the actions of the rule for the exit code of the Agent
+// and returns the outcome, which is either restart, restart-with-backoff or
+// terminal, or upgrade if the Agent was upgraded and has to be restarted
+// right away
+func (e *Engine) applyExitRule(docker dockerClient, rule *exitpolicy.Rule, exitCode int) exitpolicy.Action {
+	for _, action := range rule.Actions {
+		switch action {
+		case exitpolicy.CaptureLogs:
+			logAgentContainerTail(docker)
+		case exitpolicy.CaptureBundle:
+			e.captureCrashBundle(docker, exitCode)
+		case exitpolicy.Hook:
+			err := runExitHook(rule.Hook, exitCode)
+			if err != nil {
+				log.Errorf("exit code policy hook failed: %v", err)
+			}
+		case exitpolicy.Upgrade:
+			err := e.upgradeAgent(docker)
+			if err == nil {
+				return exitpolicy.Upgrade
+			}
+			log.Error("could not upgrade agent", err)
+		default:
+			return action
+		}
+	}
+	return exitpolicy.RestartWithBackoff
+}
+
+// terminalExit returns the result of StartSupervised once the Agent is no
+// longer supervised
+func terminalExit(exitCode int) error {
+	if exitCode == terminalSuccessAgentExitCode {
+		return nil
+	}
+	return &TerminalError{
+		err:      "agent exited with terminal exit code",
+		exitCode: TerminalFailureAgentExitCode,
+	}
+}
+
+func runExitHookCommand(command []string, exitCode int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exitHookTimeout)
+	defer cancel()
+	cmd := osexec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", exitHookExitCodeEnvVar, exitCode))
+	output, err := cmd.CombinedOutput()
+	log.Infof("Exit code policy hook %s output: %s", command[0], output)
+	return err
+}
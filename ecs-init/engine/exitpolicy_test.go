@@ -0,0 +1,84 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartSupervisedAppliesExitPolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir, err := ioutil.TempDir("", "exit-code-policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	policyFile := filepath.Join(dir, "exit-code-policy.json")
+	require.NoError(t, ioutil.WriteFile(policyFile, []byte(`{
+		"rules": [
+			{"exitCodes": ["3"], "actions": ["capture-logs", "hook", "restart"], "hook": ["notify"]},
+			{"exitCodes": ["4"], "actions": ["terminal"]}
+		]
+	}`), 0644))
+
+	var hookExitCodes []int
+	runExitHookBkp := runExitHook
+	defer func() {
+		runExitHook = runExitHookBkp
+	}()
+	runExitHook = func(command []string, exitCode int) error {
+		assert.Equal(t, []string{"notify"}, command)
+		hookExitCodes = append(hookExitCodes, exitCode)
+		return nil
+	}
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(3, nil),
+		mockDocker.EXPECT().GetContainerLogTail(gomock.Any()),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(4, nil),
+	)
+
+	engine := &Engine{config: config.Defaults(), exitPolicyFile: policyFile}
+	err = engine.StartSupervised()
+	terminalErr, ok := err.(*TerminalError)
+	require.True(t, ok, "Expected error to be of type TerminalError")
+	assert.Equal(t, TerminalFailureAgentExitCode, terminalErr.ExitCode())
+	assert.Equal(t, []int{3}, hookExitCodes)
+}
+
+func TestLoadExitPolicyFallsBackToDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exit-code-policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	policyFile := filepath.Join(dir, "exit-code-policy.json")
+	require.NoError(t, ioutil.WriteFile(policyFile, []byte(`{"rules": [{"exitCodes": ["3"]}]}`), 0644))
+
+	for _, path := range []string{"", filepath.Join(dir, "missing.json"), policyFile} {
+		policy := loadExitPolicy(path)
+		assert.Len(t, policy.Rules, 4, "expected the built-in policy for %q", path)
+	}
+}
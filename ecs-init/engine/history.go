@@ -0,0 +1,137 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+
+	"github.com/pkg/errors"
+)
+
+// Injection point for testing purposes
+var eventJournal = func() *journal.Journal {
+	return journal.New(config.EventJournalFile(), journal.DefaultMaxSize, journal.DefaultMaxFiles)
+}
+
+// HistoryOptions select the events printed by History
+type HistoryOptions struct {
+	// Types is a comma separated list of event types
+	Types string
+	// Since is either a duration back from now, such as 24h, or an RFC 3339
+	// timestamp
+	Since string
+	// Correlation is either a correlation or an invocation ID
+	Correlation string
+	// Output is either StatusOutputText or StatusOutputJSON
+	Output string
+}
+
+// History prints the events of the lifecycle event journal selected by the
+// options, oldest first
+func History(options *HistoryOptions) error {
+	if options.Output != StatusOutputText && options.Output != StatusOutputJSON {
+		return errors.Errorf("unsupported output format %q", options.Output)
+	}
+	filter, err := historyFilter(options)
+	if err != nil {
+		return err
+	}
+	events, err := eventJournal().Read(filter)
+	if err != nil {
+		return engineError("could not read the event journal", err)
+	}
+	err = writeHistory(reportOutput, events, options.Output)
+	if err != nil {
+		return engineError("could not write history", err)
+	}
+	return nil
+}
+
+func historyFilter(options *HistoryOptions) (*journal.Filter, error) {
+	filter := &journal.Filter{Correlation: options.Correlation}
+	for _, eventType := range strings.Split(options.Types, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+	if options.Since == "" {
+		return filter, nil
+	}
+	if d, err := time.ParseDuration(options.Since); err == nil {
+		filter.Since = time.Now().Add(-d)
+		return filter, nil
+	}
+	since, err := time.Parse(time.RFC3339, options.Since)
+	if err != nil {
+		return nil, errors.Errorf("invalid since %q, expected a duration such as 24h or an RFC 3339 timestamp",
+			options.Since)
+	}
+	filter.Since = since
+	return filter, nil
+}
+
+func writeHistory(w io.Writer, events []*journal.Event, output string) error {
+	if output == StatusOutputJSON {
+		encoder := json.NewEncoder(w)
+		for _, event := range events {
+			err := encoder.Encode(event)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, event := range events {
+		_, err := fmt.Fprintln(w, formatEvent(event))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatEvent formats the event on a single line, with its fields sorted by
+// name
+func formatEvent(event *journal.Event) string {
+	correlation := event.Correlation
+	if correlation == "" {
+		correlation = "-"
+	}
+	parts := []string{
+		event.Time.UTC().Format(time.RFC3339),
+		fmt.Sprintf("%-12s", event.Type),
+		event.Invocation,
+		fmt.Sprintf("%-16s", correlation),
+	}
+	var names []string
+	for name := range event.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", name, event.Fields[name]))
+	}
+	if event.Error != "" {
+		parts = append(parts, fmt.Sprintf("error=%q", event.Error))
+	}
+	return strings.Join(parts, " ")
+}
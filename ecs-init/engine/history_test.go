@@ -0,0 +1,120 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// journalMock makes the journal in dir the default journal and the journal
+// read by History. The backup can be restored by executing the returned
+// function in a deferred manner.
+func journalMock(dir string) (*journal.Journal, func()) {
+	eventJournalBkp := eventJournal
+	testJournal := journal.New(filepath.Join(dir, "events.log"), journal.DefaultMaxSize, journal.DefaultMaxFiles)
+	journal.SetDefault(testJournal)
+	eventJournal = func() *journal.Journal {
+		return testJournal
+	}
+	return testJournal, func() {
+		journal.SetDefault(nil)
+		eventJournal = eventJournalBkp
+	}
+}
+
+func TestStartSupervisedRecordsAgentRuns(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	testJournal, restore := journalMock(dir)
+	defer restore()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(TerminalFailureAgentExitCode, nil),
+	)
+
+	engine := &Engine{config: config.Defaults()}
+	assert.Error(t, engine.StartSupervised())
+
+	events, err := testJournal.Read(&journal.Filter{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, journal.AgentStart, events[0].Type)
+	assert.Equal(t, journal.AgentExit, events[1].Type)
+	assert.NotEmpty(t, events[0].Correlation)
+	assert.Equal(t, events[0].Correlation, events[1].Correlation)
+	assert.Equal(t, "5", events[1].Fields["exitCode"])
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	testJournal, restore := journalMock(dir)
+	defer restore()
+	out := &bytes.Buffer{}
+	defer statusMocks(out, nil)()
+
+	eventTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, testJournal.Append(&journal.Event{
+		Time:        eventTime,
+		Invocation:  "invocation",
+		Correlation: "run",
+		Type:        journal.AgentExit,
+		Fields:      journal.Fields{"exitCode": "1", "requested": "false"},
+	}))
+	require.NoError(t, testJournal.Append(&journal.Event{
+		Time:       eventTime,
+		Invocation: "invocation",
+		Type:       journal.Download,
+		Error:      "test error",
+	}))
+
+	err = History(&HistoryOptions{Types: "agent-exit", Output: StatusOutputText})
+	require.NoError(t, err)
+	assert.Equal(t, `2020-01-01T00:00:00Z agent-exit   invocation run              exitCode="1" requested="false"`+"\n",
+		out.String())
+
+	out.Reset()
+	err = History(&HistoryOptions{Since: "2019-12-31T00:00:00Z", Output: StatusOutputJSON})
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 2)
+
+	out.Reset()
+	err = History(&HistoryOptions{Since: "24h", Output: StatusOutputText})
+	require.NoError(t, err)
+	assert.Empty(t, out.String())
+
+	assert.Error(t, History(&HistoryOptions{Since: "yesterday", Output: StatusOutputText}))
+	assert.Error(t, History(&HistoryOptions{Output: "yaml"}))
+}
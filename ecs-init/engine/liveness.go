@@ -0,0 +1,108 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	log "github.com/cihub/seelog"
+)
+
+// livenessProbe restarts an Agent that keeps running but stopped answering on
+// its introspection endpoint, such as a deadlocked Agent
+type livenessProbe struct {
+	interval    time.Duration
+	gracePeriod time.Duration
+	failures    int
+}
+
+// newLivenessProbe returns nil unless the liveness probe is enabled
+func newLivenessProbe(cfg *config.Config) *livenessProbe {
+	if !cfg.AgentLivenessProbe {
+		return nil
+	}
+	return &livenessProbe{
+		interval:    cfg.AgentLivenessInterval,
+		gracePeriod: cfg.AgentLivenessGracePeriod,
+		failures:    cfg.AgentLivenessFailures,
+	}
+}
+
+// watch probes the Agent until it fails too many probes in a row, in which
+// case the tail of its logs is captured and it is stopped, so that the
+// supervision loop starts it again. The Agent isn't probed while its
+// supervision is paused. The returned function stops watching and returns
+// whether the Agent was stopped.
+func (p *livenessProbe) watch(docker dockerClient, paused func() bool) func() bool {
+	if p == nil {
+		return func() bool {
+			return false
+		}
+	}
+	done := make(chan struct{})
+	stopped := make(chan bool, 1)
+	go func() {
+		stopped <- p.probe(docker, paused, done)
+	}()
+	return func() bool {
+		close(done)
+		return <-stopped
+	}
+}
+
+func (p *livenessProbe) probe(docker dockerClient, paused func() bool, done <-chan struct{}) bool {
+	grace := time.NewTimer(p.gracePeriod)
+	defer grace.Stop()
+	select {
+	case <-done:
+		return false
+	case <-grace.C:
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-done:
+			return false
+		case <-ticker.C:
+		}
+		if paused() {
+			failures = 0
+			continue
+		}
+		err := probeAgentIntrospection()
+		if err == nil {
+			failures = 0
+			continue
+		}
+		failures++
+		log.Warnf("Agent liveness probe failed (%d/%d): %v", failures, p.failures, err)
+		if failures < p.failures {
+			continue
+		}
+		log.Errorf("Agent did not answer %d liveness probes in a row, restarting it", failures)
+		logAgentContainerTail(docker)
+		notifyStatus("Restarting unresponsive Amazon Elastic Container Service Agent")
+		err = docker.StopAgent()
+		if err != nil {
+			log.Errorf("could not stop unresponsive agent: %v", err)
+			failures = 0
+			continue
+		}
+		return true
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartSupervisedRestartsUnresponsiveAgent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	defer probeAgentIntrospectionMock(func() error {
+		return errors.New("connection refused")
+	})()
+	cfg := config.Defaults()
+	cfg.AgentLivenessProbe = true
+	cfg.AgentLivenessInterval = time.Millisecond
+	cfg.AgentLivenessGracePeriod = time.Millisecond
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	agentStopped := make(chan struct{})
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			<-agentStopped
+			// the restarted Agent isn't probed
+			cfg.AgentLivenessProbe = false
+			return 143, nil
+		}),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
+	)
+	gomock.InOrder(
+		mockDocker.EXPECT().GetContainerLogTail(gomock.Any()),
+		mockDocker.EXPECT().StopAgent().Do(func() {
+			close(agentStopped)
+		}),
+	)
+
+	// exit code 143 is terminal according to this policy, which doesn't apply
+	// to an Agent stopped by the liveness probe
+	dir, err := ioutil.TempDir("", "exit-code-policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	policyFile := filepath.Join(dir, "exit-code-policy.json")
+	require.NoError(t, ioutil.WriteFile(policyFile,
+		[]byte(`{"rules": [{"exitCodes": ["143"], "actions": ["terminal"]}]}`), 0644))
+
+	engine := &Engine{config: cfg, exitPolicyFile: policyFile}
+	err = engine.StartSupervised()
+	assert.NoError(t, err)
+}
+
+func TestLivenessProbeResetsFailuresOnSuccess(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	probes := 0
+	probed := make(chan struct{})
+	defer probeAgentIntrospectionMock(func() error {
+		probes++
+		if probes == 6 {
+			close(probed)
+		}
+		if probes%2 == 0 {
+			return nil
+		}
+		return errors.New("connection refused")
+	})()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+
+	probe := &livenessProbe{interval: time.Millisecond, gracePeriod: time.Millisecond, failures: 2}
+	stop := probe.watch(mockDocker, func() bool {
+		return false
+	})
+	<-probed
+	assert.False(t, stop(), "expected an Agent that answers every other probe to be left running")
+}
+
+func TestNilLivenessProbe(t *testing.T) {
+	var probe *livenessProbe
+	assert.False(t, probe.watch(nil, nil)())
+}
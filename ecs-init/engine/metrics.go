@@ -0,0 +1,58 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"strings"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+	"github.com/aws/amazon-ecs-init/ecs-init/metrics"
+
+	log "github.com/cihub/seelog"
+)
+
+// recordHostSetup records a host setup step of pre-start or post-stop in the
+// event journal and counts it in the metrics if it failed. The step is named
+// after the event type and its setting or rule and action, such as
+// sysctl:route_localnet:enable.
+func recordHostSetup(eventType string, fields journal.Fields, err error) {
+	journal.Record(eventType, "", fields, err)
+	if err == nil {
+		return
+	}
+	target := fields["setting"]
+	if target == "" {
+		target = fields["rule"]
+	}
+	metrics.HostSetupFailed(strings.Join([]string{eventType, target, fields["action"]}, ":"))
+}
+
+// serveMetrics serves the metrics on the configured listen address while the
+// Agent is supervised. Failing to serve the metrics doesn't prevent the
+// Agent from being supervised. The returned function stops serving them.
+func (e *Engine) serveMetrics() func() {
+	registry := metrics.Default()
+	if e.metricsAddress == "" || registry == nil {
+		return func() {}
+	}
+	server, err := registry.Listen(e.metricsAddress)
+	if err != nil {
+		log.Errorf("Could not serve metrics: %v", err)
+		return func() {}
+	}
+	log.Infof("Serving metrics on http://%s/metrics", server.Addr())
+	return func() {
+		server.Close()
+	}
+}
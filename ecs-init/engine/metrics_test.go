@@ -0,0 +1,88 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/metrics"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricsMock makes a registry in dir the default metrics registry. The
+// backup can be restored by executing the returned function in a deferred
+// manner.
+func metricsMock(dir string) (*metrics.Registry, func()) {
+	registry := metrics.New(filepath.Join(dir, "metrics.json"), "")
+	metrics.SetDefault(registry)
+	return registry, func() {
+		metrics.SetDefault(nil)
+	}
+}
+
+func TestPostStopCountsHostSetupFailures(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir, err := ioutil.TempDir("", "metrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	registry, restore := metricsMock(dir)
+	defer restore()
+
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockLoopbackRouting.EXPECT().RestoreDefault().Return(fmt.Errorf("cannot restore"))
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+	mockRoute.EXPECT().Remove().Return(nil)
+
+	engine := &Engine{
+		config:                config.Defaults(),
+		loopbackRouting:       mockLoopbackRouting,
+		credentialsProxyRoute: mockRoute,
+	}
+	assert.Error(t, engine.PostStop())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, registry.Write(buf))
+	assert.Contains(t, buf.String(), `ecs_init_host_setup_failures_total{step="sysctl:route_localnet:restore-default"} 1`)
+	assert.NotContains(t, buf.String(), "credentials-proxy")
+}
+
+func TestServeMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, restore := metricsMock(dir)
+	defer restore()
+	metrics.AgentExited(1)
+
+	engine := &Engine{config: config.Defaults(), metricsAddress: "127.0.0.1:0"}
+	stop := engine.serveMetrics()
+	stop()
+
+	// Serving metrics on an address that isn't loopback is refused, without
+	// failing the supervision of the Agent
+	engine = &Engine{config: config.Defaults(), metricsAddress: "0.0.0.0:0"}
+	stop = engine.serveMetrics()
+	stop()
+}
@@ -0,0 +1,165 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/sdnotify"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// Injection points for testing purposes
+var (
+	sdNotify              = sdnotify.Notify
+	sdNotifyEnabled       = sdnotify.Enabled
+	sdWatchdogInterval    = sdnotify.WatchdogInterval
+	readinessPollInterval = time.Second
+)
+
+// notify sends notifications to systemd when ecs-init runs in a unit of
+// Type=notify
+func notify(notifications ...string) {
+	err := sdNotify(notifications...)
+	if err != nil {
+		log.Warnf("Could not notify systemd: %v", err)
+	}
+}
+
+// notifyStatus sends a status update to systemd, shown by systemctl status
+func notifyStatus(format string, args ...interface{}) {
+	notify(sdnotify.Status(fmt.Sprintf(format, args...)))
+}
+
+// watchReadiness notifies systemd that ecs-init is ready once the Agent
+// container is running, and if configured, once the Agent answers on its
+// introspection endpoint. Systemd is notified once for the lifetime of
+// ecs-init. The returned function stops watching.
+func (e *Engine) watchReadiness(docker dockerClient) func() {
+	if e.notifiedReady || !sdNotifyEnabled() {
+		return func() {}
+	}
+	done := make(chan struct{})
+	ready := make(chan bool, 1)
+	go func() {
+		ready <- waitAgentReady(docker, e.config.NotifyReadyOnIntrospection, done)
+	}()
+	return func() {
+		close(done)
+		if <-ready {
+			e.notifiedReady = true
+		}
+	}
+}
+
+func waitAgentReady(docker dockerClient, introspection bool, done <-chan struct{}) bool {
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+	running := false
+	for {
+		select {
+		case <-done:
+			return false
+		case <-ticker.C:
+		}
+		if !running {
+			state, err := docker.GetAgentContainerState()
+			running = err == nil && state != nil && state.Running
+		}
+		if running && (!introspection || probeAgentIntrospection() == nil) {
+			log.Info("Amazon Elastic Container Service Agent is running, notifying systemd")
+			notify(sdnotify.Ready, sdnotify.Status("Amazon Elastic Container Service Agent is running"))
+			return true
+		}
+	}
+}
+
+// supervisorWatchdog pings the systemd watchdog while the supervision loop
+// is healthy, that is while Docker answers and the loop is either waiting on
+// the Agent or backing off, or hasn't been busy with anything else for longer
+// than the watchdog interval
+type supervisorWatchdog struct {
+	interval time.Duration
+	// busySince is the time in nanoseconds at which the supervision loop
+	// stopped waiting, or 0 while it waits
+	busySince int64
+}
+
+// startWatchdog pings the systemd watchdog if it's enabled for ecs-init.
+// The returned function stops pinging.
+func (e *Engine) startWatchdog(docker dockerClient) func() {
+	interval, err := sdWatchdogInterval()
+	if err != nil {
+		log.Warnf("Not pinging the systemd watchdog: %v", err)
+		return func() {}
+	}
+	if interval == 0 {
+		return func() {}
+	}
+	e.watchdog = &supervisorWatchdog{interval: interval}
+	e.watchdog.busy()
+	done := make(chan struct{})
+	go e.watchdog.run(docker, done)
+	return func() {
+		close(done)
+	}
+}
+
+func (w *supervisorWatchdog) run(docker dockerClient, done <-chan struct{}) {
+	ticker := time.NewTicker(w.interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		err := w.check(docker)
+		if err != nil {
+			log.Warnf("Not pinging the systemd watchdog: %v", err)
+			continue
+		}
+		notify(sdnotify.Watchdog)
+	}
+}
+
+func (w *supervisorWatchdog) check(docker dockerClient) error {
+	if since := atomic.LoadInt64(&w.busySince); since != 0 {
+		busy := time.Since(time.Unix(0, since))
+		if busy > w.interval {
+			return errors.Errorf("supervision loop has been busy for %s", busy)
+		}
+	}
+	return errors.Wrap(docker.Ping(), "docker is not answering")
+}
+
+// wait marks the supervision loop as waiting on the Agent or a backoff
+func (w *supervisorWatchdog) wait() {
+	if w == nil {
+		return
+	}
+	atomic.StoreInt64(&w.busySince, 0)
+}
+
+// busy marks the supervision loop as busy with anything but waiting
+func (w *supervisorWatchdog) busy() {
+	if w == nil {
+		return
+	}
+	atomic.StoreInt64(&w.busySince, time.Now().UnixNano())
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/docker"
+	"github.com/aws/amazon-ecs-init/ecs-init/sdnotify"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// sdNotifyMock records the notifications sent to systemd. The backup can be
+// restored by executing the returned function in a deferred manner.
+func sdNotifyMock(notifications *[]string, lock *sync.Mutex) func() {
+	sdNotifyBkp := sdNotify
+	sdNotifyEnabledBkp := sdNotifyEnabled
+	readinessPollIntervalBkp := readinessPollInterval
+	sdNotify = func(n ...string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		*notifications = append(*notifications, n...)
+		return nil
+	}
+	sdNotifyEnabled = func() bool {
+		return true
+	}
+	readinessPollInterval = time.Millisecond
+	return func() {
+		sdNotify = sdNotifyBkp
+		sdNotifyEnabled = sdNotifyEnabledBkp
+		readinessPollInterval = readinessPollIntervalBkp
+	}
+}
+
+func TestStartSupervisedNotifiesReadyOnce(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var notifications []string
+	var lock sync.Mutex
+	defer sdNotifyMock(&notifications, &lock)()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	agentReady := make(chan struct{})
+	mockDocker.EXPECT().GetAgentContainerState().Return(&docker.AgentContainerState{Running: true}, nil).Do(func() {
+		close(agentReady)
+	})
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			<-agentReady
+			return 1, nil
+		}),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
+	)
+
+	engine := &Engine{config: config.Defaults()}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+	assert.True(t, engine.notifiedReady)
+	lock.Lock()
+	defer lock.Unlock()
+	if assert.Len(t, notifications, 5) {
+		assert.Equal(t, []string{
+			sdnotify.Status("Starting Amazon Elastic Container Service Agent"),
+			sdnotify.Ready,
+			sdnotify.Status("Amazon Elastic Container Service Agent is running"),
+		}, notifications[:3])
+		assert.True(t, strings.HasPrefix(notifications[3], sdnotify.Status("Agent exited with code 1, restarting in ")))
+		assert.Equal(t, sdnotify.Status("Starting Amazon Elastic Container Service Agent"), notifications[4])
+	}
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	gomock.InOrder(
+		mockDocker.EXPECT().Ping().Return(nil),
+		mockDocker.EXPECT().Ping().Return(errors.New("test error")),
+	)
+
+	watchdog := &supervisorWatchdog{interval: time.Minute}
+	watchdog.busy()
+	assert.NoError(t, watchdog.check(mockDocker), "expected a briefly busy loop to be healthy")
+	assert.Error(t, watchdog.check(mockDocker), "expected an unresponsive docker to be unhealthy")
+
+	watchdog.busySince = time.Now().Add(-2 * time.Minute).UnixNano()
+	assert.Error(t, watchdog.check(mockDocker), "expected a loop busy for longer than the interval to be unhealthy")
+
+	var nilWatchdog *supervisorWatchdog
+	nilWatchdog.wait()
+	nilWatchdog.busy()
+}
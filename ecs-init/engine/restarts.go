@@ -0,0 +1,183 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/backoff"
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
+
+	log "github.com/cihub/seelog"
+)
+
+const (
+	// maxRestartRecords bounds the size of the restart history file
+	maxRestartRecords  = 100
+	restartHistoryPerm = 0644
+)
+
+// restartRecord is a single run of the Agent container
+type restartRecord struct {
+	ExitCode  int       `json:"exitCode"`
+	StartedAt time.Time `json:"startedAt"`
+	ExitedAt  time.Time `json:"exitedAt"`
+	// Unresponsive is set when the Agent was stopped by the liveness probe
+	Unresponsive bool `json:"unresponsive,omitempty"`
+	// Requested is set when the Agent was stopped through the control
+	// socket or while its supervision was paused
+	Requested bool `json:"requested,omitempty"`
+}
+
+// restartHistory is the exit history of the Agent container. It is persisted
+// so that crash loops are detected across restarts of ecs-init.
+type restartHistory struct {
+	Restarts []restartRecord `json:"restarts"`
+	// ResetAt is the last time the Agent ran for longer than the healthy
+	// uptime or the circuit breaker tripped. Failures before it are not
+	// counted.
+	ResetAt time.Time `json:"resetAt"`
+	// Probation is the probation of an upgraded Agent in progress, so that
+	// it survives restarts of ecs-init
+	Probation *probationRecord `json:"probation,omitempty"`
+}
+
+// probationRecord is the persisted state of an upgradeProbation
+type probationRecord struct {
+	Until         time.Time `json:"until"`
+	Introspection bool      `json:"introspection,omitempty"`
+	Correlation   string    `json:"correlation,omitempty"`
+}
+
+// loadRestartHistory reads the restart history from path. A missing or
+// unreadable history is treated as empty, as is an empty path, which
+// disables persistence altogether.
+func loadRestartHistory(path string) *restartHistory {
+	history := &restartHistory{}
+	if path == "" {
+		return history
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Could not read agent restart history: %v", err)
+		}
+		return history
+	}
+	err = json.Unmarshal(data, history)
+	if err != nil {
+		log.Warnf("Could not parse agent restart history, starting afresh: %v", err)
+		return &restartHistory{}
+	}
+	return history
+}
+
+func (h *restartHistory) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, restartHistoryPerm)
+}
+
+// record appends a run of the Agent to the history, dropping the oldest
+// records beyond maxRestartRecords
+func (h *restartHistory) record(run restartRecord) {
+	h.Restarts = append(h.Restarts, run)
+	if len(h.Restarts) > maxRestartRecords {
+		h.Restarts = h.Restarts[len(h.Restarts)-maxRestartRecords:]
+	}
+}
+
+// failuresSince counts the Agent failures that happened after both since and
+// the last reset, which are the exits the policy restarts with a backoff
+func (h *restartHistory) failuresSince(since time.Time, policy *exitpolicy.Policy) int {
+	if h.ResetAt.After(since) {
+		since = h.ResetAt
+	}
+	failures := 0
+	for _, run := range h.Restarts {
+		if run.ExitedAt.After(since) && !run.Requested && isAgentFailure(run, policy) {
+			failures++
+		}
+	}
+	return failures
+}
+
+// isAgentFailure returns true if the exit of a run of the Agent is a failure
+// according to the exit code policy. Runs stopped by the liveness probe are
+// restarted with a backoff whatever their exit code.
+func isAgentFailure(run restartRecord, policy *exitpolicy.Policy) bool {
+	if run.Unresponsive {
+		return true
+	}
+	return policy.Match(run.ExitCode).IsFailure()
+}
+
+// recordAgentRun persists a run of the Agent. The backoff decays back to its
+// minimum duration when the Agent ran for at least the healthy uptime.
+func (e *Engine) recordAgentRun(history *restartHistory, retryBackoff backoff.Backoff, run restartRecord) {
+	history.record(run)
+	if run.ExitedAt.Sub(run.StartedAt) >= e.config.RestartHealthyUptime {
+		history.ResetAt = run.ExitedAt
+		retryBackoff.Reset()
+	}
+	err := history.save(e.restartHistoryFile)
+	if err != nil {
+		log.Warnf("Could not save agent restart history: %v", err)
+	}
+}
+
+// resumeBackoff advances the backoff past the failures recorded by previous
+// runs of ecs-init, so that the retry delay isn't reset by ecs-init itself
+// being restarted
+func (e *Engine) resumeBackoff(history *restartHistory, retryBackoff backoff.Backoff) {
+	failures := history.failuresSince(time.Now().Add(-e.config.RestartFailureWindow), e.supervisor.exitPolicy())
+	for i := 0; i < failures; i++ {
+		retryBackoff.Duration()
+	}
+}
+
+// checkCrashLoop trips the circuit breaker when the Agent failed too many
+// times within the failure window. The tail of the Agent logs is captured
+// before giving up on the Agent.
+func (e *Engine) checkCrashLoop(docker dockerClient, history *restartHistory) error {
+	maxFailures := e.config.RestartMaxFailures
+	if maxFailures == 0 {
+		return nil
+	}
+	window := e.config.RestartFailureWindow
+	failures := history.failuresSince(time.Now().Add(-window), e.supervisor.exitPolicy())
+	if failures < maxFailures {
+		return nil
+	}
+	log.Errorf("Agent failed %d times within %s, no longer restarting it", failures, window)
+	logAgentContainerTail(docker)
+	history.ResetAt = time.Now()
+	err := history.save(e.restartHistoryFile)
+	if err != nil {
+		log.Warnf("Could not save agent restart history: %v", err)
+	}
+	return &TerminalError{
+		err:      fmt.Sprintf("agent is crash looping, %d failures within %s", failures, window),
+		exitCode: CrashLoopExitCode,
+	}
+}
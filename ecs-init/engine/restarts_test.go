@@ -0,0 +1,163 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/docker"
+	"github.com/aws/amazon-ecs-init/ecs-init/exitpolicy"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func restartHistoryFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "restart-history")
+	require.NoError(t, err)
+	return filepath.Join(dir, "restart-history.json"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestStartSupervisedTripsCircuitBreaker(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	cfg := config.Defaults()
+	cfg.RestartMaxFailures = 2
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(1, nil),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(1, nil),
+		mockDocker.EXPECT().GetContainerLogTail(gomock.Any()),
+	)
+
+	engine := &Engine{config: cfg, restartHistoryFile: historyFile}
+	err := engine.StartSupervised()
+	terminalErr, ok := err.(*TerminalError)
+	require.True(t, ok, "Expected error to be of type TerminalError")
+	assert.Equal(t, CrashLoopExitCode, terminalErr.ExitCode())
+
+	history := loadRestartHistory(historyFile)
+	assert.Len(t, history.Restarts, 2)
+	assert.False(t, history.ResetAt.IsZero(), "Expected the failure count to be reset once tripped")
+}
+
+func TestStartSupervisedCountsFailuresAcrossRestarts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	cfg := config.Defaults()
+	cfg.RestartMaxFailures = 2
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+
+	// a failure recorded by a previous run of ecs-init
+	previous := &restartHistory{}
+	previous.record(restartRecord{
+		ExitCode:  1,
+		StartedAt: time.Now().Add(-time.Minute),
+		ExitedAt:  time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, previous.save(historyFile))
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(containerFailureAgentExitCode, nil),
+		mockDocker.EXPECT().GetContainerLogTail(gomock.Any()).Times(2),
+	)
+
+	engine := &Engine{config: cfg, restartHistoryFile: historyFile}
+	err := engine.StartSupervised()
+	_, ok := err.(*TerminalError)
+	assert.True(t, ok, "Expected error to be of type TerminalError")
+}
+
+func TestRecordAgentRunResetsBackoffAfterHealthyUptime(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockBackoff := docker.NewMockBackoff(mockCtrl)
+	mockBackoff.EXPECT().Reset()
+
+	exitedAt := time.Now()
+	history := &restartHistory{}
+	engine := &Engine{config: config.Defaults()}
+	engine.recordAgentRun(history, mockBackoff, restartRecord{
+		ExitCode:  1,
+		StartedAt: exitedAt.Add(-time.Hour),
+		ExitedAt:  exitedAt,
+	})
+	// a short run doesn't reset the backoff
+	engine.recordAgentRun(history, mockBackoff, restartRecord{
+		ExitCode:  1,
+		StartedAt: exitedAt,
+		ExitedAt:  exitedAt.Add(time.Second),
+	})
+	assert.Equal(t, exitedAt, history.ResetAt)
+	assert.Equal(t, 1, history.failuresSince(exitedAt.Add(-time.Hour), exitpolicy.Default()))
+}
+
+func TestRestartHistoryFailuresSince(t *testing.T) {
+	now := time.Now()
+	history := &restartHistory{}
+	for i, exitCode := range []int{1, terminalSuccessAgentExitCode, upgradeAgentExitCode, 2, 1} {
+		exitedAt := now.Add(time.Duration(i-5) * time.Minute)
+		history.record(restartRecord{ExitCode: exitCode, StartedAt: exitedAt, ExitedAt: exitedAt})
+	}
+	assert.Equal(t, 3, history.failuresSince(now.Add(-time.Hour), exitpolicy.Default()))
+	assert.Equal(t, 2, history.failuresSince(now.Add(-3*time.Minute), exitpolicy.Default()))
+
+	history.ResetAt = now.Add(-90 * time.Second)
+	assert.Equal(t, 1, history.failuresSince(now.Add(-time.Hour), exitpolicy.Default()))
+}
+
+func TestRestartHistoryFailuresFollowExitPolicy(t *testing.T) {
+	now := time.Now()
+	history := &restartHistory{}
+	for _, exitCode := range []int{3, 3, 1} {
+		history.record(restartRecord{ExitCode: exitCode, StartedAt: now, ExitedAt: now})
+	}
+	history.record(restartRecord{ExitCode: 3, StartedAt: now, ExitedAt: now, Unresponsive: true})
+	policyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(policyFile, []byte(`{"rules": [{"exitCodes": ["3"], "actions": ["restart"]}]}`), 0644))
+	policy, err := exitpolicy.Load(policyFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, history.failuresSince(now.Add(-time.Hour), exitpolicy.Default()))
+	assert.Equal(t, 2, history.failuresSince(now.Add(-time.Hour), policy),
+		"expected the exit codes restarted right away not to count as failures")
+}
+
+func TestRestartHistoryIsBounded(t *testing.T) {
+	history := &restartHistory{}
+	for i := 0; i < maxRestartRecords+10; i++ {
+		history.record(restartRecord{ExitCode: i})
+	}
+	require.Len(t, history.Restarts, maxRestartRecords)
+	assert.Equal(t, 10, history.Restarts[0].ExitCode)
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
+
+	"github.com/pkg/errors"
+)
+
+// Injection point for testing purposes
+var configSources = config.Sources
+
+// ShowConfigOptions select how ShowConfig prints the configuration
+type ShowConfigOptions struct {
+	// Origin prints the file and line each variable comes from
+	Origin bool
+	// Output is either StatusOutputText or StatusOutputJSON
+	Output string
+}
+
+// ShowConfig prints the effective variables of the configuration files and
+// the ecs-init settings of the environment, with the values of secrets
+// redacted
+func ShowConfig(options *ShowConfigOptions) error {
+	if options.Output != StatusOutputText && options.Output != StatusOutputJSON {
+		return errors.Errorf("unsupported output format %q", options.Output)
+	}
+	sources, err := configSources()
+	if err != nil {
+		return engineError("could not read the configuration", err)
+	}
+	origins := config.Origins(sources)
+	for _, origin := range origins {
+		origin.Value = redact.Value(origin.Name, origin.Value)
+	}
+	err = writeShowConfigReport(reportOutput, origins, options)
+	if err != nil {
+		return engineError("could not write the configuration", err)
+	}
+	return nil
+}
+
+func writeShowConfigReport(w io.Writer, origins []*config.Origin, options *ShowConfigOptions) error {
+	if options.Output == StatusOutputJSON {
+		if !options.Origin {
+			for _, origin := range origins {
+				origin.Source, origin.Line = "", 0
+			}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(origins)
+	}
+	for _, origin := range origins {
+		var err error
+		if options.Origin {
+			_, err = fmt.Fprintf(w, "%s: %s=%s\n", origin, origin.Name, origin.Value)
+		} else {
+			_, err = fmt.Fprintf(w, "%s=%s\n", origin.Name, origin.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configSourcesMock backs up configSources and replaces it with one returning
+// sources, returning a function that restores it
+func configSourcesMock(sources ...*config.Source) func() {
+	configSourcesBkp := configSources
+	configSources = func() ([]*config.Source, error) {
+		return sources, nil
+	}
+	return func() {
+		configSources = configSourcesBkp
+	}
+}
+
+func TestShowConfigOrigin(t *testing.T) {
+	defer configSourcesMock(
+		config.FileSource("/etc/ecs/ecs.config", []byte("ECS_CLUSTER=a\nAWS_SECRET_ACCESS_KEY=secret\n")),
+		config.FileSource("/etc/ecs/ecs.config.d/10-cluster.conf", []byte("\nECS_CLUSTER=b\n")),
+	)()
+	var output bytes.Buffer
+	defer statusMocks(&output, nil)()
+
+	err := ShowConfig(&ShowConfigOptions{Origin: true, Output: StatusOutputText})
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/ecs/ecs.config:2: AWS_SECRET_ACCESS_KEY=REDACTED\n"+
+		"/etc/ecs/ecs.config.d/10-cluster.conf:2: ECS_CLUSTER=b\n", output.String())
+}
+
+func TestShowConfigJSON(t *testing.T) {
+	defer configSourcesMock(config.FileSource("/etc/ecs/ecs.config", []byte("ECS_CLUSTER=a\n")))()
+	var output bytes.Buffer
+	defer statusMocks(&output, nil)()
+
+	err := ShowConfig(&ShowConfigOptions{Output: StatusOutputJSON})
+	require.NoError(t, err)
+	var origins []*config.Origin
+	require.NoError(t, json.Unmarshal(output.Bytes(), &origins))
+	assert.Equal(t, []*config.Origin{{Name: "ECS_CLUSTER", Value: "a"}}, origins)
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/sdnotify"
+
+	log "github.com/cihub/seelog"
+)
+
+// Injection point for testing purposes
+var notifyStopSignals = func(signals chan<- os.Signal) func() {
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	return func() {
+		signal.Stop(signals)
+	}
+}
+
+// agentStopRetryInterval is how often the Agent is stopped again after a stop
+// signal, until it exits
+var agentStopRetryInterval = time.Second
+
+// handleStopSignals stops the Agent when ecs-init receives SIGTERM or SIGINT,
+// so that the init system doesn't have to run the stop action. The returned
+// channel is closed as soon as a stop signal is received, and the returned
+// function stops handling signals.
+func (e *Engine) handleStopSignals() (<-chan struct{}, func()) {
+	signals := make(chan os.Signal, 1)
+	stopNotify := notifyStopSignals(signals)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Infof("Received %s, stopping Amazon Elastic Container Service Agent", sig)
+			notify(sdnotify.Stopping, sdnotify.Status("Stopping Amazon Elastic Container Service Agent"))
+			close(stop)
+			err := e.PreStop()
+			if err != nil {
+				log.Errorf("could not stop agent on %s: %v", sig, err)
+			}
+		case <-done:
+		}
+	}()
+	return stop, func() {
+		stopNotify()
+		close(done)
+	}
+}
+
+// stopAgentAfterSignal keeps stopping the Agent once a stop signal is
+// received, until the returned function is called after the Agent exited. A
+// signal received while the Agent container is being created finds nothing
+// to stop, so the Agent is stopped again once its container exists.
+func stopAgentAfterSignal(docker dockerClient, stop <-chan struct{}) func() {
+	exited := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-stop:
+		case <-exited:
+			return
+		}
+		for {
+			select {
+			case <-time.After(agentStopRetryInterval):
+			case <-exited:
+				return
+			}
+			err := docker.StopAgent()
+			if err != nil {
+				log.Warnf("Could not stop Amazon Elastic Container Service Agent: %v", err)
+			}
+		}
+	}()
+	return func() {
+		close(exited)
+		<-done
+	}
+}
+
+func stopRequested(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitBeforeRestart waits for the backoff duration before the Agent is
+// restarted, or until a restart is requested. It returns false if a stop
+// signal was received in the meantime.
+func waitBeforeRestart(d time.Duration, stop, restart <-chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-restart:
+		return true
+	case <-stop:
+		log.Info("Not restarting Amazon Elastic Container Service Agent, ecs-init is stopping")
+		return false
+	}
+}
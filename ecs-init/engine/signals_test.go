@@ -0,0 +1,164 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// notifyStopSignalsMock replaces signal notification with a channel that
+// the test sends signals on. The backup can be restored by executing the
+// returned function in a deferred manner.
+func notifyStopSignalsMock(signals chan os.Signal) func() {
+	notifyStopSignalsBkp := notifyStopSignals
+	notifyStopSignals = func(c chan<- os.Signal) func() {
+		go func() {
+			for sig := range signals {
+				c <- sig
+			}
+		}()
+		return func() {}
+	}
+	return func() {
+		notifyStopSignals = notifyStopSignalsBkp
+	}
+}
+
+func TestStartSupervisedStopsAgentOnSignal(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	signals := make(chan os.Signal)
+	defer close(signals)
+	defer notifyStopSignalsMock(signals)()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	agentStopped := make(chan struct{})
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			signals <- syscall.SIGTERM
+			<-agentStopped
+			return 143, nil
+		}),
+	)
+	mockDocker.EXPECT().StopAgent().Do(func() {
+		close(agentStopped)
+	})
+	mockDocker.EXPECT().GetContainerLogTail(gomock.Any())
+
+	engine := &Engine{config: config.Defaults()}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+}
+
+func TestStartSupervisedStopsAgentCreatedAfterSignal(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	signals := make(chan os.Signal)
+	defer close(signals)
+	defer notifyStopSignalsMock(signals)()
+	agentStopRetryIntervalBkp := agentStopRetryInterval
+	agentStopRetryInterval = time.Millisecond
+	defer func() {
+		agentStopRetryInterval = agentStopRetryIntervalBkp
+	}()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	var lock sync.Mutex
+	containerCreated := false
+	firstStop := make(chan struct{})
+	agentStopped := make(chan struct{})
+	var stopAgent sync.Once
+	stops := 0
+	mockDocker.EXPECT().StopAgent().DoAndReturn(func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		stops++
+		if stops == 1 {
+			close(firstStop)
+		}
+		if containerCreated {
+			stopAgent.Do(func() { close(agentStopped) })
+		}
+		return nil
+	}).MinTimes(2)
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			// the signal is received before the container is created, so
+			// the first stop finds nothing to stop
+			signals <- syscall.SIGTERM
+			<-firstStop
+			lock.Lock()
+			containerCreated = true
+			lock.Unlock()
+			<-agentStopped
+			return 143, nil
+		}),
+	)
+	mockDocker.EXPECT().GetContainerLogTail(gomock.Any())
+
+	engine := &Engine{config: config.Defaults()}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+}
+
+func TestStartSupervisedDoesNotStartAgentAfterSignal(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	signals := make(chan os.Signal)
+	defer close(signals)
+	defer notifyStopSignalsMock(signals)()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	stopped := make(chan struct{})
+	mockDocker.EXPECT().RemoveExistingAgentContainer().DoAndReturn(func() error {
+		signals <- syscall.SIGTERM
+		<-stopped
+		return nil
+	})
+	mockDocker.EXPECT().StopAgent().Do(func() {
+		close(stopped)
+	})
+
+	engine := &Engine{config: config.Defaults()}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+}
+
+func TestWaitBeforeRestart(t *testing.T) {
+	stop := make(chan struct{})
+	assert.True(t, waitBeforeRestart(time.Millisecond, stop, nil))
+	close(stop)
+	assert.False(t, waitBeforeRestart(time.Hour, stop, nil))
+
+	restart := make(chan struct{}, 1)
+	restart <- struct{}{}
+	assert.True(t, waitBeforeRestart(time.Hour, nil, restart))
+}
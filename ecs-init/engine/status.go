@@ -0,0 +1,355 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/cache"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
+	"github.com/aws/amazon-ecs-init/ecs-init/volumes"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// StatusOutputText prints the status report in a human readable form
+	StatusOutputText = "text"
+	// StatusOutputJSON prints the status report as a JSON document
+	StatusOutputJSON = "json"
+
+	// statusRestartRecords is the number of recent Agent runs of the restart
+	// history in the status report
+	statusRestartRecords = 5
+)
+
+// Injection points for testing purposes
+var (
+	reportOutput   io.Writer = os.Stdout
+	readStatusFile           = ioutil.ReadFile
+)
+
+// ComponentStatus describes the health of a single part of the node's ECS
+// stack
+type ComponentStatus struct {
+	Name    string            `json:"name"`
+	Healthy bool              `json:"healthy"`
+	Summary string            `json:"summary"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// AgentRestart is a recent run of the ECS Agent container recorded in the
+// restart history
+type AgentRestart struct {
+	ExitedAt time.Time `json:"exitedAt"`
+	ExitCode int       `json:"exitCode"`
+	// Reason is either requested, unresponsive, failure or exit, for the runs
+	// stopped on request, stopped by the liveness probe, that failed
+	// according to the exit code policy, or that exited otherwise
+	Reason string `json:"reason"`
+}
+
+// NodeStatus is the report produced by the status action. The node is
+// healthy only if all of its components are healthy.
+type NodeStatus struct {
+	Healthy    bool              `json:"healthy"`
+	Components []ComponentStatus `json:"components"`
+	// Restarts are the most recent runs of the Agent, newest first
+	Restarts []AgentRestart `json:"restarts,omitempty"`
+}
+
+// Status reports the health of the ECS Agent container, the agent cache,
+// the host networking setup, the GPU info file and the volume plugin state
+// in the requested output format. An error is returned if any of them is
+// degraded so that the exit code can be used by node health checks.
+func (e *Engine) Status(output string) error {
+	if output != StatusOutputText && output != StatusOutputJSON {
+		return errors.Errorf("unsupported output format %q", output)
+	}
+	status := e.nodeStatus()
+	err := writeStatus(reportOutput, status, output)
+	if err != nil {
+		return engineError("could not write status", err)
+	}
+	if !status.Healthy {
+		return errors.New("one or more components of the ECS stack are degraded")
+	}
+	return nil
+}
+
+func (e *Engine) nodeStatus() *NodeStatus {
+	docker, dockerErr := getDockerClient(e.config)
+	var envVariables map[string]string
+	if dockerErr == nil {
+		envVariables = docker.LoadEnvVars()
+	}
+	components := []ComponentStatus{
+		agentContainerStatus(docker, dockerErr),
+		e.agentCacheStatus(docker, dockerErr),
+		e.credentialsProxyRouteStatus(),
+		e.loopbackRoutingStatus(),
+		gpuStatus(envVariables),
+		volumePluginStatus(),
+	}
+	status := &NodeStatus{
+		Healthy:    true,
+		Components: components,
+		Restarts:   e.recentRestarts(),
+	}
+	for _, component := range components {
+		if !component.Healthy {
+			status.Healthy = false
+		}
+	}
+	return status
+}
+
+func agentContainerStatus(docker dockerClient, dockerErr error) ComponentStatus {
+	component := ComponentStatus{Name: "agent"}
+	if dockerErr != nil {
+		component.Summary = fmt.Sprintf("could not connect to docker: %v", dockerErr)
+		return component
+	}
+	state, err := docker.GetAgentContainerState()
+	if err != nil {
+		component.Summary = fmt.Sprintf("could not inspect agent container: %v", err)
+		return component
+	}
+	if state == nil {
+		component.Summary = "agent container not found"
+		return component
+	}
+	component.Details = map[string]string{
+		"id":           state.ID,
+		"image":        state.Image,
+		"status":       state.Status,
+		"startedAt":    formatStatusTime(state.StartedAt),
+		"finishedAt":   formatStatusTime(state.FinishedAt),
+		"lastExitCode": strconv.Itoa(state.ExitCode),
+		"oomKilled":    strconv.FormatBool(state.OOMKilled),
+		"restartCount": strconv.Itoa(state.RestartCount),
+	}
+	if state.Error != "" {
+		component.Details["error"] = state.Error
+	}
+	if !state.Running {
+		component.Summary = fmt.Sprintf("agent container is %s, last exit code %d", state.Status, state.ExitCode)
+		return component
+	}
+	component.Healthy = true
+	component.Summary = fmt.Sprintf("agent container running since %s", formatStatusTime(state.StartedAt))
+	return component
+}
+
+// recentRestarts returns the most recent runs of the Agent recorded in the
+// restart history, newest first
+func (e *Engine) recentRestarts() []AgentRestart {
+	history := loadRestartHistory(e.restartHistoryFile)
+	policy := loadExitPolicy(e.exitPolicyFile)
+	var restarts []AgentRestart
+	for i := len(history.Restarts) - 1; i >= 0 && len(restarts) < statusRestartRecords; i-- {
+		run := history.Restarts[i]
+		reason := "exit"
+		switch {
+		case run.Requested:
+			reason = "requested"
+		case run.Unresponsive:
+			reason = "unresponsive"
+		case isAgentFailure(run, policy):
+			reason = "failure"
+		}
+		restarts = append(restarts, AgentRestart{
+			ExitedAt: run.ExitedAt,
+			ExitCode: run.ExitCode,
+			Reason:   reason,
+		})
+	}
+	return restarts
+}
+
+func (e *Engine) agentCacheStatus(docker dockerClient, dockerErr error) ComponentStatus {
+	component := ComponentStatus{Name: "cache"}
+	cacheStatus := e.downloader.AgentCacheStatus()
+	component.Details = map[string]string{
+		"status":  cacheStatusName(cacheStatus),
+		"version": e.config.AgentVersion,
+		"tarball": config.AgentVersionTarball(e.config.AgentVersion),
+	}
+	rollback, err := e.downloader.LastRollback()
+	if err == nil && rollback != nil {
+		component.Details["lastRollback"] = fmt.Sprintf("%s %s", formatStatusTime(rollback.Time), rollback.Reason)
+	}
+	imageLoaded := false
+	if dockerErr == nil {
+		loaded, err := docker.IsAgentImageLoaded()
+		if err == nil {
+			imageLoaded = loaded
+			component.Details["imageLoaded"] = strconv.FormatBool(loaded)
+		}
+	}
+	switch {
+	case cacheStatus != cache.StatusUncached:
+		component.Healthy = true
+		component.Summary = "agent image is cached"
+	case imageLoaded:
+		component.Healthy = true
+		component.Summary = "agent image is not cached, but is loaded in docker"
+	default:
+		component.Summary = "agent image is neither cached nor loaded in docker"
+	}
+	return component
+}
+
+func (e *Engine) credentialsProxyRouteStatus() ComponentStatus {
+	component := ComponentStatus{Name: "iptables"}
+	err := e.credentialsProxyRoute.Check()
+	if err != nil {
+		component.Summary = err.Error()
+		return component
+	}
+	component.Healthy = true
+	component.Summary = "credentials proxy route is in place"
+	return component
+}
+
+func (e *Engine) loopbackRoutingStatus() ComponentStatus {
+	component := ComponentStatus{Name: "sysctl"}
+	enabled, err := e.loopbackRouting.IsEnabled()
+	if err != nil {
+		component.Summary = fmt.Sprintf("could not read route_localnet: %v", err)
+		return component
+	}
+	if !enabled {
+		component.Summary = "route_localnet is disabled"
+		return component
+	}
+	component.Healthy = true
+	component.Summary = "route_localnet is enabled"
+	return component
+}
+
+func gpuStatus(envVariables map[string]string) ComponentStatus {
+	component := ComponentStatus{Name: "gpu", Healthy: true}
+	if envVariables[config.GPUSupportEnvVar] != "true" {
+		component.Summary = "GPU support is not enabled"
+		return component
+	}
+	data, err := readStatusFile(gpu.NvidiaGPUInfoFilePath)
+	if err != nil {
+		component.Healthy = false
+		component.Summary = fmt.Sprintf("could not read GPU info file: %v", err)
+		return component
+	}
+	var info gpu.NvidiaGPUManager
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		component.Healthy = false
+		component.Summary = fmt.Sprintf("could not parse GPU info file: %v", err)
+		return component
+	}
+	component.Details = map[string]string{
+		"driverVersion": info.DriverVersion,
+		"gpus":          strconv.Itoa(len(info.GPUIDs)),
+	}
+	component.Summary = fmt.Sprintf("%d GPUs with driver version %s", len(info.GPUIDs), info.DriverVersion)
+	return component
+}
+
+func volumePluginStatus() ComponentStatus {
+	component := ComponentStatus{Name: "volume-plugin", Healthy: true}
+	data, err := readStatusFile(volumes.PluginStateFileAbsPath)
+	if os.IsNotExist(err) {
+		component.Summary = "no volume plugin state"
+		return component
+	}
+	if err != nil {
+		component.Healthy = false
+		component.Summary = fmt.Sprintf("could not read volume plugin state file: %v", err)
+		return component
+	}
+	var state volumes.VolumeState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		component.Healthy = false
+		component.Summary = fmt.Sprintf("could not parse volume plugin state file: %v", err)
+		return component
+	}
+	component.Summary = fmt.Sprintf("%d volumes managed by the volume plugin", len(state.Volumes))
+	return component
+}
+
+func cacheStatusName(status cache.CacheStatus) string {
+	switch status {
+	case cache.StatusCached:
+		return "cached"
+	case cache.StatusReloadNeeded:
+		return "reload-needed"
+	default:
+		return "uncached"
+	}
+}
+
+func formatStatusTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeStatus(w io.Writer, status *NodeStatus, output string) error {
+	if output == StatusOutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+	overall := "HEALTHY"
+	if !status.Healthy {
+		overall = "DEGRADED"
+	}
+	_, err := fmt.Fprintf(w, "ECS node status: %s\n\n", overall)
+	if err != nil {
+		return err
+	}
+	for _, component := range status.Components {
+		health := "OK"
+		if !component.Healthy {
+			health = "DEGRADED"
+		}
+		_, err = fmt.Fprintf(w, "  %-15s %-10s %s\n", component.Name, health, component.Summary)
+		if err != nil {
+			return err
+		}
+	}
+	if len(status.Restarts) == 0 {
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "\nRecent agent restarts:\n\n")
+	if err != nil {
+		return err
+	}
+	for _, restart := range status.Restarts {
+		_, err = fmt.Fprintf(w, "  %-25s exit code %-5d %s\n", formatStatusTime(restart.ExitedAt), restart.ExitCode, restart.Reason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
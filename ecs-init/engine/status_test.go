@@ -0,0 +1,178 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/cache"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/docker"
+	"github.com/aws/amazon-ecs-init/ecs-init/gpu"
+	"github.com/aws/amazon-ecs-init/ecs-init/volumes"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusMocks replaces the report output and state file reader with test
+// doubles. The backup can be restored by executing the returned function
+// in a deferred manner.
+func statusMocks(out *bytes.Buffer, files map[string]string) func() {
+	reportOutputBkp := reportOutput
+	readStatusFileBkp := readStatusFile
+	reportOutput = out
+	readStatusFile = func(filename string) ([]byte, error) {
+		data, ok := files[filename]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(data), nil
+	}
+	return func() {
+		reportOutput = reportOutputBkp
+		readStatusFile = readStatusFileBkp
+	}
+}
+
+func TestStatusHealthy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+	exitedAt := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	history := &restartHistory{}
+	for i := 0; i < statusRestartRecords; i++ {
+		history.record(restartRecord{ExitCode: 1, ExitedAt: exitedAt})
+	}
+	history.record(restartRecord{ExitCode: 1, ExitedAt: exitedAt.Add(time.Minute), Unresponsive: true})
+	history.record(restartRecord{ExitCode: terminalSuccessAgentExitCode, ExitedAt: exitedAt.Add(2 * time.Minute)})
+	history.record(restartRecord{ExitCode: 143, ExitedAt: exitedAt.Add(3 * time.Minute), Requested: true})
+	require.NoError(t, history.save(historyFile))
+
+	out := &bytes.Buffer{}
+	defer statusMocks(out, map[string]string{
+		gpu.NvidiaGPUInfoFilePath:      `{"DriverVersion":"396.44","GPUIDs":["gpu-0","gpu-1"]}`,
+		volumes.PluginStateFileAbsPath: `{"volumes":{"efsvol":{"type":"efs"}}}`,
+	})()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+
+	mockDocker.EXPECT().LoadEnvVars().Return(map[string]string{
+		"ECS_ENABLE_GPU_SUPPORT": "true",
+	})
+	mockDocker.EXPECT().GetAgentContainerState().Return(&docker.AgentContainerState{
+		ID:        "id",
+		Status:    "running",
+		Running:   true,
+		StartedAt: time.Now(),
+	}, nil)
+	mockDownloader.EXPECT().AgentCacheStatus().Return(cache.StatusCached)
+	mockDownloader.EXPECT().LastRollback().Return(&cache.Rollback{
+		Time:   time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Reason: "agent exited with code 1 within the upgrade probation period",
+	}, nil)
+	mockDocker.EXPECT().IsAgentImageLoaded().Return(true, nil)
+	mockRoute.EXPECT().Check().Return(nil)
+	mockLoopbackRouting.EXPECT().IsEnabled().Return(true, nil)
+
+	engine := &Engine{
+		config:                config.Defaults(),
+		downloader:            mockDownloader,
+		loopbackRouting:       mockLoopbackRouting,
+		credentialsProxyRoute: mockRoute,
+		restartHistoryFile:    historyFile,
+	}
+	err := engine.Status(StatusOutputJSON)
+	require.NoError(t, err)
+
+	var status NodeStatus
+	require.NoError(t, json.Unmarshal(out.Bytes(), &status))
+	assert.True(t, status.Healthy)
+	require.Len(t, status.Components, 6)
+	assert.Equal(t, "2020-01-01T00:00:00Z agent exited with code 1 within the upgrade probation period",
+		status.Components[1].Details["lastRollback"])
+	assert.Equal(t, "2", status.Components[4].Details["gpus"])
+	assert.Equal(t, "1 volumes managed by the volume plugin", status.Components[5].Summary)
+	require.Len(t, status.Restarts, statusRestartRecords)
+	assert.Equal(t, AgentRestart{ExitedAt: exitedAt.Add(3 * time.Minute), ExitCode: 143, Reason: "requested"}, status.Restarts[0])
+	assert.Equal(t, "exit", status.Restarts[1].Reason)
+	assert.Equal(t, "unresponsive", status.Restarts[2].Reason)
+	assert.Equal(t, AgentRestart{ExitedAt: exitedAt, ExitCode: 1, Reason: "failure"}, status.Restarts[3])
+}
+
+func TestStatusDegraded(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+	history := &restartHistory{}
+	history.record(restartRecord{ExitCode: 2, ExitedAt: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, history.save(historyFile))
+
+	out := &bytes.Buffer{}
+	defer statusMocks(out, map[string]string{})()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+	mockLoopbackRouting := NewMockloopbackRouting(mockCtrl)
+	mockRoute := NewMockcredentialsProxyRoute(mockCtrl)
+
+	mockDocker.EXPECT().LoadEnvVars().Return(nil)
+	mockDocker.EXPECT().GetAgentContainerState().Return(&docker.AgentContainerState{
+		ID:       "id",
+		Status:   "exited",
+		ExitCode: 2,
+	}, nil)
+	mockDownloader.EXPECT().AgentCacheStatus().Return(cache.StatusUncached)
+	mockDownloader.EXPECT().LastRollback().Return(nil, os.ErrNotExist)
+	mockDocker.EXPECT().IsAgentImageLoaded().Return(false, nil)
+	mockRoute.EXPECT().Check().Return(errors.New("nat PREROUTING chain entry not found"))
+	mockLoopbackRouting.EXPECT().IsEnabled().Return(false, nil)
+
+	engine := &Engine{
+		config:                config.Defaults(),
+		downloader:            mockDownloader,
+		loopbackRouting:       mockLoopbackRouting,
+		credentialsProxyRoute: mockRoute,
+		restartHistoryFile:    historyFile,
+	}
+	err := engine.Status(StatusOutputText)
+	assert.Error(t, err)
+
+	report := out.String()
+	assert.True(t, strings.HasPrefix(report, "ECS node status: DEGRADED"))
+	assert.Contains(t, report, "agent container is exited, last exit code 2")
+	assert.Contains(t, report, "nat PREROUTING chain entry not found")
+	assert.Contains(t, report, "route_localnet is disabled")
+	assert.Contains(t, report, "Recent agent restarts:")
+	assert.Contains(t, report, "2020-01-01T00:00:00Z      exit code 2     failure")
+}
+
+func TestStatusUnsupportedOutput(t *testing.T) {
+	engine := &Engine{config: config.Defaults()}
+	err := engine.Status("yaml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,322 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/cache"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/aws/amazon-ecs-init/ecs-init/journal"
+	"github.com/aws/amazon-ecs-init/ecs-init/metrics"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const introspectionProbeTimeout = 5 * time.Second
+
+// Injection points for testing purposes
+var (
+	probeAgentIntrospection = agentIntrospectionHealthy
+	probationProbeInterval  = 5 * time.Second
+)
+
+// upgradeProbation tracks an upgraded Agent until it has run for the
+// probation period. An upgraded Agent that fails during its probation is
+// rolled back to the known-good Agent.
+type upgradeProbation struct {
+	until time.Time
+	// introspection requires the Agent to answer on its introspection
+	// endpoint before the end of the probation period
+	introspection bool
+	// unhealthy is set when the Agent never answered on its introspection
+	// endpoint
+	unhealthy bool
+	// correlation correlates the journal events of the upgrade
+	correlation string
+}
+
+func newUpgradeProbation(cfg *config.Config, correlation string) *upgradeProbation {
+	return &upgradeProbation{
+		until:         time.Now().Add(cfg.UpgradeProbationPeriod),
+		introspection: cfg.UpgradeProbationIntrospection,
+		correlation:   correlation,
+	}
+}
+
+// record returns the state of the probation to persist, nil if there is no
+// probation
+func (p *upgradeProbation) record() *probationRecord {
+	if p == nil {
+		return nil
+	}
+	return &probationRecord{
+		Until:         p.until,
+		Introspection: p.introspection,
+		Correlation:   p.correlation,
+	}
+}
+
+// resumeProbation restores the probation of an upgraded Agent recorded by a
+// previous run of ecs-init, unless its period is over
+func (e *Engine) resumeProbation(history *restartHistory) {
+	record := history.Probation
+	if record == nil {
+		return
+	}
+	if !record.Until.After(time.Now()) {
+		log.Infof("Upgrade probation recorded until %s is over", record.Until)
+		e.saveProbation(history)
+		return
+	}
+	log.Infof("Resuming the upgrade probation of the agent until %s", record.Until)
+	e.probation = &upgradeProbation{
+		until:         record.Until,
+		introspection: record.Introspection,
+		correlation:   record.Correlation,
+	}
+}
+
+// saveProbation persists the probation of the upgraded Agent, if any, along
+// with the restart history
+func (e *Engine) saveProbation(history *restartHistory) {
+	history.Probation = e.probation.record()
+	err := history.save(e.restartHistoryFile)
+	if err != nil {
+		log.Warnf("Could not save the upgrade probation: %v", err)
+	}
+}
+
+// checkDesiredAgentVersion refuses upgrades to an Agent older than the
+// minimum version. The version of the desired Agent is only known if the
+// name of its image file has one.
+func (e *Engine) checkDesiredAgentVersion() error {
+	if e.config.AgentMinVersion == "" {
+		return nil
+	}
+	version, err := e.downloader.DesiredAgentVersion()
+	if err != nil {
+		log.Warnf("Could not check the desired agent against the minimum version %s: %v", e.config.AgentMinVersion, err)
+		return nil
+	}
+	err = config.CheckMinimumAgentVersion(version, e.config.AgentMinVersion)
+	if err != nil {
+		journal.Record(journal.Upgrade, "", journal.Fields{"step": "version-check", "version": version}, err)
+		return errors.Wrap(err, "refusing to upgrade the agent")
+	}
+	return nil
+}
+
+// upgradeAgent tags the running Agent image as known-good before loading the
+// desired Agent into Docker and putting it on probation. An Agent that is
+// itself on probation isn't tagged, so that a rollback restores the Agent
+// that was known-good before it.
+func (e *Engine) upgradeAgent(docker dockerClient) error {
+	err := e.checkDesiredAgentVersion()
+	if err != nil {
+		return err
+	}
+	if e.probation != nil {
+		log.Info("Current agent is on upgrade probation, keeping the previous known-good agent image")
+	} else {
+		err = docker.TagAgentImageKnownGood()
+		if err != nil {
+			log.Warnf("Could not tag the current agent image as known-good, a rollback will load the cached agent: %v", err)
+		}
+	}
+	log.Info("Loading new desired Amazon Elastic Container Service Agent into Docker")
+	notifyStatus("Upgrading Amazon Elastic Container Service Agent")
+	correlation := journal.NewCorrelationID()
+	err = e.load(docker, e.downloader.LoadDesiredAgent)
+	journal.Record(journal.Upgrade, correlation, journal.Fields{"step": "load"}, err)
+	metrics.UpgradeAttempted(err)
+	if err != nil {
+		return err
+	}
+	e.probation = newUpgradeProbation(e.config, correlation)
+	return nil
+}
+
+// runAgent starts the Agent and waits for it to exit, probing its
+// introspection endpoint while an upgrade is on probation or when the
+// liveness probe is enabled, notifying systemd once the Agent is ready, and
+// stopping it once a stop signal is received
+func (e *Engine) runAgent(docker dockerClient, stop <-chan struct{}) (restartRecord, error) {
+	run := restartRecord{StartedAt: time.Now()}
+	stopReadiness := e.watchReadiness(docker)
+	defer stopReadiness()
+	e.watchdog.wait()
+	defer e.watchdog.busy()
+	if e.probation != nil && e.probation.introspection {
+		stop := e.probation.watch(docker)
+		defer func() {
+			e.probation.unhealthy = stop()
+		}()
+	}
+	stopLiveness := newLivenessProbe(e.config).watch(docker, e.supervisor.isPaused)
+	var env map[string]string
+	if e.supervisor.isWatchingConfig() {
+		env = docker.LoadEnvVars()
+	}
+	e.supervisor.agentStarted(e.probation, env)
+	correlation := journal.NewCorrelationID()
+	journal.Record(journal.AgentStart, correlation, nil, nil)
+	agentExited := stopAgentAfterSignal(docker, stop)
+	exitCode, err := docker.StartAgent()
+	agentExited()
+	run.ExitCode = exitCode
+	run.ExitedAt = time.Now()
+	run.Unresponsive = stopLiveness()
+	run.Requested = e.supervisor.agentExited(exitCode)
+	journal.Record(journal.AgentExit, correlation, journal.Fields{
+		"exitCode":     strconv.Itoa(exitCode),
+		"unresponsive": strconv.FormatBool(run.Unresponsive),
+		"requested":    strconv.FormatBool(run.Requested),
+	}, err)
+	if err == nil {
+		metrics.AgentExited(exitCode)
+	}
+	return run, err
+}
+
+// watch probes the introspection endpoint of the Agent until it answers or
+// the probation period ends, in which case the Agent is stopped. The returned
+// function stops watching and returns whether the Agent was stopped.
+func (p *upgradeProbation) watch(docker dockerClient) func() bool {
+	done := make(chan struct{})
+	stopped := make(chan bool, 1)
+	go func() {
+		stopped <- p.probe(docker, done)
+	}()
+	return func() bool {
+		close(done)
+		return <-stopped
+	}
+}
+
+func (p *upgradeProbation) probe(docker dockerClient, done <-chan struct{}) bool {
+	ticker := time.NewTicker(probationProbeInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(time.Until(p.until))
+	defer deadline.Stop()
+	for {
+		select {
+		case <-done:
+			return false
+		case <-ticker.C:
+			if probeAgentIntrospection() == nil {
+				log.Info("Upgraded agent is answering on its introspection endpoint")
+				return false
+			}
+		case <-deadline.C:
+			log.Warn("Upgraded agent did not answer on its introspection endpoint within the probation period, stopping it")
+			err := docker.StopAgent()
+			if err != nil {
+				log.Errorf("could not stop upgraded agent: %v", err)
+			}
+			return true
+		}
+	}
+}
+
+// failure returns why the Agent run failed the probation, or an empty string
+// if it didn't
+func (p *upgradeProbation) failure(run restartRecord) string {
+	if p.unhealthy {
+		return "agent did not answer on its introspection endpoint within the upgrade probation period"
+	}
+	if run.ExitedAt.After(p.until) {
+		return ""
+	}
+	switch run.ExitCode {
+	case terminalSuccessAgentExitCode, upgradeAgentExitCode:
+		return ""
+	}
+	return fmt.Sprintf("agent exited with code %d within the upgrade probation period", run.ExitCode)
+}
+
+// endProbation ends the probation of an upgraded Agent after its first run,
+// rolling back the upgrade if the run failed. An Agent that exits to upgrade
+// again within the probation period stays on probation until the next
+// upgrade replaces it. It returns true if the upgrade was rolled back.
+func (e *Engine) endProbation(docker dockerClient, run restartRecord) bool {
+	probation := e.probation
+	reason := probation.failure(run)
+	if reason == "" && run.ExitCode == upgradeAgentExitCode && !run.ExitedAt.After(probation.until) {
+		return false
+	}
+	e.probation = nil
+	if reason == "" {
+		journal.Record(journal.Upgrade, probation.correlation, journal.Fields{"step": "probation-passed"}, nil)
+		return false
+	}
+	log.Warnf("Upgraded agent failed, rolling back: %s", reason)
+	notifyStatus("Rolling back Amazon Elastic Container Service Agent upgrade: %s", reason)
+	err := e.rollbackAgent(docker, reason, run.ExitCode)
+	journal.Record(journal.Rollback, probation.correlation, journal.Fields{
+		"reason":   reason,
+		"exitCode": strconv.Itoa(run.ExitCode),
+	}, err)
+	metrics.RolledBack(err)
+	if err != nil {
+		log.Errorf("could not roll back agent upgrade: %v", err)
+		return false
+	}
+	return true
+}
+
+// rollbackAgent restores the known-good Agent image, falling back to the
+// cached Agent if the known-good image is gone, and records the rollback in
+// the cache state
+func (e *Engine) rollbackAgent(docker dockerClient, reason string, exitCode int) error {
+	restored := config.AgentKnownGoodImageName
+	err := docker.RestoreKnownGoodAgentImage()
+	if err != nil {
+		log.Warnf("Could not restore the known-good agent image, loading the cached agent: %v", err)
+		restored = config.AgentVersionTarball(e.config.AgentVersion)
+		err = e.load(docker, e.cachedAgent)
+		if err != nil {
+			return err
+		}
+	}
+	err = e.downloader.RecordRollback(&cache.Rollback{
+		Time:     time.Now(),
+		Reason:   reason,
+		ExitCode: exitCode,
+		Restored: restored,
+	})
+	if err != nil {
+		log.Warnf("Could not record the rollback in the cache state: %v", err)
+	}
+	return nil
+}
+
+// agentIntrospectionHealthy returns an error unless the Agent answers on its
+// introspection metadata endpoint
+func agentIntrospectionHealthy() error {
+	client := http.Client{Timeout: introspectionProbeTimeout}
+	resp, err := client.Get(config.AgentIntrospectionMetadataURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("introspection endpoint returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/cache"
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probeAgentIntrospectionMock replaces the introspection probe and its
+// interval. The backup can be restored by executing the returned function in
+// a deferred manner.
+func probeAgentIntrospectionMock(probe func() error) func() {
+	probeAgentIntrospectionBkp := probeAgentIntrospection
+	probationProbeIntervalBkp := probationProbeInterval
+	probeAgentIntrospection = probe
+	probationProbeInterval = time.Millisecond
+	return func() {
+		probeAgentIntrospection = probeAgentIntrospectionBkp
+		probationProbeInterval = probationProbeIntervalBkp
+	}
+}
+
+func TestStartSupervisedRollsBackFailedUpgrade(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(upgradeAgentExitCode, nil),
+		mockDocker.EXPECT().TagAgentImageKnownGood(),
+		mockDownloader.EXPECT().LoadDesiredAgent().Return(&os.File{}, nil),
+		mockDocker.EXPECT().LoadImage(gomock.Any()),
+		mockDownloader.EXPECT().RecordCachedAgent(),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(containerFailureAgentExitCode, nil),
+		mockDocker.EXPECT().RestoreKnownGoodAgentImage(),
+		mockDownloader.EXPECT().RecordRollback(gomock.Any()).Do(func(rollback *cache.Rollback) {
+			assert.Equal(t, containerFailureAgentExitCode, rollback.ExitCode)
+			assert.Equal(t, config.AgentKnownGoodImageName, rollback.Restored)
+		}),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
+	)
+
+	engine := &Engine{
+		config:     config.Defaults(),
+		downloader: mockDownloader,
+	}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+}
+
+func TestStartSupervisedPersistsUpgradeProbation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(upgradeAgentExitCode, nil),
+		mockDocker.EXPECT().TagAgentImageKnownGood(),
+		mockDownloader.EXPECT().LoadDesiredAgent().Return(&os.File{}, nil),
+		mockDocker.EXPECT().LoadImage(gomock.Any()),
+		mockDownloader.EXPECT().RecordCachedAgent(),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().DoAndReturn(func() (int, error) {
+			probation := loadRestartHistory(historyFile).Probation
+			if assert.NotNil(t, probation, "expected the probation to be persisted while the upgraded agent runs") {
+				assert.True(t, probation.Until.After(time.Now()))
+			}
+			return terminalSuccessAgentExitCode, nil
+		}),
+	)
+
+	engine := &Engine{
+		config:             config.Defaults(),
+		downloader:         mockDownloader,
+		restartHistoryFile: historyFile,
+	}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+	assert.Nil(t, loadRestartHistory(historyFile).Probation, "expected the probation to be cleared once passed")
+}
+
+func TestStartSupervisedResumesUpgradeProbation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+
+	// an upgrade on probation recorded by a previous run of ecs-init
+	previous := &restartHistory{Probation: &probationRecord{Until: time.Now().Add(time.Hour)}}
+	require.NoError(t, previous.save(historyFile))
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(containerFailureAgentExitCode, nil),
+		mockDocker.EXPECT().RestoreKnownGoodAgentImage(),
+		mockDownloader.EXPECT().RecordRollback(gomock.Any()),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
+	)
+
+	engine := &Engine{
+		config:             config.Defaults(),
+		downloader:         mockDownloader,
+		restartHistoryFile: historyFile,
+	}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+	assert.Nil(t, loadRestartHistory(historyFile).Probation)
+}
+
+func TestStartSupervisedUpgradeOnProbation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+
+	// the upgraded agent upgrades again before the end of its probation
+	previous := &restartHistory{Probation: &probationRecord{Until: time.Now().Add(time.Hour)}}
+	require.NoError(t, previous.save(historyFile))
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	defer getDockerClientMock(mockDocker)()
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	gomock.InOrder(
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(upgradeAgentExitCode, nil),
+		mockDownloader.EXPECT().LoadDesiredAgent().Return(&os.File{}, nil),
+		mockDocker.EXPECT().LoadImage(gomock.Any()),
+		mockDownloader.EXPECT().RecordCachedAgent(),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(containerFailureAgentExitCode, nil),
+		mockDocker.EXPECT().RestoreKnownGoodAgentImage(),
+		mockDownloader.EXPECT().RecordRollback(gomock.Any()),
+		mockDocker.EXPECT().RemoveExistingAgentContainer(),
+		mockDocker.EXPECT().StartAgent().Return(terminalSuccessAgentExitCode, nil),
+	)
+
+	engine := &Engine{
+		config:             config.Defaults(),
+		downloader:         mockDownloader,
+		restartHistoryFile: historyFile,
+	}
+	err := engine.StartSupervised()
+	assert.NoError(t, err)
+}
+
+func TestResumeProbationOver(t *testing.T) {
+	historyFile, cleanup := restartHistoryFile(t)
+	defer cleanup()
+	history := &restartHistory{Probation: &probationRecord{Until: time.Now().Add(-time.Minute)}}
+
+	engine := &Engine{restartHistoryFile: historyFile}
+	engine.resumeProbation(history)
+	assert.Nil(t, engine.probation)
+	assert.Nil(t, loadRestartHistory(historyFile).Probation)
+}
+
+func TestUpgradeAgentRefusesOlderThanMinimumVersion(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDownloader := NewMockdownloader(mockCtrl)
+	mockDownloader.EXPECT().DesiredAgentVersion().Return("v1.50.0", nil)
+
+	cfg := config.Defaults()
+	cfg.AgentMinVersion = "v1.60.0"
+	engine := &Engine{
+		config:     cfg,
+		downloader: mockDownloader,
+	}
+	assert.Error(t, engine.upgradeAgent(mockDocker))
+}
+
+func TestUpgradeAgentUnknownDesiredVersion(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDownloader := NewMockdownloader(mockCtrl)
+	gomock.InOrder(
+		mockDownloader.EXPECT().DesiredAgentVersion().Return("", errors.New("no version")),
+		mockDocker.EXPECT().TagAgentImageKnownGood(),
+		mockDownloader.EXPECT().LoadDesiredAgent().Return(&os.File{}, nil),
+		mockDocker.EXPECT().LoadImage(gomock.Any()),
+		mockDownloader.EXPECT().RecordCachedAgent(),
+	)
+
+	cfg := config.Defaults()
+	cfg.AgentMinVersion = "v1.60.0"
+	engine := &Engine{
+		config:     cfg,
+		downloader: mockDownloader,
+	}
+	assert.NoError(t, engine.upgradeAgent(mockDocker))
+	assert.NotNil(t, engine.probation)
+}
+
+func TestUpgradeAgentOnProbationKeepsKnownGood(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// TagAgentImageKnownGood isn't expected
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDownloader := NewMockdownloader(mockCtrl)
+	gomock.InOrder(
+		mockDownloader.EXPECT().LoadDesiredAgent().Return(&os.File{}, nil),
+		mockDocker.EXPECT().LoadImage(gomock.Any()),
+		mockDownloader.EXPECT().RecordCachedAgent(),
+	)
+
+	probation := newUpgradeProbation(config.Defaults(), "")
+	engine := &Engine{
+		config:     config.Defaults(),
+		downloader: mockDownloader,
+		probation:  probation,
+	}
+	assert.NoError(t, engine.upgradeAgent(mockDocker))
+	assert.NotNil(t, engine.probation)
+	assert.False(t, engine.probation == probation, "expected the new upgrade to be put on probation")
+}
+
+func TestRollbackAgentFallsBackToCachedAgent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	mockDownloader := NewMockdownloader(mockCtrl)
+
+	gomock.InOrder(
+		mockDocker.EXPECT().RestoreKnownGoodAgentImage().Return(errors.New("no such image")),
+		mockDownloader.EXPECT().LoadCachedAgent().Return(&os.File{}, nil),
+		mockDocker.EXPECT().LoadImage(gomock.Any()),
+		mockDownloader.EXPECT().RecordCachedAgent(),
+		mockDownloader.EXPECT().RecordRollback(gomock.Any()).Do(func(rollback *cache.Rollback) {
+			assert.Equal(t, config.AgentTarball(), rollback.Restored)
+		}),
+	)
+
+	engine := &Engine{
+		config:     config.Defaults(),
+		downloader: mockDownloader,
+	}
+	err := engine.rollbackAgent(mockDocker, "failed", 1)
+	assert.NoError(t, err)
+}
+
+func TestUpgradeProbationStopsUnresponsiveAgent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	defer probeAgentIntrospectionMock(func() error {
+		return errors.New("connection refused")
+	})()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+	stopped := make(chan struct{})
+	mockDocker.EXPECT().StopAgent().Do(func() {
+		close(stopped)
+	})
+
+	probation := &upgradeProbation{until: time.Now().Add(10 * time.Millisecond)}
+	stop := probation.watch(mockDocker)
+	<-stopped
+	assert.True(t, stop())
+}
+
+func TestUpgradeProbationHealthyAgent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	probed := make(chan struct{}, 1)
+	defer probeAgentIntrospectionMock(func() error {
+		probed <- struct{}{}
+		return nil
+	})()
+
+	mockDocker := NewMockdockerClient(mockCtrl)
+
+	probation := &upgradeProbation{until: time.Now().Add(time.Hour)}
+	stop := probation.watch(mockDocker)
+	<-probed
+	assert.False(t, stop())
+}
+
+func TestUpgradeProbationFailure(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name      string
+		unhealthy bool
+		exitCode  int
+		exitedAt  time.Time
+		failed    bool
+	}{
+		{"failure during probation", false, 1, now, true},
+		{"terminal failure during probation", false, TerminalFailureAgentExitCode, now, true},
+		{"success during probation", false, terminalSuccessAgentExitCode, now, false},
+		{"upgrade during probation", false, upgradeAgentExitCode, now, false},
+		{"failure after probation", false, 1, now.Add(2 * time.Hour), false},
+		{"unhealthy", true, 1, now.Add(2 * time.Hour), true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			probation := &upgradeProbation{until: now.Add(time.Hour), unhealthy: tc.unhealthy}
+			reason := probation.failure(restartRecord{ExitCode: tc.exitCode, ExitedAt: tc.exitedAt})
+			assert.Equal(t, tc.failed, reason != "")
+		})
+	}
+}
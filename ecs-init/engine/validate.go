@@ -0,0 +1,113 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
+	"github.com/pkg/errors"
+)
+
+// Injection point for testing purposes
+var defaultConfigFiles = config.Files
+
+// ValidateConfigOptions select the files checked by ValidateConfig
+type ValidateConfigOptions struct {
+	// Files are the paths of the configuration files to check, in
+	// increasing order of precedence. The instance and Agent configuration
+	// files and their drop-ins are checked if empty, skipping the ones that
+	// don't exist.
+	Files []string
+	// Output is either StatusOutputText or StatusOutputJSON
+	Output string
+}
+
+// validateConfigReport is the JSON report of ValidateConfig
+type validateConfigReport struct {
+	Files    []string          `json:"files"`
+	Problems []*config.Problem `json:"problems"`
+}
+
+// ValidateConfig lints the configuration files and prints the problems it
+// finds. An error is returned if there are any, so that the exit code can be
+// used by AMI build pipelines.
+func ValidateConfig(options *ValidateConfigOptions) error {
+	if options.Output != StatusOutputText && options.Output != StatusOutputJSON {
+		return errors.Errorf("unsupported output format %q", options.Output)
+	}
+	files, err := readConfigFiles(options.Files)
+	if err != nil {
+		return err
+	}
+	report := &validateConfigReport{Problems: config.Validate(files)}
+	for _, file := range files {
+		report.Files = append(report.Files, file.Path)
+	}
+	err = writeValidateConfigReport(reportOutput, report, options.Output)
+	if err != nil {
+		return engineError("could not write config validation report", err)
+	}
+	if len(report.Problems) > 0 {
+		return errors.Errorf("found %d problems in the configuration", len(report.Problems))
+	}
+	return nil
+}
+
+func readConfigFiles(paths []string) ([]*config.File, error) {
+	optional := len(paths) == 0
+	if optional {
+		var err error
+		paths, err = defaultConfigFiles()
+		if err != nil {
+			return nil, err
+		}
+	}
+	var files []*config.File
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if optional && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read configuration file %s", path)
+		}
+		files = append(files, &config.File{Path: path, Data: data})
+	}
+	return files, nil
+}
+
+func writeValidateConfigReport(w io.Writer, report *validateConfigReport, output string) error {
+	if output == StatusOutputJSON {
+		if report.Problems == nil {
+			report.Problems = []*config.Problem{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	for _, problem := range report.Problems {
+		_, err := fmt.Fprintln(w, problem)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d problems found in %d configuration files\n", len(report.Problems), len(report.Files))
+	return err
+}
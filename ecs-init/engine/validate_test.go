@@ -0,0 +1,90 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validateConfigMocks writes the configuration files to a temporary
+// directory used as the default configuration files, returning the path of
+// the Agent configuration file and a function that restores the defaults
+func validateConfigMocks(t *testing.T, instanceConfig, agentConfig string) (string, func()) {
+	dir, err := ioutil.TempDir("", "validate-config")
+	require.NoError(t, err)
+	instanceFile := filepath.Join(dir, "instance.config")
+	agentFile := filepath.Join(dir, "ecs.config")
+	if agentConfig != "" {
+		require.NoError(t, ioutil.WriteFile(agentFile, []byte(agentConfig), 0644))
+	}
+	if instanceConfig != "" {
+		require.NoError(t, ioutil.WriteFile(instanceFile, []byte(instanceConfig), 0644))
+	}
+	defaultConfigFilesBkp := defaultConfigFiles
+	defaultConfigFiles = func() ([]string, error) {
+		return []string{instanceFile, agentFile}, nil
+	}
+	return agentFile, func() {
+		defaultConfigFiles = defaultConfigFilesBkp
+		os.RemoveAll(dir)
+	}
+}
+
+func TestValidateConfigSkipsMissingDefaultFiles(t *testing.T) {
+	agentFile, restore := validateConfigMocks(t, "", "ECS_CLUSTER=prod\n")
+	defer restore()
+	var output bytes.Buffer
+	defer statusMocks(&output, nil)()
+
+	err := ValidateConfig(&ValidateConfigOptions{Output: StatusOutputJSON})
+	require.NoError(t, err)
+
+	var report validateConfigReport
+	require.NoError(t, json.Unmarshal(output.Bytes(), &report))
+	assert.Equal(t, []string{agentFile}, report.Files)
+	assert.Empty(t, report.Problems)
+}
+
+func TestValidateConfigProblems(t *testing.T) {
+	agentFile, restore := validateConfigMocks(t, "ECS_CLUSTR=prod\n", "ECS_UPDATES_ENABLED=maybe\n")
+	defer restore()
+	var output bytes.Buffer
+	defer statusMocks(&output, nil)()
+
+	err := ValidateConfig(&ValidateConfigOptions{Output: StatusOutputText})
+	assert.Error(t, err)
+	assert.Contains(t, output.String(), "instance.config:1: unknown key ECS_CLUSTR, did you mean ECS_CLUSTER?\n")
+	assert.Contains(t, output.String(), agentFile+`:1: invalid value "maybe" for ECS_UPDATES_ENABLED`)
+	assert.Contains(t, output.String(), "2 problems found in 2 configuration files\n")
+}
+
+func TestValidateConfigExplicitFileMissing(t *testing.T) {
+	var output bytes.Buffer
+	defer statusMocks(&output, nil)()
+
+	err := ValidateConfig(&ValidateConfigOptions{
+		Files:  []string{"/does/not/exist/ecs.config"},
+		Output: StatusOutputText,
+	})
+	assert.Error(t, err)
+	assert.Empty(t, output.String())
+}
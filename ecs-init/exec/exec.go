@@ -21,6 +21,7 @@ import (
 
 //go:generate mockgen.sh sysctl $GOFILE sysctl
 //go:generate mockgen.sh iptables $GOFILE iptables
+//go:generate mockgen.sh doctor $GOFILE ../doctor
 
 // Exec defines common methods from exec package that are used to run external
 // commands
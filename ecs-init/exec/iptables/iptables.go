@@ -18,9 +18,9 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
 	"github.com/aws/amazon-ecs-init/ecs-init/exec"
 	log "github.com/cihub/seelog"
 	"github.com/pkg/errors"
@@ -42,13 +42,13 @@ const (
 	iptablesInsert iptablesAction = "-I"
 	// iptablesDelete enumerates the 'delete' action
 	iptablesDelete iptablesAction = "-D"
+	// iptablesCheck enumerates the 'check' action
+	iptablesCheck iptablesAction = "-C"
 
 	iptablesTableFilter = "filter"
 	iptablesTableNat    = "nat"
 
-	offhostIntrospectionAccessConfigEnv   = "ECS_ALLOW_OFFHOST_INTROSPECTION_ACCESS"
-	offhostIntrospectonAccessInterfaceEnv = "ECS_OFFHOST_INTROSPECTION_INTERFACE_NAME"
-	agentIntrospectionServerPort          = "51678"
+	agentIntrospectionServerPort = "51678"
 
 	ipv4RouteFile                         = "/proc/net/route"
 	ipv4ZeroAddrInHex                     = "00000000"
@@ -63,7 +63,9 @@ var (
 // NetfilterRoute implements the engine.credentialsProxyRoute interface by
 // running the external 'iptables' command
 type NetfilterRoute struct {
-	cmdExec exec.Exec
+	cmdExec                    exec.Exec
+	skipLocalhostTrafficFilter bool
+	allowOffhostIntrospection  bool
 }
 
 // getNetfilterChainArgsFunc defines a function pointer type that returns
@@ -71,7 +73,7 @@ type NetfilterRoute struct {
 type getNetfilterChainArgsFunc func() []string
 
 // NewNetfilterRoute creates a new NetfilterRoute object
-func NewNetfilterRoute(cmdExec exec.Exec) (*NetfilterRoute, error) {
+func NewNetfilterRoute(cmdExec exec.Exec, cfg *config.Config) (*NetfilterRoute, error) {
 	// Return an error if 'iptables' command cannot be found in the path
 	_, err := cmdExec.LookPath(iptablesExecutable)
 	if err != nil {
@@ -79,7 +81,7 @@ func NewNetfilterRoute(cmdExec exec.Exec) (*NetfilterRoute, error) {
 		return nil, err
 	}
 
-	defaultOffhostIntrospectionInterface, err = getOffhostIntrospectionInterface()
+	defaultOffhostIntrospectionInterface, err = getOffhostIntrospectionInterface(cfg.OffhostIntrospectionInterface)
 	if err != nil {
 		log.Warnf("Error resolving default offhost introspection network interface, will use eth0 as fallback: %+v", err)
 		// fall back to the previous behavior (always use 'eth0') in the rare case that it
@@ -88,7 +90,9 @@ func NewNetfilterRoute(cmdExec exec.Exec) (*NetfilterRoute, error) {
 	}
 
 	return &NetfilterRoute{
-		cmdExec: cmdExec,
+		cmdExec:                    cmdExec,
+		skipLocalhostTrafficFilter: cfg.SkipLocalhostTrafficFilter,
+		allowOffhostIntrospection:  cfg.AllowOffhostIntrospectionAccess,
 	}, nil
 }
 
@@ -99,14 +103,14 @@ func (route *NetfilterRoute) Create() error {
 		return err
 	}
 
-	if !skipLocalhostTrafficFilter() {
+	if !route.skipLocalhostTrafficFilter {
 		err = route.modifyNetfilterEntry(iptablesTableFilter, iptablesInsert, getLocalhostTrafficFilterInputChainArgs)
 		if err != nil {
 			return err
 		}
 	}
 
-	if !allowOffhostIntrospection() {
+	if !route.allowOffhostIntrospection {
 		err = route.modifyNetfilterEntry(iptablesTableFilter, iptablesInsert, getBlockIntrospectionOffhostAccessInputChainArgs)
 		if err != nil {
 			log.Errorf("Error adding input chain entry to block offhost introspection access: %v", err)
@@ -126,7 +130,7 @@ func (route *NetfilterRoute) Remove() error {
 	}
 
 	var localhostInputError, introspectionInputError error
-	if !skipLocalhostTrafficFilter() {
+	if !route.skipLocalhostTrafficFilter {
 		localhostInputError = route.modifyNetfilterEntry(iptablesTableFilter, iptablesDelete, getLocalhostTrafficFilterInputChainArgs)
 		if localhostInputError != nil {
 			localhostInputError = fmt.Errorf("error removing input chain entry: %v", localhostInputError)
@@ -147,6 +151,36 @@ func (route *NetfilterRoute) Remove() error {
 	return combinedError(preroutingErr, localhostInputError, introspectionInputError, outputErr)
 }
 
+// Check verifies that the entries added by Create are present in the
+// netfilter table
+func (route *NetfilterRoute) Check() error {
+	var errs []error
+	errs = append(errs, route.checkNetfilterEntry(iptablesTableNat, getPreroutingChainArgs))
+	if !route.skipLocalhostTrafficFilter {
+		errs = append(errs, route.checkNetfilterEntry(iptablesTableFilter, getLocalhostTrafficFilterInputChainArgs))
+	}
+	if !route.allowOffhostIntrospection {
+		errs = append(errs, route.checkNetfilterEntry(iptablesTableFilter, getBlockIntrospectionOffhostAccessInputChainArgs))
+	}
+	errs = append(errs, route.checkNetfilterEntry(iptablesTableNat, getOutputChainArgs))
+	return combinedError(errs...)
+}
+
+// checkNetfilterEntry returns an error if the entry described by the
+// function pointer is not present in the netfilter table
+func (route *NetfilterRoute) checkNetfilterEntry(table string, getNetfilterChainArgs getNetfilterChainArgsFunc) error {
+	chainArgs := getNetfilterChainArgs()
+	args := append(getTableArgs(table), string(iptablesCheck))
+	args = append(args, chainArgs...)
+	cmd := route.cmdExec.Command(iptablesExecutable, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Debugf("Error checking %s %s chain entry: %v; raw output: %s", table, chainArgs[0], err, out)
+		return fmt.Errorf("%s %s chain entry not found", table, chainArgs[0])
+	}
+	return nil
+}
+
 func combinedError(errs ...error) error {
 	errMsgs := []string{}
 	for _, err := range errs {
@@ -213,10 +247,9 @@ func getBlockIntrospectionOffhostAccessInputChainArgs() []string {
 	}
 }
 
-func getOffhostIntrospectionInterface() (string, error) {
-	s := os.Getenv(offhostIntrospectonAccessInterfaceEnv)
-	if s != "" {
-		return s, nil
+func getOffhostIntrospectionInterface(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
 	}
 	return getDefaultNetworkInterfaceIPv4()
 }
@@ -271,34 +304,9 @@ func getActionName(action iptablesAction) string {
 		return "append"
 	case iptablesInsert:
 		return "insert"
+	case iptablesCheck:
+		return "check"
 	default:
 		return "delete"
 	}
 }
-
-func skipLocalhostTrafficFilter() bool {
-	s := os.Getenv("ECS_SKIP_LOCALHOST_TRAFFIC_FILTER")
-	if s == "" {
-		return false
-	}
-	b, err := strconv.ParseBool(s)
-	if err != nil {
-		log.Errorf("Failed to parse value for ECS_SKIP_LOCALHOST_TRAFFIC_FILTER [%s]: %v. Default it to false.", s, err)
-		return false
-	}
-	return b
-}
-
-func allowOffhostIntrospection() bool {
-	s := os.Getenv(offhostIntrospectionAccessConfigEnv)
-	if s == "" {
-		return false
-	}
-	b, err := strconv.ParseBool(s)
-	if err != nil {
-		log.Errorf("Failed to parse value for %s [%s]: %v. Default it to false.",
-			offhostIntrospectionAccessConfigEnv, s, err)
-		return false
-	}
-	return b
-}
@@ -15,10 +15,11 @@ package iptables
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"testing"
 
+	"github.com/aws/amazon-ecs-init/ecs-init/config"
+
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -91,7 +92,7 @@ func TestNewNetfilterRouteFailsWhenExecutableNotFound(t *testing.T) {
 	mockExec := NewMockExec(ctrl)
 	mockExec.EXPECT().LookPath(iptablesExecutable).Return("", fmt.Errorf("Not found"))
 
-	_, err := NewNetfilterRoute(mockExec)
+	_, err := NewNetfilterRoute(mockExec, config.Defaults())
 	assert.Error(t, err, "Expected error when executable's path lookup fails")
 }
 
@@ -103,13 +104,14 @@ func TestNewNetfilterRouteWithDefaultOffhostIntrospectionInterfaceFallback(t *te
 	mockExec := NewMockExec(ctrl)
 	mockExec.EXPECT().LookPath(iptablesExecutable).Return("", nil)
 
-	_, err := NewNetfilterRoute(mockExec)
+	_, err := NewNetfilterRoute(mockExec, config.Defaults())
 	assert.NoError(t, err)
 	assert.Equal(t, defaultOffhostIntrospectionInterface, fallbackOffhostIntrospectionInterface)
 }
 
 func TestCreate(t *testing.T) {
 	defer overrideIPRouteInput(testIPV4RouteInput)()
+	cfg := config.Defaults()
 	testCases := []struct {
 		setOffhostInterface bool
 		inputRouteArgs      []string
@@ -125,8 +127,7 @@ func TestCreate(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		if tc.setOffhostInterface {
-			os.Setenv(offhostIntrospectonAccessInterfaceEnv, "sn0")
-			defer os.Unsetenv(offhostIntrospectonAccessInterfaceEnv)
+			cfg.OffhostIntrospectionInterface = "sn0"
 		}
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -151,7 +152,7 @@ func TestCreate(t *testing.T) {
 			mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 		)
 
-		route, err := NewNetfilterRoute(mockExec)
+		route, err := NewNetfilterRoute(mockExec, cfg)
 		require.NoError(t, err, "Error creating netfilter route object")
 
 		err = route.Create()
@@ -162,8 +163,8 @@ func TestCreate(t *testing.T) {
 
 func TestCreateSkipLocalTrafficFilter(t *testing.T) {
 	defer overrideIPRouteInput(testIPV4RouteInput)()
-	os.Setenv("ECS_SKIP_LOCALHOST_TRAFFIC_FILTER", "true")
-	defer os.Unsetenv("ECS_SKIP_LOCALHOST_TRAFFIC_FILTER")
+	cfg := config.Defaults()
+	cfg.SkipLocalhostTrafficFilter = true
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -183,7 +184,7 @@ func TestCreateSkipLocalTrafficFilter(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, cfg)
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Create()
@@ -191,8 +192,8 @@ func TestCreateSkipLocalTrafficFilter(t *testing.T) {
 }
 
 func TestCreateAllowOffhostIntrospectionAccess(t *testing.T) {
-	os.Setenv(offhostIntrospectionAccessConfigEnv, "true")
-	defer os.Unsetenv(offhostIntrospectionAccessConfigEnv)
+	cfg := config.Defaults()
+	cfg.AllowOffhostIntrospectionAccess = true
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -212,7 +213,7 @@ func TestCreateAllowOffhostIntrospectionAccess(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, cfg)
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Create()
@@ -233,7 +234,7 @@ func TestCreateErrorOnPreRoutingCommandError(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, fmt.Errorf("didn't expect this, did you?")),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Create()
@@ -256,7 +257,7 @@ func TestCreateErrorOnInputChainCommandError(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, testErr),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
 	require.NoError(t, err)
 
 	err = route.Create()
@@ -287,7 +288,7 @@ func TestCreateErrorOnOutputChainCommandError(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, fmt.Errorf("didn't expect this, did you?")),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Create()
@@ -319,7 +320,7 @@ func TestRemove(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Remove()
@@ -328,8 +329,8 @@ func TestRemove(t *testing.T) {
 
 func TestRemoveSkipLocalTrafficFilter(t *testing.T) {
 	defer overrideIPRouteInput(testIPV4RouteInput)()
-	os.Setenv("ECS_SKIP_LOCALHOST_TRAFFIC_FILTER", "true")
-	defer os.Unsetenv("ECS_SKIP_LOCALHOST_TRAFFIC_FILTER")
+	cfg := config.Defaults()
+	cfg.SkipLocalhostTrafficFilter = true
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -349,7 +350,7 @@ func TestRemoveSkipLocalTrafficFilter(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, cfg)
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Remove()
@@ -358,8 +359,8 @@ func TestRemoveSkipLocalTrafficFilter(t *testing.T) {
 
 func TestRemoveAllowIntrospectionOffhostAccess(t *testing.T) {
 	defer overrideIPRouteInput(testIPV4RouteInput)()
-	os.Setenv(offhostIntrospectionAccessConfigEnv, "true")
-	defer os.Unsetenv(offhostIntrospectionAccessConfigEnv)
+	cfg := config.Defaults()
+	cfg.AllowOffhostIntrospectionAccess = true
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -382,7 +383,7 @@ func TestRemoveAllowIntrospectionOffhostAccess(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, cfg)
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Remove()
@@ -413,7 +414,7 @@ func TestRemoveErrorOnPreroutingChainCommandError(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Remove()
@@ -444,7 +445,7 @@ func TestRemoveErrorOnOutputChainCommandError(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, fmt.Errorf("no cpu cycles to spare, sorry")),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Remove()
@@ -474,13 +475,70 @@ func TestRemoveErrorOnInputChainCommandsErrors(t *testing.T) {
 		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
 	)
 
-	route, err := NewNetfilterRoute(mockExec)
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
 	require.NoError(t, err, "Error creating netfilter route object")
 
 	err = route.Remove()
 	assert.Error(t, err, "Expected error removing route")
 }
 
+func TestCheck(t *testing.T) {
+	defer overrideIPRouteInput(testIPV4RouteInput)()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCmd := NewMockCmd(ctrl)
+	mockExec := NewMockExec(ctrl)
+	gomock.InOrder(
+		mockExec.EXPECT().LookPath(iptablesExecutable).Return("", nil),
+		mockExec.EXPECT().Command(iptablesExecutable,
+			expectedArgs("nat", "-C", "PREROUTING", preroutingRouteArgs)).Return(mockCmd),
+		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
+		mockExec.EXPECT().Command(iptablesExecutable,
+			expectedArgs("filter", "-C", "INPUT", localhostTrafficFilterInputRouteArgs)).Return(mockCmd),
+		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
+		mockExec.EXPECT().Command(iptablesExecutable,
+			expectedArgs("filter", "-C", "INPUT", blockIntrospectionOffhostAccessInputRouteArgs)).Return(mockCmd),
+		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
+		mockExec.EXPECT().Command(iptablesExecutable,
+			expectedArgs("nat", "-C", "OUTPUT", outputRouteArgs)).Return(mockCmd),
+		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
+	)
+
+	route, err := NewNetfilterRoute(mockExec, config.Defaults())
+	require.NoError(t, err, "Error creating netfilter route object")
+
+	assert.NoError(t, route.Check())
+}
+
+func TestCheckMissingEntries(t *testing.T) {
+	defer overrideIPRouteInput(testIPV4RouteInput)()
+	cfg := config.Defaults()
+	cfg.SkipLocalhostTrafficFilter = true
+	cfg.AllowOffhostIntrospectionAccess = true
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCmd := NewMockCmd(ctrl)
+	mockExec := NewMockExec(ctrl)
+	gomock.InOrder(
+		mockExec.EXPECT().LookPath(iptablesExecutable).Return("", nil),
+		mockExec.EXPECT().Command(iptablesExecutable,
+			expectedArgs("nat", "-C", "PREROUTING", preroutingRouteArgs)).Return(mockCmd),
+		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, testErr),
+		mockExec.EXPECT().Command(iptablesExecutable,
+			expectedArgs("nat", "-C", "OUTPUT", outputRouteArgs)).Return(mockCmd),
+		mockCmd.EXPECT().CombinedOutput().Return([]byte{0}, nil),
+	)
+
+	route, err := NewNetfilterRoute(mockExec, cfg)
+	require.NoError(t, err, "Error creating netfilter route object")
+
+	err = route.Check()
+	require.Error(t, err)
+	assert.Equal(t, "nat PREROUTING chain entry not found", err.Error())
+}
+
 func TestCombinedError(t *testing.T) {
 	err1 := errors.New("err1")
 	err2 := errors.New("err2")
@@ -519,7 +577,7 @@ func TestGetLocalhostTrafficFilterInputChainArgs(t *testing.T) {
 
 func TestGetBlockIntrospectionOffhostAccessInputChainArgs(t *testing.T) {
 	defer overrideIPRouteInput(testIPV4RouteInput)()
-	defaultOffhostIntrospectionInterface, _ = getOffhostIntrospectionInterface()
+	defaultOffhostIntrospectionInterface, _ = getOffhostIntrospectionInterface("")
 	assert.Equal(t, []string{
 		"INPUT",
 		"-p", "tcp",
@@ -546,6 +604,7 @@ func TestGetActionName(t *testing.T) {
 	assert.Equal(t, "append", getActionName(iptablesAppend))
 	assert.Equal(t, "insert", getActionName(iptablesInsert))
 	assert.Equal(t, "delete", getActionName(iptablesDelete))
+	assert.Equal(t, "check", getActionName(iptablesCheck))
 }
 
 func expectedArgs(table, action, chain string, args []string) []string {
@@ -573,11 +632,8 @@ lo	00000000	01201FAC	0003	0	0	0	00000000	0	0	0
 	assert.Equal(t, "", iface)
 }
 
-func TestGetOffhostIntrospectionInterfaceWithEnvOverride(t *testing.T) {
-	os.Setenv(offhostIntrospectonAccessInterfaceEnv, "test_iface")
-	defer os.Unsetenv(offhostIntrospectonAccessInterfaceEnv)
-
-	iface, err := getOffhostIntrospectionInterface()
+func TestGetOffhostIntrospectionInterfaceWithConfigOverride(t *testing.T) {
+	iface, err := getOffhostIntrospectionInterface("test_iface")
 	assert.NoError(t, err)
 	assert.Equal(t, "test_iface", iface)
 }
@@ -585,7 +641,7 @@ func TestGetOffhostIntrospectionInterfaceWithEnvOverride(t *testing.T) {
 func TestGetOffhostIntrospectionInterfaceUseDefaultV4(t *testing.T) {
 	defer overrideIPRouteInput(testIPV4RouteInput)()
 
-	iface, err := getOffhostIntrospectionInterface()
+	iface, err := getOffhostIntrospectionInterface("")
 	assert.NoError(t, err)
 	assert.Equal(t, offhostIntrospectionInterface, iface)
 }
@@ -593,7 +649,7 @@ func TestGetOffhostIntrospectionInterfaceUseDefaultV4(t *testing.T) {
 func TestGetOffhostIntrospectionInterfaceFailure(t *testing.T) {
 	defer overrideIPRouteInput("")()
 
-	iface, err := getOffhostIntrospectionInterface()
+	iface, err := getOffhostIntrospectionInterface("")
 	assert.Error(t, err)
 	assert.Equal(t, "", iface)
 }
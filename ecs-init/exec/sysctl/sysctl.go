@@ -87,6 +87,21 @@ func (ipv4RouteLocalnet *Ipv4RouteLocalnet) Enable() error {
 	return err
 }
 
+// IsEnabled returns true if routing to loopback addresses is enabled
+func (ipv4RouteLocalnet *Ipv4RouteLocalnet) IsEnabled() (bool, error) {
+	cmd := ipv4RouteLocalnet.cmdExec.Command(sysctlExecutable, "-n", allIpv4RouteLocalnetConfigKey)
+	out, err := cmd.Output()
+	if err != nil {
+		log.Errorf("Error getting all route_localnet %v; raw output: %s", err, out)
+		return false, err
+	}
+	val, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return val == 1, nil
+}
+
 // Restore restores the default value for loopback addresses
 func (ipv4RouteLocalnet *Ipv4RouteLocalnet) RestoreDefault() error {
 	cmd := ipv4RouteLocalnet.cmdExec.Command(sysctlExecutable, defaultIpv4RouteLocalnetConfigKey)
@@ -73,6 +73,29 @@ func TestEnableReturnsErrorOnCommandError(t *testing.T) {
 	}
 }
 
+func TestIsEnabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCmd := NewMockCmd(ctrl)
+	mockCmd.EXPECT().Output().Return([]byte("1\n"), nil)
+	mockExec := NewMockExec(ctrl)
+	mockExec.EXPECT().LookPath(sysctlExecutable).Return("", nil)
+	mockExec.EXPECT().Command(sysctlExecutable, "-n", "net.ipv4.conf.all.route_localnet").Return(mockCmd)
+	routeLocalNet, err := NewIpv4RouteLocalNet(mockExec)
+	if err != nil {
+		t.Fatalf("Error creating Ipv4RouteLocalNet object: %v", err)
+	}
+
+	enabled, err := routeLocalNet.IsEnabled()
+	if err != nil {
+		t.Fatalf("Error checking route localnet: %v", err)
+	}
+	if !enabled {
+		t.Error("Expected route localnet to be enabled")
+	}
+}
+
 func TestParseDefaultRouteLocalNet(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
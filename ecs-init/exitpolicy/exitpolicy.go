@@ -0,0 +1,214 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package exitpolicy provides the policy that maps exit codes of the ECS
+// Agent to the actions ecs-init takes when the Agent exits.
+package exitpolicy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Action is something ecs-init does when the Agent exits
+type Action string
+
+const (
+	// Restart restarts the Agent right away
+	Restart Action = "restart"
+	// RestartWithBackoff restarts the Agent after a backoff that grows with
+	// each failure
+	RestartWithBackoff Action = "restart-with-backoff"
+	// Terminal stops supervising the Agent. ecs-init exits successfully if
+	// the Agent did, and with a terminal exit code otherwise.
+	Terminal Action = "terminal"
+	// Upgrade loads the desired Agent and restarts it right away. If the
+	// upgrade fails, the remaining actions of the rule are taken.
+	Upgrade Action = "upgrade"
+	// CaptureLogs logs the tail of the Agent container logs
+	CaptureLogs Action = "capture-logs"
+	// CaptureBundle writes a crash diagnostics bundle of the Agent
+	// container to the log directory
+	CaptureBundle Action = "capture-bundle"
+	// Hook runs the command of the rule
+	Hook Action = "hook"
+)
+
+// Rule maps exit codes to the actions taken when the Agent exits with one of
+// them. The actions are taken in order and must end with exactly one of
+// restart, restart-with-backoff or terminal.
+type Rule struct {
+	// ExitCodes are single exit codes such as "3" or inclusive ranges such
+	// as "10-20"
+	ExitCodes []string `json:"exitCodes"`
+	Actions   []Action `json:"actions"`
+	// Hook is the command run by the hook action. The exit code of the Agent
+	// is passed to it in the ECS_AGENT_EXIT_CODE environment variable.
+	Hook []string `json:"hook,omitempty"`
+
+	ranges []exitCodeRange
+}
+
+type exitCodeRange struct {
+	from int
+	to   int
+}
+
+// Policy is an ordered list of rules. The first rule that matches an exit
+// code applies.
+type Policy struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Default returns the built-in policy
+func Default() *Policy {
+	policy := &Policy{
+		Rules: []*Rule{
+			{ExitCodes: []string{"0"}, Actions: []Action{Terminal}},
+			{ExitCodes: []string{"2"}, Actions: []Action{CaptureLogs, CaptureBundle, RestartWithBackoff}},
+			{ExitCodes: []string{"5"}, Actions: []Action{Terminal}},
+			{ExitCodes: []string{"42"}, Actions: []Action{Upgrade, RestartWithBackoff}},
+		},
+	}
+	// the built-in rules are known to be valid
+	policy.validate()
+	return policy
+}
+
+// Load reads a policy from a JSON file. The rules of the file take
+// precedence over the built-in rules, which still apply to the exit codes
+// that the file doesn't match.
+func Load(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	err = json.Unmarshal(data, policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid exit code policy %s", path)
+	}
+	err = policy.validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid exit code policy %s", path)
+	}
+	policy.Rules = append(policy.Rules, Default().Rules...)
+	return policy, nil
+}
+
+// Match returns the rule that applies to the exit code. Exit codes that no
+// rule matches are restarted with a backoff.
+func (p *Policy) Match(exitCode int) *Rule {
+	for _, rule := range p.Rules {
+		if rule.matches(exitCode) {
+			return rule
+		}
+	}
+	return &Rule{Actions: []Action{RestartWithBackoff}}
+}
+
+// IsFailure returns true if the exit codes of the rule are failures of the
+// Agent, which count towards the crash loop circuit breaker: the rule
+// restarts the Agent with a backoff, without upgrading it first.
+func (r *Rule) IsFailure() bool {
+	for _, action := range r.Actions {
+		if action == Upgrade {
+			return false
+		}
+	}
+	return len(r.Actions) > 0 && r.Actions[len(r.Actions)-1] == RestartWithBackoff
+}
+
+func (p *Policy) validate() error {
+	for i, rule := range p.Rules {
+		err := rule.validate()
+		if err != nil {
+			return errors.Wrapf(err, "rule %d", i+1)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matches(exitCode int) bool {
+	for _, codes := range r.ranges {
+		if exitCode >= codes.from && exitCode <= codes.to {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) validate() error {
+	if len(r.ExitCodes) == 0 {
+		return errors.New("no exit codes")
+	}
+	r.ranges = nil
+	for _, codes := range r.ExitCodes {
+		exitCodes, err := parseExitCodes(codes)
+		if err != nil {
+			return err
+		}
+		r.ranges = append(r.ranges, exitCodes)
+	}
+	return r.validateActions()
+}
+
+func (r *Rule) validateActions() error {
+	if len(r.Actions) == 0 {
+		return errors.New("no actions")
+	}
+	for i, action := range r.Actions {
+		last := i == len(r.Actions)-1
+		switch action {
+		case Restart, RestartWithBackoff, Terminal:
+			if !last {
+				return errors.Errorf("action %q must be the last action", action)
+			}
+		case Upgrade, CaptureLogs, CaptureBundle:
+		case Hook:
+			if len(r.Hook) == 0 {
+				return errors.New("action \"hook\" requires a hook command")
+			}
+		default:
+			return errors.Errorf("unknown action %q", action)
+		}
+		if last && !isOutcome(action) {
+			return errors.New("actions must end with one of restart, restart-with-backoff or terminal")
+		}
+	}
+	return nil
+}
+
+func isOutcome(action Action) bool {
+	return action == Restart || action == RestartWithBackoff || action == Terminal
+}
+
+func parseExitCodes(codes string) (exitCodeRange, error) {
+	bounds := strings.SplitN(codes, "-", 2)
+	from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil || from < 0 {
+		return exitCodeRange{}, errors.Errorf("invalid exit code %q", codes)
+	}
+	to := from
+	if len(bounds) == 2 {
+		to, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil || to < from {
+			return exitCodeRange{}, errors.Errorf("invalid exit code range %q", codes)
+		}
+	}
+	return exitCodeRange{from: from, to: to}, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package exitpolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, policy string) (string, func()) {
+	dir, err := ioutil.TempDir("", "exitpolicy")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "exit-code-policy.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(policy), 0644))
+	return path, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	testCases := []struct {
+		exitCode int
+		actions  []Action
+	}{
+		{0, []Action{Terminal}},
+		{1, []Action{RestartWithBackoff}},
+		{2, []Action{CaptureLogs, CaptureBundle, RestartWithBackoff}},
+		{5, []Action{Terminal}},
+		{42, []Action{Upgrade, RestartWithBackoff}},
+		{137, []Action{RestartWithBackoff}},
+	}
+	policy := Default()
+	for _, tc := range testCases {
+		assert.Equal(t, tc.actions, policy.Match(tc.exitCode).Actions, "unexpected actions for exit code %d", tc.exitCode)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path, cleanup := writePolicy(t, `{
+		"rules": [
+			{"exitCodes": ["3", "10-20"], "actions": ["capture-logs", "hook", "restart"], "hook": ["/bin/true"]},
+			{"exitCodes": ["2"], "actions": ["terminal"]}
+		]
+	}`)
+	defer cleanup()
+
+	policy, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Action{CaptureLogs, Hook, Restart}, policy.Match(3).Actions)
+	assert.Equal(t, []Action{CaptureLogs, Hook, Restart}, policy.Match(15).Actions)
+	assert.Equal(t, []string{"/bin/true"}, policy.Match(20).Hook)
+	assert.Equal(t, []Action{Terminal}, policy.Match(2).Actions, "expected rules from the file to take precedence")
+	assert.Equal(t, []Action{Upgrade, RestartWithBackoff}, policy.Match(42).Actions, "expected built-in rules to still apply")
+	assert.Equal(t, []Action{RestartWithBackoff}, policy.Match(21).Actions)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy string
+	}{
+		{"malformed", `{"rules": [`},
+		{"no exit codes", `{"rules": [{"actions": ["restart"]}]}`},
+		{"invalid exit code", `{"rules": [{"exitCodes": ["x"], "actions": ["restart"]}]}`},
+		{"invalid range", `{"rules": [{"exitCodes": ["20-10"], "actions": ["restart"]}]}`},
+		{"no actions", `{"rules": [{"exitCodes": ["3"]}]}`},
+		{"unknown action", `{"rules": [{"exitCodes": ["3"], "actions": ["reboot"]}]}`},
+		{"outcome not last", `{"rules": [{"exitCodes": ["3"], "actions": ["restart", "capture-logs"]}]}`},
+		{"no outcome", `{"rules": [{"exitCodes": ["3"], "actions": ["capture-logs"]}]}`},
+		{"hook without command", `{"rules": [{"exitCodes": ["3"], "actions": ["hook", "restart"]}]}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, cleanup := writePolicy(t, tc.policy)
+			defer cleanup()
+			_, err := Load(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	_, err := Load("/nonexistent/exit-code-policy.json")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRuleIsFailure(t *testing.T) {
+	policy := Default()
+	assert.True(t, policy.Match(1).IsFailure())
+	assert.True(t, policy.Match(2).IsFailure())
+	assert.False(t, policy.Match(0).IsFailure())
+	assert.False(t, policy.Match(5).IsFailure())
+	assert.False(t, policy.Match(42).IsFailure(), "expected upgrades not to be failures")
+	assert.False(t, (&Rule{Actions: []Action{CaptureLogs, Restart}}).IsFailure())
+}
@@ -0,0 +1,246 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package inotify watches directories for changes to their files with the
+// inotify API of Linux
+package inotify
+
+import (
+	"bytes"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// dirEvents are the events watched on directories: the files that are
+// written, created, removed or renamed, and the directory itself being
+// removed or renamed
+const dirEvents = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+// eventBufferSize fits a few dozen events with their file names
+const eventBufferSize = 64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)
+
+// Event is a change to a watched directory or to one of its files
+type Event struct {
+	// Dir is the path of the watched directory
+	Dir string
+	// Name is the name of the file in Dir, empty if the event is about Dir
+	// itself
+	Name string
+	// Mask holds the syscall.IN_* flags of the event
+	Mask uint32
+}
+
+// IsDir returns true if the event is about a directory
+func (e Event) IsDir() bool {
+	return e.Mask&syscall.IN_ISDIR != 0
+}
+
+// Watcher reports the events of the directories it watches
+type Watcher struct {
+	fd    int
+	epoll int
+	// wake is the pipe written to by Close to wake up the reading goroutine
+	wake    [2]int
+	lock    sync.Mutex
+	watches map[int32]string
+	events  chan Event
+	// done is closed by Close
+	done   chan struct{}
+	closed bool
+}
+
+// New returns a Watcher that doesn't watch any directory yet
+func New() (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(err, "inotify: could not initialize")
+	}
+	w := &Watcher{
+		fd:      fd,
+		watches: make(map[int32]string),
+		events:  make(chan Event),
+		done:    make(chan struct{}),
+	}
+	err = w.initPoll()
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	go w.read()
+	return w, nil
+}
+
+// initPoll sets up the epoll instance waiting for inotify events or Close
+func (w *Watcher) initPoll() error {
+	err := syscall.Pipe2(w.wake[:], syscall.O_CLOEXEC|syscall.O_NONBLOCK)
+	if err != nil {
+		return errors.Wrap(err, "inotify: could not create wake up pipe")
+	}
+	w.epoll, err = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err == nil {
+		err = syscall.EpollCtl(w.epoll, syscall.EPOLL_CTL_ADD, w.fd,
+			&syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(w.fd)})
+	}
+	if err == nil {
+		err = syscall.EpollCtl(w.epoll, syscall.EPOLL_CTL_ADD, w.wake[0],
+			&syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(w.wake[0])})
+	}
+	if err != nil {
+		syscall.Close(w.wake[0])
+		syscall.Close(w.wake[1])
+		if w.epoll > 0 {
+			syscall.Close(w.epoll)
+		}
+		return errors.Wrap(err, "inotify: could not poll")
+	}
+	return nil
+}
+
+// Add watches the directory at path. The directory is no longer watched once
+// it's removed or renamed.
+func (w *Watcher) Add(path string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return errors.New("inotify: watcher is closed")
+	}
+	wd, err := syscall.InotifyAddWatch(w.fd, path, dirEvents)
+	if err != nil {
+		return errors.Wrapf(err, "inotify: could not watch %s", path)
+	}
+	w.watches[int32(wd)] = path
+	return nil
+}
+
+// Events returns the channel of the events, which is closed once the watcher
+// is closed
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Close stops watching the directories
+func (w *Watcher) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	_, err := syscall.Write(w.wake[1], []byte{0})
+	return err
+}
+
+// read forwards the inotify events until the watcher is closed
+func (w *Watcher) read() {
+	defer w.release()
+	buffer := make([]byte, eventBufferSize)
+	polled := make([]syscall.EpollEvent, 2)
+	for {
+		n, err := syscall.EpollWait(w.epoll, polled, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			log.Warnf("inotify: could not wait for events: %v", err)
+			return
+		}
+		for _, event := range polled[:n] {
+			if event.Fd == int32(w.wake[0]) {
+				return
+			}
+		}
+		if !w.readEvents(buffer) {
+			return
+		}
+	}
+}
+
+// readEvents reads the pending inotify events. It returns false if the
+// watcher is closed while forwarding them, or if they can't be read.
+func (w *Watcher) readEvents(buffer []byte) bool {
+	for {
+		n, err := syscall.Read(w.fd, buffer)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			return true
+		}
+		if err != nil {
+			log.Warnf("inotify: could not read events: %v", err)
+			return false
+		}
+		for _, event := range w.parse(buffer[:n]) {
+			if !w.send(event) {
+				return false
+			}
+		}
+	}
+}
+
+// parse decodes the events of buffer, dropping the events of directories
+// that are no longer watched
+func (w *Watcher) parse(buffer []byte) []Event {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var events []Event
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buffer); {
+		raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+		nameStart := offset + syscall.SizeofInotifyEvent
+		offset = nameStart + int(raw.Len)
+		dir, ok := w.watches[raw.Wd]
+		if !ok {
+			continue
+		}
+		if raw.Mask&syscall.IN_IGNORED != 0 {
+			delete(w.watches, raw.Wd)
+			continue
+		}
+		name := buffer[nameStart:offset]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		events = append(events, Event{Dir: dir, Name: string(name), Mask: raw.Mask})
+	}
+	return events
+}
+
+// send forwards the event unless the watcher is closed first
+func (w *Watcher) send(event Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// release closes the file descriptors once the reading goroutine is done,
+// which is when the watcher is closed or fails
+func (w *Watcher) release() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+	}
+	syscall.Close(w.fd)
+	syscall.Close(w.epoll)
+	syscall.Close(w.wake[0])
+	syscall.Close(w.wake[1])
+	close(w.events)
+}
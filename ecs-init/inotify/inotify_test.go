@@ -0,0 +1,87 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package inotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextEvent returns the next event of the watcher, failing the test if there
+// is none within a few seconds
+func nextEvent(t *testing.T, w *Watcher) Event {
+	select {
+	case event, ok := <-w.Events():
+		require.True(t, ok, "expected the events channel to be open")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an inotify event")
+	}
+	return Event{}
+}
+
+func TestWatcherReportsFileEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inotify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	w, err := New()
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Add(dir))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ecs.config"), []byte("ECS_CLUSTER=a\n"), 0644))
+	event := nextEvent(t, w)
+	assert.Equal(t, dir, event.Dir)
+	assert.Equal(t, "ecs.config", event.Name)
+	assert.NotZero(t, event.Mask&syscall.IN_CREATE)
+	assert.False(t, event.IsDir())
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "ecs.config.d"), 0755))
+	// the file may also be reported as written and closed first
+	event = nextEvent(t, w)
+	for event.Name != "ecs.config.d" {
+		event = nextEvent(t, w)
+	}
+	assert.True(t, event.IsDir())
+}
+
+func TestWatcherAddMissingDirectory(t *testing.T) {
+	w, err := New()
+	require.NoError(t, err)
+	defer w.Close()
+
+	assert.Error(t, w.Add("/does/not/exist"))
+}
+
+func TestWatcherClose(t *testing.T) {
+	w, err := New()
+	require.NoError(t, err)
+
+	require.NoError(t, w.Close())
+	select {
+	case _, ok := <-w.Events():
+		assert.False(t, ok, "expected the events channel to be closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the events channel to be closed")
+	}
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.Add(os.TempDir()))
+}
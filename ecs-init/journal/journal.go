@@ -0,0 +1,267 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package journal records the significant lifecycle events of ecs-init and
+// the Agent as JSON lines in a rotating journal, so that they can be reviewed
+// apart from the free-form log.
+package journal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
+
+	log "github.com/cihub/seelog"
+)
+
+// Event types
+const (
+	PreStart    = "pre-start"
+	CacheState  = "cache-state"
+	Download    = "download"
+	Checksum    = "checksum"
+	Signature   = "signature"
+	ImageLoad   = "image-load"
+	ImagePull   = "image-pull"
+	AgentStart  = "agent-start"
+	AgentExit   = "agent-exit"
+	Upgrade     = "upgrade"
+	Rollback    = "rollback"
+	Iptables    = "iptables"
+	Sysctl      = "sysctl"
+	PostStop    = "post-stop"
+	CrashBundle = "crash-bundle"
+)
+
+const (
+	// DefaultMaxSize is the size in bytes beyond which the journal is
+	// rotated
+	DefaultMaxSize = 10 * 1024 * 1024
+	// DefaultMaxFiles is the number of rotated journal files that are kept
+	DefaultMaxFiles = 5
+	journalPerm     = 0644
+)
+
+// Fields are the details of an event
+type Fields map[string]string
+
+// Event is a single line of the journal
+type Event struct {
+	Time time.Time `json:"time"`
+	// Invocation is shared by the events recorded by the same ecs-init
+	// process
+	Invocation string `json:"invocation"`
+	// Correlation is shared by the events of the same operation, such as
+	// the start and exit of an Agent run
+	Correlation string `json:"correlation,omitempty"`
+	Type        string `json:"type"`
+	Fields      Fields `json:"fields,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Journal appends events to a file, rotating it once it grows beyond its
+// maximum size
+type Journal struct {
+	lock     sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+}
+
+// New creates a journal at path
+func New(path string, maxSize int64, maxFiles int) *Journal {
+	return &Journal{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+}
+
+var (
+	invocation     = NewCorrelationID()
+	defaultJournal *Journal
+	defaultLock    sync.Mutex
+)
+
+// SetDefault sets the journal that Record appends to. Events aren't recorded
+// until it is set.
+func SetDefault(journal *Journal) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultJournal = journal
+}
+
+// NewCorrelationID returns a random ID to correlate events with
+func NewCorrelationID() string {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// Record appends an event to the default journal. Failing to record an event
+// is logged and otherwise ignored.
+func Record(eventType string, correlation string, fields Fields, err error) {
+	defaultLock.Lock()
+	journal := defaultJournal
+	defaultLock.Unlock()
+	if journal == nil {
+		return
+	}
+	event := &Event{
+		Time:        time.Now().UTC(),
+		Invocation:  invocation,
+		Correlation: correlation,
+		Type:        eventType,
+		Fields:      redact.Map(fields),
+	}
+	if err != nil {
+		event.Error = redact.String(err.Error())
+	}
+	writeErr := journal.Append(event)
+	if writeErr != nil {
+		log.Warnf("Could not record %s event in the journal: %v", eventType, writeErr)
+	}
+}
+
+// Append writes the event to the journal
+func (j *Journal) Append(event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	err = j.rotate(int64(len(data)))
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, journalPerm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+// rotate shifts the journal files by one if appending size bytes would grow
+// the journal beyond its maximum size, dropping the oldest one
+func (j *Journal) rotate(size int64) error {
+	info, err := os.Stat(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.Size()+size <= j.maxSize {
+		return nil
+	}
+	for i := j.maxFiles - 1; i > 0; i-- {
+		err = os.Rename(j.rotatedPath(i), j.rotatedPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if j.maxFiles == 0 {
+		return os.Remove(j.path)
+	}
+	return os.Rename(j.path, j.rotatedPath(1))
+}
+
+func (j *Journal) rotatedPath(i int) string {
+	return fmt.Sprintf("%s.%d", j.path, i)
+}
+
+// Filter selects events read from the journal. Zero values match all events.
+type Filter struct {
+	Types       []string
+	Since       time.Time
+	Correlation string
+}
+
+func (f *Filter) matches(event *Event) bool {
+	if event.Time.Before(f.Since) {
+		return false
+	}
+	if f.Correlation != "" && f.Correlation != event.Correlation && f.Correlation != event.Invocation {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, eventType := range f.Types {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns the events of the journal and its rotated files that match
+// the filter, oldest first. Lines that aren't valid events are skipped.
+func (j *Journal) Read(filter *Filter) ([]*Event, error) {
+	var events []*Event
+	for i := j.maxFiles; i >= 0; i-- {
+		path := j.path
+		if i > 0 {
+			path = j.rotatedPath(i)
+		}
+		fileEvents, err := readFile(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, fileEvents...)
+	}
+	return events, nil
+}
+
+func readFile(path string, filter *Filter) ([]*Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var events []*Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		event := &Event{}
+		if json.Unmarshal([]byte(line), event) != nil {
+			continue
+		}
+		if filter.matches(event) {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
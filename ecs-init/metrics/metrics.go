@@ -0,0 +1,427 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics exposes metrics of ecs-init and the supervision of the
+// Agent in the Prometheus text format. As the actions of ecs-init run as
+// separate processes, the metrics are persisted in a state file that every
+// update goes through.
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	counter = "counter"
+	gauge   = "gauge"
+	summary = "summary"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+
+	statePerm    = 0644
+	textfilePerm = 0644
+)
+
+// Metric names
+const (
+	agentExits        = "ecs_init_agent_exits_total"
+	agentUpgrades     = "ecs_init_agent_upgrades_total"
+	agentRollbacks    = "ecs_init_agent_rollbacks_total"
+	cacheStatus       = "ecs_init_cache_status"
+	downloadBytes     = "ecs_init_download_bytes_total"
+	downloadDuration  = "ecs_init_download_duration_seconds"
+	imageLoadDuration = "ecs_init_image_load_duration_seconds"
+	hostSetupFailures = "ecs_init_host_setup_failures_total"
+)
+
+type descriptor struct {
+	help string
+	kind string
+}
+
+var descriptors = map[string]descriptor{
+	agentExits:        {"Exits of the Agent container, by exit code.", counter},
+	agentUpgrades:     {"Attempts to load an upgraded Agent, by result.", counter},
+	agentRollbacks:    {"Rollbacks of failed Agent upgrades, by result.", counter},
+	cacheStatus:       {"Status of the Agent image cache, 1 for the current status.", gauge},
+	downloadBytes:     {"Bytes of Agent artifacts downloaded, by source.", counter},
+	downloadDuration:  {"Duration of Agent artifact downloads, by source and result.", summary},
+	imageLoadDuration: {"Duration of Agent image loads into Docker, by result.", summary},
+	hostSetupFailures: {"Failures of host setup steps in pre-start and post-stop, by step.", counter},
+}
+
+// cacheStatuses are the values of the status label of cacheStatus
+var cacheStatuses = []string{"uncached", "cached", "reload-needed"}
+
+// series is a metric with a set of label values. Value is the sum of the
+// observations of a summary.
+type series struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	Count  uint64            `json:"count,omitempty"`
+}
+
+// key identifies the series by its name and labels in the exposition format
+func (s *series) key() string {
+	return s.Name + formatLabels(s.Labels)
+}
+
+// Registry persists metrics in a state file and, if configured, writes them
+// to a node_exporter textfile after every update
+type Registry struct {
+	lock         sync.Mutex
+	statePath    string
+	textfilePath string
+}
+
+// New creates a registry persisting its metrics at statePath. The textfile
+// isn't written if textfilePath is empty.
+func New(statePath, textfilePath string) *Registry {
+	return &Registry{
+		statePath:    statePath,
+		textfilePath: textfilePath,
+	}
+}
+
+var (
+	defaultRegistry *Registry
+	defaultLock     sync.Mutex
+)
+
+// SetDefault sets the registry that metrics are recorded in. Metrics aren't
+// recorded until it is set.
+func SetDefault(registry *Registry) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultRegistry = registry
+}
+
+// Default returns the registry that metrics are recorded in, if any
+func Default() *Registry {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	return defaultRegistry
+}
+
+func update(f func(state map[string]*series)) {
+	registry := Default()
+	if registry == nil {
+		return
+	}
+	err := registry.update(f)
+	if err != nil {
+		log.Warnf("Could not update metrics: %v", err)
+	}
+}
+
+// AgentExited counts an exit of the Agent container
+func AgentExited(exitCode int) {
+	update(func(state map[string]*series) {
+		add(state, agentExits, map[string]string{"exit_code": strconv.Itoa(exitCode)}, 1)
+	})
+}
+
+// UpgradeAttempted counts an attempt to load an upgraded Agent
+func UpgradeAttempted(err error) {
+	update(func(state map[string]*series) {
+		add(state, agentUpgrades, resultLabels(nil, err), 1)
+	})
+}
+
+// RolledBack counts a rollback of a failed Agent upgrade
+func RolledBack(err error) {
+	update(func(state map[string]*series) {
+		add(state, agentRollbacks, resultLabels(nil, err), 1)
+	})
+}
+
+// CacheStatus sets the current status of the Agent image cache
+func CacheStatus(status string) {
+	update(func(state map[string]*series) {
+		for _, s := range cacheStatuses {
+			value := 0.0
+			if s == status {
+				value = 1
+			}
+			set(state, cacheStatus, map[string]string{"status": s}, value)
+		}
+	})
+}
+
+// Downloaded records a download of an Agent artifact from a source, such as
+// an S3 bucket or a mirror
+func Downloaded(source string, bytes int64, duration time.Duration, err error) {
+	update(func(state map[string]*series) {
+		add(state, downloadBytes, map[string]string{"source": source}, float64(bytes))
+		observe(state, downloadDuration, resultLabels(map[string]string{"source": source}, err), duration)
+	})
+}
+
+// ImageLoaded records a load of an Agent image into Docker
+func ImageLoaded(duration time.Duration, err error) {
+	update(func(state map[string]*series) {
+		observe(state, imageLoadDuration, resultLabels(nil, err), duration)
+	})
+}
+
+// HostSetupFailed counts a failure of a host setup step
+func HostSetupFailed(step string) {
+	update(func(state map[string]*series) {
+		add(state, hostSetupFailures, map[string]string{"step": step}, 1)
+	})
+}
+
+func resultLabels(labels map[string]string, err error) map[string]string {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["result"] = resultSuccess
+	if err != nil {
+		labels["result"] = resultFailure
+	}
+	return labels
+}
+
+func get(state map[string]*series, name string, labels map[string]string) *series {
+	s := &series{Name: name, Labels: labels}
+	if existing, ok := state[s.key()]; ok {
+		return existing
+	}
+	state[s.key()] = s
+	return s
+}
+
+func add(state map[string]*series, name string, labels map[string]string, delta float64) {
+	get(state, name, labels).Value += delta
+}
+
+func set(state map[string]*series, name string, labels map[string]string, value float64) {
+	get(state, name, labels).Value = value
+}
+
+func observe(state map[string]*series, name string, labels map[string]string, duration time.Duration) {
+	s := get(state, name, labels)
+	s.Value += duration.Seconds()
+	s.Count++
+}
+
+// update applies f to the persisted metrics, which are saved and written to
+// the textfile afterwards
+func (r *Registry) update(f func(state map[string]*series)) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	state, err := r.load()
+	if err != nil {
+		return err
+	}
+	f(state)
+	data, err := json.Marshal(values(state))
+	if err != nil {
+		return err
+	}
+	err = writeFile(r.statePath, statePerm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if r.textfilePath == "" {
+		return nil
+	}
+	return writeFile(r.textfilePath, textfilePerm, func(w io.Writer) error {
+		return write(w, state)
+	})
+}
+
+func (r *Registry) load() (map[string]*series, error) {
+	state := map[string]*series{}
+	data, err := ioutil.ReadFile(r.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	var persisted []*series
+	err = json.Unmarshal(data, &persisted)
+	if err != nil {
+		log.Warnf("Could not parse persisted metrics, starting afresh: %v", err)
+		return state, nil
+	}
+	for _, s := range persisted {
+		state[s.key()] = s
+	}
+	return state, nil
+}
+
+// writeFile writes a temporary file renamed over path, so that the processes
+// of ecs-init and node_exporter never read a partial file
+func writeFile(path string, perm os.FileMode, f func(w io.Writer) error) error {
+	tempPath := path + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = f(file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// Write writes the persisted metrics in the Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	state, err := r.load()
+	r.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return write(w, state)
+}
+
+func values(state map[string]*series) []*series {
+	var all []*series
+	for _, s := range state {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].key() < all[j].key()
+	})
+	return all
+}
+
+func write(w io.Writer, state map[string]*series) error {
+	var lines []string
+	name := ""
+	for _, s := range values(state) {
+		d, ok := descriptors[s.Name]
+		if !ok {
+			continue
+		}
+		if s.Name != name {
+			name = s.Name
+			lines = append(lines, fmt.Sprintf("# HELP %s %s", name, d.help), fmt.Sprintf("# TYPE %s %s", name, d.kind))
+		}
+		labels := formatLabels(s.Labels)
+		if d.kind != summary {
+			lines = append(lines, fmt.Sprintf("%s%s %s", s.Name, labels, formatValue(s.Value)))
+			continue
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s_sum%s %s", s.Name, labels, formatValue(s.Value)),
+			fmt.Sprintf("%s_count%s %d", s.Name, labels, s.Count))
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var names []string
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueReplacer.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Server serves the metrics of a registry over HTTP
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// Listen serves the metrics of the registry at /metrics on address, which
+// must be a loopback address as the metrics aren't authenticated
+func (r *Registry) Listen(address string) (*Server, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid metrics listen address %q", address)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, errors.Errorf("metrics listen address %q is not a loopback address", address)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not listen on %s", address)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", r.serveHTTP)
+	s := &Server{
+		listener: listener,
+		server:   &http.Server{Handler: mux},
+	}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops serving the metrics
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+func (r *Registry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	buf := &bytes.Buffer{}
+	err := r.Write(buf)
+	if err != nil {
+		log.Warnf("Could not read metrics: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
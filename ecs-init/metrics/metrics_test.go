@@ -0,0 +1,124 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempRegistry(t *testing.T) (*Registry, string, func()) {
+	dir, err := ioutil.TempDir("", "metrics")
+	require.NoError(t, err)
+	textfile := filepath.Join(dir, "ecs-init.prom")
+	return New(filepath.Join(dir, "metrics.json"), textfile), textfile, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+const expectedMetrics = `# HELP ecs_init_agent_exits_total Exits of the Agent container, by exit code.
+# TYPE ecs_init_agent_exits_total counter
+ecs_init_agent_exits_total{exit_code="1"} 2
+ecs_init_agent_exits_total{exit_code="5"} 1
+# HELP ecs_init_cache_status Status of the Agent image cache, 1 for the current status.
+# TYPE ecs_init_cache_status gauge
+ecs_init_cache_status{status="cached"} 1
+ecs_init_cache_status{status="reload-needed"} 0
+ecs_init_cache_status{status="uncached"} 0
+# HELP ecs_init_download_bytes_total Bytes of Agent artifacts downloaded, by source.
+# TYPE ecs_init_download_bytes_total counter
+ecs_init_download_bytes_total{source="bucket"} 1024
+# HELP ecs_init_download_duration_seconds Duration of Agent artifact downloads, by source and result.
+# TYPE ecs_init_download_duration_seconds summary
+ecs_init_download_duration_seconds_sum{result="failure",source="bucket"} 0.5
+ecs_init_download_duration_seconds_count{result="failure",source="bucket"} 1
+ecs_init_download_duration_seconds_sum{result="success",source="bucket"} 2
+ecs_init_download_duration_seconds_count{result="success",source="bucket"} 1
+# HELP ecs_init_host_setup_failures_total Failures of host setup steps in pre-start and post-stop, by step.
+# TYPE ecs_init_host_setup_failures_total counter
+ecs_init_host_setup_failures_total{step="iptables \"create\""} 1
+`
+
+func TestRecord(t *testing.T) {
+	registry, textfile, cleanup := tempRegistry(t)
+	defer cleanup()
+	SetDefault(registry)
+	defer SetDefault(nil)
+
+	AgentExited(1)
+	AgentExited(5)
+	AgentExited(1)
+	CacheStatus("uncached")
+	CacheStatus("cached")
+	Downloaded("bucket", 1024, 2*time.Second, nil)
+	Downloaded("bucket", 0, 500*time.Millisecond, errors.New("test error"))
+	HostSetupFailed(`iptables "create"`)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, registry.Write(buf))
+	assert.Equal(t, expectedMetrics, buf.String())
+
+	data, err := ioutil.ReadFile(textfile)
+	require.NoError(t, err)
+	assert.Equal(t, expectedMetrics, string(data))
+
+	// Another process shares the persisted metrics
+	buf.Reset()
+	require.NoError(t, New(registry.statePath, "").Write(buf))
+	assert.Equal(t, expectedMetrics, buf.String())
+}
+
+func TestRecordWithoutRegistry(t *testing.T) {
+	SetDefault(nil)
+	AgentExited(1)
+}
+
+func TestListen(t *testing.T) {
+	registry, _, cleanup := tempRegistry(t)
+	defer cleanup()
+	SetDefault(registry)
+	defer SetDefault(nil)
+	ImageLoaded(time.Second, nil)
+
+	server, err := registry.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr() + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `ecs_init_image_load_duration_seconds_count{result="success"} 1`)
+}
+
+func TestListenRequiresLoopback(t *testing.T) {
+	registry, _, cleanup := tempRegistry(t)
+	defer cleanup()
+
+	for _, address := range []string{"0.0.0.0:9100", ":9100", "example.com:9100", "9100"} {
+		_, err := registry.Listen(address)
+		assert.Error(t, err, address)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package redact removes secrets, such as registry credentials, AWS keys
+// and the passwords of proxy URLs, from the log lines, diagnostics bundles
+// and state dumps of ecs-init and the volume plugin.
+package redact
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/cihub/seelog"
+)
+
+const (
+	// Redacted replaces the redacted secrets
+	Redacted = "REDACTED"
+	// FormatterName is the name of the seelog formatter of redacted
+	// messages, to be used as %RedactedMsg instead of %Msg in seelog
+	// formats
+	FormatterName = "RedactedMsg"
+)
+
+// SensitiveKeys are the names of the configuration variables known to hold
+// secrets, whether or not their names match sensitiveKeyParts
+var SensitiveKeys = []string{
+	"ECS_ENGINE_AUTH_DATA",
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+}
+
+// sensitiveKeyParts are the substrings of the names of variables, options
+// and fields whose values are redacted
+var sensitiveKeyParts = []string{"SECRET", "PASSWORD", "PASSWD", "TOKEN", "CREDENTIAL", "AUTH", "KEY"}
+
+// nonSensitiveKeys are the names of the variables whose names match
+// sensitiveKeyParts but whose values are safe to show
+var nonSensitiveKeys = []string{"ECS_ENGINE_AUTH_TYPE"}
+
+var (
+	// assignmentPattern matches the NAME=value lines of configuration and
+	// environment files
+	assignmentPattern = regexp.MustCompile(`(?m)^(\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=).*$`)
+	// keyValuePattern matches key=value, key: value and "key":"value" pairs
+	// whose keys look sensitive, within text and JSON
+	keyValuePattern = regexp.MustCompile(`(?i)([A-Za-z0-9_.-]*(?:secret|password|passwd|token|credential|auth|key)[A-Za-z0-9_.-]*)(\\?"?\s*[:=]\s*)(\\?"[^"\\]*\\?"|[^\s,;&"}\\]+)`)
+	// userinfoPattern matches the user and password of URLs, such as the
+	// ones of HTTP_PROXY
+	userinfoPattern = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://)[^/\s@"]+@`)
+	// authorizationPattern matches the credentials of HTTP authorization
+	// headers
+	authorizationPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+	// accessKeyIDPattern matches AWS access key IDs
+	accessKeyIDPattern = regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`)
+)
+
+// RegisterFormatter registers the %RedactedMsg seelog formatter, which
+// formats the message of log lines redacted by String. It must be called
+// before the loggers using it are created.
+func RegisterFormatter() error {
+	return log.RegisterCustomFormatter(FormatterName, func(params string) log.FormatterFunc {
+		return func(message string, level log.LogLevel, context log.LogContextInterface) interface{} {
+			return String(message)
+		}
+	})
+}
+
+// IsSensitiveKey returns true if the values of the variable, option or field
+// named key are redacted
+func IsSensitiveKey(key string) bool {
+	key = strings.ToUpper(key)
+	for _, name := range nonSensitiveKeys {
+		if key == name {
+			return false
+		}
+	}
+	for _, name := range SensitiveKeys {
+		if key == name {
+			return true
+		}
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the value of the variable, option or field named key, which
+// is redacted entirely if the key is sensitive
+func Value(key, value string) string {
+	if IsSensitiveKey(key) {
+		return Redacted
+	}
+	return String(value)
+}
+
+// Map returns a copy of m with its values redacted by Value
+func Map(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(m))
+	for key, value := range m {
+		redacted[key] = Value(key, value)
+	}
+	return redacted
+}
+
+// Env returns the NAME=value environment with its values redacted by Value
+func Env(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	redacted := make([]string, 0, len(env))
+	for _, variable := range env {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) == 2 {
+			variable = parts[0] + "=" + Value(parts[0], parts[1])
+		} else {
+			variable = String(variable)
+		}
+		redacted = append(redacted, variable)
+	}
+	return redacted
+}
+
+// String returns s with the secrets it is found to hold redacted: the
+// values of sensitive NAME=value lines and key/value pairs, the userinfo of
+// URLs, the credentials of authorization headers and AWS access key IDs
+func String(s string) string {
+	s = assignmentPattern.ReplaceAllStringFunc(s, func(line string) string {
+		match := assignmentPattern.FindStringSubmatch(line)
+		if !IsSensitiveKey(match[2]) {
+			return line
+		}
+		return match[1] + Redacted
+	})
+	s = keyValuePattern.ReplaceAllStringFunc(s, func(pair string) string {
+		match := keyValuePattern.FindStringSubmatch(pair)
+		if !IsSensitiveKey(match[1]) || strings.Contains(match[3], Redacted) {
+			return pair
+		}
+		value := Redacted
+		if strings.HasPrefix(match[3], `\"`) {
+			value = `\"` + Redacted + `\"`
+		} else if strings.HasPrefix(match[3], `"`) {
+			value = `"` + Redacted + `"`
+		}
+		return match[1] + match[2] + value
+	})
+	s = userinfoPattern.ReplaceAllString(s, "${1}"+Redacted+"@")
+	s = authorizationPattern.ReplaceAllString(s, "$1 "+Redacted)
+	return accessKeyIDPattern.ReplaceAllString(s, Redacted)
+}
+
+// Bytes returns data with the secrets it is found to hold redacted by String
+func Bytes(data []byte) []byte {
+	return []byte(String(string(data)))
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sdnotify implements the systemd service notification protocol
+// described in sd_notify(3), so that ecs-init can be run by units of
+// Type=notify with a WatchdogSec.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Ready tells systemd that the service finished starting up
+	Ready = "READY=1"
+	// Stopping tells systemd that the service is stopping
+	Stopping = "STOPPING=1"
+	// Watchdog keeps the watchdog of the service from firing
+	Watchdog = "WATCHDOG=1"
+
+	notifySocketEnvVar   = "NOTIFY_SOCKET"
+	watchdogUsecEnvVar   = "WATCHDOG_USEC"
+	watchdogPIDEnvVar    = "WATCHDOG_PID"
+	abstractSocketPrefix = "@"
+)
+
+// Status returns a notification of a free-form status of the service
+func Status(status string) string {
+	return "STATUS=" + status
+}
+
+// Enabled returns true if systemd expects notifications from this process
+func Enabled() bool {
+	return os.Getenv(notifySocketEnvVar) != ""
+}
+
+// Notify sends the notifications to systemd. It does nothing if systemd
+// doesn't expect notifications.
+func Notify(notifications ...string) error {
+	socketPath := os.Getenv(notifySocketEnvVar)
+	if socketPath == "" {
+		return nil
+	}
+	if strings.HasPrefix(socketPath, abstractSocketPrefix) {
+		socketPath = "\x00" + strings.TrimPrefix(socketPath, abstractSocketPrefix)
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return errors.Wrap(err, "could not connect to the systemd notification socket")
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(strings.Join(notifications, "\n")))
+	return errors.Wrap(err, "could not send systemd notification")
+}
+
+// WatchdogInterval returns the interval within which systemd expects
+// watchdog notifications, or 0 if the watchdog isn't enabled for this
+// process
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv(watchdogUsecEnvVar)
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv(watchdogPIDEnvVar); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	interval, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || interval <= 0 {
+		return 0, errors.Errorf("invalid %s %q", watchdogUsecEnvVar, usec)
+	}
+	return time.Duration(interval) * time.Microsecond, nil
+}
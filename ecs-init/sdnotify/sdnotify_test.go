@@ -0,0 +1,78 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sdnotify
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sdnotify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	os.Setenv(notifySocketEnvVar, socketPath)
+	defer os.Unsetenv(notifySocketEnvVar)
+
+	assert.True(t, Enabled())
+	require.NoError(t, Notify(Ready, Status("agent running")))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "READY=1\nSTATUS=agent running", string(buf[:n]))
+}
+
+func TestNotifyDisabled(t *testing.T) {
+	os.Unsetenv(notifySocketEnvVar)
+	assert.False(t, Enabled())
+	assert.NoError(t, Notify(Ready))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv(watchdogUsecEnvVar)
+	defer os.Unsetenv(watchdogPIDEnvVar)
+
+	interval, err := WatchdogInterval()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), interval, "expected watchdog to be disabled without WATCHDOG_USEC")
+
+	os.Setenv(watchdogUsecEnvVar, "30000000")
+	os.Setenv(watchdogPIDEnvVar, strconv.Itoa(os.Getpid()))
+	interval, err = WatchdogInterval()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, interval)
+
+	os.Setenv(watchdogPIDEnvVar, "1")
+	interval, err = WatchdogInterval()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), interval, "expected watchdog to be disabled for another process")
+
+	os.Unsetenv(watchdogPIDEnvVar)
+	os.Setenv(watchdogUsecEnvVar, "soon")
+	_, err = WatchdogInterval()
+	assert.Error(t, err)
+}
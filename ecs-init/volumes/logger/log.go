@@ -18,6 +18,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
 	"github.com/cihub/seelog"
 )
 
@@ -56,7 +57,7 @@ func Setup() {
 func logfmtFormatter(params string) seelog.FormatterFunc {
 	return func(message string, level seelog.LogLevel, context seelog.LogContextInterface) interface{} {
 		return fmt.Sprintf(`level=%s time=%s msg=%q
-`, level.String(), context.CallTime().UTC().Format(time.RFC3339), message)
+`, level.String(), context.CallTime().UTC().Format(time.RFC3339), redact.String(message))
 	}
 }
 
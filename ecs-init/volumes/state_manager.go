@@ -62,6 +62,10 @@ func NewStateManager() *StateManager {
 	}
 }
 
+// recordVolume saves the volume to the state file. Its mount options are
+// kept as they are, since they're restored from the state file after a
+// restart. The state file is readable by root only, and its copies in log
+// bundles are redacted.
 func (s *StateManager) recordVolume(volName string, vol *Volume) error {
 	s.VolState.Volumes[volName] = &VolumeInfo{
 		Type:      vol.Type,
@@ -93,7 +97,8 @@ var saveStateToDisk = saveState
 
 func saveState(b []byte) error {
 	// Make our temp-file on the same volume as our data-file to ensure we can
-	// actually move it atomically; cross-device renaming will error out
+	// actually move it atomically; cross-device renaming will error out. It's
+	// created with 0600 permissions, as the mount options may hold secrets.
 	tmpfile, err := ioutil.TempFile(PluginStatePath, "tmp_ecs_volume_plugin")
 	if err != nil {
 		return fmt.Errorf("failed to create temp file: %v", err)
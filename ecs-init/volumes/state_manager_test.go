@@ -17,6 +17,8 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/aws/amazon-ecs-init/ecs-init/redact"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,6 +45,28 @@ func TestSaveStateSuccess(t *testing.T) {
 	assert.NoError(t, s.save())
 }
 
+func TestRecordVolumeKeepsOptions(t *testing.T) {
+	s := NewStateManager()
+	saveStateToDisk = func(b []byte) error {
+		assert.Contains(t, string(b), `"o": "addr=127.0.0.1,password=secret"`)
+		assert.Contains(t, string(b), `"device": "fs-12345678:/"`)
+		// the copies of the state file in log bundles are redacted
+		assert.Contains(t, string(redact.Bytes(b)), `"o": "addr=127.0.0.1,password=REDACTED"`)
+		return nil
+	}
+	defer func() {
+		saveStateToDisk = saveState
+	}()
+	assert.NoError(t, s.recordVolume("vol", &Volume{
+		Type: "efs",
+		Path: "/vol",
+		Options: map[string]string{
+			"device": "fs-12345678:/",
+			"o":      "addr=127.0.0.1,password=secret",
+		},
+	}))
+}
+
 func TestSaveStateToDisk(t *testing.T) {
 	s := NewStateManager()
 	s.VolState.Volumes["vol"] = &VolumeInfo{
@@ -60,6 +60,7 @@ Requires:       upstart
 Requires:       iptables
 Requires:       docker >= 17.06.2ce
 Requires:       procps
+Requires:       gnupg2
 
 # The following 'Provides' lists the vendored dependencies bundled in
 # and used to produce the ecs-init package. As dependencies are added
@@ -175,6 +176,7 @@ required routes among its preparation steps.
 install -D amazon-ecs-init %{buildroot}%{_libexecdir}/amazon-ecs-init
 install -D amazon-ecs-volume-plugin %{buildroot}%{_libexecdir}/amazon-ecs-volume-plugin
 install -m %{no_exec_perm} -D scripts/amazon-ecs-init.1 %{buildroot}%{_mandir}/man1/amazon-ecs-init.1
+install -m %{no_exec_perm} -D scripts/amazon-ecs-agent.gpg %{buildroot}%{_sysconfdir}/ecs/trusted-keys.d/amazon-ecs-agent.gpg
 
 mkdir -p %{buildroot}%{_sysconfdir}/ecs
 touch %{buildroot}%{_sysconfdir}/ecs/ecs.config
@@ -200,6 +202,8 @@ install -m %{no_exec_perm} -D %{SOURCE5} %{buildroot}%{_sysconfdir}/init/amazon-
 %files
 %{_libexecdir}/amazon-ecs-init
 %{_mandir}/man1/amazon-ecs-init.1*
+%dir %{_sysconfdir}/ecs/trusted-keys.d
+%{_sysconfdir}/ecs/trusted-keys.d/amazon-ecs-agent.gpg
 %{_libexecdir}/amazon-ecs-volume-plugin
 %config(noreplace) %ghost %{_sysconfdir}/ecs/ecs.config
 %config(noreplace) %ghost %{_sysconfdir}/ecs/ecs.config.json
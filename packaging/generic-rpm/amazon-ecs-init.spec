@@ -47,6 +47,7 @@ BuildRequires:  systemd
 Requires:       systemd
 Requires:       iptables
 Requires:       procps
+Requires:       gnupg2
 
 %description
 ecs-init supports the initialization and supervision of the Amazon ECS
@@ -63,6 +64,7 @@ required routes among its preparation steps.
 install -D amazon-ecs-init %{buildroot}%{_libexecdir}/amazon-ecs-init
 install -D amazon-ecs-volume-plugin %{buildroot}%{_libexecdir}/amazon-ecs-volume-plugin
 install -m %{no_exec_perm} -D scripts/amazon-ecs-init.1 %{buildroot}%{_mandir}/man1/amazon-ecs-init.1
+install -m %{no_exec_perm} -D scripts/amazon-ecs-agent.gpg %{buildroot}%{_sysconfdir}/ecs/trusted-keys.d/amazon-ecs-agent.gpg
 
 mkdir -p %{buildroot}%{_sysconfdir}/ecs
 touch %{buildroot}%{_sysconfdir}/ecs/ecs.config
@@ -83,6 +85,8 @@ install -m %{no_exec_perm} -D %{SOURCE6} $RPM_BUILD_ROOT/%{_unitdir}/amazon-ecs-
 %files
 %{_libexecdir}/amazon-ecs-init
 %{_mandir}/man1/amazon-ecs-init.1*
+%dir %{_sysconfdir}/ecs/trusted-keys.d
+%{_sysconfdir}/ecs/trusted-keys.d/amazon-ecs-agent.gpg
 %{_libexecdir}/amazon-ecs-volume-plugin
 %config(noreplace) %ghost %{_sysconfdir}/ecs/ecs.config
 %config(noreplace) %ghost %{_sysconfdir}/ecs/ecs.config.json
@@ -30,6 +30,7 @@ BuildRequires:  go
 BuildRequires:  systemd
 Requires:       docker >= 1.6.0
 Requires:       systemd
+Requires:       gpg2
 BuildRoot:      %{_tmppath}/%{name}-%{version}-build
 ExclusiveArch:  %ix86 x86_64
 
@@ -51,6 +52,7 @@ install -d -m 755 %{buildroot}/%{_mandir}/man1
 install -d -m 755 %{buildroot}/%{_sbindir}
 install -d -m 755 %{buildroot}/%{_sysconfdir}/ecs
 install -m 644 scripts/amazon-ecs-init.1.gz %{buildroot}/%{_mandir}/man1
+install -D -m 644 scripts/amazon-ecs-agent.gpg %{buildroot}/%{_sysconfdir}/ecs/trusted-keys.d/amazon-ecs-agent.gpg
 install -m 755 amazon-ecs-init %{buildroot}/%{_sbindir}
 
 mkdir -p %{buildroot}/%{_unitdir}
@@ -70,6 +72,8 @@ touch %{buildroot}/%{_localstatedir}/cache/ecs/state
 %doc CONTRIBUTING.md LICENSE NOTICE README.md
 %config(noreplace) %{_sysconfdir}/ecs/ecs.config
 %config(noreplace) %{_sysconfdir}/ecs/ecs.config.json
+%dir %{_sysconfdir}/ecs/trusted-keys.d
+%{_sysconfdir}/ecs/trusted-keys.d/amazon-ecs-agent.gpg
 %{_mandir}/man*/*
 %{_sbindir}/*
 %{_unitdir}/%{short_name}.service